@@ -0,0 +1,137 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ErrNotComplaint is returned by ParseComplaint for messages that are not
+// feedback reports.
+var ErrNotComplaint = errors.New("not a feedback report")
+
+// Complaint is a parsed ARF (RFC 5965) feedback-loop report: a mailbox
+// provider telling the sender that a recipient marked a message as spam.
+type Complaint struct {
+	FeedbackType     string // abuse, fraud, virus, other, not-spam
+	UserAgent        string // the reporting system, e.g. "Yahoo!-Mail-Feedback/2.0"
+	OriginalMailFrom string
+	OriginalRcptTo   []string // often omitted or redacted by the provider
+	ArrivalDate      time.Time
+	SourceIP         string
+	ReportedDomain   string
+
+	// From the reported message's headers, when they are included.
+	MessageID  string
+	CampaignID string   // X-Campaign-ID
+	ReturnPath string   // the envelope sender; a VERP address when enabled
+	To         []string // often redacted by the provider
+}
+
+// IsSpamReport reports whether the complaint asks for mail to stop. A
+// "not-spam" report is the opposite: a message rescued from the spam folder.
+func (c *Complaint) IsSpamReport() bool {
+	return !strings.EqualFold(c.FeedbackType, "not-spam")
+}
+
+// ParseComplaint reads one raw RFC 5322 message and extracts its ARF report.
+// It returns ErrNotComplaint unless the message is a multipart/report with
+// report-type=feedback-report (possibly forwarded inside another multipart).
+func ParseComplaint(raw []byte) (*Complaint, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read message body: %w", err)
+	}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, ErrNotComplaint
+	}
+
+	c := &Complaint{}
+	found := false
+	walkReport(c, bytes.NewReader(body), params["boundary"], &found)
+	if !found {
+		return nil, ErrNotComplaint
+	}
+	return c, nil
+}
+
+// walkReport visits every part, reading the message/feedback-report fields
+// and the headers of the reported message.
+func walkReport(c *Complaint, r io.Reader, boundary string, found *bool) {
+	if boundary == "" {
+		return
+	}
+	mr := multipart.NewReader(r, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return
+		}
+		data = decodeBody(data, part.Header.Get("Content-Transfer-Encoding"))
+		mediaType, params, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+
+		switch mediaType {
+		case "message/feedback-report":
+			*found = true
+			parseFeedbackReport(c, data)
+		case "message/rfc822", "message/global", "text/rfc822-headers", "message/rfc822-headers", "message/global-headers":
+			h := readHeaders(data)
+			if c.MessageID == "" {
+				c.MessageID = strings.TrimSpace(h.Get("Message-Id"))
+			}
+			if c.CampaignID == "" {
+				c.CampaignID = strings.TrimSpace(h.Get("X-Campaign-Id"))
+			}
+			if c.ReturnPath == "" {
+				c.ReturnPath = strings.Trim(strings.TrimSpace(h.Get("Return-Path")), "<>")
+			}
+			if len(c.To) == 0 {
+				for _, a := range addressRe.FindAllString(h.Get("To"), -1) {
+					c.To = append(c.To, a)
+				}
+			}
+		default:
+			if strings.HasPrefix(mediaType, "multipart/") {
+				walkReport(c, bytes.NewReader(data), params["boundary"], found)
+			}
+		}
+	}
+}
+
+// parseFeedbackReport reads the machine-readable fields of an RFC 5965
+// message/feedback-report part.
+func parseFeedbackReport(c *Complaint, data []byte) {
+	buf := append(bytes.TrimLeft(data, "\r\n"), "\r\n\r\n"...)
+	h, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(buf))).ReadMIMEHeader()
+
+	c.FeedbackType = strings.ToLower(strings.TrimSpace(h.Get("Feedback-Type")))
+	c.UserAgent = strings.TrimSpace(h.Get("User-Agent"))
+	c.OriginalMailFrom = strings.Trim(strings.TrimSpace(h.Get("Original-Mail-From")), "<>")
+	for _, v := range h.Values("Original-Rcpt-To") {
+		if v = strings.Trim(strings.TrimSpace(v), "<>"); v != "" {
+			c.OriginalRcptTo = append(c.OriginalRcptTo, v)
+		}
+	}
+	if d, err := mail.ParseDate(strings.TrimSpace(h.Get("Arrival-Date"))); err == nil {
+		c.ArrivalDate = d
+	}
+	c.SourceIP = strings.TrimSpace(h.Get("Source-Ip"))
+	c.ReportedDomain = strings.TrimSpace(h.Get("Reported-Domain"))
+}
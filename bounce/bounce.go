@@ -0,0 +1,116 @@
+// Package bounce parses non-delivery reports and feedback-loop complaints so
+// bounced and complaining recipients can be fed back into campaign history
+// and the suppression list.
+//
+// Parse understands RFC 3464 delivery status notifications (multipart/report;
+// report-type=delivery-status) as well as the most common non-standard
+// formats: Exim's X-Failed-Recipients header, qmail's "qmail-send program"
+// reports, and free-form "Undeliverable" notices that quote an SMTP status.
+// ParseComplaint reads RFC 5965 ARF spam reports.
+package bounce
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrNotBounce is returned by Parse for messages that are not bounces.
+var ErrNotBounce = errors.New("not a bounce message")
+
+// Class distinguishes permanent failures, which suppress the address, from
+// temporary ones, which are only recorded.
+type Class string
+
+const (
+	Hard Class = "hard"
+	Soft Class = "soft"
+)
+
+// Recipient is one failed recipient within a bounce.
+type Recipient struct {
+	Email      string // Final-Recipient, or the address named by a non-standard report
+	Original   string // Original-Recipient (the address as mailgrid sent it), if reported
+	Action     string // failed, delayed, ...
+	Status     string // enhanced status code, e.g. 5.1.1
+	Diagnostic string
+	Class      Class
+}
+
+// Report is a parsed bounce.
+type Report struct {
+	Format     string // "dsn", "exim", "qmail" or "generic"
+	EnvelopeID string // Original-Envelope-Id (the job ID mailgrid sent as ENVID)
+	MessageID  string // Message-ID of the bounced message, from its quoted headers
+	CampaignID string // X-Campaign-ID of the bounced message
+	// ReturnPaths are the addresses the bounce itself was delivered to; with
+	// VERP one of them encodes the recipient and campaign.
+	ReturnPaths []string
+	Recipients  []Recipient
+}
+
+var (
+	enhancedStatusRe = regexp.MustCompile(`\b([245])\.(\d{1,3})\.(\d{1,3})\b`)
+	basicCodeRe      = regexp.MustCompile(`\b([245]\d\d)[ -]`)
+
+	// softHints mark permanent-looking failures that are really about the
+	// mailbox's state, not its existence.
+	softHints = []string{"quota", "mailbox full", "mailbox is full", "insufficient storage", "over the limit", "too large"}
+	// hardHints mark failures that name a non-existent address even when no
+	// status code is given.
+	hardHints = []string{"user unknown", "unknown user", "no such user", "does not exist", "invalid recipient",
+		"recipient address rejected", "mailbox unavailable", "address rejected", "no mailbox", "account has been disabled"}
+)
+
+// Classify decides whether a failure is hard or soft from the DSN action, the
+// enhanced status code and the diagnostic text.
+//
+//   - delayed, or any 4.x.x / 4xx status: soft.
+//   - 5.x.x: hard, except mailbox-full and message-too-large (5.2.2, 5.3.4)
+//     and policy rejections (5.7.x), which say nothing about the address.
+//   - no code: hard only if the diagnostic names an unknown user.
+func Classify(action, status, diagnostic string) Class {
+	if strings.EqualFold(action, "delayed") {
+		return Soft
+	}
+	diag := strings.ToLower(diagnostic)
+	if status == "" {
+		status = ExtractStatus(diagnostic)
+	}
+	switch {
+	case strings.HasPrefix(status, "4."):
+		return Soft
+	case strings.HasPrefix(status, "5."):
+		if status == "5.2.2" || status == "5.3.4" || strings.HasPrefix(status, "5.7.") || containsAny(diag, softHints) {
+			return Soft
+		}
+		return Hard
+	}
+	if m := basicCodeRe.FindStringSubmatch(diagnostic); m != nil {
+		if m[1][0] == '5' && !containsAny(diag, softHints) {
+			return Hard
+		}
+		return Soft
+	}
+	if containsAny(diag, hardHints) {
+		return Hard
+	}
+	return Soft
+}
+
+// ExtractStatus returns the first enhanced status code (e.g. "5.1.1") in s.
+func ExtractStatus(s string) string {
+	if m := enhancedStatusRe.FindString(s); m != "" {
+		return m
+	}
+	return ""
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
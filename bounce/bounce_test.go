@@ -0,0 +1,310 @@
+package bounce
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const dsnBounce = `From: Mail Delivery System <MAILER-DAEMON@mx.example.net>
+To: bounces+t1a2b3c4d.alice=example.com@bounce.example.com
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="BOUND"
+
+--BOUND
+Content-Type: text/plain
+
+I'm sorry to have to inform you that your message could not be delivered.
+
+--BOUND
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.net
+Original-Envelope-Id: mailgrid-1700000000
+
+Final-Recipient: rfc822; alice@example.com
+Original-Recipient: rfc822;alice@example.com
+Action: failed
+Status: 5.1.1
+Diagnostic-Code: smtp; 550 5.1.1 <alice@example.com>: Recipient address rejected: User unknown
+
+--BOUND
+Content-Type: text/rfc822-headers
+
+From: Mailgrid <news@example.com>
+To: alice@example.com
+Message-ID: <mg.1a2b3c4d.mfwgy3dpnbqxq33nfzrw63i.0011223344@example.com>
+X-Campaign-ID: mailgrid-1700000000
+Subject: Hello
+
+--BOUND--
+`
+
+const eximBounce = `From: Mail Delivery System <Mailer-Daemon@mx.example.org>
+To: news@example.com
+Subject: Mail delivery failed: returning message to sender
+X-Failed-Recipients: bob@example.org
+
+This message was created automatically by mail delivery software.
+
+A message that you sent could not be delivered to one or more of its
+recipients. This is a permanent error. The following address(es) failed:
+
+  bob@example.org
+    host mx.example.org [192.0.2.1]
+    SMTP error from remote mail server after RCPT TO:<bob@example.org>:
+    452 4.2.2 Mailbox full
+
+------ This is a copy of the message, including all the headers. ------
+
+Message-ID: <abc@example.com>
+X-Campaign-ID: mailgrid-42
+`
+
+const qmailBounce = `From: MAILER-DAEMON@qmail.example.com
+To: news@example.com
+Subject: failure notice
+
+Hi. This is the qmail-send program at qmail.example.com.
+I'm afraid I wasn't able to deliver your message to the following addresses.
+This is a permanent error; I've given up. Sorry it didn't work out.
+
+<carol@example.com>:
+Sorry, no mailbox here by that name. (#5.1.1)
+
+--- Below this line is a copy of the message.
+
+Message-ID: <xyz@example.com>
+`
+
+const genericBounce = `From: postmaster@corp.example
+To: news@example.com
+Subject: Undeliverable: Hello
+
+Delivery has failed to these recipients or groups:
+
+dave@corp.example
+The email address you entered couldn't be found. 550 5.1.10 RESOLVER.ADR.RecipientNotFound
+
+----- Original message -----
+From: news@example.com
+`
+
+const notBounce = `From: friend@example.com
+To: news@example.com
+Subject: Lunch?
+
+Are you free at noon?
+`
+
+func TestParse_DSN(t *testing.T) {
+	rep, err := Parse([]byte(dsnBounce))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if rep.Format != "dsn" || rep.EnvelopeID != "mailgrid-1700000000" || rep.CampaignID != "mailgrid-1700000000" {
+		t.Errorf("unexpected report: %+v", rep)
+	}
+	if !strings.HasPrefix(rep.MessageID, "<mg.1a2b3c4d.") {
+		t.Errorf("MessageID = %q", rep.MessageID)
+	}
+	if len(rep.ReturnPaths) != 1 || !strings.HasPrefix(rep.ReturnPaths[0], "bounces+") {
+		t.Errorf("ReturnPaths = %v", rep.ReturnPaths)
+	}
+	if len(rep.Recipients) != 1 {
+		t.Fatalf("recipients = %+v", rep.Recipients)
+	}
+	r := rep.Recipients[0]
+	if r.Email != "alice@example.com" || r.Original != "alice@example.com" || r.Status != "5.1.1" || r.Class != Hard {
+		t.Errorf("recipient = %+v", r)
+	}
+}
+
+func TestParse_DSNSuccessIsNotBounce(t *testing.T) {
+	raw := strings.Replace(dsnBounce, "Action: failed", "Action: delivered", 1)
+	if _, err := Parse([]byte(raw)); err != ErrNotBounce {
+		t.Errorf("expected ErrNotBounce for a success DSN, got %v", err)
+	}
+}
+
+func TestParse_NonStandardFormats(t *testing.T) {
+	tests := []struct {
+		name, raw, format, email string
+		class                    Class
+		msgID                    string
+	}{
+		{"exim", eximBounce, "exim", "bob@example.org", Soft, "<abc@example.com>"},
+		{"qmail", qmailBounce, "qmail", "carol@example.com", Hard, "<xyz@example.com>"},
+		{"generic", genericBounce, "generic", "dave@corp.example", Hard, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rep, err := Parse([]byte(tt.raw))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if rep.Format != tt.format {
+				t.Errorf("format = %q, want %q", rep.Format, tt.format)
+			}
+			if len(rep.Recipients) != 1 || rep.Recipients[0].Email != tt.email || rep.Recipients[0].Class != tt.class {
+				t.Errorf("recipients = %+v", rep.Recipients)
+			}
+			if rep.MessageID != tt.msgID {
+				t.Errorf("MessageID = %q, want %q", rep.MessageID, tt.msgID)
+			}
+		})
+	}
+}
+
+func TestParse_NotBounce(t *testing.T) {
+	if _, err := Parse([]byte(notBounce)); err != ErrNotBounce {
+		t.Errorf("expected ErrNotBounce, got %v", err)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		action, status, diag string
+		want                 Class
+	}{
+		{"failed", "5.1.1", "", Hard},
+		{"failed", "5.2.2", "mailbox full", Soft},
+		{"failed", "5.7.1", "blocked by policy", Soft},
+		{"delayed", "4.4.1", "", Soft},
+		{"failed", "", "smtp; 550 No such user", Hard},
+		{"failed", "", "smtp; 552 quota exceeded", Soft},
+		{"failed", "", "user unknown", Hard},
+		{"failed", "", "connection timed out", Soft},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.action, tt.status, tt.diag); got != tt.want {
+			t.Errorf("Classify(%q, %q, %q) = %s, want %s", tt.action, tt.status, tt.diag, got, tt.want)
+		}
+	}
+}
+
+func TestWalk_MboxAndMaildir(t *testing.T) {
+	dir := t.TempDir()
+
+	mbox := "From MAILER-DAEMON Mon Jan  1 00:00:00 2024\n" + qmailBounce +
+		"\nFrom MAILER-DAEMON Mon Jan  1 00:00:01 2024\n" + strings.Replace(notBounce, "Are you", ">From the desk: are you", 1)
+	mboxPath := filepath.Join(dir, "bounces.mbox")
+	if err := os.WriteFile(mboxPath, []byte(mbox), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var msgs []string
+	if err := Walk(mboxPath, func(raw []byte) error { msgs = append(msgs, string(raw)); return nil }); err != nil {
+		t.Fatalf("Walk mbox: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 mbox messages, got %d", len(msgs))
+	}
+	if !strings.Contains(msgs[1], "\nFrom the desk") {
+		t.Errorf("mboxrd escape not undone: %q", msgs[1])
+	}
+
+	maildir := filepath.Join(dir, "Maildir")
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(maildir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(filepath.Join(maildir, "new", "1.host"), []byte(dsnBounce), 0644)
+	os.WriteFile(filepath.Join(maildir, "cur", "2.host:2,S"), []byte(eximBounce), 0644)
+	os.WriteFile(filepath.Join(maildir, "tmp", "3.host"), []byte(qmailBounce), 0644)
+
+	count := 0
+	if err := Walk(maildir, func(raw []byte) error { count++; return nil }); err != nil {
+		t.Fatalf("Walk maildir: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("expected 2 maildir messages (tmp/ ignored), got %d", count)
+	}
+}
+
+const arfReport = `From: staff@hotmail.example
+To: fbl@example.com
+Subject: complaint about message from 192.0.2.1
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report; boundary="part1"
+
+--part1
+Content-Type: text/plain; charset="US-ASCII"
+
+This is an email abuse report for an email message received from IP
+192.0.2.1 on Thu, 8 Mar 2024 14:00:00 EDT.
+
+--part1
+Content-Type: message/feedback-report
+
+Feedback-Type: abuse
+User-Agent: SomeGenerator/1.0
+Version: 1
+Original-Mail-From: <bounces+t1a2b3c4d.alice=example.com@bounce.example.com>
+Original-Rcpt-To: <redacted@hotmail.example>
+Arrival-Date: Thu, 8 Mar 2024 14:00:00 -0500
+Source-IP: 192.0.2.1
+Reported-Domain: example.com
+
+--part1
+Content-Type: message/rfc822
+Content-Disposition: inline
+
+Return-Path: <bounces+t1a2b3c4d.alice=example.com@bounce.example.com>
+From: Mailgrid <news@example.com>
+To: redacted
+Subject: Hello
+Message-ID: <mg.1a2b3c4d.mfwgy3dpnbqxq33nfzrw63i.0011223344@example.com>
+X-Campaign-ID: mailgrid-1700000000
+
+Hello!
+--part1--
+`
+
+func TestParseComplaint(t *testing.T) {
+	c, err := ParseComplaint([]byte(arfReport))
+	if err != nil {
+		t.Fatalf("ParseComplaint: %v", err)
+	}
+	if c.FeedbackType != "abuse" || c.UserAgent != "SomeGenerator/1.0" || c.SourceIP != "192.0.2.1" || !c.IsSpamReport() {
+		t.Errorf("unexpected report fields: %+v", c)
+	}
+	if c.OriginalMailFrom != "bounces+t1a2b3c4d.alice=example.com@bounce.example.com" || c.ReturnPath != c.OriginalMailFrom {
+		t.Errorf("envelope sender = %q / %q", c.OriginalMailFrom, c.ReturnPath)
+	}
+	if len(c.OriginalRcptTo) != 1 || c.OriginalRcptTo[0] != "redacted@hotmail.example" || len(c.To) != 0 {
+		t.Errorf("recipients = %v / %v", c.OriginalRcptTo, c.To)
+	}
+	if !strings.HasPrefix(c.MessageID, "<mg.1a2b3c4d.") || c.CampaignID != "mailgrid-1700000000" {
+		t.Errorf("original headers: %q %q", c.MessageID, c.CampaignID)
+	}
+	if c.ArrivalDate.IsZero() {
+		t.Error("Arrival-Date not parsed")
+	}
+
+	notSpam := strings.Replace(arfReport, "Feedback-Type: abuse", "Feedback-Type: not-spam", 1)
+	if c, err := ParseComplaint([]byte(notSpam)); err != nil || c.IsSpamReport() {
+		t.Errorf("not-spam report: %+v, %v", c, err)
+	}
+	for _, raw := range []string{dsnBounce, notBounce} {
+		if _, err := ParseComplaint([]byte(raw)); err != ErrNotComplaint {
+			t.Errorf("expected ErrNotComplaint, got %v", err)
+		}
+	}
+}
+
+func TestWalk_SingleMessageFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.eml")
+	if err := os.WriteFile(path, []byte(arfReport), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var msgs [][]byte
+	if err := Walk(path, func(raw []byte) error { msgs = append(msgs, raw); return nil }); err != nil {
+		t.Fatalf("Walk: %v", err)
+	}
+	if len(msgs) != 1 || string(msgs[0]) != arfReport {
+		t.Errorf("expected the file as one message, got %d", len(msgs))
+	}
+}
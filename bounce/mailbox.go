@@ -0,0 +1,143 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Walk calls fn with every message stored at path, which may be an mbox file,
+// a single message file (.eml), or a Maildir directory (with cur/ and new/
+// subdirectories; a plain directory of one-message-per-file is accepted too).
+// Walk stops at the first error returned by fn.
+func Walk(path string, fn func(raw []byte) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("open mailbox: %w", err)
+	}
+	if info.IsDir() {
+		return walkMaildir(path, fn)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open mailbox: %w", err)
+	}
+	defer f.Close()
+
+	// An mbox starts with a "From " separator line; anything else is one
+	// message.
+	br := bufio.NewReaderSize(f, 64*1024)
+	if head, _ := br.Peek(5); string(head) != "From " {
+		data, err := io.ReadAll(br)
+		if err != nil {
+			return fmt.Errorf("read message: %w", err)
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil
+		}
+		return fn(data)
+	}
+	return ReadMbox(br, fn)
+}
+
+// ReadMbox splits an mbox stream into messages. Messages start at lines
+// beginning with "From " that follow a blank line (or the start of the file);
+// mboxrd-style ">From " escapes are undone.
+func ReadMbox(r io.Reader, fn func(raw []byte) error) error {
+	br := bufio.NewReaderSize(r, 64*1024)
+	var msg bytes.Buffer
+	inMsg := false
+	prevBlank := true
+
+	emit := func() error {
+		if !inMsg {
+			return nil
+		}
+		data := bytes.TrimRight(msg.Bytes(), "\r\n")
+		out := append(append([]byte(nil), data...), '\n')
+		msg.Reset()
+		return fn(out)
+	}
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case prevBlank && bytes.HasPrefix(line, []byte("From ")):
+				if ferr := emit(); ferr != nil {
+					return ferr
+				}
+				inMsg = true
+			case inMsg:
+				if unescaped := unescapeFrom(line); unescaped != nil {
+					line = unescaped
+				}
+				msg.Write(line)
+			}
+			prevBlank = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+		if err == io.EOF {
+			return emit()
+		}
+		if err != nil {
+			return fmt.Errorf("read mbox: %w", err)
+		}
+	}
+}
+
+// unescapeFrom turns ">From ", ">>From ", ... into one fewer '>'.
+func unescapeFrom(line []byte) []byte {
+	i := 0
+	for i < len(line) && line[i] == '>' {
+		i++
+	}
+	if i == 0 || !bytes.HasPrefix(line[i:], []byte("From ")) {
+		return nil
+	}
+	return line[1:]
+}
+
+func walkMaildir(dir string, fn func(raw []byte) error) error {
+	var files []string
+	subdirs := []string{filepath.Join(dir, "new"), filepath.Join(dir, "cur")}
+	found := false
+	for _, sub := range subdirs {
+		entries, err := os.ReadDir(sub)
+		if err != nil {
+			continue
+		}
+		found = true
+		for _, e := range entries {
+			if e.Type().IsRegular() {
+				files = append(files, filepath.Join(sub, e.Name()))
+			}
+		}
+	}
+	if !found {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return fmt.Errorf("read maildir: %w", err)
+		}
+		for _, e := range entries {
+			if e.Type().IsRegular() {
+				files = append(files, filepath.Join(dir, e.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return fmt.Errorf("read maildir message: %w", err)
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
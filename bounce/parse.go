@@ -0,0 +1,308 @@
+package bounce
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+var (
+	addressRe      = regexp.MustCompile(`[A-Za-z0-9._%+\-=]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	quotedMsgIDRe  = regexp.MustCompile(`(?im)^Message-ID:[ \t]*(<[^>\r\n]+>)`)
+	quotedCampIDRe = regexp.MustCompile(`(?im)^X-Campaign-ID:[ \t]*(\S+)`)
+	qmailRcptRe    = regexp.MustCompile(`(?m)^<([^>\s]+@[^>\s]+)>:\s*$`)
+
+	// bounceSubjects are lower-cased fragments of the subjects MTAs use for
+	// non-delivery reports.
+	bounceSubjects = []string{"undeliverable", "undelivered mail", "delivery status notification",
+		"mail delivery failed", "delivery failure", "returned mail", "failure notice", "delivery has failed",
+		"could not be delivered", "mail delivery system", "non-delivery"}
+
+	// originalMarkers introduce the quoted original message in plain-text
+	// bounces; recipients are only looked for above them.
+	originalMarkers = []string{"--- below this line is a copy of the message", "------ this is a copy of the message",
+		"----- original message -----", "original message follows", "----- the header of the original message is following"}
+)
+
+// Parse reads one raw RFC 5322 message and extracts its bounce information.
+// It returns ErrNotBounce for messages in no recognised bounce format.
+func Parse(raw []byte) (*Report, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read message body: %w", err)
+	}
+
+	rep := &Report{ReturnPaths: returnPaths(msg.Header)}
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+
+	// text collects the human-readable parts for the non-standard parsers.
+	var text strings.Builder
+	if strings.HasPrefix(mediaType, "multipart/") {
+		walkMultipart(rep, bytes.NewReader(body), params["boundary"], &text)
+		if rep.Format == "dsn" {
+			// A delivery-status part with no failed or delayed recipient is
+			// a success notice.
+			if len(rep.Recipients) == 0 {
+				return nil, ErrNotBounce
+			}
+			return rep, nil
+		}
+	} else {
+		text.Write(decodeBody(body, msg.Header.Get("Content-Transfer-Encoding")))
+	}
+
+	content := text.String()
+	if rep.MessageID == "" {
+		if m := quotedMsgIDRe.FindStringSubmatch(content); m != nil {
+			rep.MessageID = m[1]
+		}
+	}
+	if rep.CampaignID == "" {
+		if m := quotedCampIDRe.FindStringSubmatch(content); m != nil {
+			rep.CampaignID = m[1]
+		}
+	}
+	report := beforeOriginal(content)
+
+	if failed := msg.Header.Get("X-Failed-Recipients"); failed != "" {
+		rep.Format = "exim"
+		for _, addr := range strings.Split(failed, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				rep.Recipients = append(rep.Recipients, fromText(addr, report))
+			}
+		}
+		return rep, nil
+	}
+
+	if strings.Contains(report, "qmail-send program") {
+		rep.Format = "qmail"
+		permanent := strings.Contains(report, "permanent error")
+		for _, m := range qmailRcptRe.FindAllStringSubmatchIndex(report, -1) {
+			addr := report[m[2]:m[3]]
+			diag := paragraph(report[m[1]:])
+			r := Recipient{Email: addr, Action: "failed", Status: ExtractStatus(diag), Diagnostic: diag}
+			r.Class = Classify(r.Action, r.Status, diag)
+			if permanent && r.Status == "" && r.Class == Soft && !containsAny(strings.ToLower(diag), softHints) {
+				r.Class = Hard
+			}
+			rep.Recipients = append(rep.Recipients, r)
+		}
+		if len(rep.Recipients) > 0 {
+			return rep, nil
+		}
+	}
+
+	if looksLikeBounce(msg.Header) {
+		rep.Format = "generic"
+		skip := map[string]bool{}
+		for _, h := range []string{"From", "To", "Delivered-To", "X-Original-To", "Return-Path"} {
+			for _, a := range addressRe.FindAllString(msg.Header.Get(h), -1) {
+				skip[strings.ToLower(a)] = true
+			}
+		}
+		for _, addr := range addressRe.FindAllString(report, -1) {
+			key := strings.ToLower(addr)
+			if skip[key] || strings.HasPrefix(key, "postmaster@") || strings.HasPrefix(key, "mailer-daemon@") {
+				continue
+			}
+			skip[key] = true
+			rep.Recipients = append(rep.Recipients, fromText(addr, report))
+		}
+		if len(rep.Recipients) > 0 {
+			return rep, nil
+		}
+	}
+	return nil, ErrNotBounce
+}
+
+// walkMultipart visits every part (recursing into nested multiparts), reading
+// delivery-status fields, the quoted original headers, and text parts.
+func walkMultipart(rep *Report, r io.Reader, boundary string, text *strings.Builder) {
+	if boundary == "" {
+		return
+	}
+	mr := multipart.NewReader(r, boundary)
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			return
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return
+		}
+		data = decodeBody(data, part.Header.Get("Content-Transfer-Encoding"))
+		mediaType, params, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+
+		switch mediaType {
+		case "message/delivery-status", "message/global-delivery-status":
+			parseDeliveryStatus(rep, data)
+		case "message/rfc822", "message/global", "text/rfc822-headers", "message/rfc822-headers", "message/global-headers":
+			h := readHeaders(data)
+			if id := strings.TrimSpace(h.Get("Message-Id")); id != "" && rep.MessageID == "" {
+				rep.MessageID = id
+			}
+			if id := strings.TrimSpace(h.Get("X-Campaign-Id")); id != "" && rep.CampaignID == "" {
+				rep.CampaignID = id
+			}
+			text.Write(data)
+			text.WriteString("\n")
+		default:
+			if strings.HasPrefix(mediaType, "multipart/") {
+				walkMultipart(rep, bytes.NewReader(data), params["boundary"], text)
+			} else if mediaType == "" || strings.HasPrefix(mediaType, "text/") {
+				text.Write(data)
+				text.WriteString("\n")
+			}
+		}
+	}
+}
+
+// parseDeliveryStatus reads the per-message and per-recipient field groups of
+// an RFC 3464 message/delivery-status body.
+func parseDeliveryStatus(rep *Report, data []byte) {
+	rep.Format = "dsn"
+	tr := textproto.NewReader(bufio.NewReader(bytes.NewReader(bytes.TrimLeft(data, "\r\n"))))
+	first := true
+	for {
+		h, err := tr.ReadMIMEHeader()
+		if len(h) > 0 {
+			if first {
+				rep.EnvelopeID = strings.TrimSpace(h.Get("Original-Envelope-Id"))
+				first = false
+			}
+			if final := h.Get("Final-Recipient"); final != "" {
+				r := Recipient{
+					Email:      addressValue(final),
+					Original:   addressValue(h.Get("Original-Recipient")),
+					Action:     strings.ToLower(strings.TrimSpace(h.Get("Action"))),
+					Status:     ExtractStatus(h.Get("Status")),
+					Diagnostic: strings.TrimSpace(h.Get("Diagnostic-Code")),
+				}
+				// Only failures and delays are bounces; "delivered",
+				// "relayed" and "expanded" are success notices.
+				if r.Action == "failed" || r.Action == "delayed" || r.Action == "" {
+					r.Class = Classify(r.Action, r.Status, r.Diagnostic)
+					rep.Recipients = append(rep.Recipients, r)
+				}
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// addressValue strips the address-type prefix from a DSN field such as
+// "rfc822; user@example.com".
+func addressValue(v string) string {
+	if i := strings.IndexByte(v, ';'); i >= 0 {
+		v = v[i+1:]
+	}
+	return strings.Trim(strings.TrimSpace(v), "<>")
+}
+
+// readHeaders parses a header block, tolerating a missing terminator.
+func readHeaders(data []byte) textproto.MIMEHeader {
+	buf := append(append([]byte(nil), data...), "\r\n\r\n"...)
+	h, _ := textproto.NewReader(bufio.NewReader(bytes.NewReader(buf))).ReadMIMEHeader()
+	return h
+}
+
+func decodeBody(data []byte, encoding string) []byte {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		clean := bytes.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, data)
+		if out, err := base64.StdEncoding.DecodeString(string(clean)); err == nil {
+			return out
+		}
+	case "quoted-printable":
+		if out, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(data))); err == nil {
+			return out
+		}
+	}
+	return data
+}
+
+// returnPaths lists the addresses the bounce was delivered to.
+func returnPaths(h mail.Header) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, name := range []string{"Delivered-To", "X-Original-To", "Envelope-To", "To"} {
+		for _, v := range h[textproto.CanonicalMIMEHeaderKey(name)] {
+			for _, a := range addressRe.FindAllString(v, -1) {
+				if key := strings.ToLower(a); !seen[key] {
+					seen[key] = true
+					out = append(out, a)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func looksLikeBounce(h mail.Header) bool {
+	subject := strings.ToLower(h.Get("Subject"))
+	if containsAny(subject, bounceSubjects) {
+		return true
+	}
+	from := strings.ToLower(h.Get("From"))
+	return strings.Contains(from, "mailer-daemon") || strings.Contains(from, "postmaster")
+}
+
+// beforeOriginal cuts s at the start of the quoted original message.
+func beforeOriginal(s string) string {
+	lower := strings.ToLower(s)
+	cut := len(s)
+	for _, m := range originalMarkers {
+		if i := strings.Index(lower, m); i >= 0 && i < cut {
+			cut = i
+		}
+	}
+	return s[:cut]
+}
+
+// fromText builds a Recipient for addr using the text around its mention in
+// a plain-text report as the diagnostic.
+func fromText(addr, report string) Recipient {
+	r := Recipient{Email: addr, Action: "failed"}
+	if i := strings.Index(strings.ToLower(report), strings.ToLower(addr)); i >= 0 {
+		r.Diagnostic = paragraph(report[i+len(addr):])
+	}
+	r.Status = ExtractStatus(r.Diagnostic)
+	r.Class = Classify(r.Action, r.Status, r.Diagnostic)
+	return r
+}
+
+// paragraph returns the text up to the next blank line, whitespace-collapsed
+// and capped at 300 bytes.
+func paragraph(s string) string {
+	s = strings.TrimLeft(s, ":> \t\r\n")
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	if i := strings.Index(s, "\n\n"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > 300 {
+		s = s[:300]
+	}
+	return s
+}
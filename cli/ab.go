@@ -0,0 +1,438 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/database"
+	"github.com/bravo1goingdark/mailgrid/email"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/bravo1goingdark/mailgrid/parser"
+	"github.com/bravo1goingdark/mailgrid/scheduler"
+)
+
+// Metrics --ab-metric picks the winner of an A/B test by.
+const (
+	MetricOpens  = "opens"  // share of recipients who opened (or clicked)
+	MetricClicks = "clicks" // share of recipients who clicked
+	MetricManual = "manual" // chosen with --ab-winner
+)
+
+// maxVariants is how many variants can be named A to Z.
+const maxVariants = 26
+
+// Variant is one version of a campaign's email in an A/B test.
+type Variant struct {
+	Name         string // A, B, ...; empty when the campaign has one version
+	Subject      string // subject template
+	TemplatePath string
+	Weight       int // share of recipients relative to the other variants
+}
+
+// ParseVariants returns the variants defined by repeating --subject or
+// --template: the n-th value of each makes variant n, and a flag given once
+// is shared by every variant. Without repeats there is one unnamed variant.
+func ParseVariants(args CLIArgs) ([]Variant, error) {
+	subjects := append([]string{args.Subject}, args.SubjectVariants...)
+	templates := append([]string{args.TemplatePath}, args.TemplateVariants...)
+	n := len(subjects)
+	if len(templates) > n {
+		n = len(templates)
+	}
+	if len(subjects) > 1 && len(templates) > 1 && len(subjects) != len(templates) {
+		return nil, fmt.Errorf("%d --subject and %d --template values: give one of either, or as many of each", len(subjects), len(templates))
+	}
+	if n > maxVariants {
+		return nil, fmt.Errorf("%d variants: at most %d are supported", n, maxVariants)
+	}
+	if n == 1 {
+		if len(args.VariantWeights) > 0 {
+			return nil, fmt.Errorf("--variant-weights needs variants: repeat --subject or --template")
+		}
+		return []Variant{{Subject: args.Subject, TemplatePath: args.TemplatePath, Weight: 1}}, nil
+	}
+	if len(args.VariantWeights) > 0 && len(args.VariantWeights) != n {
+		return nil, fmt.Errorf("--variant-weights has %d values for %d variants", len(args.VariantWeights), n)
+	}
+
+	variants := make([]Variant, n)
+	for i := range variants {
+		v := Variant{Name: string(rune('A' + i)), Subject: subjects[0], TemplatePath: templates[0], Weight: 1}
+		if len(subjects) > 1 {
+			v.Subject = subjects[i]
+		}
+		if len(templates) > 1 {
+			v.TemplatePath = templates[i]
+		}
+		if len(args.VariantWeights) > 0 {
+			if v.Weight = args.VariantWeights[i]; v.Weight < 1 {
+				return nil, fmt.Errorf("--variant-weights: the weight of variant %s must be positive", v.Name)
+			}
+		}
+		variants[i] = v
+	}
+	return variants, nil
+}
+
+// AssignVariant returns the index of the variant email is sent. It depends
+// only on the address, so a recipient gets the same variant in every run,
+// and each variant gets a share of recipients in proportion to its weight.
+func AssignVariant(variants []Variant, email string) int {
+	if len(variants) < 2 {
+		return 0
+	}
+	total := 0
+	for _, v := range variants {
+		total += variantWeight(v)
+	}
+	x := RecipientBucket("variant", email) * float64(total)
+	for i, v := range variants {
+		if x -= float64(variantWeight(v)); x < 0 {
+			return i
+		}
+	}
+	return len(variants) - 1
+}
+
+func variantWeight(v Variant) int {
+	if v.Weight < 1 {
+		return 1
+	}
+	return v.Weight
+}
+
+// abPlan is the A/B testing part of a run: the variants it sends and how
+// the winner of a test is picked and rolled out.
+type abPlan struct {
+	variants []Variant
+	metric   string
+	wait     time.Duration // 0 unless a rollout is scheduled after the test
+}
+
+// parseABPlan validates the variant and --ab-* options of args.
+func parseABPlan(args CLIArgs) (*abPlan, error) {
+	variants, err := ParseVariants(args)
+	if err != nil {
+		return nil, err
+	}
+	plan := &abPlan{variants: variants, metric: strings.ToLower(strings.TrimSpace(args.ABMetric))}
+	switch plan.metric {
+	case "":
+		plan.metric = MetricOpens
+	case MetricOpens, MetricClicks, MetricManual:
+	default:
+		return nil, fmt.Errorf("invalid --ab-metric %q (use opens, clicks or manual)", args.ABMetric)
+	}
+	testing := len(variants) > 1
+
+	switch {
+	case testing && args.To != "":
+		return nil, fmt.Errorf("variants need a recipient list; --to sends a single email")
+	case args.ABTest != "" && !testing:
+		return nil, fmt.Errorf("--ab-test needs at least two variants: repeat --subject or --template")
+	case args.ABTest != "" && args.ABRollout != "":
+		return nil, fmt.Errorf("--ab-test and --ab-rollout cannot be combined; the rollout is a later run")
+	case args.ABTest != "" && (args.Interval != "" || args.Cron != ""):
+		return nil, fmt.Errorf("--ab-test cannot repeat with --interval or --cron; schedule it once with --schedule-at")
+	case args.ABRollout != "" && !testing:
+		return nil, fmt.Errorf("--ab-rollout needs the variants of the test: repeat --subject or --template as in the test run")
+	case args.ABWinner != "" && args.ABRollout == "":
+		return nil, fmt.Errorf("--ab-winner applies to --ab-rollout, which is not set")
+	case args.ABMetric != "" && args.ABTest == "" && args.ABRollout == "":
+		return nil, fmt.Errorf("--ab-metric applies to --ab-test or --ab-rollout, neither of which is set")
+	case args.ABWait != "" && args.ABTest == "":
+		return nil, fmt.Errorf("--ab-wait applies to --ab-test, which is not set")
+	}
+
+	if args.ABTest != "" {
+		share, err := ParseFraction(args.ABTest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --ab-test: %w", err)
+		}
+		if share == 0 {
+			return nil, fmt.Errorf("invalid --ab-test: the test needs more than 0%% of recipients")
+		}
+	}
+	if args.ABWait != "" {
+		if plan.metric == MetricManual {
+			return nil, fmt.Errorf("--ab-wait picks the winner by opens or clicks; with --ab-metric manual, run --ab-rollout with --ab-winner yourself")
+		}
+		if plan.wait, err = time.ParseDuration(args.ABWait); err != nil || plan.wait <= 0 {
+			return nil, fmt.Errorf("invalid --ab-wait %q: use a positive Go duration such as 4h", args.ABWait)
+		}
+	}
+	if args.ABWinner != "" {
+		if _, err := plan.variant(args.ABWinner); err != nil {
+			return nil, fmt.Errorf("invalid --ab-winner: %w", err)
+		}
+	}
+	return plan, nil
+}
+
+// variant returns the variant called name, case-insensitively.
+func (p *abPlan) variant(name string) (Variant, error) {
+	var names []string
+	for _, v := range p.variants {
+		if strings.EqualFold(v.Name, strings.TrimSpace(name)) {
+			return v, nil
+		}
+		names = append(names, v.Name)
+	}
+	return Variant{}, fmt.Errorf("no variant %q; the variants are %s", name, strings.Join(names, ", "))
+}
+
+// VariantResult is how the recipients of one variant of an A/B test
+// engaged with it.
+type VariantResult struct {
+	Variant    string
+	Recipients int // recipients the variant was sent to
+	Opened     int // recipients who opened or clicked
+	Clicked    int // recipients who clicked
+}
+
+// Rate returns the share of the variant's recipients counted by metric.
+func (r VariantResult) Rate(metric string) float64 {
+	if r.Recipients == 0 {
+		return 0
+	}
+	n := r.Opened
+	if metric == MetricClicks {
+		n = r.Clicked
+	}
+	return float64(n) / float64(r.Recipients)
+}
+
+// ABResults joins the variants recorded for the recipients campaignID sent
+// to with their tracked opens and clicks, by variant name.
+func ABResults(db *database.BoltDBClient, campaignID string) ([]VariantResult, error) {
+	members, err := db.LoadGroupMembers(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the variants of %s: %w", campaignID, err)
+	}
+	engagement, err := db.LoadEngagement(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the engagement of %s: %w", campaignID, err)
+	}
+	byEmail := make(map[string]types.Engagement, len(engagement))
+	for _, e := range engagement {
+		byEmail[strings.ToLower(e.Email)] = e
+	}
+
+	byVariant := make(map[string]*VariantResult)
+	for _, m := range members {
+		if m.Variant == "" {
+			continue
+		}
+		r := byVariant[m.Variant]
+		if r == nil {
+			r = &VariantResult{Variant: m.Variant}
+			byVariant[m.Variant] = r
+		}
+		r.Recipients++
+		e := byEmail[strings.ToLower(m.Email)]
+		if e.Opens > 0 || e.Clicks > 0 {
+			r.Opened++
+		}
+		if e.Clicks > 0 {
+			r.Clicked++
+		}
+	}
+	if len(byVariant) == 0 {
+		return nil, fmt.Errorf("campaign %s has no A/B variants recorded; was it sent with variants and the same --db-path?", campaignID)
+	}
+	results := make([]VariantResult, 0, len(byVariant))
+	for _, r := range byVariant {
+		results = append(results, *r)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Variant < results[j].Variant })
+	return results, nil
+}
+
+// PickWinner returns the variant of results with the highest rate of
+// metric. A tie goes to the variant named first.
+func PickWinner(results []VariantResult, metric string) (string, error) {
+	if metric == MetricManual {
+		return "", fmt.Errorf("--ab-metric manual needs --ab-winner")
+	}
+	best, bestRate := "", 0.0
+	for _, r := range results {
+		if rate := r.Rate(metric); rate > bestRate {
+			best, bestRate = r.Variant, rate
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no %s tracked for any variant yet; pick one with --ab-winner", metric)
+	}
+	return best, nil
+}
+
+// printVariantResults reports how each variant of an A/B test performed.
+func printVariantResults(campaignID string, results []VariantResult) {
+	fmt.Printf(" A/B test %s:\n", campaignID)
+	for _, r := range results {
+		fmt.Printf("   variant %s: %d recipient(s), %.1f%% opened, %.1f%% clicked\n",
+			r.Variant, r.Recipients, 100*r.Rate(MetricOpens), 100*r.Rate(MetricClicks))
+	}
+}
+
+// rolloutVariant picks the variant an --ab-rollout run sends: --ab-winner,
+// or the best performer of the test by the plan's metric.
+func rolloutVariant(db *database.BoltDBClient, args CLIArgs, plan *abPlan) (Variant, error) {
+	if args.ABWinner != "" {
+		return plan.variant(args.ABWinner)
+	}
+	results, err := ABResults(db, args.ABRollout)
+	if err != nil {
+		return Variant{}, err
+	}
+	printVariantResults(args.ABRollout, results)
+	name, err := PickWinner(results, plan.metric)
+	if err != nil {
+		return Variant{}, fmt.Errorf("cannot pick the winner of %s: %w", args.ABRollout, err)
+	}
+	return plan.variant(name)
+}
+
+// rolloutFilter drops the recipients an A/B test already sent a variant.
+type rolloutFilter struct {
+	tested  map[string]struct{}
+	skipped int
+}
+
+func newRolloutFilter(db *database.BoltDBClient, campaignID string) (*rolloutFilter, error) {
+	members, err := db.LoadGroupMembers(campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the recipients of %s: %w", campaignID, err)
+	}
+	f := &rolloutFilter{tested: make(map[string]struct{})}
+	for _, m := range members {
+		if m.Variant != "" {
+			f.tested[strings.ToLower(m.Email)] = struct{}{}
+		}
+	}
+	return f, nil
+}
+
+func (f *rolloutFilter) keep(r parser.Recipient) bool {
+	if _, ok := f.tested[strings.ToLower(r.Email)]; ok {
+		f.skipped++
+		return false
+	}
+	return true
+}
+
+// variantRecorder counts the emails sent per variant and saves each
+// recipient's variant under the campaign once its email was sent, so
+// recipients a test did not reach are left for the rollout.
+type variantRecorder struct {
+	db         *campaignDB
+	campaignID string
+
+	mu     sync.Mutex
+	counts map[string]int
+	batch  []types.GroupMember
+}
+
+func newVariantRecorder(db *campaignDB, campaignID string) *variantRecorder {
+	return &variantRecorder{db: db, campaignID: campaignID, counts: make(map[string]int)}
+}
+
+// sent records the variant of t, which was just sent, saving them in
+// batches. It is the dispatcher's OnSent hook.
+func (r *variantRecorder) sent(t email.Task) {
+	r.mu.Lock()
+	r.counts[t.Variant]++
+	r.batch = append(r.batch, types.GroupMember{CampaignID: r.campaignID, Email: t.Recipient.Email, Group: GroupSelected, Variant: t.Variant})
+	var full []types.GroupMember
+	if len(r.batch) >= groupBatchSize {
+		full, r.batch = r.batch, nil
+	}
+	r.mu.Unlock()
+	r.save(full)
+}
+
+func (r *variantRecorder) save(batch []types.GroupMember) {
+	if len(batch) == 0 {
+		return
+	}
+	err := r.db.with(func(d *database.BoltDBClient) error { return d.SaveGroupMembers(batch) })
+	if err != nil {
+		log.Printf("⚠️ Warning: failed to record the variants of %s: %v", r.campaignID, err)
+	}
+}
+
+// finish saves the variants not saved yet and returns the emails sent per
+// variant. Call it once the dispatch has returned.
+func (r *variantRecorder) finish() map[string]int {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	batch := r.batch
+	r.batch = nil
+	r.mu.Unlock()
+	r.save(batch)
+	return r.counts
+}
+
+// scheduleRollout stores a job that sends the winner of the A/B test
+// campaignID to the recipients it left out, after wait. It runs under
+// --scheduler-run with the same --db-path.
+func scheduleRollout(db *campaignDB, args CLIArgs, campaignID string, wait time.Duration) (string, error) {
+	payload := jobPayload(args)
+	payload.ABTest, payload.ABWait, payload.ABRollout = "", "", campaignID
+	payload.ScheduleAt, payload.Interval, payload.Cron = "", "", ""
+	job, err := scheduler.NewJob(payload, time.Now().Add(wait), "", "")
+	if err != nil {
+		return "", err
+	}
+	if err := db.with(func(d *database.BoltDBClient) error { return d.SaveJob(&job) }); err != nil {
+		return "", fmt.Errorf("failed to save rollout job: %w", err)
+	}
+	return job.ID, nil
+}
+
+// printVariants reports how many emails of each variant were sent.
+func printVariants(counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s %d", name, counts[name])
+	}
+	fmt.Printf(" Variants: %s\n", strings.Join(parts, ", "))
+}
+
+// announceRollout follows an --ab-test run: it schedules the rollout when
+// --ab-wait is set, and otherwise tells how to run it.
+func announceRollout(db *campaignDB, args CLIArgs, plan *abPlan, campaignID string) {
+	if plan.wait == 0 {
+		winner := ""
+		if plan.metric == MetricManual {
+			winner = " --ab-winner <variant>"
+		}
+		fmt.Printf(" To send the winner to the remaining recipients, repeat this command with --ab-rollout %s%s instead of --ab-test\n", campaignID, winner)
+		return
+	}
+	if db == nil {
+		log.Printf("⚠️ Warning: the A/B rollout was not scheduled: the campaign database is unavailable; run --ab-rollout %s later", campaignID)
+		return
+	}
+	jobID, err := scheduleRollout(db, args, campaignID, plan.wait)
+	if err != nil {
+		log.Printf("⚠️ Warning: the A/B rollout was not scheduled: %v; run --ab-rollout %s later", err, campaignID)
+		return
+	}
+	fmt.Printf(" Scheduled job %s to send the winner by %s to the remaining recipients at %s (runs under --scheduler-run)\n",
+		jobID, plan.metric, time.Now().Add(plan.wait).Format(time.RFC3339))
+}
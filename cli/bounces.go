@@ -0,0 +1,219 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/bounce"
+	"github.com/bravo1goingdark/mailgrid/database"
+	"github.com/bravo1goingdark/mailgrid/email"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+)
+
+// runBouncesCommand implements `mailgrid bounces ingest <mbox|maildir>...`.
+func runBouncesCommand(argv []string) error {
+	if len(argv) == 0 || argv[0] != "ingest" {
+		return fmt.Errorf("usage: mailgrid bounces ingest [flags] <mbox|maildir>...")
+	}
+	args, paths, help, err := parseCommandFlags("bounces ingest", argv[1:], false, nil)
+	if err != nil || help {
+		return err
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("usage: mailgrid bounces ingest [flags] <mbox|maildir>...")
+	}
+
+	db, err := database.NewDB(args.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	var total BounceSummary
+	for _, path := range paths {
+		s, err := IngestBounces(db, path)
+		if err != nil {
+			return err
+		}
+		total.add(s)
+	}
+
+	fmt.Printf("Scanned %d message(s): %d bounce(s) (%d hard, %d soft), %d not bounces, %d unparseable\n",
+		total.Messages, total.Bounces, total.Hard, total.Soft, total.NotBounces, total.Errors)
+	fmt.Printf("Matched to a campaign: %d, unmatched: %d\n", total.Bounces-total.Unmatched, total.Unmatched)
+	fmt.Printf("Newly suppressed: %d\n", total.Suppressed)
+	return nil
+}
+
+// BounceSummary counts the outcome of a bounce ingestion.
+type BounceSummary struct {
+	Messages   int // messages read from the mailbox
+	NotBounces int // messages in no recognised bounce format
+	Errors     int // messages that could not be parsed at all
+	Bounces    int // bounced recipients recorded
+	Hard       int
+	Soft       int
+	Unmatched  int // bounced recipients whose campaign could not be determined
+	Suppressed int // addresses newly added to the suppression list
+}
+
+func (s *BounceSummary) add(o BounceSummary) {
+	s.Messages += o.Messages
+	s.NotBounces += o.NotBounces
+	s.Errors += o.Errors
+	s.Bounces += o.Bounces
+	s.Hard += o.Hard
+	s.Soft += o.Soft
+	s.Unmatched += o.Unmatched
+	s.Suppressed += o.Suppressed
+}
+
+// IngestBounces parses every message in the mbox file or Maildir at path,
+// records each bounced recipient in the campaign history and suppresses
+// hard-bounced addresses.
+//
+// Recipients are mapped back in order of reliability: the VERP return path
+// the bounce was delivered to, the DSN Original-Recipient (the address as
+// mailgrid sent it), the recipient encoded in the original Message-ID, and
+// finally the reported Final-Recipient. The campaign comes from the VERP or
+// Message-ID tag, the DSN envelope ID, or the quoted X-Campaign-ID header.
+func IngestBounces(db *database.BoltDBClient, path string) (BounceSummary, error) {
+	var sum BounceSummary
+
+	byTag, err := campaignsByTag(db)
+	if err != nil {
+		return sum, err
+	}
+
+	now := time.Now()
+	err = bounce.Walk(path, func(raw []byte) error {
+		sum.Messages++
+		rep, err := bounce.Parse(raw)
+		if errors.Is(err, bounce.ErrNotBounce) {
+			sum.NotBounces++
+			return nil
+		}
+		if err != nil {
+			sum.Errors++
+			log.Printf("bounces: skipping message %d: %v", sum.Messages, err)
+			return nil
+		}
+
+		for _, m := range resolveBounce(rep, byTag) {
+			ev := &types.BounceEvent{
+				CampaignID: m.campaignID,
+				Email:      strings.ToLower(m.email),
+				Class:      string(m.rcpt.Class),
+				Status:     m.rcpt.Status,
+				Diagnostic: m.rcpt.Diagnostic,
+				ReceivedAt: now,
+			}
+			if _, err := db.RecordBounce(ev); err != nil {
+				return fmt.Errorf("failed to record bounce for %s: %w", ev.Email, err)
+			}
+			sum.Bounces++
+			if ev.CampaignID == "" {
+				sum.Unmatched++
+			}
+			if m.rcpt.Class != bounce.Hard {
+				sum.Soft++
+				continue
+			}
+			sum.Hard++
+			created, err := db.SaveSuppression(&types.Suppression{
+				Email:     ev.Email,
+				Reason:    "hard-bounce",
+				Source:    ev.CampaignID,
+				CreatedAt: now,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to suppress %s: %w", ev.Email, err)
+			}
+			if created {
+				sum.Suppressed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return sum, fmt.Errorf("ingest %s: %w", path, err)
+	}
+	return sum, nil
+}
+
+// campaignsByTag indexes the campaign history by the short tag embedded in
+// VERP addresses and Message-IDs.
+func campaignsByTag(db *database.BoltDBClient) (map[string]types.Campaign, error) {
+	campaigns, err := db.LoadCampaigns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load campaign history: %w", err)
+	}
+	byTag := make(map[string]types.Campaign, len(campaigns))
+	for _, c := range campaigns {
+		tag := c.Tag
+		if tag == "" {
+			tag = email.CampaignTag(c.ID)
+		}
+		// On the (unlikely) tag collision the newest campaign wins.
+		if prev, ok := byTag[tag]; !ok || c.StartedAt.After(prev.StartedAt) {
+			byTag[tag] = c
+		}
+	}
+	return byTag, nil
+}
+
+// bounceMatch is one bounced recipient resolved to an address and campaign.
+type bounceMatch struct {
+	rcpt       bounce.Recipient
+	email      string
+	campaignID string
+}
+
+// resolveBounce maps the recipients of a parsed bounce to the addresses and
+// campaigns mailgrid sent to.
+func resolveBounce(rep *bounce.Report, byTag map[string]types.Campaign) []bounceMatch {
+	var verpTag, verpRcpt string
+	for _, rp := range rep.ReturnPaths {
+		if tag, rcpt, ok := email.ParseVERP(rp); ok {
+			verpTag, verpRcpt = tag, rcpt
+			break
+		}
+	}
+	idTag, idRcpt, idOK := email.ParseMessageID(rep.MessageID)
+
+	campaignID := ""
+	for _, tag := range []string{verpTag, idTag} {
+		if c, ok := byTag[tag]; ok && tag != "" {
+			campaignID = c.ID
+			break
+		}
+	}
+	if campaignID == "" {
+		campaignID = rep.EnvelopeID
+	}
+	if campaignID == "" {
+		campaignID = rep.CampaignID
+	}
+
+	single := len(rep.Recipients) == 1
+	out := make([]bounceMatch, 0, len(rep.Recipients))
+	for _, r := range rep.Recipients {
+		addr := r.Email
+		switch {
+		case verpRcpt != "" && (single || strings.EqualFold(r.Email, verpRcpt) || strings.EqualFold(r.Original, verpRcpt)):
+			addr = verpRcpt
+		case r.Original != "":
+			addr = r.Original
+		case idOK && single:
+			addr = idRcpt
+		}
+		if addr == "" {
+			continue
+		}
+		out = append(out, bounceMatch{rcpt: r, email: addr, campaignID: campaignID})
+	}
+	return out
+}
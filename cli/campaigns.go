@@ -0,0 +1,300 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/database"
+	"github.com/bravo1goingdark/mailgrid/email"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// campaignDB is the database at --db-path holding campaign history, the
+// suppression list and the retry queue. bbolt locks the whole file while it is
+// open and a campaign can run for hours, so every read and write opens it
+// briefly; `mailgrid suppress`, the ingest commands and the unsubscribe and
+// tracking servers can write to it while a campaign runs.
+type campaignDB struct {
+	path string
+}
+
+// openCampaignDB checks that the database at path can be opened. The
+// suppression list is consulted before every send, so a database that cannot
+// be opened (a bad path, or another mailgrid process holding the file lock)
+// stops the run. With ignoreSuppressions the run goes on without history:
+// the error is logged and nil is returned.
+func openCampaignDB(path string, ignoreSuppressions bool) (*campaignDB, error) {
+	if path == "" {
+		return nil, nil
+	}
+	db := &campaignDB{path: path}
+	if err := db.with(func(*database.BoltDBClient) error { return nil }); err != nil {
+		if !ignoreSuppressions {
+			return nil, fmt.Errorf("cannot read the suppression list: %w; pass --ignore-suppressions to send without it", err)
+		}
+		log.Printf("⚠️ Warning: campaign history unavailable: %v", err)
+		return nil, nil
+	}
+	return db, nil
+}
+
+// with opens the database, runs fn and closes it again.
+func (c *campaignDB) with(fn func(db *database.BoltDBClient) error) error {
+	db, err := database.NewDB(c.path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return fn(db)
+}
+
+// PutDeferred, DeleteDeferred and LoadDeferred make campaignDB the
+// email.DeferredStore of --retry-queue.
+func (c *campaignDB) PutDeferred(key string, value []byte) error {
+	return c.with(func(db *database.BoltDBClient) error { return db.PutDeferred(key, value) })
+}
+
+func (c *campaignDB) DeleteDeferred(key string) error {
+	return c.with(func(db *database.BoltDBClient) error { return db.DeleteDeferred(key) })
+}
+
+func (c *campaignDB) LoadDeferred() (entries map[string][]byte, err error) {
+	err = c.with(func(db *database.BoltDBClient) error {
+		entries, err = db.LoadDeferred()
+		return err
+	})
+	return entries, err
+}
+
+// loadEngagement is the /api/engagement source of the monitor.
+func (c *campaignDB) loadEngagement(jobID string) (engagement []types.Engagement, err error) {
+	err = c.with(func(db *database.BoltDBClient) error {
+		engagement, err = db.LoadEngagement(jobID)
+		return err
+	})
+	return engagement, err
+}
+
+// SuppressionSummary describes which recipients a run skipped.
+type SuppressionSummary struct {
+	Total    int
+	ByReason map[string]int // keyed by Suppression.Reason ("unspecified" when empty)
+	Scoped   int            // skipped by an entry scoped to the run's list rather than a global one
+}
+
+// String renders the summary as "3 (hard-bounce: 2, manual: 1)".
+func (s SuppressionSummary) String() string {
+	reasons := make([]string, 0, len(s.ByReason))
+	for r := range s.ByReason {
+		reasons = append(reasons, r)
+	}
+	sort.Strings(reasons)
+	parts := make([]string, 0, len(reasons))
+	for _, r := range reasons {
+		parts = append(parts, fmt.Sprintf("%s: %d", r, s.ByReason[r]))
+	}
+	return fmt.Sprintf("%d (%s)", s.Total, strings.Join(parts, ", "))
+}
+
+// suppressionFilter matches recipients against the suppression entries that
+// apply to one list and tallies what it drops.
+type suppressionFilter struct {
+	set     map[string]types.Suppression
+	summary SuppressionSummary
+}
+
+// newSuppressionFilter indexes the entries that apply to list: global ones
+// and those scoped to it. An address suppressed both ways is attributed to
+// the global entry.
+func newSuppressionFilter(suppressed []types.Suppression, list string) *suppressionFilter {
+	list = strings.TrimSpace(list)
+	set := make(map[string]types.Suppression, len(suppressed))
+	for _, s := range suppressed {
+		if s.Scope != "" && s.Scope != list {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(s.Email))
+		if prev, ok := set[key]; ok && prev.Scope == "" {
+			continue
+		}
+		set[key] = s
+	}
+	return &suppressionFilter{set: set, summary: SuppressionSummary{ByReason: map[string]int{}}}
+}
+
+// keep reports whether r may be sent to, counting it when it may not.
+func (f *suppressionFilter) keep(r parser.Recipient) bool {
+	s, ok := f.set[strings.ToLower(strings.TrimSpace(r.Email))]
+	if !ok {
+		return true
+	}
+	f.summary.Total++
+	reason := s.Reason
+	if reason == "" {
+		reason = "unspecified"
+	}
+	f.summary.ByReason[reason]++
+	if s.Scope != "" {
+		f.summary.Scoped++
+	}
+	return false
+}
+
+// SuppressRecipients drops recipients suppressed globally or for list. An
+// address suppressed both ways is attributed to the global entry.
+func SuppressRecipients(recipients []parser.Recipient, suppressed []types.Suppression, list string) ([]parser.Recipient, SuppressionSummary) {
+	f := newSuppressionFilter(suppressed, list)
+	if len(f.set) == 0 {
+		return recipients, f.summary
+	}
+	kept := recipients[:0:0]
+	for _, r := range recipients {
+		if f.keep(r) {
+			kept = append(kept, r)
+		}
+	}
+	return kept, f.summary
+}
+
+// loadSuppressionFilter reads the suppression list in db, with the
+// unsubscribes serve-unsubscribe has queued for it, into a filter for list.
+func loadSuppressionFilter(db *campaignDB, list string) (*suppressionFilter, error) {
+	var suppressed []types.Suppression
+	err := db.with(func(d *database.BoltDBClient) (err error) {
+		suppressed, err = d.LoadSuppressions()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load suppression list: %w", err)
+	}
+	queued, err := readUnsubscribeQueue(db.path)
+	if err != nil {
+		return nil, err
+	}
+	return newSuppressionFilter(append(suppressed, queued...), list), nil
+}
+
+// applySuppressions wraps src so recipients on the suppression list in db
+// are skipped. The returned filter's summary fills in as src is read.
+func applySuppressions(db *campaignDB, src parser.RecipientSource, list string) (parser.RecipientSource, *suppressionFilter, error) {
+	f, err := loadSuppressionFilter(db, list)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(f.set) == 0 {
+		return src, f, nil
+	}
+	return parser.FilterSource(src, f.keep), f, nil
+}
+
+// checkSuppressed refuses a single email to addr when it is suppressed
+// globally or for list, unless ignoreSuppressions is set.
+func checkSuppressed(path, addr, list string, ignoreSuppressions bool) error {
+	if ignoreSuppressions {
+		return nil
+	}
+	db, err := openCampaignDB(path, false)
+	if err != nil || db == nil {
+		return err
+	}
+	f, err := loadSuppressionFilter(db, list)
+	if err != nil {
+		return err
+	}
+	if f.keep(parser.Recipient{Email: addr}) {
+		return nil
+	}
+	return fmt.Errorf("not sending to %s: it is on the suppression list (%s); remove it with `mailgrid suppress remove` or pass --ignore-suppressions", addr, f.summary)
+}
+
+// printSuppressions reports what a run skipped because of the suppression
+// list.
+func printSuppressions(summary SuppressionSummary, list string) {
+	if summary.Total == 0 {
+		return
+	}
+	fmt.Printf(" Suppressed %s recipient(s)", summary)
+	if summary.Scoped > 0 {
+		fmt.Printf(", %d via list %q", summary.Scoped, list)
+	}
+	fmt.Println()
+}
+
+// recordCampaignStart writes the initial history record for a bulk run. The
+// recipient counts are only known once the list has been streamed, so
+// recordCampaignEnd fills them in.
+func recordCampaignStart(db *campaignDB, jobID, parentJobID string, start time.Time) {
+	campaign := &types.Campaign{
+		ID:        jobID,
+		ParentID:  parentJobID,
+		Tag:       email.CampaignTag(jobID),
+		StartedAt: start,
+	}
+	err := db.with(func(d *database.BoltDBClient) error { return d.SaveCampaign(campaign) })
+	if err != nil {
+		log.Printf("⚠️ Warning: failed to record campaign %s: %v", jobID, err)
+	}
+}
+
+// campaignTotals are the recipient counts of a finished run.
+type campaignTotals struct {
+	Total      int
+	Suppressed int
+	Groups     map[string]int // per --holdout/--sample/--ab-test/--limit group
+	Variants   map[string]int // emails sent per A/B variant
+	Winner     string         // variant sent by an A/B rollout
+	Resumed    bool           // the run continued the job with --resume
+}
+
+// recordCampaignEnd stores the recipient and delivery counts of a finished
+// run, keeping any bounce counters ingested meanwhile. A resumed run adds its
+// deliveries to those of the runs before it.
+func recordCampaignEnd(db *campaignDB, jobID string, totals campaignTotals, result email.DispatchResult) {
+	err := db.with(func(d *database.BoltDBClient) error {
+		campaign, err := d.GetCampaign(jobID)
+		if err != nil {
+			campaign = &types.Campaign{ID: jobID, Tag: email.CampaignTag(jobID)}
+		}
+		campaign.Total = totals.Total
+		campaign.Suppressed = totals.Suppressed
+		campaign.Groups = totals.Groups
+		campaign.Variants = totals.Variants
+		campaign.Winner = totals.Winner
+		if !totals.Resumed {
+			campaign.Sent, campaign.Failed = 0, 0
+		}
+		campaign.Sent += result.Sent
+		campaign.Failed += result.Failed
+		campaign.FinishedAt = time.Now()
+		return d.SaveCampaign(campaign)
+	})
+	if err != nil {
+		log.Printf("⚠️ Warning: failed to record campaign %s: %v", jobID, err)
+	}
+}
+
+// complaintCounts returns a /metrics source reporting the complaint count of
+// jobID and of every earlier campaign that has received complaints.
+func complaintCounts(db *campaignDB, jobID string) func() (map[string]int, error) {
+	return func() (map[string]int, error) {
+		var campaigns []types.Campaign
+		err := db.with(func(d *database.BoltDBClient) (err error) {
+			campaigns, err = d.LoadCampaigns()
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		counts := map[string]int{jobID: 0}
+		for _, c := range campaigns {
+			if c.Complaints > 0 {
+				counts[c.ID] = c.Complaints
+			}
+		}
+		return counts, nil
+	}
+}
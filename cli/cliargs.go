@@ -9,16 +9,50 @@ import (
 type CLIArgs struct {
 	EnvPath       string   // Path to an SMTP config JSON file
 	CSVPath       string   // Path to recipient CSV file
+	JoinPaths     []string // Further --csv files joined onto CSVPath by email
+	JoinMode      string   // union, left or inner
+	JoinConflict  string   // first, last or error: which value a shared column keeps
+	JoinReport    string   // CSV file listing rows without a join partner
+	InputPath     string   // Path to recipient file (CSV, spreadsheet or JSON by extension; "-" for stdin)
+	Sheet         string   // Worksheet of an .xlsx/.ods file, by name or 1-based index
+	Delimiter     string   // CSV field separator: one character, or "tab"
+	Quote         string   // CSV quote character, or "none"
+	Encoding      string   // Character set of CSV/JSON input, e.g. latin1
+	EmailColumn   string   // Column holding addresses when it is not 'email'
+	RenameColumns []string // old=new column renames
 	TemplatePath  string   // Path to HTML email template
 	Subject       string   // Subject line (supports templating with {{ .name }})
+
+	// A/B testing: repeating --subject or --template defines variants
+	SubjectVariants  []string // --subject values after the first
+	TemplateVariants []string // --template values after the first
+	VariantWeights   []int    // relative share of recipients per variant (default equal)
+	ABTest           string   // share of recipients sent the variants, e.g. 20%; the rest wait for the winner
+	ABMetric         string   // how the winner is picked: opens, clicks or manual
+	ABWait           string   // Go duration after which the winner is sent to the rest
+	ABRollout        string   // A/B test campaign whose winner this run sends
+	ABWinner         string   // variant --ab-rollout sends, instead of picking by ABMetric
+
 	DryRun        bool     // If true, render but do not send emails
 	ShowPreview   bool     // If true, serve rendered HTML via localhost
 	PreviewPort   int      // Port to run the preview server on
 	Concurrency   int      // Number of parallel SMTP workers
 	RetryLimit    int      // Max retry attempts for failed sending
+	RetryQueue    bool     // Persist deferred retries to the BoltDB at DBPath
 	BatchSize     int      // Number of emails sent per SMTP batch
 	SheetURL      string   // Optional Google Sheet URL for CSV import
+	Query         string   // SQL SELECT run against the config's database
+	SourceURL     string   // HTTPS URL of a CSV or JSON recipient list
+	SourceHeaders []string // "Name: value" headers sent with --source-url
 	Filter        string   // Logical filter expression for recipients
+	ColumnTypes   []string // name=type declarations for --filter, e.g. age=int,signup=date
+	FilterExplain bool     // Report how --filter treats the list instead of sending
+	List          string   // Mailing list name; selects list-scoped suppressions
+	MXCheck       string   // report or exclude recipients whose domain has no mail exchanger
+	Sample        string   // send to this share of recipients, e.g. 5% or 0.05
+	Limit         int      // send to at most this many recipients
+	Holdout       string   // stable share of recipients never sent to, e.g. 10%
+	UTM           []string // key=value query parameters added to http(s) links
 	Attachments   []string // File paths to attach to every email
 	Cc            string   // Comma-separated emails or file path for CC
 	Bcc           string   // Comma-separated emails or file path for BCC
@@ -27,6 +61,9 @@ type CLIArgs struct {
 	WebhookURL    string   // HTTP URL to send completion notification
 	WebhookSecret string   // Optional HMAC-SHA256 secret for webhook signature
 
+	// Suppression list
+	IgnoreSuppressions bool // Send without the suppression list, also when DBPath cannot be opened
+
 	// Monitoring
 	Monitor     bool // Enable real-time monitoring dashboard
 	MonitorPort int  // Port for monitoring dashboard (includes metrics)
@@ -60,6 +97,10 @@ type CLIArgs struct {
 	// Logging
 	LogLevel  string // Log level: debug, info, warn, error (default "info")
 	LogFormat string // Log format: text, json (default "text")
+
+	// Retry mode (set by `mailgrid retry`)
+	RetryFrom     string // failed.csv to rebuild the recipient subset from
+	RetryCampaign string // Only retry failures logged under this job ID
 }
 
 // printHelp prints a custom formatted help message with grouped flags
@@ -67,12 +108,33 @@ func printHelp() {
 	fmt.Println("MailGrid - A production-ready email orchestrator for bulk email campaigns")
 	fmt.Println()
 	fmt.Println("Usage: mailgrid [flags]")
+	fmt.Println("       mailgrid <command> [flags]")
+	fmt.Println()
+	fmt.Println("COMMANDS:")
+	printCommands()
 	fmt.Println()
 	fmt.Println("RECIPIENT SOURCE (provide one):")
-	fmt.Println("  -f, --csv              string   Path to recipient CSV file")
-	fmt.Println("  -u, --sheet-url        string   Public Google Sheet URL (replaces --csv)")
+	fmt.Println("  -f, --csv              string   Path to recipient CSV file (repeat to join files by email)")
+	fmt.Println("      --input            string   Path to recipient file (.csv, .xlsx, .ods, .json, .ndjson; - for stdin)")
+	fmt.Println("      --sheet            string   Worksheet of an .xlsx/.ods file, by name or 1-based index")
+	fmt.Println("  -u, --sheet-url        string   Google Sheet URL (replaces --csv)")
+	fmt.Println("      --query            string   SQL SELECT against the \"database\" in the --env config")
+	fmt.Println("      --source-url       string   HTTPS URL of a CSV or JSON list (host must be allowlisted)")
+	fmt.Println("      --source-header    string   Header sent with --source-url, \"Name: value\" (repeatable)")
 	fmt.Println("      --to               string   Email address for single-recipient sending")
 	fmt.Println()
+	fmt.Println("JOINING (with repeated --csv):")
+	fmt.Println("      --join             string   union, left or inner (default left)")
+	fmt.Println("      --join-conflict    string   Value kept for a column in several files: first, last or error (default first)")
+	fmt.Println("      --unmatched-report string   Write rows without a match in the other files to this CSV")
+	fmt.Println()
+	fmt.Println("INPUT FORMAT:")
+	fmt.Println("      --delimiter        string   CSV field separator, e.g. ';' or tab (default ,)")
+	fmt.Println("      --quote            string   CSV quote character, or none (default \")")
+	fmt.Println("      --encoding         string   Input character set: utf-8, latin1, windows-1252, iso-8859-15, utf-16")
+	fmt.Println("      --email-column     string   Column holding email addresses (default email)")
+	fmt.Println("      --rename-column    old=new  Rename a column for templates and filters (repeatable)")
+	fmt.Println()
 	fmt.Println("EMAIL CONTENT:")
 	fmt.Println("  -t, --template         string   Path to email HTML template")
 	fmt.Println("      --text             string   Inline plain-text body or path to a .txt file")
@@ -80,15 +142,33 @@ func printHelp() {
 	fmt.Println("  -a, --attach           strings  File attachments (repeat flag to add multiple)")
 	fmt.Println("      --cc               string   Comma-separated emails or file path for CC")
 	fmt.Println("      --bcc              string   Comma-separated emails or file path for BCC")
+	fmt.Println("      --utm              key=value  Add a UTM parameter to every link (repeatable)")
+	fmt.Println()
+	fmt.Println("A/B TESTING (repeat --subject or --template to define variants A, B, ...):")
+	fmt.Println("      --variant-weights  ints     Relative share of recipients per variant, e.g. 70,30 (default equal)")
+	fmt.Println("      --ab-test          string   Send the variants to this share of recipients, e.g. 20%; the rest get the winner")
+	fmt.Println("      --ab-metric        string   How the winner is picked: opens, clicks or manual (default opens)")
+	fmt.Println("      --ab-wait          duration Schedule sending the winner to the rest this long after the test, e.g. 4h")
+	fmt.Println("      --ab-rollout       string   Send the winner of this A/B test campaign ID to the recipients it left out")
+	fmt.Println("      --ab-winner        string   Variant --ab-rollout sends, e.g. B, instead of picking by --ab-metric")
 	fmt.Println()
 	fmt.Println("RECIPIENT FILTERING:")
 	fmt.Println("  -F, --filter           string   Logical filter for recipients")
+	fmt.Println("      --column-types     name=type  Column types for --filter: int, float, bool, date, or auto to infer (repeatable)")
+	fmt.Println("      --filter-explain            Show how many recipients each --filter clause removes, without sending")
+	fmt.Println("      --list             string   Mailing list name; applies suppressions scoped to it")
+	fmt.Println("      --ignore-suppressions       Send without checking the suppression list, even when --db-path cannot be opened")
+	fmt.Println("      --mx-check         string   Look up recipient domains: report or exclude those without a mail exchanger")
+	fmt.Println("      --sample           string   Send to a stable random share of recipients, e.g. 5% or 0.05")
+	fmt.Println("      --limit            int      Send to at most this many recipients")
+	fmt.Println("      --holdout          string   Never send to a stable share of recipients, e.g. 10%")
 	fmt.Println()
 	fmt.Println("SMTP CONFIGURATION:")
 	fmt.Println("  -e, --env              string   Path to SMTP config JSON")
 	fmt.Println("  -c, --concurrency      int      Number of concurrent SMTP workers")
 	fmt.Println("  -b, --batch-size       int      Number of emails per SMTP batch")
 	fmt.Println("  -r, --retries          int      Retry attempts per failed email")
+	fmt.Println("      --retry-queue               Persist deferred retries to --db-path so they survive a crash")
 	fmt.Println("      --smtp-timeout     int      SMTP dial timeout in seconds (default 10)")
 	fmt.Println()
 	fmt.Println("RESUMABLE SENDING:")
@@ -131,52 +211,143 @@ func printHelp() {
 	fmt.Println("  --subject \"Test Email from Mailgrid\", --job-retries 3")
 }
 
+// registerFlags binds every campaign flag to args on fs. It is shared by the
+// top-level command line and by subcommands that run a campaign.
+func registerFlags(fs *pflag.FlagSet, args *CLIArgs) {
+	fs.StringVarP(&args.EnvPath, "env", "e", "", "Path to SMTP config JSON")
+	fs.VarP(csvPaths{args}, "csv", "f", "Path to recipient CSV file; repeat to join further files onto the first by email")
+	fs.StringVar(&args.JoinMode, "join", "", "How repeated --csv files combine: union, left or inner (default left)")
+	fs.StringVar(&args.JoinConflict, "join-conflict", "", "Value kept for a column present in several joined files: first, last or error (default first)")
+	fs.StringVar(&args.JoinReport, "unmatched-report", "", "Write rows of joined files without a match in the other files to this CSV")
+	fs.StringVar(&args.InputPath, "input", "", "Path to recipient file: .xlsx/.ods spreadsheets, .json/.ndjson/.jsonl JSON, anything else CSV; - reads stdin")
+	fs.StringVarP(&args.SheetURL, "sheet-url", "u", "", "Google Sheet URL (replaces --csv); private sheets need a service account in the config")
+	fs.StringVar(&args.SourceURL, "source-url", "", "HTTPS URL of a CSV or JSON recipient list; the host must be in source.allowed_hosts of the config")
+	fs.StringArrayVar(&args.SourceHeaders, "source-header", nil, "Header sent with --source-url as \"Name: value\" (repeatable)")
+	registerInputFlags(fs, args)
+	fs.StringVar(&args.Query, "query", "", "SQL SELECT returning recipients (an email column is required), run against the \"database\" DSN in the --env config")
+	fs.VarP(&repeatedFlag{value: &args.TemplatePath, more: &args.TemplateVariants}, "template", "t", "Path to email HTML template; repeat to test variants")
+	fs.StringVar(&args.Cc, "cc", "", "Comma-separated emails or file path for CC")
+	fs.StringVar(&args.Bcc, "bcc", "", "Comma-separated emails or file path for BCC")
+	fs.StringArrayVar(&args.UTM, "utm", nil, "UTM parameter key=value added to every http(s) link, e.g. source=newsletter (repeatable, value templated with {{ .field }})")
+	args.Subject = "Test Email from Mailgrid"
+	fs.VarP(&repeatedFlag{value: &args.Subject, more: &args.SubjectVariants}, "subject", "s", "Email subject (templated with {{ .field }}); repeat to test variants")
+	fs.IntSliceVar(&args.VariantWeights, "variant-weights", nil, "Relative share of recipients per --subject/--template variant, e.g. 70,30 (default equal)")
+	fs.StringVar(&args.ABTest, "ab-test", "", "Send the variants to this share of recipients (e.g. 20%); the rest wait for the winner")
+	fs.StringVar(&args.ABMetric, "ab-metric", "", "How the A/B winner is picked: opens, clicks or manual (default opens)")
+	fs.StringVar(&args.ABWait, "ab-wait", "", "Go duration after an --ab-test run when the winner is sent to the rest, e.g. 4h (needs --ab-metric opens or clicks)")
+	fs.StringVar(&args.ABRollout, "ab-rollout", "", "Send the winning variant of this A/B test campaign ID to the recipients it did not include")
+	fs.StringVar(&args.ABWinner, "ab-winner", "", "Variant --ab-rollout sends, e.g. B, instead of picking it by --ab-metric")
+	fs.BoolVarP(&args.DryRun, "dry-run", "d", false, "Render emails to console without sending")
+	fs.BoolVarP(&args.ShowPreview, "preview", "p", false, "Start a local preview server to view rendered email")
+	fs.IntVar(&args.PreviewPort, "port", 8080, "Port for preview server")
+	fs.IntVarP(&args.Concurrency, "concurrency", "c", 1, "Number of concurrent SMTP workers")
+	fs.IntVarP(&args.RetryLimit, "retries", "r", 1, "Retry attempts per failed email")
+	fs.BoolVar(&args.RetryQueue, "retry-queue", false, "Persist deferred retries to --db-path so they survive a crash")
+	fs.IntVarP(&args.BatchSize, "batch-size", "b", 1, "Number of emails per SMTP batch")
+	fs.StringVarP(&args.Filter, "filter", "F", "", "Logical filter for recipients")
+	fs.StringArrayVar(&args.ColumnTypes, "column-types", nil, "Column types for --filter as name=type pairs, e.g. age=int,signup=date; auto infers the rest from the data (default strings)")
+	fs.BoolVar(&args.FilterExplain, "filter-explain", false, "Report matches, rows removed by each --filter clause and sample recipients, without sending")
+	fs.StringVar(&args.MXCheck, "mx-check", "", "Look up each recipient domain's MX/A records and report or exclude domains that cannot receive mail")
+	fs.StringVar(&args.Sample, "sample", "", "Send only to this share of recipients (e.g. 5% or 0.05), chosen by a hash of each address")
+	fs.IntVar(&args.Limit, "limit", 0, "Send to at most this many recipients, in list order (0 means no limit)")
+	fs.StringVar(&args.Holdout, "holdout", "", "Exclude this share of recipients (e.g. 10%), the same addresses in every run")
+	fs.StringVar(&args.List, "list", "", "Mailing list name; applies suppressions scoped to it in addition to global ones")
+	fs.BoolVar(&args.IgnoreSuppressions, "ignore-suppressions", false, "Send without checking the suppression list; otherwise a --db-path that cannot be opened stops the run")
+	fs.StringSliceVarP(&args.Attachments, "attach", "a", []string{}, "File attachments (repeat flag to add multiple)")
+	fs.StringVar(&args.To, "to", "", "Email address for single-recipient sending (mutually exclusive with --csv or --sheet-url)")
+	fs.StringVar(&args.Text, "text", "", "Inline plain-text body or path to a .txt file (mutually exclusive with --template)")
+	fs.StringVarP(&args.WebhookURL, "webhook", "w", "", "HTTP URL to send POST request with campaign results")
+	fs.StringVar(&args.WebhookSecret, "webhook-secret", "", "HMAC-SHA256 secret for X-Mailgrid-Signature webhook header (optional)")
+
+	fs.BoolVarP(&args.Monitor, "monitor", "m", false, "Enable real-time monitoring dashboard")
+	fs.IntVar(&args.MonitorPort, "monitor-port", 9091, "Port for monitoring dashboard and metrics")
+
+	fs.StringVarP(&args.ScheduleAt, "schedule-at", "A", "", "Schedule time in RFC3339 (e.g., 2025-09-08T09:00:00Z)")
+	fs.StringVarP(&args.Interval, "interval", "i", "", "Repeat interval as Go duration (e.g., 1h, 30m)")
+	fs.StringVarP(&args.Cron, "cron", "C", "", "Cron expression (5-field) for recurring schedules")
+	fs.IntVarP(&args.JobRetries, "job-retries", "J", 3, "Scheduler-level retry attempts on handler failure")
+	fs.BoolVarP(&args.ListJobs, "jobs-list", "L", false, "List scheduled jobs")
+	fs.StringVarP(&args.CancelJobID, "jobs-cancel", "X", "", "Cancel job by ID")
+	fs.BoolVarP(&args.SchedulerRun, "scheduler-run", "R", false, "Run the scheduler dispatcher in the foreground")
+
+	fs.BoolVar(&args.ShowVersion, "version", false, "Show version information and exit")
+
+	fs.BoolVar(&args.Resume, "resume", false, "Resume sending from last saved offset")
+	fs.BoolVar(&args.ResetOffset, "reset-offset", false, "Clear offset file and start from beginning")
+	fs.StringVar(&args.DBPath, "db-path", "mailgrid.db", "Path to BoltDB database file for job persistence")
+
+	fs.IntVar(&args.SMTPTimeout, "smtp-timeout", 10, "SMTP dial timeout in seconds")
+	fs.IntVar(&args.MonitorClientTimeout, "monitor-client-timeout", 300, "Idle SSE client timeout in seconds")
+
+	fs.StringVar(&args.LogLevel, "log-level", "info", "Log level: debug, info, warn, error")
+	fs.StringVar(&args.LogFormat, "log-format", "text", "Log format: text, json")
+}
+
+// registerInputFlags binds the flags that describe how a recipient file is
+// read. Commands that read a list without sending share them.
+func registerInputFlags(fs *pflag.FlagSet, args *CLIArgs) {
+	fs.StringVar(&args.Sheet, "sheet", "", "Worksheet of an .xlsx/.ods file, by name or 1-based index (default first)")
+	fs.StringVar(&args.Delimiter, "delimiter", "", "CSV field separator: a single character, or tab (default ,)")
+	fs.StringVar(&args.Quote, "quote", "", "CSV quote character, or none to read quotes literally (default \")")
+	fs.StringVar(&args.Encoding, "encoding", "", "Character set of CSV/JSON input: utf-8, latin1, windows-1252, iso-8859-15, utf-16, utf-16le, utf-16be (default utf-8)")
+	fs.StringVar(&args.EmailColumn, "email-column", "", "Column holding email addresses, matched case-insensitively (default email)")
+	fs.StringArrayVar(&args.RenameColumns, "rename-column", nil, "Rename a column as old=new before templates and filters see it (repeatable)")
+}
+
+// csvPaths binds the repeatable --csv flag: the first path is the primary
+// list and later ones are joined onto it.
+type csvPaths struct{ args *CLIArgs }
+
+func (c csvPaths) String() string {
+	if c.args == nil {
+		return ""
+	}
+	return c.args.CSVPath
+}
+
+func (c csvPaths) Set(path string) error {
+	if c.args.CSVPath == "" {
+		c.args.CSVPath = path
+	} else {
+		c.args.JoinPaths = append(c.args.JoinPaths, path)
+	}
+	return nil
+}
+
+func (c csvPaths) Type() string { return "string" }
+
+// repeatedFlag binds a flag whose first value replaces value, default
+// included, and whose later values are appended to more. --subject and
+// --template use it: each value after the first is an A/B variant.
+type repeatedFlag struct {
+	value *string
+	more  *[]string
+	set   bool
+}
+
+func (f *repeatedFlag) String() string {
+	if f.value == nil {
+		return ""
+	}
+	return *f.value
+}
+
+func (f *repeatedFlag) Set(v string) error {
+	if f.set {
+		*f.more = append(*f.more, v)
+	} else {
+		*f.value, f.set = v, true
+	}
+	return nil
+}
+
+func (f *repeatedFlag) Type() string { return "string" }
+
 func ParseFlags() CLIArgs {
 	var args CLIArgs
 	var showHelp bool
 
-	pflag.StringVarP(&args.EnvPath, "env", "e", "", "Path to SMTP config JSON")
-	pflag.StringVarP(&args.CSVPath, "csv", "f", "", "Path to recipient CSV file")
-	pflag.StringVarP(&args.SheetURL, "sheet-url", "u", "", "Public Google Sheet URL (replaces --csv)")
-	pflag.StringVarP(&args.TemplatePath, "template", "t", "", "Path to email HTML template")
-	pflag.StringVar(&args.Cc, "cc", "", "Comma-separated emails or file path for CC")
-	pflag.StringVar(&args.Bcc, "bcc", "", "Comma-separated emails or file path for BCC")
-	pflag.StringVarP(&args.Subject, "subject", "s", "Test Email from Mailgrid", "Email subject (templated with {{ .field }})")
-	pflag.BoolVarP(&args.DryRun, "dry-run", "d", false, "Render emails to console without sending")
-	pflag.BoolVarP(&args.ShowPreview, "preview", "p", false, "Start a local preview server to view rendered email")
-	pflag.IntVar(&args.PreviewPort, "port", 8080, "Port for preview server")
-	pflag.IntVarP(&args.Concurrency, "concurrency", "c", 1, "Number of concurrent SMTP workers")
-	pflag.IntVarP(&args.RetryLimit, "retries", "r", 1, "Retry attempts per failed email")
-	pflag.IntVarP(&args.BatchSize, "batch-size", "b", 1, "Number of emails per SMTP batch")
-	pflag.StringVarP(&args.Filter, "filter", "F", "", "Logical filter for recipients")
-	pflag.StringSliceVarP(&args.Attachments, "attach", "a", []string{}, "File attachments (repeat flag to add multiple)")
-	pflag.StringVar(&args.To, "to", "", "Email address for single-recipient sending (mutually exclusive with --csv or --sheet-url)")
-	pflag.StringVar(&args.Text, "text", "", "Inline plain-text body or path to a .txt file (mutually exclusive with --template)")
-	pflag.StringVarP(&args.WebhookURL, "webhook", "w", "", "HTTP URL to send POST request with campaign results")
-	pflag.StringVar(&args.WebhookSecret, "webhook-secret", "", "HMAC-SHA256 secret for X-Mailgrid-Signature webhook header (optional)")
-
-	pflag.BoolVarP(&args.Monitor, "monitor", "m", false, "Enable real-time monitoring dashboard")
-	pflag.IntVar(&args.MonitorPort, "monitor-port", 9091, "Port for monitoring dashboard and metrics")
-
-	pflag.StringVarP(&args.ScheduleAt, "schedule-at", "A", "", "Schedule time in RFC3339 (e.g., 2025-09-08T09:00:00Z)")
-	pflag.StringVarP(&args.Interval, "interval", "i", "", "Repeat interval as Go duration (e.g., 1h, 30m)")
-	pflag.StringVarP(&args.Cron, "cron", "C", "", "Cron expression (5-field) for recurring schedules")
-	pflag.IntVarP(&args.JobRetries, "job-retries", "J", 3, "Scheduler-level retry attempts on handler failure")
-	pflag.BoolVarP(&args.ListJobs, "jobs-list", "L", false, "List scheduled jobs")
-	pflag.StringVarP(&args.CancelJobID, "jobs-cancel", "X", "", "Cancel job by ID")
-	pflag.BoolVarP(&args.SchedulerRun, "scheduler-run", "R", false, "Run the scheduler dispatcher in the foreground")
-
-	pflag.BoolVar(&args.ShowVersion, "version", false, "Show version information and exit")
-
-	pflag.BoolVar(&args.Resume, "resume", false, "Resume sending from last saved offset")
-	pflag.BoolVar(&args.ResetOffset, "reset-offset", false, "Clear offset file and start from beginning")
-	pflag.StringVar(&args.DBPath, "db-path", "mailgrid.db", "Path to BoltDB database file for job persistence")
-
-	pflag.IntVar(&args.SMTPTimeout, "smtp-timeout", 10, "SMTP dial timeout in seconds")
-	pflag.IntVar(&args.MonitorClientTimeout, "monitor-client-timeout", 300, "Idle SSE client timeout in seconds")
-
-	pflag.StringVar(&args.LogLevel, "log-level", "info", "Log level: debug, info, warn, error")
-	pflag.StringVar(&args.LogFormat, "log-format", "text", "Log format: text, json")
+	registerFlags(pflag.CommandLine, &args)
 
 	// Add help flag manually to control behavior
 	pflag.BoolVarP(&showHelp, "help", "h", false, "Show this help message")
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/bravo1goingdark/mailgrid/logger"
+	"github.com/spf13/pflag"
+)
+
+// command is a `mailgrid <name>` subcommand. run receives the arguments that
+// follow the command name.
+type command struct {
+	summary string
+	run     func(argv []string) error
+}
+
+// commands is the subcommand registry consulted by cmd/mailgrid before the
+// flag-only campaign path.
+var commands = map[string]command{
+	"bounces": {
+		summary: "Ingest bounces from an mbox or Maildir and suppress hard bounces",
+		run:     runBouncesCommand,
+	},
+	"complaints": {
+		summary: "Ingest ARF spam complaints and suppress the complainers",
+		run:     runComplaintsCommand,
+	},
+	"retry": {
+		summary: "Resend only to recipients that failed transiently in an earlier run",
+		run:     runRetryCommand,
+	},
+	"serve-unsubscribe": {
+		summary: "Serve signed unsubscribe links and one-click unsubscribe requests",
+		run:     runServeUnsubscribeCommand,
+	},
+	"serve-tracking": {
+		summary: "Serve the open pixel and click redirects and record engagement",
+		run:     runServeTrackingCommand,
+	},
+	"validate": {
+		summary: "Check a recipient list for bad, risky and duplicate addresses without sending",
+		run:     runValidateCommand,
+	},
+	"suppress": {
+		summary: "Manage the suppression list (add, remove, import, export, list)",
+		run:     runSuppressCommand,
+	},
+}
+
+// IsCommand reports whether name is a registered subcommand.
+func IsCommand(name string) bool {
+	_, ok := commands[name]
+	return ok
+}
+
+// RunCommand executes the named subcommand with argv.
+func RunCommand(name string, argv []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.run(argv)
+}
+
+// printCommands lists registered subcommands for the help screen.
+func printCommands() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-22s %s\n", name, commands[name].summary)
+	}
+}
+
+// parseCommandFlags parses argv for subcommand name. When withCampaign is
+// set, the full set of campaign flags is registered so the command can hand
+// the result to Run; extra registers command-specific flags. It initialises
+// logging from --log-level/--log-format and returns the positional arguments
+// and whether --help was shown.
+func parseCommandFlags(name string, argv []string, withCampaign bool, extra func(fs *pflag.FlagSet, args *CLIArgs)) (CLIArgs, []string, bool, error) {
+	var args CLIArgs
+	var showHelp bool
+
+	fs := pflag.NewFlagSet("mailgrid "+name, pflag.ContinueOnError)
+	if withCampaign {
+		registerFlags(fs, &args)
+	} else {
+		fs.StringVar(&args.DBPath, "db-path", "mailgrid.db", "Path to BoltDB database file")
+		fs.StringVar(&args.LogLevel, "log-level", "info", "Log level: debug, info, warn, error")
+		fs.StringVar(&args.LogFormat, "log-format", "text", "Log format: text, json")
+	}
+	if extra != nil {
+		extra(fs, &args)
+	}
+	fs.BoolVarP(&showHelp, "help", "h", false, "Show this help message")
+
+	if err := fs.Parse(argv); err != nil {
+		return args, nil, false, err
+	}
+	if showHelp {
+		fmt.Fprintf(os.Stdout, "Usage: mailgrid %s [flags]\n\nFlags:\n", name)
+		fs.SetOutput(os.Stdout)
+		fs.PrintDefaults()
+		return args, nil, true, nil
+	}
+
+	logger.Init(args.LogLevel, args.LogFormat)
+	return args, fs.Args(), false, nil
+}
@@ -0,0 +1,189 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/bounce"
+	"github.com/bravo1goingdark/mailgrid/database"
+	"github.com/bravo1goingdark/mailgrid/email"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+const complaintsUsage = "usage: mailgrid complaints ingest [flags] <file|mbox|maildir>..."
+
+// runComplaintsCommand implements `mailgrid complaints ingest <path>...`.
+func runComplaintsCommand(argv []string) error {
+	if len(argv) == 0 || argv[0] != "ingest" {
+		return errors.New(complaintsUsage)
+	}
+	args, paths, help, err := parseCommandFlags("complaints ingest", argv[1:], false, nil)
+	if err != nil || help {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New(complaintsUsage)
+	}
+
+	db, err := database.NewDB(args.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	var total ComplaintSummary
+	for _, path := range paths {
+		s, err := IngestComplaints(db, path)
+		if err != nil {
+			return err
+		}
+		total.add(s)
+	}
+
+	fmt.Printf("Scanned %d message(s): %d complaint(s), %d not-spam report(s), %d not feedback reports, %d unparseable\n",
+		total.Messages, total.Complaints, total.NotSpam, total.NotReports, total.Errors)
+	fmt.Printf("Matched to a campaign: %d, unmatched: %d, recipient unknown: %d\n",
+		total.Complaints-total.Unmatched, total.Unmatched, total.NoRecipient)
+	fmt.Printf("Newly suppressed: %d\n", total.Suppressed)
+	return nil
+}
+
+// ComplaintSummary counts the outcome of a complaint ingestion.
+type ComplaintSummary struct {
+	Messages    int // messages read from the mailbox
+	NotReports  int // messages that are not ARF reports
+	Errors      int // messages that could not be parsed at all
+	Complaints  int // complaints recorded
+	NotSpam     int // "not-spam" reports, which are ignored
+	NoRecipient int // reports whose recipient could not be determined (redacted)
+	Unmatched   int // complaints whose campaign could not be determined
+	Suppressed  int // addresses newly added to the suppression list
+}
+
+func (s *ComplaintSummary) add(o ComplaintSummary) {
+	s.Messages += o.Messages
+	s.NotReports += o.NotReports
+	s.Errors += o.Errors
+	s.Complaints += o.Complaints
+	s.NotSpam += o.NotSpam
+	s.NoRecipient += o.NoRecipient
+	s.Unmatched += o.Unmatched
+	s.Suppressed += o.Suppressed
+}
+
+// IngestComplaints parses every ARF report at path (a message file, an mbox
+// or a Maildir), records each complaint in the campaign history and
+// suppresses the complainer for every list.
+//
+// Providers usually redact the recipient, so it is taken from what mailgrid
+// put in the message itself, most reliable first: the recipient encoded in
+// the reported Message-ID, the VERP return path, and only then the report's
+// Original-Rcpt-To or the reported To header. The campaign comes from the
+// Message-ID or VERP tag, or the X-Campaign-ID header.
+func IngestComplaints(db *database.BoltDBClient, path string) (ComplaintSummary, error) {
+	var sum ComplaintSummary
+
+	byTag, err := campaignsByTag(db)
+	if err != nil {
+		return sum, err
+	}
+
+	now := time.Now()
+	err = bounce.Walk(path, func(raw []byte) error {
+		sum.Messages++
+		c, err := bounce.ParseComplaint(raw)
+		if errors.Is(err, bounce.ErrNotComplaint) {
+			sum.NotReports++
+			return nil
+		}
+		if err != nil {
+			sum.Errors++
+			log.Printf("complaints: skipping message %d: %v", sum.Messages, err)
+			return nil
+		}
+		if !c.IsSpamReport() {
+			sum.NotSpam++
+			return nil
+		}
+
+		addr, campaignID := resolveComplaint(c, byTag)
+		if addr == "" {
+			sum.NoRecipient++
+			log.Printf("complaints: message %d names no usable recipient (Message-ID %s)", sum.Messages, c.MessageID)
+			return nil
+		}
+		ev := &types.ComplaintEvent{
+			CampaignID:   campaignID,
+			Email:        strings.ToLower(addr),
+			FeedbackType: c.FeedbackType,
+			Reporter:     c.UserAgent,
+			ArrivalDate:  c.ArrivalDate,
+			ReceivedAt:   now,
+		}
+		if _, err := db.RecordComplaint(ev); err != nil {
+			return fmt.Errorf("failed to record complaint for %s: %w", ev.Email, err)
+		}
+		sum.Complaints++
+		if ev.CampaignID == "" {
+			sum.Unmatched++
+		}
+		created, err := db.SaveSuppression(&types.Suppression{
+			Email:     ev.Email,
+			Reason:    "complaint",
+			Source:    ev.CampaignID,
+			CreatedAt: now,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to suppress %s: %w", ev.Email, err)
+		}
+		if created {
+			sum.Suppressed++
+		}
+		return nil
+	})
+	if err != nil {
+		return sum, fmt.Errorf("ingest %s: %w", path, err)
+	}
+	return sum, nil
+}
+
+// resolveComplaint maps a report to the address and campaign mailgrid sent
+// to. Either result may be empty.
+func resolveComplaint(c *bounce.Complaint, byTag map[string]types.Campaign) (string, string) {
+	var tags []string
+	addr := ""
+	if tag, rcpt, ok := email.ParseMessageID(c.MessageID); ok {
+		tags = append(tags, tag)
+		addr = rcpt
+	}
+	for _, rp := range []string{c.ReturnPath, c.OriginalMailFrom} {
+		if tag, rcpt, ok := email.ParseVERP(rp); ok {
+			tags = append(tags, tag)
+			if addr == "" {
+				addr = rcpt
+			}
+			break
+		}
+	}
+	for _, list := range [][]string{c.OriginalRcptTo, c.To} {
+		if addr == "" && len(list) == 1 && parser.IsValidEmail(list[0]) {
+			addr = list[0]
+		}
+	}
+
+	campaignID := ""
+	for _, tag := range tags {
+		if camp, ok := byTag[tag]; ok {
+			campaignID = camp.ID
+			break
+		}
+	}
+	if campaignID == "" {
+		campaignID = c.CampaignID
+	}
+	return addr, campaignID
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// explainSamples is how many matched and unmatched recipients
+// --filter-explain lists.
+const explainSamples = 5
+
+// ClauseStats counts the rows one top-level clause of a filter rejected.
+type ClauseStats struct {
+	Clause string
+	// Failed counts every row the clause rejects; Eliminated only those it
+	// is the first clause to reject, so the Eliminated counts add up to the
+	// rows the filter drops.
+	Failed     int
+	Eliminated int
+}
+
+// ExplainedRecipient is a sample recipient and, when it did not match, the
+// first clause it failed.
+type ExplainedRecipient struct {
+	Row    int
+	Email  string
+	Failed string
+}
+
+// FilterExplanation is how a filter treats a recipient list.
+type FilterExplanation struct {
+	Filter    string
+	Rows      int
+	Matched   int
+	Clauses   []ClauseStats
+	Matches   []ExplainedRecipient
+	Unmatched []ExplainedRecipient
+}
+
+// ExplainFilter reads every recipient of src and reports how many match
+// filter, how many rows each clause joined by && removes, and up to samples
+// matched and unmatched recipients. Columns are typed as in types.
+func ExplainFilter(src parser.RecipientSource, filter string, types map[string]parser.ColumnType, samples int) (*FilterExplanation, error) {
+	exp, err := parser.ParseTypedExpression(filter, types)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	clauses, err := parser.SplitClauses(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	report := &FilterExplanation{Filter: filter, Clauses: make([]ClauseStats, len(clauses))}
+	matchers := make([]func(parser.Recipient) bool, len(clauses))
+	for i, clause := range clauses {
+		ce, err := parser.ParseTypedExpression(clause, types)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter clause %q: %w", clause, err)
+		}
+		report.Clauses[i].Clause = clause
+		matchers[i] = parser.Matcher(ce)
+	}
+	match := parser.Matcher(exp)
+
+	for {
+		r, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read recipients: %w", err)
+		}
+		report.Rows++
+
+		first := -1
+		for i, m := range matchers {
+			if !m(r) {
+				report.Clauses[i].Failed++
+				if first < 0 {
+					first = i
+					report.Clauses[i].Eliminated++
+				}
+			}
+		}
+		sample := ExplainedRecipient{Row: r.Row + 1, Email: r.Email}
+		if match(r) {
+			report.Matched++
+			if len(report.Matches) < samples {
+				report.Matches = append(report.Matches, sample)
+			}
+			continue
+		}
+		if first >= 0 {
+			sample.Failed = clauses[first]
+		}
+		if len(report.Unmatched) < samples {
+			report.Unmatched = append(report.Unmatched, sample)
+		}
+	}
+	return report, nil
+}
+
+// WriteText prints the report for a terminal.
+func (r *FilterExplanation) WriteText(w io.Writer) {
+	pct := 0.0
+	if r.Rows > 0 {
+		pct = 100 * float64(r.Matched) / float64(r.Rows)
+	}
+	fmt.Fprintf(w, "Filter: %s\n", r.Filter)
+	fmt.Fprintf(w, "%d of %d recipients matched (%.1f%%); nothing was sent\n", r.Matched, r.Rows, pct)
+
+	if len(r.Clauses) > 1 {
+		fmt.Fprintf(w, "\n%-10s %-10s %s\n", "FAILED", "REMOVED", "CLAUSE")
+		for _, c := range r.Clauses {
+			fmt.Fprintf(w, "%-10d %-10d %s\n", c.Failed, c.Eliminated, c.Clause)
+		}
+		fmt.Fprintf(w, "REMOVED counts each row under the first clause it fails.\n")
+	}
+
+	for _, list := range []struct {
+		title string
+		rows  []ExplainedRecipient
+	}{{"Matched", r.Matches}, {"Not matched", r.Unmatched}} {
+		if len(list.rows) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s (first %d):\n", list.title, len(list.rows))
+		for _, s := range list.rows {
+			if s.Failed != "" {
+				fmt.Fprintf(w, "  row %-6d %-40s fails %s\n", s.Row, s.Email, s.Failed)
+			} else {
+				fmt.Fprintf(w, "  row %-6d %s\n", s.Row, s.Email)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+	"github.com/expr-lang/expr/file"
+)
+
+// typeSampleRows is how many recipients column types are inferred from.
+const typeSampleRows = 100
+
+// inferTypes is the --column-types entry that infers the types of the
+// columns not declared from the first rows.
+const inferTypes = "auto"
+
+// recipientFilter is a compiled --filter and the column types it was
+// compiled with.
+type recipientFilter struct {
+	expr  parser.Expression
+	types map[string]parser.ColumnType
+}
+
+// compileFilter compiles --filter with typed columns: those declared with
+// --column-types are typed as declared and the rest are strings, unless
+// "auto" asks for them to be inferred from the first rows of src. Fields
+// the filter uses must be among columns, the header of the list, or when
+// the list has none, appear in those rows. It returns a source still
+// yielding the rows it read.
+func compileFilter(args CLIArgs, src parser.RecipientSource, columns []string) (parser.RecipientSource, *recipientFilter, error) {
+	specs, infer := splitColumnTypes(args.ColumnTypes)
+	declared, err := parser.ParseColumnTypes(specs...)
+	if err != nil {
+		return src, nil, fmt.Errorf("invalid --column-types: %w", err)
+	}
+	src, sample, err := peekRecipients(src, typeSampleRows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	types := make(map[string]parser.ColumnType)
+	for _, col := range columns {
+		types[strings.ToLower(col)] = parser.TypeString
+	}
+	for _, r := range sample {
+		for col := range r.Data {
+			types[strings.ToLower(col)] = parser.TypeString
+		}
+	}
+	var inferred []string
+	if infer {
+		for col, t := range parser.InferColumnTypes(sample) {
+			types[col] = t
+			if _, ok := declared[col]; !ok && t != parser.TypeString {
+				inferred = append(inferred, col+"="+string(t))
+			}
+		}
+	}
+	for col, t := range declared {
+		types[col] = t
+	}
+
+	expr, err := parser.ParseTypedExpression(args.Filter, types)
+	if err != nil {
+		// Only expr's own errors, from type checking, can be due to types.
+		var compileErr *file.Error
+		switch {
+		case !errors.As(err, &compileErr):
+		case len(inferred) > 0:
+			sort.Strings(inferred)
+			err = fmt.Errorf("%w (types inferred from the data: %s; override them with --column-types)", err, strings.Join(inferred, ", "))
+		case !infer:
+			err = fmt.Errorf("%w (columns not declared with --column-types, such as age=int, are strings; --column-types auto infers them)", err)
+		}
+		return src, nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	// Rows of lists without a header, such as JSON, need not share their
+	// fields, so a field missing from the first rows is only suspicious.
+	switch {
+	case columns != nil:
+		if err := parser.CheckFields(expr, columns); err != nil {
+			return src, nil, err
+		}
+	case len(sample) > 0:
+		if err := parser.ValidateFields(expr, sample); err != nil {
+			log.Printf("⚠️ Warning: %v (checked against the first %d rows)", err, len(sample))
+		}
+	}
+	return src, &recipientFilter{expr: expr, types: types}, nil
+}
+
+// splitColumnTypes separates the "auto" entries of --column-types from the
+// declarations and reports whether there were any.
+func splitColumnTypes(specs []string) ([]string, bool) {
+	var out []string
+	infer := false
+	for _, spec := range specs {
+		var pairs []string
+		for _, pair := range strings.Split(spec, ",") {
+			if strings.EqualFold(strings.TrimSpace(pair), inferTypes) {
+				infer = true
+				continue
+			}
+			pairs = append(pairs, pair)
+		}
+		out = append(out, strings.Join(pairs, ","))
+	}
+	return out, infer
+}
+
+// sourceColumns returns the header of src, or nil when it has none.
+func sourceColumns(src parser.RecipientSource) []string {
+	if t, ok := src.(interface{ Columns() []string }); ok {
+		return t.Columns()
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package cli
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bravo1goingdark/mailgrid/database"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// Groups a recipient can land in after --holdout, --sample, --ab-test and
+// --limit. Only the selected group is sent to; the rollout group waits for
+// the winner of the A/B test.
+const (
+	GroupSelected  = "selected"
+	GroupHoldout   = "holdout"
+	GroupUnsampled = "unsampled"
+	GroupRollout   = "rollout"
+	GroupOverLimit = "over_limit"
+)
+
+// groupBatchSize is how many group memberships are saved per transaction.
+const groupBatchSize = 1000
+
+// ParseFraction parses a --sample or --holdout value, either a percentage
+// such as "5%" or a fraction such as "0.05". It must lie between 0 and 1.
+func ParseFraction(s string) (float64, error) {
+	v := strings.TrimSpace(s)
+	scale := 1.0
+	if strings.HasSuffix(v, "%") {
+		v, scale = strings.TrimSpace(strings.TrimSuffix(v, "%")), 100
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || math.IsNaN(f) {
+		return 0, fmt.Errorf("%q is not a percentage or fraction, e.g. 5%% or 0.05", s)
+	}
+	f /= scale
+	if f < 0 || f > 1 {
+		return 0, fmt.Errorf("%q is not between 0%% and 100%%", s)
+	}
+	return f, nil
+}
+
+// RecipientBucket places email at a point in [0, 1) that depends only on the
+// address, case-insensitively, and salt. The same recipient therefore lands
+// in the same group in every run, and different salts give independent
+// groups.
+func RecipientBucket(salt, email string) float64 {
+	sum := sha256.Sum256([]byte(salt + "\x00" + strings.ToLower(strings.TrimSpace(email))))
+	return float64(binary.BigEndian.Uint64(sum[:8])) / (1 << 64)
+}
+
+// groupFilter assigns recipients to the groups of --holdout, --sample,
+// --ab-test and --limit in that order: the holdout is set aside first, the
+// sample is drawn from the rest, the A/B test takes its share of the sample,
+// and the limit caps what is left.
+type groupFilter struct {
+	holdout float64 // 0 when --holdout is not set
+	sample  float64 // 1 when --sample is not set
+	test    float64 // 1 when --ab-test is not set
+	limit   int     // 0 when --limit is not set
+
+	counts map[string]int
+
+	// When save is set, held-out and selected recipients are saved to db,
+	// in batches, once the campaign has an ID.
+	save       bool
+	db         *campaignDB
+	campaignID string
+	pending    []types.GroupMember
+}
+
+// newGroupFilter returns a filter for the group options of args, or nil
+// when none is set.
+func newGroupFilter(args CLIArgs) (*groupFilter, error) {
+	if args.Holdout == "" && args.Sample == "" && args.ABTest == "" && args.Limit == 0 {
+		return nil, nil
+	}
+	if args.Limit < 0 {
+		return nil, fmt.Errorf("invalid --limit %d: must be positive", args.Limit)
+	}
+	g := &groupFilter{sample: 1, test: 1, limit: args.Limit, counts: make(map[string]int)}
+	var err error
+	if args.Holdout != "" {
+		if g.holdout, err = ParseFraction(args.Holdout); err != nil {
+			return nil, fmt.Errorf("invalid --holdout: %w", err)
+		}
+	}
+	if args.Sample != "" {
+		if g.sample, err = ParseFraction(args.Sample); err != nil {
+			return nil, fmt.Errorf("invalid --sample: %w", err)
+		}
+	}
+	if args.ABTest != "" {
+		if g.test, err = ParseFraction(args.ABTest); err != nil {
+			return nil, fmt.Errorf("invalid --ab-test: %w", err)
+		}
+	}
+	return g, nil
+}
+
+// group returns the group of the next recipient read.
+func (g *groupFilter) group(r parser.Recipient) string {
+	switch {
+	case g.holdout > 0 && RecipientBucket(GroupHoldout, r.Email) < g.holdout:
+		return GroupHoldout
+	case g.sample < 1 && RecipientBucket("sample", r.Email) >= g.sample:
+		return GroupUnsampled
+	case g.test < 1 && RecipientBucket("ab-test", r.Email) >= g.test:
+		return GroupRollout
+	case g.limit > 0 && g.counts[GroupSelected] >= g.limit:
+		return GroupOverLimit
+	}
+	return GroupSelected
+}
+
+// keep reports whether r is selected, counting and recording its group.
+func (g *groupFilter) keep(r parser.Recipient) bool {
+	group := g.group(r)
+	g.counts[group]++
+	if g.save && (group == GroupHoldout || group == GroupSelected) {
+		g.pending = append(g.pending, types.GroupMember{Email: r.Email, Group: group})
+		if len(g.pending) >= groupBatchSize {
+			g.flush()
+		}
+	}
+	return group == GroupSelected
+}
+
+// record makes memberships be saved to db under campaignID. Until it is
+// called they are held in memory.
+func (g *groupFilter) record(db *campaignDB, campaignID string) {
+	g.db, g.campaignID = db, campaignID
+}
+
+// flush saves the memberships read so far, when recording.
+func (g *groupFilter) flush() {
+	if g.db == nil || len(g.pending) == 0 {
+		return
+	}
+	for i := range g.pending {
+		g.pending[i].CampaignID = g.campaignID
+	}
+	err := g.db.with(func(db *database.BoltDBClient) error { return db.SaveGroupMembers(g.pending) })
+	if err != nil {
+		log.Printf("⚠️ Warning: failed to record recipient groups of %s: %v", g.campaignID, err)
+	}
+	g.pending = g.pending[:0]
+}
+
+// summary returns the recipients in each group, or nil without a filter.
+func (g *groupFilter) summary() map[string]int {
+	if g == nil {
+		return nil
+	}
+	return g.counts
+}
+
+// printGroups reports how --holdout, --sample, --ab-test and --limit split
+// the list.
+func printGroups(g *groupFilter) {
+	if g == nil {
+		return
+	}
+	var parts []string
+	for _, group := range []string{GroupSelected, GroupHoldout, GroupUnsampled, GroupRollout, GroupOverLimit} {
+		if n := g.counts[group]; n > 0 || group == GroupSelected {
+			parts = append(parts, fmt.Sprintf("%d %s", n, strings.ReplaceAll(group, "_", " ")))
+		}
+	}
+	fmt.Printf(" Recipient groups: %s\n", strings.Join(parts, ", "))
+}
@@ -0,0 +1,122 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// joinOptions validates the --join and --join-conflict flags.
+func joinOptions(args CLIArgs) (parser.JoinOptions, error) {
+	mode, err := parser.ParseJoinMode(args.JoinMode)
+	if err != nil {
+		return parser.JoinOptions{}, fmt.Errorf("invalid --join: %w", err)
+	}
+	conflict, err := parser.ParseConflictRule(args.JoinConflict)
+	if err != nil {
+		return parser.JoinOptions{}, fmt.Errorf("invalid --join-conflict: %w", err)
+	}
+	if len(args.JoinPaths) == 0 && (args.JoinMode != "" || args.JoinConflict != "" || args.JoinReport != "") {
+		return parser.JoinOptions{}, fmt.Errorf("--join, --join-conflict and --unmatched-report need a second --csv")
+	}
+	for _, path := range args.JoinPaths {
+		if path == "-" {
+			return parser.JoinOptions{}, fmt.Errorf("only the first --csv can be read from stdin")
+		}
+	}
+	return parser.JoinOptions{Mode: mode, Conflict: conflict}, nil
+}
+
+// joinedSource is a join of recipient lists. Closing it logs the match
+// counts and finishes the unmatched report.
+type joinedSource struct {
+	*parser.JoinSource
+	mode   parser.JoinMode
+	report *os.File
+	writer *csv.Writer
+}
+
+func (j joinedSource) Close() error {
+	err := j.JoinSource.Close()
+	stats := j.Stats()
+	log.Printf("Join (%s): %d rows matched in every file, %d primary rows without a match, %d rows only in joined files",
+		j.mode, stats.Matched, stats.PrimaryUnmatched, stats.SecondaryUnmatched)
+	if j.report != nil {
+		j.writer.Flush()
+		if flushErr := j.writer.Error(); flushErr != nil && err == nil {
+			err = fmt.Errorf("failed to write unmatched report: %w", flushErr)
+		}
+		if closeErr := j.report.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// joinRecipientSources joins the --csv files after the first onto primary.
+// It does not close primary on error.
+func joinRecipientSources(args CLIArgs, primary parser.CountingSource, opts parser.ReadOptions) (parser.CountingSource, error) {
+	jopts, err := joinOptions(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// --sheet selects a worksheet of the first file only.
+	opts.Sheet = ""
+	others := make([]parser.JoinInput, 0, len(args.JoinPaths))
+	closeOthers := func() {
+		for _, in := range others {
+			in.Source.Close()
+		}
+	}
+	for _, path := range args.JoinPaths {
+		src, err := parser.OpenFile(path, opts)
+		if err != nil {
+			closeOthers()
+			return nil, fmt.Errorf("failed to open %s: %w", path, err)
+		}
+		others = append(others, parser.JoinInput{Name: path, Source: src})
+	}
+
+	joined := joinedSource{mode: jopts.Mode}
+	if args.JoinReport != "" {
+		f, err := os.Create(args.JoinReport)
+		if err != nil {
+			closeOthers()
+			return nil, fmt.Errorf("failed to create unmatched report: %w", err)
+		}
+		joined.report, joined.writer = f, csv.NewWriter(f)
+		joined.writer.Write([]string{"source", "row", "email", "missing_from"})
+		jopts.Unmatched = func(u parser.UnmatchedRow) {
+			joined.writer.Write([]string{u.Source, strconv.Itoa(u.Row + 1), u.Email, u.MissingFrom})
+		}
+	}
+
+	name := primaryName(args)
+	joined.JoinSource, err = parser.JoinSources(parser.JoinInput{Name: name, Source: primary}, others, jopts)
+	if err != nil {
+		if joined.report != nil {
+			joined.report.Close()
+			os.Remove(args.JoinReport)
+		}
+		return nil, fmt.Errorf("failed to join recipient lists: %w", err)
+	}
+	return joined, nil
+}
+
+// primaryName names the first recipient list in join reports.
+func primaryName(args CLIArgs) string {
+	switch {
+	case args.Query != "":
+		return "query"
+	case args.SourceURL != "":
+		return args.SourceURL
+	case args.SheetURL != "":
+		return args.SheetURL
+	}
+	return args.CSVPath
+}
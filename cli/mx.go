@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/config"
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// defaultDNSTimeout limits each domain lookup when the config sets none.
+const defaultDNSTimeout = 5 * time.Second
+
+// mxFilter checks each recipient's domain for a mail exchanger before its
+// email is rendered. Every domain is looked up once.
+type mxFilter struct {
+	ctx     context.Context
+	checker *parser.DomainChecker
+	exclude bool
+	summary mxSummary
+}
+
+// mxSummary counts the recipients at domains that cannot receive mail.
+type mxSummary struct {
+	Recipients int                            // recipients at such domains
+	Domains    map[string]parser.DomainStatus // undeliverable and unresolved domains
+	Unknown    int                            // distinct domains whose lookup failed
+}
+
+// checkMXMode validates --mx-check. It returns whether undeliverable
+// domains are excluded; an empty mode disables the check.
+func checkMXMode(mode string) (exclude bool, err error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", "report":
+		return false, nil
+	case "exclude":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid --mx-check %q (use report or exclude)", mode)
+}
+
+// newMXFilter returns a filter using the resolver configured in cfg, or nil
+// when --mx-check is not set.
+func newMXFilter(ctx context.Context, mode string, cfg config.DNSConfig) (*mxFilter, error) {
+	exclude, err := checkMXMode(mode)
+	if err != nil || mode == "" {
+		return nil, err
+	}
+	timeout := defaultDNSTimeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	return &mxFilter{
+		ctx:     ctx,
+		checker: parser.NewDomainChecker(newResolver(cfg.Server), timeout),
+		exclude: exclude,
+		summary: mxSummary{Domains: make(map[string]parser.DomainStatus)},
+	}, nil
+}
+
+// newResolver returns a resolver sending every query to server, or the
+// system resolver when server is empty. A server without a port uses 53.
+func newResolver(server string) parser.MXResolver {
+	if server == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// keep reports whether r may be sent to, logging each undeliverable domain
+// the first time it is seen.
+func (f *mxFilter) keep(r parser.Recipient) bool {
+	domain, status := f.checker.CheckAddress(f.ctx, r.Email)
+	if status == parser.DomainUnknown {
+		if _, seen := f.summary.Domains[domain]; !seen {
+			f.summary.Domains[domain] = status
+			f.summary.Unknown++
+			log.Printf("⚠️ Warning: MX lookup for %s failed; sending to it anyway", domain)
+		}
+		return true
+	}
+	if status.Deliverable() {
+		return true
+	}
+
+	f.summary.Recipients++
+	if _, seen := f.summary.Domains[domain]; !seen {
+		f.summary.Domains[domain] = status
+		action := "reporting"
+		if f.exclude {
+			action = "excluding"
+		}
+		log.Printf("⚠️ Warning: %s cannot receive mail (%s); %s its recipients", domain, describeDomainStatus(status), action)
+	}
+	return !f.exclude
+}
+
+func describeDomainStatus(s parser.DomainStatus) string {
+	switch s {
+	case parser.DomainNullMX:
+		return "null MX"
+	case parser.DomainNoMailHost:
+		return "no MX or address record"
+	}
+	return string(s)
+}
+
+// printMXSummary reports what the MX check found.
+func printMXSummary(f *mxFilter) {
+	if f == nil || f.summary.Recipients == 0 {
+		return
+	}
+	var domains []string
+	for d, s := range f.summary.Domains {
+		if !s.Deliverable() {
+			domains = append(domains, d)
+		}
+	}
+	sort.Strings(domains)
+	n := len(domains)
+	if n > 10 {
+		domains = append(domains[:10], fmt.Sprintf("and %d more", n-10))
+	}
+	verb := "Found"
+	if f.exclude {
+		verb = "Excluded"
+	}
+	fmt.Printf(" %s %d recipient(s) at %d domain(s) without a mail exchanger: %s\n",
+		verb, f.summary.Recipients, n, strings.Join(domains, ", "))
+}
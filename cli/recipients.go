@@ -0,0 +1,278 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bravo1goingdark/mailgrid/config"
+	"github.com/bravo1goingdark/mailgrid/email"
+	"github.com/bravo1goingdark/mailgrid/monitor"
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// sheetSource is a CSV source read from a Google Sheet export. Closing it
+// closes the HTTP response body.
+type sheetSource struct {
+	parser.CountingSource
+	body io.Closer
+}
+
+func (s sheetSource) Close() error { return s.body.Close() }
+
+// openRecipientSource opens the recipient list named by args as a stream,
+// joining any further --csv files onto it. The returned CountingSource
+// reports row and skip counts once the stream has been read. cfg supplies
+// the database, download and Google settings.
+func openRecipientSource(args CLIArgs, cfg *config.AppConfig) (parser.RecipientSource, parser.CountingSource, error) {
+	opts, err := readOptions(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	src, err := openPrimarySource(args, cfg, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(args.JoinPaths) == 0 {
+		return src, src, nil
+	}
+	joined, err := joinRecipientSources(args, src, opts)
+	if err != nil {
+		src.Close()
+		return nil, nil, err
+	}
+	return joined, joined, nil
+}
+
+// openPrimarySource opens the one recipient list named by --csv, --query,
+// --source-url or --sheet-url.
+func openPrimarySource(args CLIArgs, cfg *config.AppConfig, opts parser.ReadOptions) (parser.CountingSource, error) {
+	switch {
+	case args.Query != "":
+		db := cfg.Database
+		if db.DSN == "" {
+			return nil, fmt.Errorf("--query requires a \"database\" section with a dsn in the config file")
+		}
+		src, err := parser.OpenSQL(db.Driver, db.DSN, args.Query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query recipients: %w", err)
+		}
+		return src, nil
+
+	case args.SourceURL != "":
+		urlOpts, err := urlOptions(args, cfg.Source)
+		if err != nil {
+			return nil, err
+		}
+		src, err := parser.OpenURL(args.SourceURL, urlOpts, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch recipient list: %w", err)
+		}
+		return src, nil
+
+	case args.SheetURL == "":
+		src, err := parser.OpenFile(args.CSVPath, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open recipient list: %w", err)
+		}
+		return src, nil
+
+	case cfg.Google.ServiceAccountFile != "":
+		src, err := parser.OpenPrivateSheet(args.SheetURL, parser.GoogleSheetsOptions{
+			CredentialsFile: cfg.Google.ServiceAccountFile,
+			TokenURL:        cfg.Google.TokenURL,
+			APIBaseURL:      cfg.Google.SheetsAPIURL,
+		}, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Google Sheet: %w", err)
+		}
+		id, gid, _ := parser.ExtractSheetInfo(args.SheetURL)
+		fmt.Printf(" Loaded private Google Sheet: Spreadsheet ID = %s, GID = %s\n", id, gid)
+		return src, nil
+	}
+
+	stream, err := parser.GetSheetCSVStream(args.SheetURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Google Sheet: %w", err)
+	}
+	src, err := parser.NewCSVSourceWithOptions(stream, opts)
+	if err != nil {
+		stream.Close()
+		return nil, fmt.Errorf("failed to parse Google Sheet as CSV: %w", err)
+	}
+	id, gid, _ := parser.ExtractSheetInfo(args.SheetURL)
+	fmt.Printf(" Loaded Google Sheet: Spreadsheet ID = %s, GID = %s\n", id, gid)
+	return sheetSource{CountingSource: src, body: stream}, nil
+}
+
+// urlOptions combines the config's download settings with --source-header
+// flags, which override config headers of the same name.
+func urlOptions(args CLIArgs, cfg config.SourceConfig) (parser.URLOptions, error) {
+	o := parser.URLOptions{
+		AllowedHosts: cfg.AllowedHosts,
+		Headers:      make(map[string]string, len(cfg.Headers)+len(args.SourceHeaders)),
+		BearerToken:  cfg.BearerToken,
+	}
+	for k, v := range cfg.Headers {
+		o.Headers[k] = v
+	}
+	for _, h := range args.SourceHeaders {
+		name, value, ok := strings.Cut(h, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return o, fmt.Errorf("invalid --source-header %q: expected \"Name: value\"", h)
+		}
+		o.Headers[name] = strings.TrimSpace(value)
+	}
+	if len(o.AllowedHosts) == 0 {
+		return o, fmt.Errorf("--source-url requires source.allowed_hosts in the config file")
+	}
+
+	if !cfg.NoCache {
+		o.CacheDir = cfg.CacheDir
+		if o.CacheDir == "" {
+			if dir, err := os.UserCacheDir(); err == nil {
+				o.CacheDir = filepath.Join(dir, "mailgrid", "sources")
+			}
+		}
+	}
+	return o, nil
+}
+
+// readOptions builds the parser options from the input format flags.
+func readOptions(args CLIArgs) (parser.ReadOptions, error) {
+	opts := parser.ReadOptions{
+		Sheet:       args.Sheet,
+		Encoding:    args.Encoding,
+		EmailColumn: strings.TrimSpace(args.EmailColumn),
+	}
+
+	var err error
+	if opts.Delimiter, err = flagRune("--delimiter", args.Delimiter); err != nil {
+		return opts, err
+	}
+	if strings.EqualFold(args.Quote, "none") {
+		opts.Quote = parser.NoQuote
+	} else if opts.Quote, err = flagRune("--quote", args.Quote); err != nil {
+		return opts, err
+	}
+
+	for _, spec := range args.RenameColumns {
+		from, to, ok := strings.Cut(spec, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return opts, fmt.Errorf("invalid --rename-column %q: expected old=new", spec)
+		}
+		if opts.Rename == nil {
+			opts.Rename = make(map[string]string)
+		}
+		if _, dup := opts.Rename[strings.ToLower(from)]; dup {
+			return opts, fmt.Errorf("duplicate --rename-column for %q", from)
+		}
+		opts.Rename[strings.ToLower(from)] = to
+	}
+
+	if err := opts.Validate(); err != nil {
+		return opts, fmt.Errorf("invalid input format: %w", err)
+	}
+	return opts, nil
+}
+
+// flagRune parses a single-character flag value. "tab" and "\t" name the
+// tab character, which is awkward to pass on a command line.
+func flagRune(flag, value string) (rune, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return 0, nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	if utf8.RuneCountInString(value) != 1 {
+		return 0, fmt.Errorf("invalid %s %q: expected a single character", flag, value)
+	}
+	r, _ := utf8.DecodeRuneInString(value)
+	return r, nil
+}
+
+// peekedSource yields recipients read ahead of time before the rest of src.
+type peekedSource struct {
+	ahead []parser.Recipient
+	parser.RecipientSource
+}
+
+func (p *peekedSource) Next() (parser.Recipient, error) {
+	if len(p.ahead) > 0 {
+		r := p.ahead[0]
+		p.ahead = p.ahead[1:]
+		return r, nil
+	}
+	return p.RecipientSource.Next()
+}
+
+// peekRecipient reads the first recipient of src and returns a source that
+// still yields it. ok is false when src is empty.
+func peekRecipient(src parser.RecipientSource) (parser.RecipientSource, parser.Recipient, bool, error) {
+	src, ahead, err := peekRecipients(src, 1)
+	if err != nil || len(ahead) == 0 {
+		return src, parser.Recipient{}, false, err
+	}
+	return src, ahead[0], true, nil
+}
+
+// peekRecipients reads up to n recipients of src and returns a source that
+// still yields them.
+func peekRecipients(src parser.RecipientSource, n int) (parser.RecipientSource, []parser.Recipient, error) {
+	var ahead []parser.Recipient
+	for len(ahead) < n {
+		r, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read recipients: %w", err)
+		}
+		ahead = append(ahead, r)
+	}
+	if len(ahead) == 0 {
+		return src, nil, nil
+	}
+	return &peekedSource{ahead: ahead, RecipientSource: src}, ahead, nil
+}
+
+// announcePending forwards tasks from in, registering each recipient with
+// the monitor as it is queued, with its A/B variant when the monitor tracks
+// them. The dashboard total therefore grows while the list is streamed
+// instead of being known up front.
+func announcePending(ctx context.Context, in <-chan email.Task, mon monitor.Monitor) <-chan email.Task {
+	out := make(chan email.Task, cap(in))
+	go func() {
+		defer close(out)
+		variants, _ := mon.(interface{ AssignVariant(email, variant string) })
+		for t := range in {
+			mon.InitializePending([]string{t.Recipient.Email})
+			if t.Variant != "" && variants != nil {
+				variants.AssignVariant(t.Recipient.Email, t.Variant)
+			}
+			select {
+			case out <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// logSourceSummary reports what reading the recipient list skipped.
+func logSourceSummary(src parser.CountingSource, deduped *parser.DedupedSource) {
+	if src.Skipped() > 0 || deduped.Duplicates() > 0 {
+		log.Printf("Recipient list: %d rows skipped, %d duplicates removed (total rows: %d)",
+			src.Skipped(), deduped.Duplicates(), src.Rows())
+	}
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bravo1goingdark/mailgrid/logger"
+	"github.com/bravo1goingdark/mailgrid/parser"
+	"github.com/spf13/pflag"
+)
+
+// runRetryCommand implements `mailgrid retry`. It accepts every campaign flag
+// plus --from-failed and --campaign, and runs the normal Run pipeline over
+// the subset of the original list that failed transiently.
+func runRetryCommand(argv []string) error {
+	args, _, help, err := parseCommandFlags("retry", argv, true, func(fs *pflag.FlagSet, a *CLIArgs) {
+		fs.StringVar(&a.RetryFrom, "from-failed", logger.FailedLogFile, "Failure log to rebuild the recipient subset from")
+		fs.StringVar(&a.RetryCampaign, "campaign", "", "Only retry failures logged under this job ID")
+	})
+	if err != nil || help {
+		return err
+	}
+	if args.To != "" {
+		return fmt.Errorf("retry rebuilds recipients from the original list; --to is not supported")
+	}
+	return Run(args)
+}
+
+// RetrySelection summarises how a failure log was joined to a recipient list.
+type RetrySelection struct {
+	Recipients  []parser.Recipient
+	ParentJobID string // campaign the failures came from, when unambiguous
+	Transient   int    // distinct addresses eligible for retry
+	Bounced     int    // distinct addresses skipped as hard bounces (5xx)
+	Missing     int    // eligible addresses not present in the recipient list
+}
+
+// isHardBounce reports whether an SMTP code is a permanent (5xx) rejection.
+// Empty codes (connection errors, legacy rows) are treated as transient.
+func isHardBounce(code string) bool {
+	return strings.HasPrefix(code, "5")
+}
+
+// SelectRetryRecipients filters recipients down to the addresses recorded as
+// transient failures in failures. When campaignID is set, only failures
+// logged under that job ID are considered. If an address appears more than
+// once, its most recent row decides whether it is retried.
+func SelectRetryRecipients(recipients []parser.Recipient, failures []logger.FailureRecord, campaignID string) RetrySelection {
+	sel, eligible := retryEligible(failures, campaignID)
+	found := make(map[string]struct{}, len(eligible))
+	for _, r := range recipients {
+		key := strings.ToLower(r.Email)
+		if _, ok := eligible[key]; ok {
+			sel.Recipients = append(sel.Recipients, r)
+			found[key] = struct{}{}
+		}
+	}
+	sel.Missing = len(eligible) - len(found)
+	return sel
+}
+
+// retryEligible tallies failures and returns the lower-cased addresses that
+// qualify for a retry. Recipients and Missing are left for the caller.
+func retryEligible(failures []logger.FailureRecord, campaignID string) (RetrySelection, map[string]struct{}) {
+	latest := make(map[string]logger.FailureRecord)
+	jobIDs := make(map[string]struct{})
+	for _, f := range failures {
+		if campaignID != "" && f.JobID != campaignID {
+			continue
+		}
+		latest[strings.ToLower(f.Email)] = f
+		if f.JobID != "" {
+			jobIDs[f.JobID] = struct{}{}
+		}
+	}
+
+	var sel RetrySelection
+	eligible := make(map[string]struct{}, len(latest))
+	for key, f := range latest {
+		if isHardBounce(f.Code) {
+			sel.Bounced++
+			continue
+		}
+		eligible[key] = struct{}{}
+	}
+	sel.Transient = len(eligible)
+
+	switch {
+	case campaignID != "":
+		sel.ParentJobID = campaignID
+	case len(jobIDs) == 1:
+		for id := range jobIDs {
+			sel.ParentJobID = id
+		}
+	}
+	return sel, eligible
+}
+
+// applyRetrySelection narrows src for a `mailgrid retry` run and returns the
+// originating job ID. The retry subset is small, so it is read into memory;
+// rows of the returned source are numbered within that subset.
+func applyRetrySelection(args CLIArgs, src parser.RecipientSource) (parser.RecipientSource, int, string, error) {
+	path := args.RetryFrom
+	if path == "" {
+		path = logger.FailedLogFile
+	}
+	failures, err := logger.ReadFailures(path)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to read failure log: %w", err)
+	}
+
+	sel, eligible := retryEligible(failures, args.RetryCampaign)
+	found := make(map[string]struct{}, len(eligible))
+	recipients, err := parser.Collect(parser.FilterSource(src, func(r parser.Recipient) bool {
+		key := strings.ToLower(r.Email)
+		if _, ok := eligible[key]; !ok {
+			return false
+		}
+		found[key] = struct{}{}
+		return true
+	}))
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("failed to read recipients: %w", err)
+	}
+	sel.Missing = len(eligible) - len(found)
+
+	log.Printf("Retry: %d transient failure(s), %d hard bounce(s) skipped, %d not found in the recipient list",
+		sel.Transient, sel.Bounced, sel.Missing)
+	if len(recipients) == 0 {
+		return nil, 0, "", fmt.Errorf("no retryable recipients found in %s", path)
+	}
+	return parser.SliceSource(recipients), len(recipients), sel.ParentJobID, nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
@@ -30,7 +29,7 @@ const maxAttachSize = 10 << 20 // 10 MB
 
 // Run is the main orchestration function. It controls the full Mailgrid lifecycle:
 // 1. Load config
-// 2. Parse CSV or Google Sheet
+// 2. Parse CSV, spreadsheet or Google Sheet
 // 3. Apply optional filter
 // 4. Preview or send emails
 func Run(args CLIArgs) error {
@@ -64,6 +63,43 @@ func Run(args CLIArgs) error {
 		return cancelScheduledJob(args.DBPath, args.EnvPath, args.CancelJobID)
 	}
 
+	// --input names the recipient file without implying CSV; both flags
+	// pick the reader by file extension, or by content for stdin ("-").
+	if args.InputPath != "" {
+		if args.CSVPath != "" {
+			return fmt.Errorf("provide only one of --csv or --input")
+		}
+		args.CSVPath = args.InputPath
+	}
+	if args.Sheet != "" && args.CSVPath == "" {
+		return fmt.Errorf("--sheet requires an .xlsx or .ods file via --csv or --input")
+	}
+	if _, err := readOptions(args); err != nil {
+		return err
+	}
+	if len(args.ColumnTypes) > 0 && args.Filter == "" {
+		return fmt.Errorf("--column-types applies to --filter, which is not set")
+	}
+	if args.FilterExplain && args.Filter == "" {
+		return fmt.Errorf("--filter-explain requires --filter")
+	}
+	if args.FilterExplain && args.SchedulerRun {
+		return fmt.Errorf("--filter-explain cannot be scheduled; run it without --scheduler-run")
+	}
+	if _, err := checkMXMode(args.MXCheck); err != nil {
+		return err
+	}
+	if _, err := newGroupFilter(args); err != nil {
+		return err
+	}
+	plan, err := parseABPlan(args)
+	if err != nil {
+		return err
+	}
+	if _, err := joinOptions(args); err != nil {
+		return err
+	}
+
 	// Run scheduler dispatcher in foreground
 	if args.SchedulerRun {
 		// Load SMTP config for the scheduler
@@ -71,6 +107,15 @@ func Run(args CLIArgs) error {
 		if err != nil {
 			return fmt.Errorf("failed to load SMTP config: %w", err)
 		}
+		if _, err := ParseUTM(args.UTM); err != nil {
+			return err
+		}
+		if args.CSVPath == "-" {
+			return fmt.Errorf("recipients read from stdin cannot be scheduled; pass a file path")
+		}
+		if args.Query != "" && smtpConfig.Database.DSN == "" {
+			return fmt.Errorf("--query requires a \"database\" section with a dsn in the config file")
+		}
 
 		// Configure optimized scheduler manager
 		config := scheduler.DefaultOptimizedConfig()
@@ -107,22 +152,63 @@ func Run(args CLIArgs) error {
 					Bcc:          a.Bcc,
 					RetryLimit:   a.RetryLimit,
 				}
-				return SendSingleEmail(cliArgs, smtpConfig.SMTP)
+				if err := checkSuppressed(args.DBPath, a.To, a.List, a.NoSuppress); err != nil {
+					return err
+				}
+				signer, err := newUnsubscribeSigner(smtpConfig.Unsubscribe)
+				if err != nil {
+					return err
+				}
+				utm, err := ParseUTM(a.UTM)
+				if err != nil {
+					return err
+				}
+				return SendSingleEmail(cliArgs, smtpConfig.SMTP, UnsubscribeLinks(signer, a.List, ""), UTMParams(utm))
 			} else {
 				// Bulk email
 				cliArgs := CLIArgs{
-					EnvPath:      a.EnvPath,
-					CSVPath:      a.CSVPath,
-					SheetURL:     a.SheetURL,
-					TemplatePath: a.Template,
-					Subject:      a.Subject,
-					Attachments:  a.Attachments,
-					Cc:           a.Cc,
-					Bcc:          a.Bcc,
-					Concurrency:  a.Concurrency,
-					RetryLimit:   a.RetryLimit,
-					BatchSize:    a.BatchSize,
-					Filter:       a.Filter,
+					EnvPath:            a.EnvPath,
+					CSVPath:            a.CSVPath,
+					JoinPaths:          a.Join,
+					JoinMode:           a.JoinMode,
+					JoinConflict:       a.JoinConflict,
+					JoinReport:         a.JoinReport,
+					Sheet:              a.Sheet,
+					SheetURL:           a.SheetURL,
+					Query:              a.Query,
+					SourceURL:          a.SourceURL,
+					SourceHeaders:      a.Headers,
+					Delimiter:          a.Delimiter,
+					Quote:              a.Quote,
+					Encoding:           a.Encoding,
+					EmailColumn:        a.EmailColumn,
+					RenameColumns:      a.Rename,
+					TemplatePath:       a.Template,
+					TemplateVariants:   a.Templates,
+					Subject:            a.Subject,
+					SubjectVariants:    a.Subjects,
+					VariantWeights:     a.Weights,
+					ABTest:             a.ABTest,
+					ABMetric:           a.ABMetric,
+					ABWait:             a.ABWait,
+					ABRollout:          a.ABRollout,
+					ABWinner:           a.ABWinner,
+					Attachments:        a.Attachments,
+					Cc:                 a.Cc,
+					Bcc:                a.Bcc,
+					Concurrency:        a.Concurrency,
+					RetryLimit:         a.RetryLimit,
+					BatchSize:          a.BatchSize,
+					Filter:             a.Filter,
+					ColumnTypes:        a.ColumnTypes,
+					List:               a.List,
+					IgnoreSuppressions: a.NoSuppress,
+					MXCheck:            a.MXCheck,
+					Sample:             a.Sample,
+					Limit:              a.Limit,
+					Holdout:            a.Holdout,
+					UTM:                a.UTM,
+					DBPath:             args.DBPath,
 				}
 				return Run(cliArgs)
 			}
@@ -143,32 +229,17 @@ func Run(args CLIArgs) error {
 			runAt = time.Now()
 		}
 
-		// Create job payload
-		payload := types.CLIArgs{
-			EnvPath:     args.EnvPath,
-			To:          args.To,
-			Subject:     args.Subject,
-			Text:        args.Text,
-			Template:    args.TemplatePath,
-			CSVPath:     args.CSVPath,
-			SheetURL:    args.SheetURL,
-			Attachments: args.Attachments,
-			Cc:          args.Cc,
-			Bcc:         args.Bcc,
-			Concurrency: args.Concurrency,
-			RetryLimit:  args.RetryLimit,
-			BatchSize:   args.BatchSize,
-			Filter:      args.Filter,
-			ScheduleAt:  args.ScheduleAt,
-			Interval:    args.Interval,
-			Cron:        args.Cron,
-			JobRetries:  args.JobRetries,
-		}
+		payload := jobPayload(args)
 
 		// Schedule the job (this will auto-start the scheduler)
 		if err := manager.ScheduleJob(payload, runAt, args.Cron, args.Interval, handler); err != nil {
 			return fmt.Errorf("failed to schedule job: %w", err)
 		}
+		// Jobs stored by earlier runs, such as A/B rollouts, use the same
+		// handler.
+		if err := manager.ReattachHandlers(handler); err != nil {
+			return err
+		}
 
 		scheduleInfo := ""
 		if args.ScheduleAt != "" {
@@ -208,18 +279,43 @@ func Run(args CLIArgs) error {
 	if args.BatchSize < 1 {
 		args.BatchSize = 1
 	}
+	unsubSigner, err := newUnsubscribeSigner(cfg.Unsubscribe)
+	if err != nil {
+		return err
+	}
+	for _, v := range plan.variants {
+		if err := checkUnsubscribeTemplate(unsubSigner, v.TemplatePath); err != nil {
+			return err
+		}
+	}
+	trackSigner, err := newTrackingSigner(cfg.Tracking)
+	if err != nil {
+		return err
+	}
+	utm, err := ParseUTM(args.UTM)
+	if err != nil {
+		return err
+	}
 	if args.To != "" {
-		if args.CSVPath != "" || args.SheetURL != "" {
-			return fmt.Errorf(" --to is mutually exclusive with --csv and --sheet-url")
+		if args.CSVPath != "" || args.SheetURL != "" || args.Query != "" || args.SourceURL != "" {
+			return fmt.Errorf(" --to is mutually exclusive with --csv, --sheet-url, --source-url and --query")
 		}
-
-		return SendSingleEmail(args, cfg.SMTP)
+		if err := checkSuppressed(args.DBPath, args.To, args.List, args.IgnoreSuppressions); err != nil {
+			return err
+		}
+		return SendSingleEmail(args, cfg.SMTP, UnsubscribeLinks(unsubSigner, args.List, ""), UTMParams(utm))
 	}
-	if args.CSVPath == "" && args.SheetURL == "" {
-		return fmt.Errorf(" You must provide either --csv or --sheet-url")
+	sources := 0
+	for _, s := range []string{args.CSVPath, args.SheetURL, args.SourceURL, args.Query} {
+		if s != "" {
+			sources++
+		}
+	}
+	if sources == 0 {
+		return fmt.Errorf(" You must provide one of --csv, --sheet-url, --source-url or --query")
 	}
-	if args.CSVPath != "" && args.SheetURL != "" {
-		return fmt.Errorf(" Provide only one of --csv or --sheet-url, not both")
+	if sources > 1 {
+		return fmt.Errorf(" Provide only one of --csv, --sheet-url, --source-url or --query")
 	}
 
 	for _, f := range args.Attachments {
@@ -256,54 +352,146 @@ func Run(args CLIArgs) error {
 		return fmt.Errorf("failed to parse BCC: %w", err)
 	}
 
-	// Parse Recipients
-	var recipients []parser.Recipient
+	// Recipients are streamed: the list is read row by row while tasks are
+	// dispatched, so memory use does not grow with its length. Duplicate
+	// addresses are tracked in a temporary on-disk index.
+	src, csvSrc, err := openRecipientSource(args, cfg)
+	if err != nil {
+		return err
+	}
+	deduper, err := parser.NewDiskDeduper("")
+	if err != nil {
+		src.Close()
+		return fmt.Errorf("failed to create duplicate index: %w", err)
+	}
+	deduped := parser.DedupeSource(src, deduper)
+	defer func() {
+		if closeErr := deduped.Close(); closeErr != nil {
+			log.Printf("Warning: Failed to close recipient list: %v", closeErr)
+		}
+	}()
+	var stream parser.RecipientSource = deduped
 
-	if args.SheetURL != "" {
-		stream, err := parser.GetSheetCSVStream(args.SheetURL)
+	// Optional logical filtering
+	var filtered *parser.FilteredSource
+	if args.Filter != "" {
+		var f *recipientFilter
+		stream, f, err = compileFilter(args, stream, sourceColumns(csvSrc))
 		if err != nil {
-			return fmt.Errorf("failed to fetch Google Sheet: %w", err)
+			return err
 		}
-		defer func(stream io.ReadCloser) {
-			if closeErr := stream.Close(); closeErr != nil {
-				log.Printf("Warning: Failed to close Google Sheet stream: %v", closeErr)
+		if args.FilterExplain {
+			report, err := ExplainFilter(stream, args.Filter, f.types, explainSamples)
+			if err != nil {
+				return err
 			}
-		}(stream)
+			report.WriteText(os.Stdout)
+			return nil
+		}
+		filtered = parser.FilterSource(stream, parser.Matcher(f.expr))
+		stream = filtered
+	}
 
-		recipients, err = parser.ParseCSVFromReader(stream)
+	// Retry mode: keep only the addresses that failed transiently before.
+	var parentJobID string
+	if args.RetryFrom != "" || args.RetryCampaign != "" {
+		var n int
+		stream, n, parentJobID, err = applyRetrySelection(args, stream)
 		if err != nil {
-			return fmt.Errorf("failed to parse Google Sheet as CSV: %w", err)
+			return err
 		}
+		if parentJobID != "" {
+			fmt.Printf(" Retrying %d recipient(s) from campaign %s\n", n, parentJobID)
+		}
+	}
 
-		id, gid, _ := parser.ExtractSheetInfo(args.SheetURL)
-		fmt.Printf(" Loaded Google Sheet: Spreadsheet ID = %s, GID = %s\n", id, gid)
-
-	} else {
-		recipients, err = parser.ParseCSV(args.CSVPath)
+	// Campaign history, the suppression list and the optional retry queue
+	// share the BoltDB at --db-path, which is opened only for each read and
+	// write.
+	db, err := openCampaignDB(args.DBPath, args.IgnoreSuppressions)
+	if err != nil {
+		return err
+	}
+	suppression := newSuppressionFilter(nil, args.List)
+	if db != nil && !args.IgnoreSuppressions {
+		stream, suppression, err = applySuppressions(db, stream, args.List)
 		if err != nil {
-			return fmt.Errorf("failed to parse CSV: %w", err)
+			return err
 		}
 	}
 
-	if len(recipients) == 0 && !args.DryRun {
-		return fmt.Errorf("no recipients found (CSV/Sheet is empty or all rows were skipped)")
+	// Optional pre-flight: drop or report recipients whose domain cannot
+	// receive mail, before anything is rendered for them.
+	mx, err := newMXFilter(ctx, args.MXCheck, cfg.DNS)
+	if err != nil {
+		return err
+	}
+	if mx != nil {
+		stream = parser.FilterSource(stream, mx.keep)
 	}
 
-	// Optional logical filtering
-	if args.Filter != "" {
-		if len(recipients) == 0 {
-			return fmt.Errorf("no recipients found in CSV for filtering")
+	// An A/B rollout sends the winning variant to the recipients the test
+	// left out.
+	var rollout *rolloutFilter
+	var winner string
+	if args.ABRollout != "" {
+		if db == nil {
+			return fmt.Errorf("--ab-rollout needs the campaign database at --db-path %s", args.DBPath)
 		}
-
-		expr, err := parser.ParseExpression(args.Filter)
+		var v Variant
+		err := db.with(func(d *database.BoltDBClient) (err error) {
+			if v, err = rolloutVariant(d, args, plan); err != nil {
+				return err
+			}
+			rollout, err = newRolloutFilter(d, args.ABRollout)
+			return err
+		})
 		if err != nil {
-			return fmt.Errorf("invalid filter: %w", err)
+			return err
 		}
+		plan.variants, winner = []Variant{v}, v.Name
+		if parentJobID == "" {
+			parentJobID = args.ABRollout
+		}
+		fmt.Printf(" Rolling out variant %s of A/B test %s to the recipients it did not include\n", winner, args.ABRollout)
+		stream = parser.FilterSource(stream, rollout.keep)
+	}
 
-		recipients = parser.Filter(recipients, expr)
-
-		if len(recipients) == 0 {
+	// Set aside the holdout, then draw the sample, keep the A/B test share
+	// and apply the limit. The groups are counted before --resume so a
+	// resumed run sees the same split.
+	groups, err := newGroupFilter(args)
+	if err != nil {
+		return err
+	}
+	if groups != nil {
+		groups.save = db != nil && !args.DryRun
+		stream = parser.FilterSource(stream, groups.keep)
+	}
+
+	// Count what is left for the campaign totals.
+	total := 0
+	stream = parser.FilterSource(stream, func(parser.Recipient) bool {
+		total++
+		return true
+	})
+
+	// noRecipients explains an empty stream by the last stage that dropped
+	// rows.
+	noRecipients := func() error {
+		switch {
+		case suppression.summary.Total > 0:
+			return fmt.Errorf("no recipients left to send to: every recipient is suppressed")
+		case filtered != nil && filtered.Dropped() > 0:
 			return fmt.Errorf("no recipients matched the filter: %q", args.Filter)
+		case mx != nil && mx.exclude && mx.summary.Recipients > 0:
+			return fmt.Errorf("no recipients left to send to: every recipient domain lacks a mail exchanger")
+		case groups != nil && groups.counts[GroupSelected] == 0 && len(groups.counts) > 0:
+			return fmt.Errorf("no recipients left to send to: --holdout/--sample/--ab-test/--limit selected none")
+		case rollout != nil && rollout.skipped > 0:
+			return fmt.Errorf("no recipients left to send to: every recipient was in A/B test %s", args.ABRollout)
+		default:
+			return fmt.Errorf("no recipients found (CSV/Sheet is empty or all rows were skipped)")
 		}
 	}
 
@@ -312,14 +500,22 @@ func Run(args CLIArgs) error {
 		if args.TemplatePath == "" {
 			return fmt.Errorf("cannot preview without --template")
 		}
-		if len(recipients) == 0 {
-			return fmt.Errorf("no recipients found in CSV for preview")
+		_, first, ok, err := peekRecipient(stream)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return noRecipients()
 		}
-		rendered, err := utils.RenderTemplate(recipients[0], args.TemplatePath)
+		rendered, err := utils.RenderTemplate(first, args.TemplatePath)
 		if err != nil {
 			return fmt.Errorf("failed to render template: %w", err)
 		}
-		return utils.StartPreviewServer(rendered, args.PreviewPort)
+		preview := email.Task{Recipient: first, Body: rendered}
+		if err := decorate(&preview, []TaskDecorator{UnsubscribeLinks(unsubSigner, args.List, ""), UTMParams(utm)}); err != nil {
+			return err
+		}
+		return utils.StartPreviewServer(preview.Body, args.PreviewPort)
 	}
 
 	// Resolve optional plain-text body for multipart/alternative sending.
@@ -333,56 +529,69 @@ func Run(args CLIArgs) error {
 		}
 	}
 
-	// Initialize offset tracker for resumable delivery
-	var tracker *offset.Tracker
+	// Initialize offset tracker for resumable delivery. The offset is a row
+	// number in the recipient list, so resuming works without loading it.
+	tracker := offset.NewTracker(".mailgrid.offset")
 	var startOffset int
 
-	// Tracker is created for any bulk run (more than one recipient). We can no
-	// longer gate on len(tasks) because tasks are now streamed lazily.
-	if len(recipients) > 1 {
-		tracker = offset.NewTracker(".mailgrid.offset")
-
-		if args.ResetOffset {
-			if err := tracker.Reset(); err != nil {
-				log.Printf("⚠️ Warning: Failed to reset offset: %v", err)
-			} else {
-				fmt.Println(" Offset file cleared, starting from beginning")
-			}
+	if args.ResetOffset {
+		if err := tracker.Reset(); err != nil {
+			log.Printf("⚠️ Warning: Failed to reset offset: %v", err)
+		} else {
+			fmt.Println(" Offset file cleared, starting from beginning")
 		}
+	}
 
-		if args.Resume {
-			if err := tracker.Load(); err != nil {
-				log.Printf("⚠️ Warning: Failed to load offset (starting from beginning): %v", err)
-			} else {
-				startOffset = tracker.GetOffset()
-				if startOffset > 0 {
-					// Upper-bound check: if the offset already covers every
-					// recipient (including ones that will skip due to render
-					// errors) we are definitely done.
-					if startOffset >= len(recipients) {
-						fmt.Printf(" All emails already sent (offset: %d, recipients: %d)\n", startOffset, len(recipients))
-						return nil
-					}
-					fmt.Printf(" Resuming from offset %d (skipping %d already sent)\n", startOffset, startOffset)
-				}
+	var resumed *parser.FilteredSource
+	var resumedJobID string
+	if args.Resume {
+		if err := tracker.Load(); err != nil {
+			log.Printf("⚠️ Warning: Failed to load offset (starting from beginning): %v", err)
+		} else {
+			resumedJobID = tracker.GetJobID()
+			startOffset = tracker.GetOffset()
+			if startOffset > 0 {
+				fmt.Printf(" Resuming from row %d (skipping rows already sent)\n", startOffset)
+				resumed = parser.FilterSource(stream, func(r parser.Recipient) bool { return r.Row >= startOffset })
+				stream = resumed
 			}
 		}
+	}
 
-		jobID := fmt.Sprintf("mailgrid-%d", time.Now().Unix())
-		tracker.SetJobID(jobID)
+	// A resumed run continues the job saved with the offset, so the retries
+	// it left in --retry-queue are picked up and nothing else's are.
+	if resumedJobID == "" {
+		tracker.SetJobID(newJobID(args, time.Now()))
 	}
 
-	// Dry-run uses the slice path because printDryRun consumes a slice and
-	// dry-run has no scaling concern.
+	stream, _, ok, err := peekRecipient(stream)
+	if err != nil {
+		return err
+	}
+	if !ok && !args.DryRun {
+		if resumed != nil && resumed.Dropped() > 0 {
+			fmt.Printf(" All emails already sent (offset: %d)\n", startOffset)
+			return nil
+		}
+		return noRecipients()
+	}
+
+	// Dry-run prints each task as it is rendered.
 	if args.DryRun {
-		tasks, err := PrepareEmailTasks(recipients, args.TemplatePath, plainText, args.Subject, args.Attachments, ccList, bccList)
-		if err != nil {
+		taskCh, errCh := StreamVariantTasks(ctx, stream, plan.variants, plainText, args.Attachments, ccList, bccList, startOffset, 0,
+			UnsubscribeLinks(unsubSigner, args.List, ""), UTMParams(utm), TrackingLinks(trackSigner, ""))
+		n := 0
+		for t := range taskCh {
+			n++
+			printDryRunTask(n, t)
+		}
+		if err := <-errCh; err != nil {
 			return err
 		}
-		if startOffset > 0 && startOffset < len(tasks) {
-			tasks = tasks[startOffset:]
-		}
-		printDryRun(tasks)
+		printSuppressions(suppression.summary, args.List)
+		printMXSummary(mx)
+		printGroups(groups)
+		fmt.Printf(" Dry-run complete: %d emails rendered\n", n)
 		return nil
 	}
 
@@ -390,19 +599,10 @@ func Run(args CLIArgs) error {
 	email.SetRetryLimit(args.RetryLimit)
 
 	var jobID string
-	if tracker != nil && tracker.GetJobID() != "" {
+	if tracker.GetJobID() != "" {
 		jobID = tracker.GetJobID()
 	} else {
-		jobID = fmt.Sprintf("mailgrid-%d", start.Unix())
-	}
-
-	// Pre-compute the email list for the monitor seed. This is cheap and
-	// avoids materializing rendered bodies up front.
-	pendingEmails := make([]string, 0, len(recipients))
-	for _, r := range recipients {
-		if r.Email != "" {
-			pendingEmails = append(pendingEmails, r.Email)
-		}
+		jobID = newJobID(args, start)
 	}
 
 	var mon monitor.Monitor = monitor.NewNoOpMonitor()
@@ -427,31 +627,89 @@ func Run(args CLIArgs) error {
 			RetryLimit:        args.RetryLimit,
 			FilterExpression:  args.Filter,
 		}
-		mon.InitializeCampaign(jobID, configSummary, len(pendingEmails))
+		// The total is unknown until the list has been streamed; each
+		// recipient is registered as its task is queued.
+		mon.InitializeCampaign(jobID, configSummary, 0)
+		if db != nil {
+			monitorServer.SetComplaintCounts(complaintCounts(db, jobID))
+			monitorServer.SetEngagementSource(db.loadEngagement)
+		}
 
 		fmt.Printf("  Monitor dashboard: http://localhost:%d\n", args.MonitorPort)
 	}
 
+	// The campaign and its group memberships are recorded before the
+	// producer starts, as it flushes memberships while it reads the list.
+	if db != nil {
+		if resumedJobID == "" {
+			recordCampaignStart(db, jobID, parentJobID, start)
+		}
+		if groups != nil {
+			groups.record(db, jobID)
+		}
+	}
+
 	// Stream rendered tasks into a single attachment cache shared across the
 	// dispatch run so each unique attachment is base64-encoded exactly once.
 	cache := email.NewAttachmentCache(0)
-	taskCh, _ := StreamEmailTasks(ctx, recipients, args.TemplatePath, plainText, args.Subject, args.Attachments, ccList, bccList, startOffset, args.Concurrency*args.BatchSize)
+	taskCh, errCh := StreamVariantTasks(ctx, stream, plan.variants, plainText, args.Attachments, ccList, bccList, startOffset, args.Concurrency*args.BatchSize,
+		UnsubscribeLinks(unsubSigner, args.List, jobID), UTMParams(utm), TrackingLinks(trackSigner, jobID))
+	if args.Monitor {
+		taskCh = announcePending(ctx, taskCh, mon)
+	}
 
 	opts := &email.DispatchOptions{
 		Context:         ctx,
 		Monitor:         mon,
 		Tracker:         tracker,
 		AttachmentCache: cache,
-		PendingEmails:   pendingEmails,
+		JobID:           jobID,
+	}
+	// Recording the variant of each email sent lets a rollout pick the
+	// winner and send it to everyone the test did not reach.
+	var variants *variantRecorder
+	if db != nil && plan.variants[0].Name != "" {
+		variants = newVariantRecorder(db, jobID)
+		opts.OnSent = variants.sent
+	}
+
+	// Persist deferred retries so a crash mid-backoff does not lose them; a
+	// later run against the same database picks them up first.
+	if args.RetryQueue {
+		if db == nil {
+			return fmt.Errorf("failed to open retry queue database %s", args.DBPath)
+		}
+		opts.DeferredStore = db
 	}
 	dispatchResult := email.StartDispatcherStream(ctx, taskCh, cfg.SMTP, args.Concurrency, args.BatchSize, opts)
+	// errCh is closed once the producer has stopped reading the list.
+	readErr := <-errCh
+	if db != nil {
+		if groups != nil {
+			groups.flush()
+		}
+		recordCampaignEnd(db, jobID, campaignTotals{
+			Total:      total,
+			Suppressed: suppression.summary.Total,
+			Groups:     groups.summary(),
+			Variants:   variants.finish(),
+			Winner:     winner,
+			Resumed:    resumedJobID != "",
+		}, dispatchResult)
+	}
+	logSourceSummary(csvSrc, deduped)
+	printSuppressions(suppression.summary, args.List)
+	printMXSummary(mx)
+	printGroups(groups)
+	printVariants(variants.finish())
+	if args.ABTest != "" {
+		announceRollout(db, args, plan, jobID)
+	}
 
 	// Save final offset after campaign completion (defense-in-depth: the
 	// dispatcher already does this internally before returning).
-	if tracker != nil {
-		if err := tracker.Save(); err != nil {
-			log.Printf("️ Warning: Failed to save final offset: %v", err)
-		}
+	if err := tracker.Save(); err != nil {
+		log.Printf("️ Warning: Failed to save final offset: %v", err)
 	}
 	duration := time.Since(start)
 
@@ -465,6 +723,9 @@ func Run(args CLIArgs) error {
 	}
 
 	fmt.Printf("\u2705 Completed in %s using %d workers\n", duration, args.Concurrency)
+	if readErr != nil {
+		return readErr
+	}
 
 	// Send webhook notification if URL is provided
 	if args.WebhookURL != "" {
@@ -486,10 +747,15 @@ func Run(args CLIArgs) error {
 		// Create webhook payload
 		result := webhook.CampaignResult{
 			JobID:                jobID,
+			ParentJobID:          parentJobID,
 			Status:               "completed",
-			TotalRecipients:      len(pendingEmails),
+			TotalRecipients:      total,
 			SuccessfulDeliveries: successfulDeliveries,
 			FailedDeliveries:     failedDeliveries,
+			Suppressed:           suppression.summary.Total,
+			Groups:               groups.summary(),
+			Variants:             variants.finish(),
+			Winner:               winner,
 			StartTime:            start,
 			EndTime:              endTime,
 			DurationSeconds:      int(duration.Seconds()),
@@ -524,6 +790,69 @@ func Run(args CLIArgs) error {
 	return nil
 }
 
+// jobPayload returns the arguments a scheduled job stores to repeat this run.
+func jobPayload(args CLIArgs) types.CLIArgs {
+	return types.CLIArgs{
+		EnvPath:      args.EnvPath,
+		To:           args.To,
+		Subject:      args.Subject,
+		Subjects:     args.SubjectVariants,
+		Text:         args.Text,
+		Template:     args.TemplatePath,
+		Templates:    args.TemplateVariants,
+		Weights:      args.VariantWeights,
+		ABTest:       args.ABTest,
+		ABMetric:     args.ABMetric,
+		ABWait:       args.ABWait,
+		ABRollout:    args.ABRollout,
+		ABWinner:     args.ABWinner,
+		CSVPath:      args.CSVPath,
+		Join:         args.JoinPaths,
+		JoinMode:     args.JoinMode,
+		JoinConflict: args.JoinConflict,
+		JoinReport:   args.JoinReport,
+		Sheet:        args.Sheet,
+		SheetURL:     args.SheetURL,
+		Query:        args.Query,
+		SourceURL:    args.SourceURL,
+		Headers:      args.SourceHeaders,
+		Delimiter:    args.Delimiter,
+		Quote:        args.Quote,
+		Encoding:     args.Encoding,
+		EmailColumn:  args.EmailColumn,
+		Rename:       args.RenameColumns,
+		Attachments:  args.Attachments,
+		Cc:           args.Cc,
+		Bcc:          args.Bcc,
+		Concurrency:  args.Concurrency,
+		RetryLimit:   args.RetryLimit,
+		BatchSize:    args.BatchSize,
+		Filter:       args.Filter,
+		ColumnTypes:  args.ColumnTypes,
+		List:         args.List,
+		NoSuppress:   args.IgnoreSuppressions,
+		MXCheck:      args.MXCheck,
+		Sample:       args.Sample,
+		Limit:        args.Limit,
+		Holdout:      args.Holdout,
+		UTM:          args.UTM,
+		ScheduleAt:   args.ScheduleAt,
+		Interval:     args.Interval,
+		Cron:         args.Cron,
+		JobRetries:   args.JobRetries,
+	}
+}
+
+// newJobID returns the campaign job ID for a run starting at t. Retry runs
+// get a distinct prefix so their failures are never confused with the
+// original campaign's.
+func newJobID(args CLIArgs, t time.Time) string {
+	if args.RetryFrom != "" || args.RetryCampaign != "" {
+		return fmt.Sprintf("mailgrid-retry-%d", t.Unix())
+	}
+	return fmt.Sprintf("mailgrid-%d", t.Unix())
+}
+
 // listScheduledJobs lists all scheduled jobs from the database
 func listScheduledJobs(dbPath, envPath string) error {
 	db, err := database.NewDB(dbPath)
@@ -0,0 +1,299 @@
+package cli
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/database"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/bravo1goingdark/mailgrid/parser"
+	"github.com/spf13/pflag"
+)
+
+const suppressUsage = "usage: mailgrid suppress add|remove|import|export|list [flags] [args]"
+
+// suppressFlags are the options shared by the `mailgrid suppress` actions.
+type suppressFlags struct {
+	scope  string
+	reason string
+	output string
+}
+
+// runSuppressCommand implements `mailgrid suppress <action>`.
+func runSuppressCommand(argv []string) error {
+	if len(argv) == 0 {
+		return errors.New(suppressUsage)
+	}
+	action := argv[0]
+	switch action {
+	case "add", "remove", "import", "export", "list":
+	default:
+		return fmt.Errorf("unknown suppress action %q; %s", action, suppressUsage)
+	}
+
+	var f suppressFlags
+	args, rest, help, err := parseCommandFlags("suppress "+action, argv[1:], false, func(fs *pflag.FlagSet, _ *CLIArgs) {
+		fs.StringVar(&f.scope, "scope", "", "List the entry applies to (empty: every list)")
+		switch action {
+		case "add", "import":
+			fs.StringVar(&f.reason, "reason", "manual", "Why the address is suppressed")
+		case "export":
+			fs.StringVarP(&f.output, "output", "o", "", "Write CSV to this file instead of stdout")
+		}
+	})
+	if err != nil || help {
+		return err
+	}
+
+	db, err := database.NewDB(args.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+
+	switch action {
+	case "add":
+		if len(rest) == 0 {
+			return errors.New("usage: mailgrid suppress add [--scope list] [--reason text] <email>...")
+		}
+		added := 0
+		for _, addr := range rest {
+			ok, err := addSuppression(db, addr, f.scope, f.reason, "cli")
+			if err != nil {
+				return err
+			}
+			if ok {
+				added++
+			}
+		}
+		fmt.Printf("Suppressed %d new address(es)%s\n", added, scopeSuffix(f.scope))
+	case "remove":
+		if len(rest) == 0 {
+			return errors.New("usage: mailgrid suppress remove [--scope list] <email>...")
+		}
+		removed := 0
+		for _, addr := range rest {
+			existed, err := db.DeleteSuppression(addr, f.scope)
+			if err != nil {
+				return err
+			}
+			if existed {
+				removed++
+			} else {
+				fmt.Printf("%s was not suppressed%s\n", addr, scopeSuffix(f.scope))
+			}
+		}
+		fmt.Printf("Removed %d address(es)%s\n", removed, scopeSuffix(f.scope))
+	case "import":
+		if len(rest) != 1 {
+			return errors.New("usage: mailgrid suppress import [--scope list] [--reason text] <file.csv>")
+		}
+		file, err := os.Open(rest[0])
+		if err != nil {
+			return fmt.Errorf("open import file: %w", err)
+		}
+		defer file.Close()
+		added, skipped, err := ImportSuppressions(db, file, f.scope, f.reason, rest[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d new address(es), skipped %d invalid row(s)\n", added, skipped)
+	case "export":
+		var w io.Writer = os.Stdout
+		if f.output != "" {
+			file, err := os.Create(f.output)
+			if err != nil {
+				return fmt.Errorf("create export file: %w", err)
+			}
+			defer file.Close()
+			w = file
+		}
+		return ExportSuppressions(db, w, f.scope)
+	case "list":
+		entries, err := loadSuppressions(db, f.scope)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Println("No suppressed addresses.")
+			return nil
+		}
+		fmt.Printf("%-40s %-15s %-15s %s\n", "EMAIL", "SCOPE", "REASON", "SINCE")
+		for _, e := range entries {
+			scope := e.Scope
+			if scope == "" {
+				scope = "(all)"
+			}
+			fmt.Printf("%-40s %-15s %-15s %s\n", e.Email, scope, e.Reason, e.CreatedAt.Format("2006-01-02 15:04"))
+		}
+		fmt.Printf("\n%d address(es)\n", len(entries))
+	}
+	return nil
+}
+
+func scopeSuffix(scope string) string {
+	if scope == "" {
+		return ""
+	}
+	return fmt.Sprintf(" for list %q", scope)
+}
+
+// addSuppression validates and stores one address.
+func addSuppression(db *database.BoltDBClient, addr, scope, reason, source string) (bool, error) {
+	addr = strings.TrimSpace(addr)
+	if !parser.IsValidEmail(addr) {
+		return false, fmt.Errorf("invalid email address %q", addr)
+	}
+	return db.SaveSuppression(&types.Suppression{
+		Email:     strings.ToLower(addr),
+		Scope:     strings.TrimSpace(scope),
+		Reason:    reason,
+		Source:    source,
+		CreatedAt: time.Now(),
+	})
+}
+
+// loadSuppressions returns the entries in scope (all scopes when empty),
+// sorted by address then scope.
+func loadSuppressions(db *database.BoltDBClient, scope string) ([]types.Suppression, error) {
+	all, err := db.LoadSuppressions()
+	if err != nil {
+		return nil, err
+	}
+	out := all[:0]
+	for _, s := range all {
+		if scope == "" || s.Scope == scope {
+			out = append(out, s)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Email != out[j].Email {
+			return out[i].Email < out[j].Email
+		}
+		return out[i].Scope < out[j].Scope
+	})
+	return out, nil
+}
+
+// importBatchSize is how many imported rows are written per transaction.
+const importBatchSize = 1000
+
+// suppressionHeader is the column layout written by ExportSuppressions and
+// understood by ImportSuppressions.
+var suppressionHeader = []string{"email", "scope", "reason", "source", "created_at"}
+
+// ImportSuppressions reads addresses from CSV. A header row naming an
+// "email" column is honoured along with optional scope, reason and
+// created_at columns (so an export can be re-imported); without one, the
+// first column is the address. Blank scope/reason cells fall back to the
+// given defaults. It returns how many entries were new and how many rows
+// were skipped as invalid.
+func ImportSuppressions(db *database.BoltDBClient, r io.Reader, scope, reason, source string) (int, int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	cols := map[string]int{"email": 0}
+	added, skipped := 0, 0
+	batch := make([]types.Suppression, 0, importBatchSize)
+	flush := func() error {
+		n, err := db.SaveSuppressions(batch)
+		added += n
+		batch = batch[:0]
+		return err
+	}
+	for line := 0; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return added, skipped, fmt.Errorf("read import file: %w", err)
+		}
+		if line == 0 {
+			if idx := headerIndex(rec); idx != nil {
+				cols = idx
+				continue
+			}
+		}
+
+		cell := func(name string) string {
+			if i, ok := cols[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		addr := cell("email")
+		if !parser.IsValidEmail(addr) {
+			skipped++
+			continue
+		}
+		entry := types.Suppression{
+			Email:     strings.ToLower(addr),
+			Scope:     firstNonEmpty(cell("scope"), scope),
+			Reason:    firstNonEmpty(cell("reason"), reason),
+			Source:    firstNonEmpty(cell("source"), source),
+			CreatedAt: time.Now(),
+		}
+		if ts, err := time.Parse(time.RFC3339, cell("created_at")); err == nil {
+			entry.CreatedAt = ts
+		}
+		batch = append(batch, entry)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil {
+				return added, skipped, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return added, skipped, err
+	}
+	return added, skipped, nil
+}
+
+// ExportSuppressions writes the entries in scope (all when empty) as CSV.
+func ExportSuppressions(db *database.BoltDBClient, w io.Writer, scope string) error {
+	entries, err := loadSuppressions(db, scope)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.Write(suppressionHeader); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := cw.Write([]string{e.Email, e.Scope, e.Reason, e.Source, e.CreatedAt.Format(time.RFC3339)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// headerIndex maps lower-cased column names to positions when rec looks like
+// a header row (it has an "email" column), or returns nil.
+func headerIndex(rec []string) map[string]int {
+	idx := make(map[string]int, len(rec))
+	for i, name := range rec {
+		idx[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := idx["email"]; !ok {
+		return nil
+	}
+	return idx
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -3,7 +3,9 @@ package cli
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"text/template"
@@ -17,13 +19,27 @@ import (
 	"github.com/bravo1goingdark/mailgrid/webhook"
 )
 
+// TaskDecorator adjusts a rendered task before it is queued, e.g. to fill in
+// per-recipient links. An error skips the recipient like a render failure.
+type TaskDecorator func(t *email.Task) error
+
+// decorate applies decorators to t in order.
+func decorate(t *email.Task, decorators []TaskDecorator) error {
+	for _, d := range decorators {
+		if err := d(t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // PrepareEmailTasks renders the subject and body templates for each recipient
 // and returns a list of email.Task objects ready for sending.
 //
 // plainText is optional plain-text content (inline string or file path resolved
 // by the caller). When both templatePath (HTML) and plainText are provided, the
 // task carries both bodies so the sender can build a multipart/alternative message.
-func PrepareEmailTasks(recipients []parser.Recipient, templatePath, plainText, subjectTpl string, attachments []string, ccList []string, bccList []string) ([]email.Task, error) {
+func PrepareEmailTasks(recipients []parser.Recipient, templatePath, plainText, subjectTpl string, attachments []string, ccList []string, bccList []string, decorators ...TaskDecorator) ([]email.Task, error) {
 	tmpl, err := template.New("subject").Option("missingkey=error").Parse(subjectTpl)
 	if err != nil {
 		return nil, fmt.Errorf("invalid subject template: %w", err)
@@ -50,13 +66,13 @@ func PrepareEmailTasks(recipients []parser.Recipient, templatePath, plainText, s
 		}
 
 		var sb bytes.Buffer
-		if err := tmpl.Execute(&sb, r.Data); err != nil {
+		if err := tmpl.Execute(&sb, r.TemplateData()); err != nil {
 			log.Printf("️ Skipping %s: subject template failed (%v)", r.Email, err)
 			skipped++
 			continue
 		}
 
-		tasks = append(tasks, email.Task{
+		task := email.Task{
 			Recipient:   r,
 			Subject:     sb.String(),
 			Body:        body,
@@ -69,7 +85,13 @@ func PrepareEmailTasks(recipients []parser.Recipient, templatePath, plainText, s
 			// the offset's contiguous high-water mark advancing without gaps
 			// when recipients are skipped (missing fields, render errors).
 			Index: len(tasks),
-		})
+		}
+		if err := decorate(&task, decorators); err != nil {
+			log.Printf("️ Skipping %s: %v", r.Email, err)
+			skipped++
+			continue
+		}
+		tasks = append(tasks, task)
 	}
 
 	if skipped > 0 {
@@ -80,55 +102,87 @@ func PrepareEmailTasks(recipients []parser.Recipient, templatePath, plainText, s
 }
 
 // StreamEmailTasks renders subject and body templates lazily and writes the
-// resulting tasks to the returned channel. The channel is closed when all
-// recipients have been processed or ctx is cancelled.
+// resulting tasks to the returned channel. The channel is closed when src is
+// exhausted or ctx is cancelled; a read error from src is sent on the error
+// channel, which is closed once the producer goroutine has returned. The
+// caller keeps ownership of src and may close it after that.
 //
 // Use this in place of PrepareEmailTasks when the recipient set is large
 // enough that materializing all rendered bodies in RAM is undesirable; the
 // channel-based dispatcher (email.StartDispatcherStream) consumes the same
 // channel directly so render and send overlap.
 //
-// skipN drops the first N renderable tasks before emitting any — used to
-// resume from a saved offset. Each emitted task carries Index = N + position
-// after the skip, matching the offset baseline so MarkComplete advances the
-// tracker correctly. Recipients that fail to render (missing fields, template
-// error) are logged, skipped, and never count toward skipN or Index.
-func StreamEmailTasks(ctx context.Context, recipients []parser.Recipient, templatePath, plainText, subjectTpl string, attachments []string, ccList []string, bccList []string, skipN, bufSize int) (<-chan email.Task, <-chan error) {
+// Offsets are source row numbers. skipRows drops recipients with
+// Recipient.Row below it before anything is rendered — used to resume from a
+// saved offset. Each task carries Index = its row, and Skipped = the rows
+// since the previous task (or since skipRows) that produced none: rows the
+// source filtered out, and recipients here that fail to render (missing
+// fields, template error, decorator error), which are logged and skipped.
+// Completing the task completes those rows too, so the offset advances
+// across them.
+func StreamEmailTasks(ctx context.Context, src parser.RecipientSource, templatePath, plainText, subjectTpl string, attachments []string, ccList []string, bccList []string, skipRows, bufSize int, decorators ...TaskDecorator) (<-chan email.Task, <-chan error) {
+	variants := []Variant{{Subject: subjectTpl, TemplatePath: templatePath}}
+	return StreamVariantTasks(ctx, src, variants, plainText, attachments, ccList, bccList, skipRows, bufSize, decorators...)
+}
+
+// StreamVariantTasks is StreamEmailTasks for an A/B test: each recipient is
+// rendered with the subject and template of one of variants, picked by
+// AssignVariant, and its task is tagged with the variant's name.
+func StreamVariantTasks(ctx context.Context, src parser.RecipientSource, variants []Variant, plainText string, attachments []string, ccList []string, bccList []string, skipRows, bufSize int, decorators ...TaskDecorator) (<-chan email.Task, <-chan error) {
 	if bufSize <= 0 {
 		bufSize = 64
 	}
 	out := make(chan email.Task, bufSize)
 	errCh := make(chan error, 1)
 
-	tmpl, err := template.New("subject").Option("missingkey=error").Parse(subjectTpl)
-	if err != nil {
-		close(out)
-		errCh <- fmt.Errorf("invalid subject template: %w", err)
-		close(errCh)
-		return out, errCh
+	subjects := make([]*template.Template, len(variants))
+	for i, v := range variants {
+		tmpl, err := template.New("subject").Option("missingkey=error").Parse(v.Subject)
+		if err != nil {
+			close(out)
+			if v.Name != "" {
+				err = fmt.Errorf("variant %s: %w", v.Name, err)
+			}
+			errCh <- fmt.Errorf("invalid subject template: %w", err)
+			close(errCh)
+			return out, errCh
+		}
+		subjects[i] = tmpl
 	}
 
 	go func() {
-		defer close(out)
 		defer close(errCh)
+		defer close(out)
 
 		skipped := 0
 		emitted := 0
-		processed := 0 // counts every renderable task including those skipped for resume
-		for _, r := range recipients {
+		next := skipRows // first row not yet accounted for by an emitted task
+		for {
 			if ctx.Err() != nil {
 				return
 			}
+			r, rerr := src.Next()
+			if errors.Is(rerr, io.EOF) {
+				break
+			}
+			if rerr != nil {
+				errCh <- fmt.Errorf("failed to read recipients: %w", rerr)
+				return
+			}
+			if r.Row < skipRows {
+				continue
+			}
 			if HasMissingFields(r) {
 				log.Printf("️ Skipping %s: missing CSV fields", r.Email)
 				skipped++
 				continue
 			}
 
+			i := AssignVariant(variants, r.Email)
+			variant := variants[i]
 			var body string
-			if templatePath != "" {
-				var rerr error
-				body, rerr = utils.RenderTemplate(r, templatePath)
+			if variant.TemplatePath != "" {
+				body, rerr = utils.RenderTemplate(r, variant.TemplatePath)
 				if rerr != nil {
 					log.Printf("️ Skipping %s: template rendering failed (%v)", r.Email, rerr)
 					skipped++
@@ -137,17 +191,12 @@ func StreamEmailTasks(ctx context.Context, recipients []parser.Recipient, templa
 			}
 
 			var sb bytes.Buffer
-			if rerr := tmpl.Execute(&sb, r.Data); rerr != nil {
+			if rerr := subjects[i].Execute(&sb, r.TemplateData()); rerr != nil {
 				log.Printf("️ Skipping %s: subject template failed (%v)", r.Email, rerr)
 				skipped++
 				continue
 			}
 
-			if processed < skipN {
-				processed++
-				continue
-			}
-
 			task := email.Task{
 				Recipient:   r,
 				Subject:     sb.String(),
@@ -157,9 +206,19 @@ func StreamEmailTasks(ctx context.Context, recipients []parser.Recipient, templa
 				CC:          ccList,
 				BCC:         bccList,
 				Retries:     0,
-				Index:       processed,
+				Index:       r.Row,
+				Variant:     variant.Name,
+			}
+			if derr := decorate(&task, decorators); derr != nil {
+				log.Printf("️ Skipping %s: %v", r.Email, derr)
+				skipped++
+				continue
 			}
-			processed++
+			if r.Row > next {
+				task.Skipped = r.Row - next
+			}
+			next = r.Row + 1
+
 			select {
 			case out <- task:
 				emitted++
@@ -168,8 +227,8 @@ func StreamEmailTasks(ctx context.Context, recipients []parser.Recipient, templa
 			}
 		}
 
-		if skipped > 0 || skipN > 0 {
-			log.Printf("Streamed %d tasks. Skipped %d recipient(s); resumed past %d.", emitted, skipped, skipN)
+		if skipped > 0 || skipRows > 0 {
+			log.Printf("Streamed %d tasks. Skipped %d recipient(s); resumed from row %d.", emitted, skipped, skipRows)
 		}
 	}()
 
@@ -186,31 +245,42 @@ func HasMissingFields(r parser.Recipient) bool {
 // printDryRun logs rendered email content to the console instead of sending.
 func printDryRun(tasks []email.Task) {
 	for i, t := range tasks {
-		fmt.Printf(" Email #%d → %s\nSubject: %s\n", i+1, t.Recipient.Email, t.Subject)
-		if len(t.Attachments) > 0 {
-			fmt.Printf("Attachments: %v\n", t.Attachments)
-		}
-		switch {
-		case t.Body != "" && t.PlainText != "":
-			fmt.Printf("\n[plain text]\n%s\n\n[html]\n%s\n\n", t.PlainText, t.Body)
-		case t.Body != "":
-			fmt.Printf("\n%s\n\n", t.Body)
-		case t.PlainText != "":
-			fmt.Printf("\n%s\n\n", t.PlainText)
-		default:
-			fmt.Printf("\n(no body)\n\n")
-		}
+		printDryRunTask(i+1, t)
 	}
 	fmt.Printf(" Dry-run complete: %d emails rendered\n", len(tasks))
 }
 
+// printDryRunTask logs the n-th rendered email of a dry run.
+func printDryRunTask(n int, t email.Task) {
+	fmt.Printf(" Email #%d → %s\nSubject: %s\n", n, t.Recipient.Email, t.Subject)
+	if t.Variant != "" {
+		fmt.Printf("Variant: %s\n", t.Variant)
+	}
+	if len(t.Attachments) > 0 {
+		fmt.Printf("Attachments: %v\n", t.Attachments)
+	}
+	if t.UnsubscribeURL != "" {
+		fmt.Printf("List-Unsubscribe: <%s>\n", t.UnsubscribeURL)
+	}
+	switch {
+	case t.Body != "" && t.PlainText != "":
+		fmt.Printf("\n[plain text]\n%s\n\n[html]\n%s\n\n", t.PlainText, t.Body)
+	case t.Body != "":
+		fmt.Printf("\n%s\n\n", t.Body)
+	case t.PlainText != "":
+		fmt.Printf("\n%s\n\n", t.PlainText)
+	default:
+		fmt.Printf("\n(no body)\n\n")
+	}
+}
+
 // SendSingleEmail handles one-off email sending using --to.
 //
 // Accepted combinations:
 //   - --template only: HTML email
 //   - --text only:     plain-text email
 //   - --template + --text: multipart/alternative (HTML + plain text)
-func SendSingleEmail(args CLIArgs, cfg config.SMTPConfig) error {
+func SendSingleEmail(args CLIArgs, cfg config.SMTPConfig, decorators ...TaskDecorator) error {
 	if args.To == "" {
 		return fmt.Errorf("--to flag is required for single email sending")
 	}
@@ -247,6 +317,7 @@ func SendSingleEmail(args CLIArgs, cfg config.SMTPConfig) error {
 		args.Attachments,
 		ccList,
 		bccList,
+		decorators...,
 	)
 	if err != nil {
 		return fmt.Errorf("failed to prepare task: %w", err)
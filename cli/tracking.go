@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/config"
+	"github.com/bravo1goingdark/mailgrid/database"
+	"github.com/bravo1goingdark/mailgrid/email"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/bravo1goingdark/mailgrid/tracking"
+	"github.com/bravo1goingdark/mailgrid/webhook"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// trackingFlushInterval is how often buffered opens and clicks are
+	// written to the database.
+	trackingFlushInterval = 5 * time.Second
+	// trackingBufferSize caps the events held while the database is locked
+	// by another process, such as a --scheduler-run daemon.
+	trackingBufferSize = 100000
+)
+
+// runServeTrackingCommand implements `mailgrid serve-tracking`.
+func runServeTrackingCommand(argv []string) error {
+	var listen, webhookURL, webhookSecret string
+	args, _, help, err := parseCommandFlags("serve-tracking", argv, false, func(fs *pflag.FlagSet, a *CLIArgs) {
+		fs.StringVarP(&a.EnvPath, "env", "e", "", "Path to config JSON holding the tracking section")
+		fs.StringVar(&listen, "listen", ":8091", "Address to listen on")
+		fs.StringVar(&webhookURL, "webhook", "", "URL to POST every open and click to")
+		fs.StringVar(&webhookSecret, "webhook-secret", "", "HMAC-SHA256 secret for signing webhook payloads")
+	})
+	if err != nil || help {
+		return err
+	}
+	if args.EnvPath == "" {
+		return errors.New("config required: set --env path/to/config.json")
+	}
+	if err := webhook.ValidateURL(webhookURL); err != nil {
+		return err
+	}
+	cfg, err := config.LoadConfig(args.EnvPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	signer, err := newTrackingSigner(cfg.Tracking)
+	if err != nil {
+		return err
+	}
+	if signer == nil {
+		return errors.New("tracking.base_url and tracking.secret must be set in the config")
+	}
+
+	buf := tracking.NewBuffer(trackingBufferSize)
+	var rec tracking.Recorder = buf
+	if webhookURL != "" {
+		hooks := webhook.NewClientWithSecret(webhookSecret)
+		defer hooks.Close()
+		rec = tracking.RecorderFunc(func(ev types.EngagementEvent) {
+			buf.Record(ev)
+			_ = hooks.SendEngagement(webhookURL, webhook.EngagementEvent{
+				Event:     ev.Kind,
+				JobID:     ev.CampaignID,
+				Email:     ev.Email,
+				URL:       ev.URL,
+				Timestamp: ev.At,
+			})
+		})
+	}
+	write := func(events []types.EngagementEvent) error {
+		db, err := database.NewDB(args.DBPath)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		return db.RecordEngagement(events)
+	}
+
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           tracking.NewHandler(signer, rec),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	// Events are buffered and written in batches: opening the database for
+	// every pixel request would contend with campaigns for the file lock.
+	done := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		ticker := time.NewTicker(trackingFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := buf.Flush(write); err != nil {
+					log.Printf("⚠️ Warning: %d tracking event(s) not yet saved: %v", buf.Len(), err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	go func() {
+		<-quit
+		fmt.Println("\nShutting down tracking server...")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}()
+
+	fmt.Printf("Tracking endpoint listening on %s (links: %s/...)\n", listen, strings.TrimRight(cfg.Tracking.BaseURL, "/"))
+	serveErr := srv.ListenAndServe()
+	close(done)
+	<-flushed
+	if err := buf.Flush(write); err != nil {
+		log.Printf("⚠️ Warning: %d tracking event(s) lost on shutdown: %v", buf.Len(), err)
+	}
+	if !errors.Is(serveErr, http.ErrServerClosed) {
+		return fmt.Errorf("server error: %w", serveErr)
+	}
+	return nil
+}
+
+// newTrackingSigner builds the tracking URL signer from config. It returns
+// nil when tracking is not configured.
+func newTrackingSigner(cfg config.TrackingConfig) (*tracking.Signer, error) {
+	if cfg.BaseURL == "" && cfg.Secret == "" {
+		return nil, nil
+	}
+	signer, err := tracking.NewSigner(cfg.BaseURL, cfg.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracking config: %w", err)
+	}
+	return signer, nil
+}
+
+// TrackingLinks returns a TaskDecorator that adds an open pixel to each
+// task's HTML body and routes its links through the click redirect. The
+// unsubscribe link is left untouched. It must run after UnsubscribeLinks;
+// with a nil signer it does nothing.
+func TrackingLinks(signer *tracking.Signer, jobID string) TaskDecorator {
+	return func(t *email.Task) error {
+		if signer == nil || t.Body == "" {
+			return nil
+		}
+		unsub := t.UnsubscribeURL
+		t.Body = signer.Rewrite(t.Body, jobID, t.Recipient.Email, func(href string) bool {
+			return unsub != "" && href == unsub
+		})
+		return nil
+	}
+}
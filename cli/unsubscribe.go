@@ -0,0 +1,292 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/config"
+	"github.com/bravo1goingdark/mailgrid/database"
+	"github.com/bravo1goingdark/mailgrid/email"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/bravo1goingdark/mailgrid/unsubscribe"
+	"github.com/bravo1goingdark/mailgrid/utils"
+	"github.com/spf13/pflag"
+)
+
+// runServeUnsubscribeCommand implements `mailgrid serve-unsubscribe`.
+func runServeUnsubscribeCommand(argv []string) error {
+	var listen string
+	args, _, help, err := parseCommandFlags("serve-unsubscribe", argv, false, func(fs *pflag.FlagSet, a *CLIArgs) {
+		fs.StringVarP(&a.EnvPath, "env", "e", "", "Path to config JSON holding the unsubscribe section")
+		fs.StringVar(&listen, "listen", ":8090", "Address to listen on")
+	})
+	if err != nil || help {
+		return err
+	}
+	if args.EnvPath == "" {
+		return errors.New("config required: set --env path/to/config.json")
+	}
+	cfg, err := config.LoadConfig(args.EnvPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	signer, err := newUnsubscribeSigner(cfg.Unsubscribe)
+	if err != nil {
+		return err
+	}
+	if signer == nil {
+		return errors.New("unsubscribe.base_url and unsubscribe.secret must be set in the config")
+	}
+
+	store, err := newDBStore(args.DBPath)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Addr:              listen,
+		Handler:           unsubscribe.NewHandler(signer, store),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	// Unsubscribes queued while another process held the database are
+	// retried until they are written.
+	done := make(chan struct{})
+	flushed := make(chan struct{})
+	go func() {
+		defer close(flushed)
+		ticker := time.NewTicker(unsubscribeRetryInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := store.flush(); err != nil {
+					log.Printf("⚠️ Warning: %d unsubscribe(s) still queued in %s: %v", store.pending(), unsubscribeQueuePath(args.DBPath), err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+	go func() {
+		<-quit
+		fmt.Println("\nShutting down unsubscribe server...")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	}()
+
+	fmt.Printf("Unsubscribe endpoint listening on %s (links: %s/...)\n", listen, strings.TrimRight(cfg.Unsubscribe.BaseURL, "/"))
+	serveErr := srv.ListenAndServe()
+	close(done)
+	<-flushed
+	if err := store.flush(); err != nil {
+		log.Printf("⚠️ Warning: %d unsubscribe(s) left queued in %s; they are written on the next start: %v", store.pending(), unsubscribeQueuePath(args.DBPath), err)
+	}
+	if !errors.Is(serveErr, http.ErrServerClosed) {
+		return fmt.Errorf("server error: %w", serveErr)
+	}
+	return nil
+}
+
+// unsubscribeRetryInterval is how often unsubscribes queued while the
+// database was locked are retried.
+const unsubscribeRetryInterval = 5 * time.Second
+
+// dbStore writes unsubscribes to the database at path, opening it for each
+// write so other processes can use it in between. While another process
+// holds the file lock, such as a --scheduler-run daemon, unsubscribes are
+// appended to a queue file next to the database and written once the lock is
+// free. Campaigns read the queue too, so a queued unsubscribe is honored
+// straight away.
+type dbStore struct {
+	path   string
+	mu     sync.Mutex
+	queued int // entries in the queue file
+}
+
+// newDBStore returns a store for the database at path, picking up the
+// unsubscribes an earlier server left queued.
+func newDBStore(path string) (*dbStore, error) {
+	queued, err := readUnsubscribeQueue(path)
+	if err != nil {
+		return nil, err
+	}
+	return &dbStore{path: path, queued: len(queued)}, nil
+}
+
+func (s *dbStore) SaveSuppression(sup *types.Suppression) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Once the queue holds entries, later ones join it instead of each
+	// waiting out the lock timeout; flush writes them in order.
+	if s.queued == 0 {
+		n, err := s.save([]types.Suppression{*sup})
+		if err == nil {
+			return n == 1, nil
+		}
+		log.Printf("unsubscribe: queueing %s: %v", sup.Email, err)
+	}
+	if err := appendUnsubscribeQueue(s.path, sup); err != nil {
+		return false, err
+	}
+	s.queued++
+	return false, unsubscribe.ErrQueued
+}
+
+// flush writes the queued unsubscribes to the database and empties the
+// queue.
+func (s *dbStore) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.queued == 0 {
+		return nil
+	}
+	queued, err := readUnsubscribeQueue(s.path)
+	if err != nil {
+		return err
+	}
+	if _, err := s.save(queued); err != nil {
+		return err
+	}
+	if err := os.Remove(unsubscribeQueuePath(s.path)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.queued = 0
+	return nil
+}
+
+// pending returns the number of queued unsubscribes.
+func (s *dbStore) pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queued
+}
+
+func (s *dbStore) save(entries []types.Suppression) (int, error) {
+	db, err := database.NewDB(s.path)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	return db.SaveSuppressions(entries)
+}
+
+// unsubscribeQueuePath returns the file holding the unsubscribes not yet
+// written to the database at dbPath.
+func unsubscribeQueuePath(dbPath string) string {
+	return dbPath + ".unsubscribe-queue"
+}
+
+// appendUnsubscribeQueue adds sup to the queue of dbPath and syncs it to
+// disk, one JSON object per line.
+func appendUnsubscribeQueue(dbPath string, sup *types.Suppression) error {
+	line, err := json.Marshal(sup)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(unsubscribeQueuePath(dbPath), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to queue unsubscribe: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to queue unsubscribe: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to queue unsubscribe: %w", err)
+	}
+	return f.Close()
+}
+
+// readUnsubscribeQueue returns the unsubscribes queued for dbPath. A line
+// cut short by a crash is skipped.
+func readUnsubscribeQueue(dbPath string) ([]types.Suppression, error) {
+	f, err := os.Open(unsubscribeQueuePath(dbPath))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read unsubscribe queue: %w", err)
+	}
+	defer f.Close()
+	var queued []types.Suppression
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var sup types.Suppression
+		if err := json.Unmarshal(scanner.Bytes(), &sup); err != nil || sup.Email == "" {
+			log.Printf("⚠️ Warning: skipping unreadable line in %s", unsubscribeQueuePath(dbPath))
+			continue
+		}
+		queued = append(queued, sup)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read unsubscribe queue: %w", err)
+	}
+	return queued, nil
+}
+
+// newUnsubscribeSigner builds the link signer from config. It returns nil
+// when unsubscribe links are not configured.
+func newUnsubscribeSigner(cfg config.UnsubscribeConfig) (*unsubscribe.Signer, error) {
+	if cfg.BaseURL == "" && cfg.Secret == "" {
+		return nil, nil
+	}
+	signer, err := unsubscribe.NewSigner(cfg.BaseURL, cfg.Secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid unsubscribe config: %w", err)
+	}
+	return signer, nil
+}
+
+// checkUnsubscribeTemplate rejects a template that calls unsubscribeURL when
+// no signer is configured, before any recipient is rendered.
+func checkUnsubscribeTemplate(signer *unsubscribe.Signer, templatePath string) error {
+	if signer != nil || templatePath == "" {
+		return nil
+	}
+	uses, err := utils.TemplateCalls(templatePath, "unsubscribeURL")
+	if err != nil {
+		return err
+	}
+	if uses {
+		return errors.New("template uses {{ unsubscribeURL }} but unsubscribe.base_url and unsubscribe.secret are not set in the config")
+	}
+	return nil
+}
+
+// UnsubscribeLinks returns a TaskDecorator giving each task its signed
+// unsubscribe link: {{ unsubscribeURL }} in the body is replaced and the
+// List-Unsubscribe headers are set. With a nil signer it only checks that the
+// body does not use the helper.
+func UnsubscribeLinks(signer *unsubscribe.Signer, list, jobID string) TaskDecorator {
+	return func(t *email.Task) error {
+		if signer == nil {
+			if strings.Contains(t.Body, utils.UnsubscribePlaceholder) {
+				return errors.New("template uses {{ unsubscribeURL }} but no unsubscribe link is configured")
+			}
+			return nil
+		}
+		link := signer.URL(unsubscribe.Subscription{Email: t.Recipient.Email, List: list, Campaign: jobID})
+		t.Body = strings.ReplaceAll(t.Body, utils.UnsubscribePlaceholder, link)
+		t.UnsubscribeURL = link
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+
+	"github.com/bravo1goingdark/mailgrid/email"
+	"github.com/bravo1goingdark/mailgrid/utils"
+)
+
+// UTMParam is one --utm query parameter with its per-recipient value
+// template. Build them with ParseUTM.
+type UTMParam struct {
+	key  string
+	tmpl *template.Template
+}
+
+// ParseUTM parses --utm specs of the form key=value. A key without a "utm_"
+// prefix gets one ("source" becomes utm_source); values are Go templates
+// rendered with the recipient's CSV fields, like --subject.
+func ParseUTM(specs []string) ([]UTMParam, error) {
+	params := make([]UTMParam, 0, len(specs))
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		key, value, ok := strings.Cut(spec, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --utm %q: expected key=value", spec)
+		}
+		if !strings.HasPrefix(key, "utm_") {
+			key = "utm_" + key
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("duplicate --utm parameter %s", key)
+		}
+		seen[key] = true
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --utm %s template: %w", key, err)
+		}
+		params = append(params, UTMParam{key: key, tmpl: tmpl})
+	}
+	return params, nil
+}
+
+// UTMParams returns a TaskDecorator that adds params to every http(s) link in
+// the task's HTML body. Parameters a link already carries are kept, as are its
+// other query parameters and fragment. mailto: and other non-web links and
+// the unsubscribe link are left alone. It must run after UnsubscribeLinks and
+// before TrackingLinks, so click redirects lead to the tagged URL.
+func UTMParams(params []UTMParam) TaskDecorator {
+	return func(t *email.Task) error {
+		if len(params) == 0 || t.Body == "" {
+			return nil
+		}
+		values := make([][2]string, 0, len(params))
+		for _, p := range params {
+			var sb bytes.Buffer
+			if err := p.tmpl.Execute(&sb, t.Recipient.TemplateData()); err != nil {
+				return fmt.Errorf("utm template failed: %w", err)
+			}
+			if v := strings.TrimSpace(sb.String()); v != "" {
+				values = append(values, [2]string{p.key, v})
+			}
+		}
+		unsub := t.UnsubscribeURL
+		t.Body = utils.RewriteLinks(t.Body, func(href string) (string, bool) {
+			if !utils.IsHTTPURL(href) || (unsub != "" && href == unsub) {
+				return "", false
+			}
+			return addQueryParams(href, values)
+		})
+		return nil
+	}
+}
+
+// addQueryParams appends the params href does not already have, keeping its
+// existing query string as written. It reports false when nothing changed or
+// href cannot be parsed.
+func addQueryParams(href string, params [][2]string) (string, bool) {
+	u, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+	existing := u.Query()
+	var add []string
+	for _, p := range params {
+		if _, ok := existing[p[0]]; !ok {
+			add = append(add, url.QueryEscape(p[0])+"="+url.QueryEscape(p[1]))
+		}
+	}
+	if len(add) == 0 {
+		return "", false
+	}
+	// Rebuild by hand rather than via u.String() so the original URL is not
+	// re-encoded and its parameter order survives.
+	base, frag, hasFrag := strings.Cut(href, "#")
+	sep := "?"
+	if strings.Contains(base, "?") {
+		sep = "&"
+		if strings.HasSuffix(base, "?") || strings.HasSuffix(base, "&") {
+			sep = ""
+		}
+	}
+	out := base + sep + strings.Join(add, "&")
+	if hasFrag {
+		out += "#" + frag
+	}
+	return out, true
+}
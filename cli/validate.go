@@ -0,0 +1,335 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+	"github.com/spf13/pflag"
+)
+
+const validateUsage = "usage: mailgrid validate [flags] --csv <file>"
+
+// Issue kinds reported by ValidateList. Every kind except IssueRole rejects
+// the row; role addresses are only rejected with ValidateOptions.RejectRole.
+const (
+	IssueMalformed  = parser.SkipMalformed
+	IssueBlankEmail = parser.SkipBlankEmail
+	IssueInvalid    = parser.SkipInvalidEmail
+	IssueDisposable = "disposable"
+	IssueDuplicate  = "duplicate"
+	IssueRole       = "role"
+)
+
+// issueOrder lists issue kinds in the order reports print them.
+var issueOrder = []string{IssueMalformed, IssueBlankEmail, IssueInvalid, IssueDisposable, IssueDuplicate, IssueRole}
+
+// validateFlags are the options of `mailgrid validate`.
+type validateFlags struct {
+	json       bool
+	clean      string
+	rejected   string
+	rejectRole bool
+	disposable string
+	maxIssues  int
+}
+
+// runValidateCommand implements `mailgrid validate --csv <file>`.
+func runValidateCommand(argv []string) error {
+	var f validateFlags
+	args, rest, help, err := parseCommandFlags("validate", argv, false, func(fs *pflag.FlagSet, a *CLIArgs) {
+		fs.StringVarP(&a.CSVPath, "csv", "f", "", "Recipient list to check: CSV, .xlsx or .ods; - reads stdin")
+		registerInputFlags(fs, a)
+		fs.BoolVar(&f.json, "json", false, "Print the report as JSON instead of text")
+		fs.StringVar(&f.clean, "clean", "", "Write the sendable rows to this CSV")
+		fs.StringVar(&f.rejected, "rejected", "", "Write the rejected rows, with line and reason, to this CSV")
+		fs.BoolVar(&f.rejectRole, "reject-role", false, "Reject role addresses such as info@ instead of only reporting them")
+		fs.StringVar(&f.disposable, "disposable-domains", "", "File of further disposable domains, one per line")
+		fs.IntVar(&f.maxIssues, "max-issues", 100, "Issues listed per kind (0 lists all); counts always cover every row")
+	})
+	if err != nil || help {
+		return err
+	}
+	if args.CSVPath == "" && len(rest) == 1 {
+		args.CSVPath = rest[0]
+	} else if args.CSVPath == "" || len(rest) > 0 {
+		return errors.New(validateUsage)
+	}
+
+	opts := ValidateOptions{RejectRole: f.rejectRole, Disposable: parser.DisposableDomains(), MaxIssues: f.maxIssues}
+	if f.disposable != "" {
+		if err := loadDomains(opts.Disposable, f.disposable); err != nil {
+			return err
+		}
+	}
+	ropts, err := readOptions(args)
+	if err != nil {
+		return err
+	}
+	src, err := parser.OpenFile(args.CSVPath, ropts)
+	if err != nil {
+		return fmt.Errorf("failed to open recipient list: %w", err)
+	}
+	defer src.Close()
+	table, ok := src.(*parser.TableSource)
+	if !ok {
+		return fmt.Errorf("validate checks CSV and spreadsheet lists; %s is not one", args.CSVPath)
+	}
+
+	var outputs []*os.File
+	for _, out := range []struct {
+		path string
+		w    *io.Writer
+	}{{f.clean, &opts.Clean}, {f.rejected, &opts.Rejected}} {
+		if out.path == "" {
+			continue
+		}
+		file, err := os.Create(out.path)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", out.path, err)
+		}
+		defer file.Close()
+		outputs = append(outputs, file)
+		*out.w = file
+	}
+
+	report, err := ValidateList(table, args.CSVPath, opts)
+	if err != nil {
+		return err
+	}
+	for _, file := range outputs {
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file.Name(), err)
+		}
+	}
+
+	if f.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	report.WriteText(os.Stdout)
+	return nil
+}
+
+// loadDomains adds the domains listed in the file at path, one per line, to
+// set. Blank lines and lines starting with # are ignored.
+func loadDomains(set parser.DomainSet, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open domain list: %w", err)
+	}
+	defer file.Close()
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			set.Add(line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("failed to read domain list: %w", err)
+	}
+	return nil
+}
+
+// ValidateOptions configures ValidateList.
+type ValidateOptions struct {
+	RejectRole bool
+	Disposable parser.DomainSet // nil checks no domains
+	MaxIssues  int              // issues listed per kind; 0 lists all
+	Clean      io.Writer        // receives the sendable rows as CSV
+	Rejected   io.Writer        // receives the rejected rows as CSV
+}
+
+// ListIssue is a problem found with one row. Line is the 1-based input line
+// the row starts on and Row its 1-based position among the data rows, as
+// in the warnings logged while sending.
+type ListIssue struct {
+	Kind     string `json:"kind"`
+	Line     int    `json:"line"`
+	Row      int    `json:"row"`
+	Email    string `json:"email,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Rejected bool   `json:"rejected"`
+}
+
+// ListReport is the outcome of validating a recipient list.
+type ListReport struct {
+	Source   string         `json:"source"`
+	Rows     int            `json:"rows"`
+	Sendable int            `json:"sendable"`
+	Rejected int            `json:"rejected"`
+	Counts   map[string]int `json:"counts"`
+	// EmptyFields counts, per column, the sendable rows where the column is
+	// empty, so a template using it would render a blank.
+	EmptyFields map[string]int `json:"empty_fields"`
+	Issues      []ListIssue    `json:"issues"`
+	// Truncated is set when MaxIssues left issues out of Issues.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ValidateList reads every row of src and reports what would keep it from
+// being sent, or make sending it unwise: malformed rows, blank, invalid and
+// disposable addresses, duplicates and role mailboxes. Duplicates are
+// compared by parser.CanonicalEmail, so Gmail addresses differing only in
+// dots or a +tag count as one inbox; the first occurrence is kept. name
+// identifies the list in the report.
+func ValidateList(src *parser.TableSource, name string, opts ValidateOptions) (*ListReport, error) {
+	report := &ListReport{
+		Source:      name,
+		Counts:      make(map[string]int, len(issueOrder)),
+		EmptyFields: make(map[string]int),
+	}
+	columns := src.Columns()
+
+	var clean, rejected *csv.Writer
+	if opts.Clean != nil {
+		clean = csv.NewWriter(opts.Clean)
+		clean.Write(columns)
+	}
+	if opts.Rejected != nil {
+		rejected = csv.NewWriter(opts.Rejected)
+		rejected.Write(append([]string{"line", "reason"}, columns...))
+	}
+
+	add := func(issue ListIssue, record []string) {
+		report.Counts[issue.Kind]++
+		if opts.MaxIssues <= 0 || report.Counts[issue.Kind] <= opts.MaxIssues {
+			report.Issues = append(report.Issues, issue)
+		} else {
+			report.Truncated = true
+		}
+		if issue.Rejected {
+			report.Rejected++
+			if rejected != nil {
+				rejected.Write(append([]string{strconv.Itoa(issue.Line), issue.Kind}, record...))
+			}
+		}
+	}
+
+	src.OnSkip(func(s parser.SkippedRow) {
+		issue := ListIssue{Kind: s.Reason, Line: s.Line, Row: s.Row + 1, Email: s.Email, Rejected: true}
+		if s.Reason == parser.SkipMalformed {
+			issue.Detail = fmt.Sprintf("expected %d fields, got %d", len(columns), len(s.Record))
+			if s.Err != nil && !errors.Is(s.Err, csv.ErrFieldCount) {
+				issue.Detail = s.Err.Error()
+			}
+		}
+		add(issue, s.Record)
+	})
+
+	seen, err := parser.NewDiskDeduper("")
+	if err != nil {
+		return nil, err
+	}
+	defer seen.Close()
+
+	for {
+		r, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			if col == "email" {
+				record[i] = r.Email
+			} else {
+				record[i] = r.Data[col]
+			}
+		}
+		issue := ListIssue{Line: src.Line(), Row: r.Row + 1, Email: r.Email, Rejected: true}
+
+		canonical := parser.CanonicalEmail(r.Email)
+		dup, err := seen.Seen(canonical)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case opts.Disposable != nil && opts.Disposable.Matches(r.Email):
+			issue.Kind = IssueDisposable
+		case dup:
+			issue.Kind = IssueDuplicate
+			if canonical != strings.ToLower(r.Email) {
+				issue.Detail = "same inbox as " + canonical
+			}
+		case parser.IsRoleAddress(r.Email):
+			issue.Kind, issue.Rejected = IssueRole, opts.RejectRole
+		}
+		if issue.Kind != "" {
+			add(issue, record)
+			if issue.Rejected {
+				continue
+			}
+		}
+
+		report.Sendable++
+		for k, v := range r.Data {
+			if v == "" {
+				report.EmptyFields[k]++
+			}
+		}
+		if clean != nil {
+			clean.Write(record)
+		}
+	}
+	report.Rows = src.Rows()
+
+	for _, w := range []*csv.Writer{clean, rejected} {
+		if w == nil {
+			continue
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return nil, fmt.Errorf("failed to write CSV: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// WriteText prints the report for a terminal.
+func (r *ListReport) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%s: %d rows, %d sendable, %d rejected\n", r.Source, r.Rows, r.Sendable, r.Rejected)
+	for _, kind := range issueOrder {
+		if n := r.Counts[kind]; n > 0 {
+			fmt.Fprintf(w, "  %-15s %d\n", kind, n)
+		}
+	}
+
+	if len(r.EmptyFields) > 0 {
+		cols := make([]string, 0, len(r.EmptyFields))
+		for col := range r.EmptyFields {
+			cols = append(cols, col)
+		}
+		sort.Strings(cols)
+		fmt.Fprintf(w, "\nEmpty fields in sendable rows:\n")
+		for _, col := range cols {
+			fmt.Fprintf(w, "  %-20s %d\n", col, r.EmptyFields[col])
+		}
+	}
+
+	if len(r.Issues) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%-8s %-15s %-40s %s\n", "LINE", "ISSUE", "EMAIL", "DETAIL")
+	for _, is := range r.Issues {
+		detail := is.Detail
+		if !is.Rejected {
+			detail = strings.TrimSpace(detail + " (kept)")
+		}
+		fmt.Fprintf(w, "%-8d %-15s %-40s %s\n", is.Line, is.Kind, is.Email, detail)
+	}
+	if r.Truncated {
+		fmt.Fprintf(w, "\nSome issues were left out; raise --max-issues to list them.\n")
+	}
+}
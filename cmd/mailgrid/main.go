@@ -4,6 +4,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/bravo1goingdark/mailgrid/cli"
 	"github.com/bravo1goingdark/mailgrid/logger"
@@ -19,6 +20,15 @@ var (
 // main is the CLI entry point for mailgrid.
 // It parses CLI flags and delegates execution to the CLI runner.
 func main() {
+	// Subcommands (mailgrid retry ...) parse their own flags.
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		defer logger.FlushAndClose()
+		if err := cli.RunCommand(os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		return
+	}
+
 	// Parse CLI flags into a structured config
 	args := cli.ParseFlags()
 
@@ -17,14 +17,73 @@ type SMTPConfig struct {
 	TLSKeyFile  string `json:"tls_key_file,omitempty"`  // Path to client certificate
 	InsecureTLS bool   `json:"insecure_tls,omitempty"`  // Skip TLS verification (use with caution)
 
+	// Connection pool tuning. Zero values use the pool defaults.
+	MaxMessagesPerConn int `json:"max_messages_per_conn,omitempty"` // QUIT and reconnect after this many messages
+	MaxIdleSeconds     int `json:"max_idle_seconds,omitempty"`      // Close pooled connections idle longer than this
+
+	// VERPDomain, when set, makes each message's envelope sender a
+	// per-recipient bounce address bounces+{token}@VERPDomain so bounces can
+	// be traced to the exact recipient and campaign. The header From is
+	// unchanged. The domain must route bounces+* mail back to you.
+	VERPDomain string `json:"verp_domain,omitempty"`
+
 	// DialTimeout overrides the default 10-second TCP connect timeout.
 	// Zero means use the default.
 	DialTimeout time.Duration `json:"-"` // set from CLI flag, not the JSON file
 }
 
+// UnsubscribeConfig enables signed per-recipient unsubscribe links. Senders
+// and `mailgrid serve-unsubscribe` must share the same secret.
+type UnsubscribeConfig struct {
+	BaseURL string `json:"base_url,omitempty"` // public URL the endpoint is reachable at
+	Secret  string `json:"secret,omitempty"`   // HMAC-SHA256 key for link tokens
+}
+
+// TrackingConfig enables open and click tracking. Senders and
+// `mailgrid serve-tracking` must share the same secret.
+type TrackingConfig struct {
+	BaseURL string `json:"base_url,omitempty"` // public URL the tracking endpoint is reachable at
+	Secret  string `json:"secret,omitempty"`   // HMAC-SHA256 key for pixel and link tokens
+}
+
+// DatabaseConfig is the connection used by --query recipient lists.
+type DatabaseConfig struct {
+	Driver string `json:"driver,omitempty"` // sqlite, postgres or mysql
+	DSN    string `json:"dsn,omitempty"`    // driver-specific data source name
+}
+
+// SourceConfig governs recipient lists downloaded with --source-url.
+type SourceConfig struct {
+	AllowedHosts []string          `json:"allowed_hosts,omitempty"` // hosts lists may come from; "*.example.com" allows subdomains
+	Headers      map[string]string `json:"headers,omitempty"`       // sent with every request
+	BearerToken  string            `json:"bearer_token,omitempty"`  // sent as Authorization: Bearer
+	CacheDir     string            `json:"cache_dir,omitempty"`     // ETag cache; empty uses the user cache directory
+	NoCache      bool              `json:"no_cache,omitempty"`      // download in full every time
+}
+
+// GoogleConfig enables private Google Sheets for --sheet-url through a
+// service account the sheets are shared with.
+type GoogleConfig struct {
+	ServiceAccountFile string `json:"service_account_file,omitempty"` // JSON key of the service account
+	TokenURL           string `json:"token_url,omitempty"`            // OAuth token endpoint override
+	SheetsAPIURL       string `json:"sheets_api_url,omitempty"`       // Sheets API root override
+}
+
+// DNSConfig selects the resolver used by --mx-check.
+type DNSConfig struct {
+	Server         string `json:"server,omitempty"`          // host[:port] of a DNS server; empty uses the system resolver
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // per-domain lookup timeout (default 5)
+}
+
 type AppConfig struct {
-	SMTP      SMTPConfig `json:"smtp"`
-	TimeoutMs int        `json:"timeout_ms"` // smtp timeout in milliseconds
+	SMTP        SMTPConfig        `json:"smtp"`
+	TimeoutMs   int               `json:"timeout_ms"` // smtp timeout in milliseconds
+	Unsubscribe UnsubscribeConfig `json:"unsubscribe,omitempty"`
+	Tracking    TrackingConfig    `json:"tracking,omitempty"`
+	Database    DatabaseConfig    `json:"database,omitempty"`
+	Source      SourceConfig      `json:"source,omitempty"`
+	Google      GoogleConfig      `json:"google,omitempty"`
+	DNS         DNSConfig         `json:"dns,omitempty"`
 }
 
 // Validate checks that all required SMTP fields are present.
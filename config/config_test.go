@@ -74,3 +74,19 @@ func TestLoadConfigInvalidJSON(t *testing.T) {
 		t.Error("Expected error when loading invalid JSON config file")
 	}
 }
+
+func TestLoadConfigPoolSettings(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "pool.json")
+	data := `{"smtp": {"host": "smtp.example.com", "port": 587, "max_messages_per_conn": 50, "max_idle_seconds": 45}}`
+	if err := os.WriteFile(configFile, []byte(data), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := LoadConfig(configFile)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if config.SMTP.MaxMessagesPerConn != 50 || config.SMTP.MaxIdleSeconds != 45 {
+		t.Errorf("pool settings not loaded: %+v", config.SMTP)
+	}
+}
@@ -6,25 +6,69 @@ import (
 	"github.com/bravo1goingdark/mailgrid/internal/types"
 	"github.com/pkg/errors"
 	"go.etcd.io/bbolt"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	jobsBucket     = "jobs"
-	lockBucket     = "locks"
-	lockExpiryTime = 5 * time.Minute
+	jobsBucket        = "jobs"
+	lockBucket        = "locks"
+	deferredBucket    = "deferred"
+	campaignsBucket   = "campaigns"
+	bouncesBucket     = "bounces"
+	suppressionBucket = "suppressions"
+	complaintsBucket  = "complaints"
+	engagementBucket  = "engagement"
+	groupsBucket      = "groups"
+	lockExpiryTime    = 5 * time.Minute
+
+	// openTimeout bounds how long NewDB waits for another process to release
+	// the file lock. Without it a second mailgrid process blocks forever.
+	openTimeout = 5 * time.Second
 )
 
 // BoltDBClient is a wrapper around bbolt.DB for job persistence.
 type BoltDBClient struct {
-	db *bbolt.DB
+	db     *bbolt.DB
+	shared *sharedDB
+	once   sync.Once
+}
+
+// sharedDB is a BoltDB handle shared by every NewDB call for the same path in
+// this process. bbolt holds an exclusive file lock, so a second bbolt.Open of
+// the same file from the same process (e.g. a scheduled campaign reading the
+// suppression list while the scheduler owns the database) would deadlock.
+type sharedDB struct {
+	db   *bbolt.DB
+	path string
+	refs int
 }
 
-// NewDB opens a BoltDB database and initializes necessary buckets.
+var (
+	openMu sync.Mutex
+	openDB = make(map[string]*sharedDB)
+)
+
+// NewDB opens a BoltDB database and initializes necessary buckets. Opening a
+// path that is already open in this process returns a client sharing the
+// existing handle; each client must still be closed.
 func NewDB(path string) (*BoltDBClient, error) {
-	db, err := bbolt.Open(path, 0600, nil)
+	key := path
+	if abs, err := filepath.Abs(path); err == nil {
+		key = abs
+	}
+
+	openMu.Lock()
+	defer openMu.Unlock()
+	if s, ok := openDB[key]; ok {
+		s.refs++
+		return &BoltDBClient{db: s.db, shared: s}, nil
+	}
+
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: openTimeout})
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to open BoltDB at %s", path)
 	}
@@ -38,19 +82,38 @@ func NewDB(path string) (*BoltDBClient, error) {
 		if err != nil {
 			return errors.Wrapf(err, "create %s bucket", lockBucket)
 		}
+		for _, name := range []string{deferredBucket, campaignsBucket, bouncesBucket, suppressionBucket, complaintsBucket, engagementBucket, groupsBucket} {
+			if _, err := tx.CreateBucketIfNotExists([]byte(name)); err != nil {
+				return errors.Wrapf(err, "create %s bucket", name)
+			}
+		}
 		return nil
 	})
 	if err != nil {
+		db.Close()
 		return nil, errors.Wrap(err, "failed to initialize BoltDB buckets")
 	}
 
-	return &BoltDBClient{db: db},
-		nil
+	s := &sharedDB{db: db, path: key, refs: 1}
+	openDB[key] = s
+	return &BoltDBClient{db: db, shared: s}, nil
 }
 
-// Close closes the BoltDB database.
+// Close releases this client. The underlying database is closed once every
+// client sharing it has been closed. Closing a client twice is a no-op.
 func (c *BoltDBClient) Close() error {
-	return c.db.Close()
+	var err error
+	c.once.Do(func() {
+		openMu.Lock()
+		defer openMu.Unlock()
+		c.shared.refs--
+		if c.shared.refs > 0 {
+			return
+		}
+		delete(openDB, c.shared.path)
+		err = c.db.Close()
+	})
+	return err
 }
 
 // SaveJob saves a job to the BoltDB database.
@@ -103,6 +166,39 @@ func (c *BoltDBClient) LoadJobs() ([]types.Job, error) {
 	return jobs, nil
 }
 
+// PutDeferred stores an encoded deferred-retry entry under key.
+func (c *BoltDBClient) PutDeferred(key string, value []byte) error {
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(deferredBucket))
+		return errors.Wrap(b.Put([]byte(key), value), "could not put deferred task")
+	})
+}
+
+// DeleteDeferred removes the deferred-retry entry stored under key.
+func (c *BoltDBClient) DeleteDeferred(key string) error {
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(deferredBucket))
+		return errors.Wrap(b.Delete([]byte(key)), "could not delete deferred task")
+	})
+}
+
+// LoadDeferred returns every persisted deferred-retry entry by key.
+func (c *BoltDBClient) LoadDeferred() (map[string][]byte, error) {
+	out := make(map[string][]byte)
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(deferredBucket))
+		return b.ForEach(func(k, v []byte) error {
+			// Copy: bbolt values are only valid for the life of the transaction.
+			out[string(k)] = append([]byte(nil), v...)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // parseLockInfo parses lock information from lock value
 func parseLockInfo(lockData []byte) (instanceID string, lockedAt time.Time, err error) {
 	parts := strings.Split(string(lockData), ":")
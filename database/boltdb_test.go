@@ -262,3 +262,214 @@ func TestParseLockInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestBoltDB_DeferredRoundTrip(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create BoltDB: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.PutDeferred("a@example.com", []byte(`{"n":1}`)); err != nil {
+		t.Fatalf("PutDeferred: %v", err)
+	}
+	if err := db.PutDeferred("b@example.com", []byte(`{"n":2}`)); err != nil {
+		t.Fatalf("PutDeferred: %v", err)
+	}
+	if err := db.DeleteDeferred("a@example.com"); err != nil {
+		t.Fatalf("DeleteDeferred: %v", err)
+	}
+
+	got, err := db.LoadDeferred()
+	if err != nil {
+		t.Fatalf("LoadDeferred: %v", err)
+	}
+	if len(got) != 1 || string(got["b@example.com"]) != `{"n":2}` {
+		t.Errorf("LoadDeferred() = %v, want only b@example.com", got)
+	}
+}
+
+func TestNewDB_SharesHandleWithinProcess(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	first, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("first NewDB: %v", err)
+	}
+	// A second open of the same file would block on bbolt's file lock if the
+	// handle were not shared.
+	second, err := NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("second NewDB: %v", err)
+	}
+	if err := first.Close(); err != nil {
+		t.Fatalf("close first: %v", err)
+	}
+	if err := second.SaveJob(&types.Job{ID: "still-open"}); err != nil {
+		t.Fatalf("handle closed while still referenced: %v", err)
+	}
+	if err := second.Close(); err != nil {
+		t.Fatalf("close second: %v", err)
+	}
+	if err := second.Close(); err != nil {
+		t.Errorf("double close should be a no-op, got %v", err)
+	}
+}
+
+func TestBoltDB_SuppressionsAndBounces(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	created, err := db.SaveSuppression(&types.Suppression{Email: "Alice@Example.com", Reason: "hard-bounce"})
+	if err != nil || !created {
+		t.Fatalf("SaveSuppression = %v, %v", created, err)
+	}
+	if created, _ := db.SaveSuppression(&types.Suppression{Email: "alice@example.com"}); created {
+		t.Error("suppression keys should be case-insensitive")
+	}
+	if created, _ := db.SaveSuppression(&types.Suppression{Email: "alice@example.com", Scope: "newsletter"}); !created {
+		t.Error("a scoped entry should be stored alongside the global one")
+	}
+	if existed, err := db.DeleteSuppression("ALICE@example.com", ""); err != nil || !existed {
+		t.Errorf("DeleteSuppression = %v, %v", existed, err)
+	}
+	if list, _ := db.LoadSuppressions(); len(list) != 1 || list[0].Scope != "newsletter" {
+		t.Errorf("expected only the scoped entry to remain, got %+v", list)
+	}
+	if existed, _ := db.DeleteSuppression("alice@example.com", "newsletter"); !existed {
+		t.Error("expected the scoped entry to be deleted")
+	}
+
+	for _, ev := range []types.BounceEvent{
+		{CampaignID: "c1", Email: "a@example.com", Class: "hard"},
+		{CampaignID: "c1", Email: "b@example.com", Class: "soft"},
+		{CampaignID: "c1", Email: "b@example.com", Class: "hard"}, // replaces the soft bounce
+		{CampaignID: "c10", Email: "a@example.com", Class: "soft"},
+	} {
+		ev := ev
+		if _, err := db.RecordBounce(&ev); err != nil {
+			t.Fatalf("RecordBounce: %v", err)
+		}
+	}
+	c, err := db.GetCampaign("c1")
+	if err != nil {
+		t.Fatalf("GetCampaign: %v", err)
+	}
+	if c.HardBounces != 2 || c.SoftBounces != 0 {
+		t.Errorf("c1 counters = %d hard / %d soft", c.HardBounces, c.SoftBounces)
+	}
+	if events, _ := db.LoadBounces("c10"); len(events) != 1 {
+		t.Errorf("c10 events = %+v", events)
+	}
+}
+
+func TestBoltDB_RecordComplaint(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	for i, ev := range []types.ComplaintEvent{
+		{CampaignID: "c1", Email: "a@example.com", FeedbackType: "abuse"},
+		{CampaignID: "c1", Email: "a@example.com", FeedbackType: "abuse"}, // duplicate report
+		{CampaignID: "c1", Email: "b@example.com", FeedbackType: "fraud"},
+		{CampaignID: "c10", Email: "a@example.com", FeedbackType: "abuse"},
+	} {
+		created, err := db.RecordComplaint(&ev)
+		if err != nil {
+			t.Fatalf("RecordComplaint: %v", err)
+		}
+		if created == (i == 1) {
+			t.Errorf("event %d: created = %v", i, created)
+		}
+	}
+	c1, err := db.GetCampaign("c1")
+	if err != nil || c1.Complaints != 2 {
+		t.Errorf("c1 = %+v, %v", c1, err)
+	}
+	if events, _ := db.LoadComplaints("c1"); len(events) != 2 {
+		t.Errorf("expected 2 complaints for c1 (not c10), got %+v", events)
+	}
+}
+
+func TestBoltDB_RecordEngagement(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	err = db.RecordEngagement([]types.EngagementEvent{
+		{CampaignID: "c1", Email: "a@example.com", Kind: "open", At: now},
+		{CampaignID: "c1", Email: "a@example.com", Kind: "open", At: now.Add(time.Minute)},
+		{CampaignID: "c1", Email: "b@example.com", Kind: "click", URL: "https://example.com/x", At: now},
+		{CampaignID: "c10", Email: "a@example.com", Kind: "open", At: now},
+	})
+	if err != nil {
+		t.Fatalf("RecordEngagement: %v", err)
+	}
+	// A later click by a recipient who already opened only adds to Clicked.
+	if err := db.RecordEngagement([]types.EngagementEvent{
+		{CampaignID: "c1", Email: "a@example.com", Kind: "click", URL: "https://example.com/x", At: now},
+	}); err != nil {
+		t.Fatalf("RecordEngagement: %v", err)
+	}
+
+	c1, err := db.GetCampaign("c1")
+	if err != nil || c1.Opened != 2 || c1.Clicked != 2 {
+		t.Errorf("c1 = %+v, %v", c1, err)
+	}
+	got, err := db.LoadEngagement("c1")
+	if err != nil || len(got) != 2 {
+		t.Fatalf("expected 2 recipients for c1 (not c10), got %+v, %v", got, err)
+	}
+	for _, e := range got {
+		if e.Email == "a@example.com" && (e.Opens != 2 || e.Clicks != 1 || !e.LastOpenAt.After(e.FirstOpenAt)) {
+			t.Errorf("a = %+v", e)
+		}
+		if e.Email == "b@example.com" && (e.Opens != 0 || e.Links["https://example.com/x"] != 1) {
+			t.Errorf("b = %+v", e)
+		}
+	}
+}
+
+func TestBoltDB_GroupMembers(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	err = db.SaveGroupMembers([]types.GroupMember{
+		{CampaignID: "c1", Email: "a@example.com", Group: "holdout"},
+		{CampaignID: "c1", Email: "b@example.com", Group: "selected"},
+		{CampaignID: "c10", Email: "a@example.com", Group: "selected"},
+	})
+	if err != nil {
+		t.Fatalf("SaveGroupMembers: %v", err)
+	}
+	// Recording the variant later keeps the group, and the other way round.
+	if err := db.SaveGroupMembers([]types.GroupMember{{CampaignID: "c1", Email: "b@example.com", Variant: "B"}}); err != nil {
+		t.Fatalf("SaveGroupMembers: %v", err)
+	}
+	if err := db.SaveGroupMembers([]types.GroupMember{{CampaignID: "c1", Email: "b@example.com", Group: "selected"}}); err != nil {
+		t.Fatalf("SaveGroupMembers: %v", err)
+	}
+	got, err := db.LoadGroupMembers("c1")
+	if err != nil || len(got) != 2 {
+		t.Fatalf("expected 2 members for c1 (not c10), got %+v, %v", got, err)
+	}
+	for _, m := range got {
+		if (m.Email == "a@example.com") != (m.Group == "holdout") {
+			t.Errorf("member = %+v", m)
+		}
+		if m.Email == "b@example.com" && (m.Group != "selected" || m.Variant != "B") {
+			t.Errorf("b = %+v", m)
+		}
+	}
+}
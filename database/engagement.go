@@ -0,0 +1,126 @@
+package database
+
+import (
+	"encoding/json"
+
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/pkg/errors"
+	"go.etcd.io/bbolt"
+)
+
+// RecordEngagement folds tracked opens and clicks into the per-recipient
+// engagement records, keyed by campaign and recipient, and refreshes the
+// Opened/Clicked counters of the affected campaigns. A click also counts as
+// an open, since the recipient evidently read the message even if images were
+// blocked. All events are written in one transaction.
+func (c *BoltDBClient) RecordEngagement(events []types.EngagementEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(engagementBucket))
+		// newly engaged recipients per campaign: [opened, clicked]
+		added := map[string]*[2]int{}
+
+		for _, ev := range events {
+			key := bounceKey(ev.CampaignID, ev.Email)
+			var e types.Engagement
+			if v := b.Get(key); v != nil {
+				if err := json.Unmarshal(v, &e); err != nil {
+					return errors.Wrap(err, "could not unmarshal engagement")
+				}
+			} else {
+				e = types.Engagement{CampaignID: ev.CampaignID, Email: ev.Email}
+			}
+			wasOpened, wasClicked := e.Opens > 0 || e.Clicks > 0, e.Clicks > 0
+
+			switch ev.Kind {
+			case "open":
+				e.Opens++
+				if e.FirstOpenAt.IsZero() || ev.At.Before(e.FirstOpenAt) {
+					e.FirstOpenAt = ev.At
+				}
+				if ev.At.After(e.LastOpenAt) {
+					e.LastOpenAt = ev.At
+				}
+			case "click":
+				e.Clicks++
+				if e.FirstClickAt.IsZero() || ev.At.Before(e.FirstClickAt) {
+					e.FirstClickAt = ev.At
+				}
+				if ev.At.After(e.LastClickAt) {
+					e.LastClickAt = ev.At
+				}
+				if ev.URL != "" {
+					if e.Links == nil {
+						e.Links = map[string]int{}
+					}
+					e.Links[ev.URL]++
+				}
+			default:
+				return errors.Errorf("unknown engagement kind %q", ev.Kind)
+			}
+
+			if !wasOpened || (!wasClicked && e.Clicks > 0) {
+				d := added[ev.CampaignID]
+				if d == nil {
+					d = &[2]int{}
+					added[ev.CampaignID] = d
+				}
+				if !wasOpened {
+					d[0]++
+				}
+				if !wasClicked && e.Clicks > 0 {
+					d[1]++
+				}
+			}
+			encoded, err := json.Marshal(&e)
+			if err != nil {
+				return errors.Wrap(err, "could not marshal engagement")
+			}
+			if err := b.Put(key, encoded); err != nil {
+				return errors.Wrap(err, "could not put engagement")
+			}
+		}
+
+		for id, d := range added {
+			if id == "" {
+				continue
+			}
+			campaign, err := getCampaign(tx, id)
+			if err != nil {
+				return err
+			}
+			if campaign == nil {
+				campaign = &types.Campaign{ID: id}
+			}
+			campaign.Opened += d[0]
+			campaign.Clicked += d[1]
+			if err := putCampaign(tx, campaign); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// LoadEngagement returns the engagement records of a campaign.
+func (c *BoltDBClient) LoadEngagement(campaignID string) ([]types.Engagement, error) {
+	var out []types.Engagement
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		prefix := bounceKey(campaignID, "")
+		cur := tx.Bucket([]byte(engagementBucket)).Cursor()
+		for k, v := cur.Seek(prefix); k != nil && hasPrefix(k, prefix); k, v = cur.Next() {
+			var e types.Engagement
+			if err := json.Unmarshal(v, &e); err != nil {
+				return errors.Wrap(err, "could not unmarshal engagement")
+			}
+			out = append(out, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
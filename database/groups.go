@@ -0,0 +1,67 @@
+package database
+
+import (
+	"encoding/json"
+
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/pkg/errors"
+	"go.etcd.io/bbolt"
+)
+
+// SaveGroupMembers stores which group and variant each recipient of a
+// campaign is in, keyed by campaign and recipient, in one transaction.
+// Saving a recipient again replaces the fields that are set and keeps the
+// saved value of those left empty.
+func (c *BoltDBClient) SaveGroupMembers(members []types.GroupMember) error {
+	if len(members) == 0 {
+		return nil
+	}
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(groupsBucket))
+		for i := range members {
+			m := members[i]
+			key := bounceKey(m.CampaignID, m.Email)
+			if v := b.Get(key); v != nil && (m.Group == "" || m.Variant == "") {
+				var saved types.GroupMember
+				if err := json.Unmarshal(v, &saved); err != nil {
+					return errors.Wrap(err, "could not unmarshal group member")
+				}
+				if m.Group == "" {
+					m.Group = saved.Group
+				}
+				if m.Variant == "" {
+					m.Variant = saved.Variant
+				}
+			}
+			encoded, err := json.Marshal(&m)
+			if err != nil {
+				return errors.Wrap(err, "could not marshal group member")
+			}
+			if err := b.Put(key, encoded); err != nil {
+				return errors.Wrap(err, "could not put group member")
+			}
+		}
+		return nil
+	})
+}
+
+// LoadGroupMembers returns the group memberships recorded for a campaign.
+func (c *BoltDBClient) LoadGroupMembers(campaignID string) ([]types.GroupMember, error) {
+	var out []types.GroupMember
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		prefix := bounceKey(campaignID, "")
+		cur := tx.Bucket([]byte(groupsBucket)).Cursor()
+		for k, v := cur.Seek(prefix); k != nil && hasPrefix(k, prefix); k, v = cur.Next() {
+			var m types.GroupMember
+			if err := json.Unmarshal(v, &m); err != nil {
+				return errors.Wrap(err, "could not unmarshal group member")
+			}
+			out = append(out, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
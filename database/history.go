@@ -0,0 +1,212 @@
+package database
+
+import (
+	"encoding/json"
+
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/pkg/errors"
+	"go.etcd.io/bbolt"
+)
+
+// SaveCampaign creates or replaces a campaign history record.
+func (c *BoltDBClient) SaveCampaign(campaign *types.Campaign) error {
+	return c.db.Update(func(tx *bbolt.Tx) error {
+		return putCampaign(tx, campaign)
+	})
+}
+
+// GetCampaign retrieves a campaign history record by job ID.
+func (c *BoltDBClient) GetCampaign(id string) (*types.Campaign, error) {
+	var campaign *types.Campaign
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		campaign, err = getCampaign(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		return nil, errors.New("campaign not found")
+	}
+	return campaign, nil
+}
+
+// LoadCampaigns returns every campaign history record.
+func (c *BoltDBClient) LoadCampaigns() ([]types.Campaign, error) {
+	var campaigns []types.Campaign
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(campaignsBucket)).ForEach(func(k, v []byte) error {
+			var campaign types.Campaign
+			if err := json.Unmarshal(v, &campaign); err != nil {
+				return errors.Wrap(err, "could not unmarshal campaign")
+			}
+			campaigns = append(campaigns, campaign)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return campaigns, nil
+}
+
+// RecordBounce stores a bounce event and refreshes the bounce counters of its
+// campaign, creating a minimal campaign record if none exists. Events are
+// keyed by campaign and recipient, so ingesting the same bounce twice leaves
+// the counters unchanged. It reports whether the event was new.
+func (c *BoltDBClient) RecordBounce(ev *types.BounceEvent) (bool, error) {
+	var created bool
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(bouncesBucket))
+		key := bounceKey(ev.CampaignID, ev.Email)
+		created = b.Get(key) == nil
+
+		encoded, err := json.Marshal(ev)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal bounce")
+		}
+		if err := b.Put(key, encoded); err != nil {
+			return errors.Wrap(err, "could not put bounce")
+		}
+		if ev.CampaignID == "" {
+			return nil
+		}
+
+		campaign, err := getCampaign(tx, ev.CampaignID)
+		if err != nil {
+			return err
+		}
+		if campaign == nil {
+			campaign = &types.Campaign{ID: ev.CampaignID}
+		}
+		campaign.HardBounces, campaign.SoftBounces = 0, 0
+		prefix := bounceKey(ev.CampaignID, "")
+		cur := b.Cursor()
+		for k, v := cur.Seek(prefix); k != nil && hasPrefix(k, prefix); k, v = cur.Next() {
+			var e types.BounceEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return errors.Wrap(err, "could not unmarshal bounce")
+			}
+			if e.Class == "hard" {
+				campaign.HardBounces++
+			} else {
+				campaign.SoftBounces++
+			}
+		}
+		return putCampaign(tx, campaign)
+	})
+	return created, err
+}
+
+// LoadBounces returns the bounce events recorded for a campaign.
+func (c *BoltDBClient) LoadBounces(campaignID string) ([]types.BounceEvent, error) {
+	var events []types.BounceEvent
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		prefix := bounceKey(campaignID, "")
+		cur := tx.Bucket([]byte(bouncesBucket)).Cursor()
+		for k, v := cur.Seek(prefix); k != nil && hasPrefix(k, prefix); k, v = cur.Next() {
+			var e types.BounceEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return errors.Wrap(err, "could not unmarshal bounce")
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// RecordComplaint stores a spam complaint and refreshes the complaint counter
+// of its campaign, creating a minimal campaign record if none exists.
+// Re-ingesting the same report leaves the counter unchanged. It reports
+// whether the event was new.
+func (c *BoltDBClient) RecordComplaint(ev *types.ComplaintEvent) (bool, error) {
+	var created bool
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(complaintsBucket))
+		key := bounceKey(ev.CampaignID, ev.Email)
+		created = b.Get(key) == nil
+
+		encoded, err := json.Marshal(ev)
+		if err != nil {
+			return errors.Wrap(err, "could not marshal complaint")
+		}
+		if err := b.Put(key, encoded); err != nil {
+			return errors.Wrap(err, "could not put complaint")
+		}
+		if ev.CampaignID == "" {
+			return nil
+		}
+
+		campaign, err := getCampaign(tx, ev.CampaignID)
+		if err != nil {
+			return err
+		}
+		if campaign == nil {
+			campaign = &types.Campaign{ID: ev.CampaignID}
+		}
+		campaign.Complaints = 0
+		prefix := bounceKey(ev.CampaignID, "")
+		cur := b.Cursor()
+		for k, _ := cur.Seek(prefix); k != nil && hasPrefix(k, prefix); k, _ = cur.Next() {
+			campaign.Complaints++
+		}
+		return putCampaign(tx, campaign)
+	})
+	return created, err
+}
+
+// LoadComplaints returns the complaint events recorded for a campaign.
+func (c *BoltDBClient) LoadComplaints(campaignID string) ([]types.ComplaintEvent, error) {
+	var events []types.ComplaintEvent
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		prefix := bounceKey(campaignID, "")
+		cur := tx.Bucket([]byte(complaintsBucket)).Cursor()
+		for k, v := cur.Seek(prefix); k != nil && hasPrefix(k, prefix); k, v = cur.Next() {
+			var e types.ComplaintEvent
+			if err := json.Unmarshal(v, &e); err != nil {
+				return errors.Wrap(err, "could not unmarshal complaint")
+			}
+			events = append(events, e)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func putCampaign(tx *bbolt.Tx, campaign *types.Campaign) error {
+	encoded, err := json.Marshal(campaign)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal campaign")
+	}
+	return errors.Wrap(tx.Bucket([]byte(campaignsBucket)).Put([]byte(campaign.ID), encoded), "could not put campaign")
+}
+
+func getCampaign(tx *bbolt.Tx, id string) (*types.Campaign, error) {
+	val := tx.Bucket([]byte(campaignsBucket)).Get([]byte(id))
+	if val == nil {
+		return nil, nil
+	}
+	var campaign types.Campaign
+	if err := json.Unmarshal(val, &campaign); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal campaign")
+	}
+	return &campaign, nil
+}
+
+// bounceKey is "<campaign>\x00<email>"; NUL cannot appear in either part.
+// Complaints use the same layout.
+func bounceKey(campaignID, email string) []byte {
+	return []byte(campaignID + "\x00" + email)
+}
+
+func hasPrefix(k, prefix []byte) bool {
+	return len(k) >= len(prefix) && string(k[:len(prefix)]) == string(prefix)
+}
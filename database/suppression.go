@@ -0,0 +1,94 @@
+package database
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/pkg/errors"
+	"go.etcd.io/bbolt"
+)
+
+// suppressionKey normalises an address so lookups are case-insensitive.
+// Global entries are keyed by the address alone; scoped entries append
+// "\x00<scope>" so one address can be suppressed for several lists.
+func suppressionKey(email, scope string) []byte {
+	key := strings.ToLower(strings.TrimSpace(email))
+	if scope = strings.TrimSpace(scope); scope != "" {
+		key += "\x00" + scope
+	}
+	return []byte(key)
+}
+
+// SaveSuppression adds or replaces a suppression entry for s.Email in
+// s.Scope. It reports whether the address was not suppressed in that scope
+// before.
+func (c *BoltDBClient) SaveSuppression(s *types.Suppression) (bool, error) {
+	n, err := c.SaveSuppressions([]types.Suppression{*s})
+	return n == 1, err
+}
+
+// SaveSuppressions stores entries in a single transaction and returns how
+// many were new. Use it for bulk imports; one transaction per row costs an
+// fsync each.
+func (c *BoltDBClient) SaveSuppressions(entries []types.Suppression) (int, error) {
+	created := 0
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(suppressionBucket))
+		for i := range entries {
+			s := &entries[i]
+			if strings.TrimSpace(s.Email) == "" {
+				return errors.New("suppression email is empty")
+			}
+			key := suppressionKey(s.Email, s.Scope)
+			if b.Get(key) == nil {
+				created++
+			}
+			encoded, err := json.Marshal(s)
+			if err != nil {
+				return errors.Wrap(err, "could not marshal suppression")
+			}
+			if err := b.Put(key, encoded); err != nil {
+				return errors.Wrap(err, "could not put suppression")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+// DeleteSuppression removes an address from the suppression list of scope
+// ("" for the global list). It reports whether the entry was present.
+func (c *BoltDBClient) DeleteSuppression(email, scope string) (bool, error) {
+	var existed bool
+	err := c.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte(suppressionBucket))
+		key := suppressionKey(email, scope)
+		existed = b.Get(key) != nil
+		return errors.Wrap(b.Delete(key), "could not delete suppression")
+	})
+	return existed, err
+}
+
+// LoadSuppressions returns every suppression entry across all scopes,
+// ordered by address.
+func (c *BoltDBClient) LoadSuppressions() ([]types.Suppression, error) {
+	var out []types.Suppression
+	err := c.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(suppressionBucket)).ForEach(func(k, v []byte) error {
+			var s types.Suppression
+			if err := json.Unmarshal(v, &s); err != nil {
+				return errors.Wrap(err, "could not unmarshal suppression")
+			}
+			out = append(out, s)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -22,7 +22,13 @@ type Task struct {
 	Attachments []string
 	CC          []string
 	BCC         []string
-	Index       int // Position in original task list for offset tracking
+	Index       int    // Row in the recipient list for offset tracking
+	Skipped     int    // Rows just before Index that produced no task; marked complete with it
+	JobID       string // Campaign/job the task belongs to; set by the dispatcher when empty
+	Variant     string // A/B test variant the recipient was assigned; empty outside tests
+	// UnsubscribeURL, when set, is advertised in List-Unsubscribe with
+	// RFC 8058 one-click support.
+	UnsubscribeURL string
 }
 
 // OffsetTracker interface for tracking email delivery progress.
@@ -32,11 +38,15 @@ type Task struct {
 // mark — the saved offset advances only past indices that have all been
 // completed, even when workers finish out of order.
 //
+// MarkCompleteRange does the same for every index in [from, to); it is used
+// for tasks that carry Skipped positions.
+//
 // UpdateOffset and Save remain on the interface for backward compatibility
 // with external callers (CLI cleanup paths, tests).
 type OffsetTracker interface {
 	UpdateOffset(offset int)
 	MarkComplete(idx int)
+	MarkCompleteRange(from, to int)
 	Save() error
 }
 
@@ -49,9 +59,16 @@ type worker struct {
 	Monitor         monitor.Monitor
 	Tracker         OffsetTracker
 	AttachmentCache *AttachmentCache
+	Pool            *ConnPool
 	Ctx             context.Context
 	Sent            *atomic.Int64
 	Failed          *atomic.Int64
+	// Deferred parks failed tasks until their backoff elapses; Settled is
+	// signalled once per task that reached a final outcome (sent or failed).
+	Deferred *retryQueue
+	Settled  chan<- struct{}
+	JobID    string
+	OnSent   func(Task)
 }
 
 // maxInt returns the larger of two integers
@@ -84,6 +101,17 @@ type DispatchOptions struct {
 	// slice automatically; streaming callers should supply it from their
 	// recipient list so the dashboard shows everyone in Pending state.
 	PendingEmails []string
+	// DeferredStore, when set, persists tasks parked in the retry queue so
+	// they survive a crash. Entries an earlier run of JobID left over are
+	// re-queued at dispatch start and take precedence over fresh copies of
+	// the same recipient; those of other jobs are left alone.
+	DeferredStore DeferredStore
+	// JobID tags permanent failures in failed.csv so `mailgrid retry
+	// --campaign` can select them later.
+	JobID string
+	// OnSent, when set, is called with each task once it has been sent. It
+	// is called from the workers, concurrently.
+	OnSent func(Task)
 }
 
 // DispatchResult holds summary statistics from a dispatch run.
@@ -182,7 +210,7 @@ func StartDispatcherStream(ctx context.Context, taskCh <-chan Task, cfg config.S
 	return runDispatch(ctx, taskCh, cfg, concurrency, batchSize, opts)
 }
 
-// runDispatch implements the worker pool, attachment cache, and offset flusher
+// runDispatch implements the worker pool, connection pool, attachment cache, and offset flusher
 // shared by both the slice- and channel-based entry points.
 func runDispatch(ctx context.Context, taskCh <-chan Task, cfg config.SMTPConfig, concurrency, batchSize int, opts *DispatchOptions) DispatchResult {
 	var sent atomic.Int64
@@ -204,21 +232,39 @@ func runDispatch(ctx context.Context, taskCh <-chan Task, cfg config.SMTPConfig,
 	// only call tracker.MarkComplete; the flusher takes care of disk syncs.
 	stopFlusher := startOffsetFlusher(tracker, opts.OffsetSaveInterval)
 
+	// Workers check SMTP sessions out of a shared pool per message instead of
+	// pinning one connection each for the whole run.
+	pool := NewConnPool(cfg, PoolOptionsFromConfig(cfg, concurrency))
+	defer pool.Close()
+
+	// Workers read from an internal queue fed by both the caller's channel
+	// and the retry queue, so a backoff never pins a worker.
+	deferred := newRetryQueue(opts.DeferredStore, opts.JobID)
+	restored := deferred.Restore()
+	work := make(chan Task, concurrency*batchSize)
+	settled := make(chan struct{}, concurrency*batchSize)
+	go feedTasks(ctx, taskCh, work, settled, deferred, restored, opts.JobID)
+
 	var wg sync.WaitGroup
 	wg.Add(concurrency)
 	for i := 0; i < concurrency; i++ {
 		go startWorker(worker{
 			ID:              i + 1,
-			TaskQueue:       taskCh,
+			TaskQueue:       work,
 			Config:          cfg,
 			Wg:              &wg,
 			BatchSize:       batchSize,
 			Monitor:         mon,
 			Tracker:         tracker,
 			AttachmentCache: cache,
+			Pool:            pool,
 			Ctx:             ctx,
 			Sent:            &sent,
 			Failed:          &failed,
+			Deferred:        deferred,
+			Settled:         settled,
+			JobID:           opts.JobID,
+			OnSent:          opts.OnSent,
 		})
 	}
 
@@ -242,6 +288,100 @@ func runDispatch(ctx context.Context, taskCh <-chan Task, cfg config.SMTPConfig,
 	}
 }
 
+// feedTasks merges fresh tasks from src with due retries from deferred and
+// hands them to workers over work. It closes work once src is drained and
+// every task it handed out has settled (sent or permanently failed), or when
+// ctx is cancelled. Due retries are preferred over fresh tasks so a recovering
+// relay clears its backlog first.
+//
+// restored holds the recipients of tasks re-queued from a DeferredStore;
+// fresh tasks for them are dropped so a resumed run does not send twice.
+// Fresh tasks without a JobID are given jobID before any worker sees them,
+// so their persisted retries are stored under the job.
+func feedTasks(ctx context.Context, src <-chan Task, work chan<- Task, settled <-chan struct{}, deferred *retryQueue, restored map[string]struct{}, jobID string) {
+	defer close(work)
+
+	outstanding := deferred.Len()
+	srcOpen := true
+	var next *Task
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		if next == nil {
+			if t, ok := deferred.PopDue(time.Now()); ok {
+				next = &t
+			}
+		}
+		if !srcOpen && outstanding == 0 && next == nil {
+			return
+		}
+
+		var out chan<- Task
+		var in <-chan Task
+		if next != nil {
+			out = work
+		} else if srcOpen {
+			in = src
+		}
+
+		var due <-chan time.Time
+		if next == nil {
+			if at, ok := deferred.NextDue(); ok {
+				if timer == nil {
+					timer = time.NewTimer(time.Until(at))
+				} else {
+					if !timer.Stop() {
+						select {
+						case <-timer.C:
+						default:
+						}
+					}
+					timer.Reset(time.Until(at))
+				}
+				due = timer.C
+			}
+		}
+
+		var send Task
+		if next != nil {
+			send = *next
+		}
+
+		select {
+		case out <- send:
+			next = nil
+		case t, ok := <-in:
+			if !ok {
+				srcOpen = false
+				continue
+			}
+			if t.JobID == "" {
+				t.JobID = jobID
+			}
+			if _, dup := restored[recipientKey(t)]; dup {
+				log.Printf("retry queue: %s already deferred from a previous run, skipping fresh copy", t.Recipient.Email)
+				continue
+			}
+			outstanding++
+			next = &t
+		case <-settled:
+			outstanding--
+		case <-deferred.wake:
+		case <-due:
+		case <-ctx.Done():
+			if n := deferred.Len(); n > 0 {
+				log.Printf("retry queue: %d deferred task(s) still parked at cancellation", n)
+			}
+			return
+		}
+	}
+}
+
 // startOffsetFlusher kicks off a background goroutine that periodically calls
 // tracker.Save while sends are in progress. Returns a stop function that
 // signals the flusher and waits for it to exit; the flusher does not perform
@@ -2,6 +2,8 @@ package email
 
 import (
 	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
 )
 
 func TestMaxInt(t *testing.T) {
@@ -61,10 +63,7 @@ func TestMaxInt(t *testing.T) {
 
 func TestTaskStruct(t *testing.T) {
 	task := Task{
-		Recipient: struct {
-			Email string
-			Data  map[string]string
-		}{Email: "test@example.com", Data: map[string]string{"name": "Test"}},
+		Recipient:   parser.Recipient{Email: "test@example.com", Data: map[string]string{"name": "Test"}},
 		Subject:     "Test Subject",
 		Body:        "Test Body",
 		Retries:     0,
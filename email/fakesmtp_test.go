@@ -0,0 +1,242 @@
+package email
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/config"
+)
+
+// fakeSMTP is a minimal in-process SMTP server for exercising the client side
+// of the email package. It understands just enough of RFC 5321 (plus BDAT
+// from RFC 3030) to let tests script relay behaviour and inspect the command
+// stream each connection saw.
+type fakeSMTP struct {
+	t          *testing.T
+	ln         net.Listener
+	extensions []string // advertised in the EHLO response
+	// closeAfter makes the server drop a connection after this many accepted
+	// messages, mimicking relays that cap messages per session.
+	closeAfter int
+	// rejectRcpt returns 550 for RCPT TO matching this address.
+	rejectRcpt string
+
+	mu       sync.Mutex
+	maxBurst int // most commands answered in a single flush (PIPELINING)
+	conns    int
+	commands [][]string // per connection, in arrival order
+	messages []string   // accepted message bodies
+	wg       sync.WaitGroup
+}
+
+func newFakeSMTP(t *testing.T, extensions ...string) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeSMTP{t: t, ln: ln, extensions: extensions}
+	s.wg.Add(1)
+	go s.serve()
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *fakeSMTP) addr() string { return s.ln.Addr().String() }
+
+func (s *fakeSMTP) config() config.SMTPConfig {
+	host, port, _ := net.SplitHostPort(s.addr())
+	p, _ := strconv.Atoi(port)
+	return config.SMTPConfig{Host: host, Port: p, From: "sender@example.com"}
+}
+
+// dial connects without STARTTLS/AUTH, which the fake does not offer.
+func (s *fakeSMTP) dial(ctx context.Context, cfg config.SMTPConfig) (*smtp.Client, error) {
+	c, err := smtp.Dial(s.addr())
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Hello("localhost"); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (s *fakeSMTP) close() {
+	s.ln.Close()
+	s.wg.Wait()
+}
+
+func (s *fakeSMTP) connCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *fakeSMTP) allCommands() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([][]string, len(s.commands))
+	for i, c := range s.commands {
+		out[i] = append([]string(nil), c...)
+	}
+	return out
+}
+
+func (s *fakeSMTP) largestBurst() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.maxBurst
+}
+
+func (s *fakeSMTP) acceptedMessages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.messages...)
+}
+
+func (s *fakeSMTP) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		id := s.conns
+		s.conns++
+		s.commands = append(s.commands, nil)
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(conn, id)
+	}
+}
+
+func (s *fakeSMTP) record(id int, cmd string) {
+	s.mu.Lock()
+	s.commands[id] = append(s.commands[id], cmd)
+	s.mu.Unlock()
+}
+
+func (s *fakeSMTP) handle(conn net.Conn, id int) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	reply := func(lines ...string) {
+		for _, l := range lines {
+			fmt.Fprintf(w, "%s\r\n", l)
+		}
+	}
+	// Replies are only flushed when no further pipelined input is buffered,
+	// which is how real servers batch responses for PIPELINING clients.
+	burst := 0
+	flush := func() {
+		burst++
+		if r.Buffered() == 0 {
+			w.Flush()
+			s.mu.Lock()
+			if burst > s.maxBurst {
+				s.maxBurst = burst
+			}
+			s.mu.Unlock()
+			burst = 0
+		}
+	}
+
+	reply("220 fake ESMTP")
+	w.Flush()
+
+	accepted := 0
+	var bdat strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.record(id, line)
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO":
+			lines := []string{"250-fake greets you"}
+			for _, ext := range s.extensions {
+				lines = append(lines, "250-"+ext)
+			}
+			lines = append(lines, "250 8BITMIME")
+			reply(lines...)
+		case "HELO", "NOOP":
+			reply("250 OK")
+		case "RSET":
+			bdat.Reset()
+			reply("250 OK")
+		case "MAIL":
+			reply("250 OK")
+		case "RCPT":
+			if s.rejectRcpt != "" && strings.Contains(line, "<"+s.rejectRcpt+">") {
+				reply("550 5.1.1 no such user")
+			} else {
+				reply("250 OK")
+			}
+		case "DATA":
+			reply("354 go ahead")
+			w.Flush()
+			var body strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				body.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, body.String())
+			s.mu.Unlock()
+			accepted++
+			reply("250 queued")
+			if s.closeAfter > 0 && accepted >= s.closeAfter {
+				w.Flush()
+				return
+			}
+		case "BDAT":
+			fields := strings.Fields(line)
+			n, _ := strconv.Atoi(fields[1])
+			chunk := make([]byte, n)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return
+			}
+			bdat.Write(chunk)
+			if len(fields) > 2 && strings.EqualFold(fields[2], "LAST") {
+				s.mu.Lock()
+				s.messages = append(s.messages, bdat.String())
+				s.mu.Unlock()
+				bdat.Reset()
+				accepted++
+				reply("250 queued")
+			} else {
+				reply("250 chunk ok")
+			}
+		case "QUIT":
+			reply("221 bye")
+			w.Flush()
+			return
+		default:
+			reply("502 not implemented")
+		}
+		flush()
+	}
+}
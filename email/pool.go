@@ -0,0 +1,191 @@
+package email
+
+import (
+	"context"
+	"log"
+	"net/smtp"
+	"sync"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/config"
+)
+
+const (
+	// defaultMaxIdle is how long an unused connection stays pooled. Most
+	// relays drop idle sessions after 60–300s; staying well under avoids
+	// handing out sockets the server already closed.
+	defaultMaxIdle = 30 * time.Second
+
+	// noopAfter is the idle time after which a pooled connection is probed
+	// with NOOP before reuse. Connections returned moments ago skip the probe.
+	noopAfter = 2 * time.Second
+)
+
+// PoolOptions tunes a ConnPool. Zero values fall back to defaults.
+type PoolOptions struct {
+	// MaxIdleConns caps how many idle connections are kept. Defaults to 1.
+	MaxIdleConns int
+	// MaxMessagesPerConn retires a connection with QUIT after this many
+	// successful messages. Zero means unlimited.
+	MaxMessagesPerConn int
+	// MaxIdle closes connections that have been unused for longer than this.
+	MaxIdle time.Duration
+}
+
+// PoolOptionsFromConfig derives pool settings from the SMTP config, sized for
+// the given number of concurrent workers.
+func PoolOptionsFromConfig(cfg config.SMTPConfig, workers int) PoolOptions {
+	return PoolOptions{
+		MaxIdleConns:       workers,
+		MaxMessagesPerConn: cfg.MaxMessagesPerConn,
+		MaxIdle:            time.Duration(cfg.MaxIdleSeconds) * time.Second,
+	}
+}
+
+// pooledConn is an SMTP session checked out of a ConnPool.
+type pooledConn struct {
+	client   *smtp.Client
+	sent     int
+	lastUsed time.Time
+}
+
+// ConnPool shares authenticated SMTP sessions across workers. A worker checks
+// a connection out per message with Get and hands it back with Put, which
+// decides whether the session is reusable (RSET after a rejected message,
+// QUIT once the per-connection message cap is reached, discard on transport
+// errors). Idle sessions are probed with NOOP before reuse.
+type ConnPool struct {
+	cfg  config.SMTPConfig
+	opts PoolOptions
+	dial func(ctx context.Context, cfg config.SMTPConfig) (*smtp.Client, error)
+
+	mu     sync.Mutex
+	idle   []*pooledConn // LIFO: the most recently used session is reused first
+	closed bool
+}
+
+// dialSMTP opens the connections of new pools; tests swap it for a dialer
+// without STARTTLS and AUTH.
+var dialSMTP = ConnectSMTPWithContext
+
+// NewConnPool creates an empty pool; connections are dialled lazily.
+func NewConnPool(cfg config.SMTPConfig, opts PoolOptions) *ConnPool {
+	if opts.MaxIdleConns < 1 {
+		opts.MaxIdleConns = 1
+	}
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = defaultMaxIdle
+	}
+	return &ConnPool{
+		cfg:  cfg,
+		opts: opts,
+		dial: dialSMTP,
+	}
+}
+
+// Get returns a healthy connection, reusing an idle one when possible.
+func (p *ConnPool) Get(ctx context.Context) (*pooledConn, error) {
+	for {
+		c := p.popIdle()
+		if c == nil {
+			break
+		}
+		idleFor := time.Since(c.lastUsed)
+		if idleFor > p.opts.MaxIdle {
+			p.discard(c, "idle timeout")
+			continue
+		}
+		if idleFor > noopAfter {
+			if err := c.client.Noop(); err != nil {
+				p.discard(c, "NOOP failed")
+				continue
+			}
+		}
+		return c, nil
+	}
+
+	client, err := p.dial(ctx, p.cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &pooledConn{client: client, lastUsed: time.Now()}, nil
+}
+
+// Put returns c to the pool after a send that ended with sendErr.
+//
+//   - nil: the message counts toward MaxMessagesPerConn; the session is
+//     retired with QUIT once the cap is reached.
+//   - a connection error: the session is closed.
+//   - any other error: RSET clears the half-finished transaction so the next
+//     message starts clean; if RSET fails the session is closed.
+func (p *ConnPool) Put(c *pooledConn, sendErr error) {
+	if c == nil {
+		return
+	}
+	switch {
+	case sendErr == nil:
+		c.sent++
+		if p.opts.MaxMessagesPerConn > 0 && c.sent >= p.opts.MaxMessagesPerConn {
+			p.retire(c)
+			return
+		}
+	case isConnectionError(sendErr):
+		p.discard(c, "connection error")
+		return
+	default:
+		if err := c.client.Reset(); err != nil {
+			p.discard(c, "RSET failed")
+			return
+		}
+	}
+
+	c.lastUsed = time.Now()
+	p.mu.Lock()
+	if p.closed || len(p.idle) >= p.opts.MaxIdleConns {
+		p.mu.Unlock()
+		p.retire(c)
+		return
+	}
+	p.idle = append(p.idle, c)
+	p.mu.Unlock()
+}
+
+// Close retires every idle connection. Connections checked out at the time
+// are retired when they are Put back.
+func (p *ConnPool) Close() {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, c := range idle {
+		p.retire(c)
+	}
+}
+
+// popIdle removes the most recently used idle connection.
+func (p *ConnPool) popIdle() *pooledConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.idle)
+	if n == 0 {
+		return nil
+	}
+	c := p.idle[n-1]
+	p.idle = p.idle[:n-1]
+	return c
+}
+
+// retire ends a healthy session politely.
+func (p *ConnPool) retire(c *pooledConn) {
+	if err := c.client.Quit(); err != nil {
+		_ = c.client.Close()
+	}
+}
+
+// discard drops a session that is known or suspected to be broken.
+func (p *ConnPool) discard(c *pooledConn, reason string) {
+	log.Printf("SMTP pool: dropping connection (%s)", reason)
+	_ = c.client.Close()
+}
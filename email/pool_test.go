@@ -0,0 +1,181 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func newTestPool(s *fakeSMTP, opts PoolOptions) *ConnPool {
+	p := NewConnPool(s.config(), opts)
+	p.dial = s.dial
+	return p
+}
+
+func sendVia(t *testing.T, p *ConnPool, s *fakeSMTP, to string) error {
+	t.Helper()
+	conn, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	task := Task{Recipient: parser.Recipient{Email: to}, Subject: "hi", PlainText: "body"}
+	err = SendWithClient(conn.client, s.config(), task, nil)
+	p.Put(conn, err)
+	return err
+}
+
+func TestConnPool_ReusesConnection(t *testing.T) {
+	s := newFakeSMTP(t)
+	p := newTestPool(s, PoolOptions{})
+	defer p.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := sendVia(t, p, s, "a@example.com"); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	if got := s.connCount(); got != 1 {
+		t.Errorf("expected 1 connection for 3 messages, got %d", got)
+	}
+}
+
+func TestConnPool_RetiresAfterMaxMessages(t *testing.T) {
+	s := newFakeSMTP(t)
+	p := newTestPool(s, PoolOptions{MaxMessagesPerConn: 2})
+	defer p.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := sendVia(t, p, s, "a@example.com"); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	if got := s.connCount(); got != 3 {
+		t.Errorf("expected 3 connections (2+2+1 messages), got %d", got)
+	}
+	for i, cmds := range s.allCommands()[:2] {
+		if cmds[len(cmds)-1] != "QUIT" {
+			t.Errorf("connection %d was not retired with QUIT: %v", i, cmds)
+		}
+	}
+}
+
+func TestConnPool_ResetsAfterRejectedMessage(t *testing.T) {
+	s := newFakeSMTP(t)
+	s.rejectRcpt = "bad@example.com"
+	p := newTestPool(s, PoolOptions{})
+	defer p.Close()
+
+	if err := sendVia(t, p, s, "bad@example.com"); err == nil {
+		t.Fatal("expected RCPT rejection")
+	}
+	if err := sendVia(t, p, s, "good@example.com"); err != nil {
+		t.Fatalf("send after rejection: %v", err)
+	}
+
+	cmds := s.allCommands()
+	if len(cmds) != 1 {
+		t.Fatalf("expected the session to survive a rejection, got %d connections", len(cmds))
+	}
+	sawReset := false
+	for _, c := range cmds[0] {
+		if c == "RSET" {
+			sawReset = true
+		}
+	}
+	if !sawReset {
+		t.Errorf("expected RSET between messages after an error, commands: %v", cmds[0])
+	}
+}
+
+func TestConnPool_ProbesIdleConnectionWithNoop(t *testing.T) {
+	s := newFakeSMTP(t)
+	p := newTestPool(s, PoolOptions{})
+	defer p.Close()
+
+	if err := sendVia(t, p, s, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	// Age the idle connection past the probe threshold.
+	p.mu.Lock()
+	p.idle[0].lastUsed = time.Now().Add(-noopAfter - time.Second)
+	p.mu.Unlock()
+
+	if err := sendVia(t, p, s, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, c := range s.allCommands()[0] {
+		if c == "NOOP" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected NOOP before reusing an idle connection")
+	}
+}
+
+func TestConnPool_DropsExpiredIdleConnection(t *testing.T) {
+	s := newFakeSMTP(t)
+	p := newTestPool(s, PoolOptions{MaxIdle: time.Second})
+	defer p.Close()
+
+	if err := sendVia(t, p, s, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.idle[0].lastUsed = time.Now().Add(-2 * time.Second)
+	p.mu.Unlock()
+
+	if err := sendVia(t, p, s, "a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.connCount(); got != 2 {
+		t.Errorf("expected a fresh connection after idle expiry, got %d connections", got)
+	}
+}
+
+func TestConnPool_DiscardsOnConnectionError(t *testing.T) {
+	s := newFakeSMTP(t)
+	p := newTestPool(s, PoolOptions{})
+	defer p.Close()
+
+	conn, err := p.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(conn, errors.New("write: broken pipe"))
+
+	p.mu.Lock()
+	idle := len(p.idle)
+	p.mu.Unlock()
+	if idle != 0 {
+		t.Errorf("expected broken connection to be discarded, %d idle", idle)
+	}
+}
+
+// TestSendPooled_RecoversWhenRelayClosesSession covers relays that enforce a
+// per-connection message cap by hanging up: the next send on the pooled
+// session fails with EOF and must be retried once on a fresh connection.
+func TestSendPooled_RecoversWhenRelayClosesSession(t *testing.T) {
+	s := newFakeSMTP(t)
+	s.closeAfter = 2
+	p := newTestPool(s, PoolOptions{})
+	defer p.Close()
+
+	w := worker{ID: 1, Config: s.config(), Pool: p, Ctx: context.Background()}
+	for i := 0; i < 4; i++ {
+		task := Task{Recipient: parser.Recipient{Email: "a@example.com"}, Subject: "hi", PlainText: "body"}
+		if err := sendPooled(w, task); err != nil {
+			t.Fatalf("send %d: %v", i, err)
+		}
+	}
+	if got := len(s.acceptedMessages()); got != 4 {
+		t.Errorf("expected 4 accepted messages, got %d", got)
+	}
+	if got := s.connCount(); got != 2 {
+		t.Errorf("expected 2 connections, got %d", got)
+	}
+}
@@ -0,0 +1,177 @@
+package email
+
+import (
+	"container/heap"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DeferredStore persists parked retries so they survive a crash and can be
+// picked up by a later run, like the deferred queue of an MTA. Values are
+// opaque JSON blobs owned by the email package; keys are the job ID and the
+// lowercased recipient address, separated by a NUL byte, so a campaign holds
+// at most one deferred entry per recipient and campaigns sharing a store
+// never see each other's entries.
+//
+// database.BoltDBClient implements this interface.
+type DeferredStore interface {
+	PutDeferred(key string, value []byte) error
+	DeleteDeferred(key string) error
+	LoadDeferred() (map[string][]byte, error)
+}
+
+// deferredEntry is a task parked until NotBefore.
+type deferredEntry struct {
+	Task      Task      `json:"task"`
+	NotBefore time.Time `json:"not_before"`
+}
+
+// deferredHeap orders entries by NotBefore (earliest first).
+type deferredHeap []deferredEntry
+
+func (h deferredHeap) Len() int           { return len(h) }
+func (h deferredHeap) Less(i, j int) bool { return h[i].NotBefore.Before(h[j].NotBefore) }
+func (h deferredHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *deferredHeap) Push(x any)        { *h = append(*h, x.(deferredEntry)) }
+func (h *deferredHeap) Pop() any {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	*h = old[:n-1]
+	return e
+}
+
+// retryQueue holds failed tasks until their next attempt time so workers never
+// sleep on a backoff. The dispatcher's feeder pops due entries and hands them
+// to whichever worker is free next.
+//
+// When a DeferredStore is attached, every Defer is written through and every
+// settled task is deleted, so the persisted set always mirrors what is parked
+// or in flight after a deferral.
+type retryQueue struct {
+	mu    sync.Mutex
+	items deferredHeap
+	wake  chan struct{} // signalled (non-blocking) on every Defer
+	store DeferredStore
+	jobID string // the job whose persisted entries Restore loads
+}
+
+// newRetryQueue builds an empty queue for jobID. store may be nil.
+func newRetryQueue(store DeferredStore, jobID string) *retryQueue {
+	return &retryQueue{
+		wake:  make(chan struct{}, 1),
+		store: store,
+		jobID: jobID,
+	}
+}
+
+// recipientKey returns the lowercased recipient address of a task.
+func recipientKey(t Task) string {
+	return strings.ToLower(strings.TrimSpace(t.Recipient.Email))
+}
+
+// deferredKey returns the persistence key for a task.
+func deferredKey(t Task) string {
+	return t.JobID + "\x00" + recipientKey(t)
+}
+
+// Defer parks task until notBefore.
+func (q *retryQueue) Defer(task Task, notBefore time.Time) {
+	if q.store != nil {
+		if data, err := json.Marshal(deferredEntry{Task: task, NotBefore: notBefore}); err != nil {
+			log.Printf("retry queue: encode %s: %v", task.Recipient.Email, err)
+		} else if err := q.store.PutDeferred(deferredKey(task), data); err != nil {
+			log.Printf("retry queue: persist %s: %v", task.Recipient.Email, err)
+		}
+	}
+
+	q.mu.Lock()
+	heap.Push(&q.items, deferredEntry{Task: task, NotBefore: notBefore})
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// PopDue removes and returns the earliest entry whose time has come.
+func (q *retryQueue) PopDue(now time.Time) (Task, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 || q.items[0].NotBefore.After(now) {
+		return Task{}, false
+	}
+	e := heap.Pop(&q.items).(deferredEntry)
+	return e.Task, true
+}
+
+// NextDue reports when the earliest parked entry becomes eligible.
+func (q *retryQueue) NextDue() (time.Time, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.items) == 0 {
+		return time.Time{}, false
+	}
+	return q.items[0].NotBefore, true
+}
+
+// Len returns the number of parked entries.
+func (q *retryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Settle drops the persisted copy of task once it has been delivered or has
+// permanently failed. It is a no-op without a store.
+func (q *retryQueue) Settle(task Task) {
+	if q.store == nil || task.Retries == 0 {
+		return
+	}
+	if err := q.store.DeleteDeferred(deferredKey(task)); err != nil {
+		log.Printf("retry queue: delete %s: %v", task.Recipient.Email, err)
+	}
+}
+
+// Restore loads the entries an earlier run of the queue's job persisted and
+// returns their recipients so the feeder can drop fresh copies of them.
+// Entries of other jobs are left in the store.
+func (q *retryQueue) Restore() map[string]struct{} {
+	if q.store == nil {
+		return nil
+	}
+	raw, err := q.store.LoadDeferred()
+	if err != nil {
+		log.Printf("retry queue: load deferred tasks: %v", err)
+		return nil
+	}
+	if len(raw) == 0 {
+		return nil
+	}
+
+	prefix := q.jobID + "\x00"
+	keys := make(map[string]struct{})
+	q.mu.Lock()
+	for key, data := range raw {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		var e deferredEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			log.Printf("retry queue: skipping corrupt entry %q: %v", key, err)
+			continue
+		}
+		heap.Push(&q.items, e)
+		keys[recipientKey(e.Task)] = struct{}{}
+	}
+	q.mu.Unlock()
+
+	if len(keys) > 0 {
+		log.Printf("retry queue: restored %d deferred task(s) of %s from a previous run", len(keys), q.jobID)
+	}
+	return keys
+}
@@ -0,0 +1,253 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// memDeferredStore is an in-memory DeferredStore for tests.
+type memDeferredStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemDeferredStore() *memDeferredStore {
+	return &memDeferredStore{data: make(map[string][]byte)}
+}
+
+func (m *memDeferredStore) PutDeferred(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.data[key] = value
+	return nil
+}
+
+func (m *memDeferredStore) DeleteDeferred(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+func (m *memDeferredStore) LoadDeferred() (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string][]byte, len(m.data))
+	for k, v := range m.data {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func taskFor(addr string, idx int) Task {
+	return Task{Recipient: parser.Recipient{Email: addr}, Index: idx}
+}
+
+func TestRetryQueue_PopDueOrdersByDeadline(t *testing.T) {
+	q := newRetryQueue(nil, "")
+	now := time.Now()
+	q.Defer(taskFor("late@example.com", 0), now.Add(time.Hour))
+	q.Defer(taskFor("soon@example.com", 1), now.Add(-time.Second))
+	q.Defer(taskFor("sooner@example.com", 2), now.Add(-time.Minute))
+
+	first, ok := q.PopDue(now)
+	if !ok || first.Recipient.Email != "sooner@example.com" {
+		t.Fatalf("PopDue() = %v, %v; want sooner@example.com", first.Recipient.Email, ok)
+	}
+	second, ok := q.PopDue(now)
+	if !ok || second.Recipient.Email != "soon@example.com" {
+		t.Fatalf("PopDue() = %v, %v; want soon@example.com", second.Recipient.Email, ok)
+	}
+	if _, ok := q.PopDue(now); ok {
+		t.Fatal("PopDue() returned an entry that is not due yet")
+	}
+	if at, ok := q.NextDue(); !ok || !at.Equal(now.Add(time.Hour)) {
+		t.Errorf("NextDue() = %v, %v; want %v", at, ok, now.Add(time.Hour))
+	}
+}
+
+func TestRetryQueue_PersistsAndRestores(t *testing.T) {
+	store := newMemDeferredStore()
+	q := newRetryQueue(store, "job-1")
+
+	task := taskFor("User@Example.com", 3)
+	task.Retries = 1
+	task.JobID = "job-1"
+	q.Defer(task, time.Now())
+	if _, ok := store.data["job-1\x00user@example.com"]; !ok {
+		t.Fatalf("expected deferred task to be written through, store = %v", store.data)
+	}
+
+	// Another campaign on the same store neither restores nor removes it.
+	other := newRetryQueue(store, "job-2")
+	if keys := other.Restore(); len(keys) != 0 || other.Len() != 0 || len(store.data) != 1 {
+		t.Fatalf("job-2 restored %v from job-1's entries", keys)
+	}
+
+	restored := newRetryQueue(store, "job-1")
+	keys := restored.Restore()
+	if _, ok := keys["user@example.com"]; !ok || restored.Len() != 1 {
+		t.Fatalf("Restore() keys = %v, len = %d", keys, restored.Len())
+	}
+	got, ok := restored.PopDue(time.Now())
+	if !ok || got.Index != 3 || got.Retries != 1 {
+		t.Fatalf("restored task = %+v, %v", got, ok)
+	}
+
+	restored.Settle(got)
+	if len(store.data) != 0 {
+		t.Errorf("Settle() left entries behind: %v", store.data)
+	}
+}
+
+// TestFeedTasks_RedeliversDeferredTasks drives the feeder the way workers do:
+// the first delivery of each task is deferred, the second settles it. The
+// work channel must close only after every retry has been handed out.
+func TestFeedTasks_RedeliversDeferredTasks(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	src := make(chan Task, 3)
+	for i := 0; i < 3; i++ {
+		src <- taskFor([]string{"a@x.com", "b@x.com", "c@x.com"}[i], i)
+	}
+	close(src)
+
+	q := newRetryQueue(nil, "")
+	work := make(chan Task)
+	settled := make(chan struct{}, 3)
+	go feedTasks(ctx, src, work, settled, q, nil, "")
+
+	seen := make(map[string]int)
+	for task := range work {
+		seen[task.Recipient.Email]++
+		if task.Retries == 0 {
+			task.Retries++
+			q.Defer(task, time.Now().Add(10*time.Millisecond))
+			continue
+		}
+		settled <- struct{}{}
+	}
+
+	for _, addr := range []string{"a@x.com", "b@x.com", "c@x.com"} {
+		if seen[addr] != 2 {
+			t.Errorf("%s delivered %d times, want 2", addr, seen[addr])
+		}
+	}
+	if ctx.Err() != nil {
+		t.Fatal("feeder did not close work before the deadline")
+	}
+}
+
+func TestFeedTasks_SkipsRecipientsRestoredFromStore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	store := newMemDeferredStore()
+	prev := newRetryQueue(store, "job-1")
+	old := taskFor("a@x.com", 0)
+	old.Retries = 2
+	old.Subject = "from previous run"
+	old.JobID = "job-1"
+	prev.Defer(old, time.Now())
+
+	q := newRetryQueue(store, "job-1")
+	restored := q.Restore()
+
+	src := make(chan Task, 2)
+	src <- taskFor("a@x.com", 0)
+	src <- taskFor("b@x.com", 1)
+	close(src)
+
+	work := make(chan Task)
+	settled := make(chan struct{}, 2)
+	go feedTasks(ctx, src, work, settled, q, restored, "job-1")
+
+	var got []Task
+	for task := range work {
+		got = append(got, task)
+		settled <- struct{}{}
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 tasks, got %d: %+v", len(got), got)
+	}
+	for _, task := range got {
+		if task.Recipient.Email == "a@x.com" && task.Subject != "from previous run" {
+			t.Errorf("fresh copy of a@x.com was sent instead of the restored one")
+		}
+		if task.JobID != "job-1" {
+			t.Errorf("%s handed out with JobID %q, want job-1", task.Recipient.Email, task.JobID)
+		}
+	}
+}
+
+func TestDispatch_ResumeRestoresDeferred(t *testing.T) {
+	logToTempDir(t)
+	dial := dialSMTP
+	t.Cleanup(func() { dialSMTP = dial })
+
+	store := newMemDeferredStore()
+	key := "job-1\x00later@example.com"
+	task := Task{Recipient: parser.Recipient{Email: "later@example.com"}, Subject: "parked", PlainText: "body"}
+	opts := func(jobID string) *DispatchOptions {
+		return &DispatchOptions{DeferredStore: store, JobID: jobID}
+	}
+
+	// The first run is refused, parks the task and is stopped.
+	refusing := newFakeSMTP(t)
+	refusing.rejectRcpt = "later@example.com"
+	dialSMTP = refusing.dial
+	ctx, cancel := context.WithCancel(context.Background())
+	src := make(chan Task, 1)
+	src <- task
+	close(src)
+	done := make(chan struct{})
+	go func() {
+		StartDispatcherStream(ctx, src, refusing.config(), 1, 1, opts("job-1"))
+		close(done)
+	}()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if data, _ := store.LoadDeferred(); data[key] != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the refused task was not persisted under its job")
+		}
+	}
+	cancel()
+	<-done
+
+	// Another job does not restore it.
+	empty := make(chan Task)
+	close(empty)
+	StartDispatcherStream(context.Background(), empty, refusing.config(), 1, 1, opts("job-2"))
+	if data, _ := store.LoadDeferred(); data[key] == nil {
+		t.Fatal("a dispatch of another job removed the entry")
+	}
+
+	// Resuming the job sends the parked task instead of the fresh copy.
+	accepting := newFakeSMTP(t)
+	dialSMTP = accepting.dial
+	fresh := task
+	fresh.Subject = "fresh"
+	src = make(chan Task, 1)
+	src <- fresh
+	close(src)
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	res := StartDispatcherStream(ctx, src, accepting.config(), 1, 1, opts("job-1"))
+
+	msgs := accepting.acceptedMessages()
+	if res.Sent != 1 || len(msgs) != 1 || !strings.Contains(msgs[0], "Subject: parked") {
+		t.Fatalf("resumed dispatch sent %d, accepted %d message(s); want the parked task once", res.Sent, len(msgs))
+	}
+	if data, _ := store.LoadDeferred(); len(data) != 0 {
+		t.Errorf("entries left after the retry was sent: %v", data)
+	}
+}
@@ -109,7 +109,14 @@ func writeBoundaryClose(bw *bufio.Writer, boundary string, trailingCRLF bool) er
 // order (case-insensitively), and issued RCPT commands exactly once with the
 // primary recipient always first. The CC header is rendered from the unique CC
 // list, while BCC addresses are kept solely on the SMTP envelope.
-// It uses cfg.SMTP.From as both the envelope sender and header From address.
+// It uses cfg.SMTP.From as both the envelope sender and header From address,
+// unless cfg.VERPDomain is set, in which case the envelope sender is the
+// recipient's VERP address (see VERPAddress). When the server supports DSN,
+// failure/delay notifications are requested with task.JobID as the ENVID.
+//
+// The envelope is pipelined when the server advertises PIPELINING and the
+// body is sent with BDAT when it advertises CHUNKING; otherwise the classic
+// one-command-per-round-trip MAIL/RCPT/DATA sequence is used.
 //
 // cache may be nil; when supplied, attachments are read and base64-encoded
 // once per dispatch run and reused across all recipients.
@@ -119,10 +126,6 @@ func SendWithClient(client *smtp.Client, cfg config.SMTPConfig, task Task, cache
 		return fmt.Errorf("SMTP sender 'from' field in config is empty")
 	}
 
-	if err := client.Mail(from); err != nil {
-		return fmt.Errorf("MAIL FROM error: %w", err)
-	}
-
 	to := strings.TrimSpace(task.Recipient.Email)
 	if to == "" {
 		return fmt.Errorf("recipient email is empty")
@@ -130,13 +133,10 @@ func SendWithClient(client *smtp.Client, cfg config.SMTPConfig, task Task, cache
 
 	body := strings.TrimSpace(task.Body)
 
+	env := envelope{from: from, envid: task.JobID, rcpts: make([]envelopeRcpt, 0, 1+len(task.CC)+len(task.BCC))}
+	env.rcpts = append(env.rcpts, envelopeRcpt{addr: to})
 	seen := make(map[string]struct{}, 1+len(task.CC)+len(task.BCC))
 	seen[strings.ToLower(to)] = struct{}{}
-	if err := client.Rcpt(to); err != nil {
-		return fmt.Errorf("RCPT TO error for %s: %w", to, err)
-	}
-
-	var rcptErr error
 
 	uniqueCC := make([]string, 0, len(task.CC))
 	for _, cc := range task.CC {
@@ -150,12 +150,7 @@ func SendWithClient(client *smtp.Client, cfg config.SMTPConfig, task Task, cache
 		}
 		seen[key] = struct{}{}
 		uniqueCC = append(uniqueCC, cc)
-		if err := client.Rcpt(cc); err != nil {
-			log.Printf("️ Failed to add CC: %s (%v)", cc, err)
-			if rcptErr == nil {
-				rcptErr = fmt.Errorf("failed to add CC recipient %s: %w", cc, err)
-			}
-		}
+		env.rcpts = append(env.rcpts, envelopeRcpt{addr: cc, kind: "CC"})
 	}
 
 	for _, bcc := range task.BCC {
@@ -168,19 +163,20 @@ func SendWithClient(client *smtp.Client, cfg config.SMTPConfig, task Task, cache
 			continue
 		}
 		seen[key] = struct{}{}
-		if err := client.Rcpt(bcc); err != nil {
-			log.Printf("️ Failed to add BCC: %s (%v)", bcc, err)
-			if rcptErr == nil {
-				rcptErr = fmt.Errorf("failed to add BCC recipient %s: %w", bcc, err)
-			}
+		env.rcpts = append(env.rcpts, envelopeRcpt{addr: bcc, kind: "BCC"})
+	}
+
+	if cfg.VERPDomain != "" {
+		if verp := VERPAddress(cfg.VERPDomain, task.JobID, to); verp != "" {
+			env.from = verp
 		}
 	}
 
-	if rcptErr != nil {
-		return rcptErr
+	if err := sendEnvelope(client, env); err != nil {
+		return err
 	}
 
-	w, err := client.Data()
+	w, err := openBody(client)
 	if err != nil {
 		return fmt.Errorf("DATA command error: %w", err)
 	}
@@ -193,8 +189,10 @@ func SendWithClient(client *smtp.Client, cfg config.SMTPConfig, task Task, cache
 		}
 		bw.Reset(io.Discard)
 		bufWriterPool.Put(bw)
-		if cerr := w.Close(); cerr != nil {
-			log.Printf("Error closing SMTP writer: %v", cerr)
+		// Close carries the server's verdict on the message (the reply to
+		// the final "." or BDAT LAST), so it is part of the send result.
+		if cerr := w.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("message rejected: %w", cerr)
 		}
 	}()
 
@@ -226,6 +224,22 @@ func SendWithClient(client *smtp.Client, cfg config.SMTPConfig, task Task, cache
 	if err = writeHeader(bw, "Subject", strings.TrimSpace(subject)); err != nil {
 		return fmt.Errorf("write Subject: %w", err)
 	}
+	if err = writeHeader(bw, "Message-ID", MessageID(task.JobID, to, from)); err != nil {
+		return fmt.Errorf("write Message-ID: %w", err)
+	}
+	if task.JobID != "" {
+		if err = writeHeader(bw, "X-Campaign-ID", task.JobID); err != nil {
+			return fmt.Errorf("write X-Campaign-ID: %w", err)
+		}
+	}
+	if task.UnsubscribeURL != "" {
+		if err = writeHeader(bw, "List-Unsubscribe", "<"+task.UnsubscribeURL+">"); err != nil {
+			return fmt.Errorf("write List-Unsubscribe: %w", err)
+		}
+		if err = writeHeader(bw, "List-Unsubscribe-Post", "List-Unsubscribe=One-Click"); err != nil {
+			return fmt.Errorf("write List-Unsubscribe-Post: %w", err)
+		}
+	}
 	if err = writeHeader(bw, "MIME-Version", "1.0"); err != nil {
 		return fmt.Errorf("write MIME-Version: %w", err)
 	}
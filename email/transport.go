@@ -0,0 +1,260 @@
+package email
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+)
+
+// bdatChunkSize is the size of each BDAT chunk when the server supports
+// CHUNKING (RFC 3030). Large enough that a typical message is one chunk.
+const bdatChunkSize = 64 << 10
+
+// envelopeRcpt is one RCPT TO target. kind is "" for the primary recipient,
+// or "CC"/"BCC" so failures can be reported the way the caller addressed them.
+type envelopeRcpt struct {
+	addr string
+	kind string
+}
+
+// envelope is the SMTP-level sender and recipient list for one message.
+type envelope struct {
+	from  string
+	rcpts []envelopeRcpt // primary recipient first
+	// envid is sent as the DSN ENVID (RFC 3461) so delivery reports name the
+	// job that produced the message. Ignored when the server lacks DSN.
+	envid string
+}
+
+// dsnNotify is the NOTIFY value requested for every recipient: report
+// failures and delays, but never successful delivery.
+const dsnNotify = "FAILURE,DELAY"
+
+// xtext encodes s per RFC 3461 §4 for use in ENVID and ORCPT parameters.
+func xtext(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < '!' || c > '~' || c == '+' || c == '=' {
+			fmt.Fprintf(&b, "+%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// validateLine rejects CR/LF in values spliced into SMTP commands.
+func validateLine(s string) error {
+	if strings.ContainsAny(s, "\r\n") {
+		return fmt.Errorf("smtp: a line must not contain CR or LF")
+	}
+	return nil
+}
+
+// mailCommand renders MAIL FROM with the parameters net/smtp would add, plus
+// RET=HDRS and ENVID when the server supports DSN. Returning headers only
+// keeps bounces small while still carrying Message-ID and campaign headers.
+func mailCommand(c *smtp.Client, env envelope, dsn bool) string {
+	cmd := "MAIL FROM:<" + env.from + ">"
+	if ok, _ := c.Extension("8BITMIME"); ok {
+		cmd += " BODY=8BITMIME"
+	}
+	if ok, _ := c.Extension("SMTPUTF8"); ok {
+		cmd += " SMTPUTF8"
+	}
+	if dsn {
+		cmd += " RET=HDRS"
+		if env.envid != "" {
+			cmd += " ENVID=" + xtext(env.envid)
+		}
+	}
+	return cmd
+}
+
+// rcptCommand renders RCPT TO, requesting failure/delay notifications and
+// recording the original recipient (ORCPT) when the server supports DSN.
+func rcptCommand(addr string, dsn bool) string {
+	cmd := "RCPT TO:<" + addr + ">"
+	if dsn {
+		cmd += " NOTIFY=" + dsnNotify
+		if isASCII(addr) {
+			cmd += " ORCPT=rfc822;" + xtext(addr)
+		}
+	}
+	return cmd
+}
+
+// sendEnvelope issues MAIL FROM and one RCPT TO per recipient, with RFC 3461
+// DSN parameters when the server advertises DSN.
+//
+// When the server advertises PIPELINING (RFC 2920) every command is written
+// in a single flush and the replies are read afterwards, collapsing 1+N round
+// trips into one. Otherwise each command waits for its reply, as net/smtp
+// does. DATA/BDAT is never pipelined with the envelope: the caller must know
+// whether any RCPT failed before committing to a message body.
+//
+// A rejected MAIL FROM or primary RCPT is returned immediately; CC/BCC
+// rejections are logged and the first one is returned after all recipients
+// have been tried.
+func sendEnvelope(c *smtp.Client, env envelope) error {
+	if err := validateLine(env.from); err != nil {
+		return fmt.Errorf("MAIL FROM error: %w", err)
+	}
+	for _, r := range env.rcpts {
+		if err := validateLine(r.addr); err != nil {
+			return fmt.Errorf("RCPT TO error for %s: %w", r.addr, err)
+		}
+	}
+
+	dsn, _ := c.Extension("DSN")
+	cmds := make([]string, 0, 1+len(env.rcpts))
+	cmds = append(cmds, mailCommand(c, env, dsn))
+	for _, r := range env.rcpts {
+		cmds = append(cmds, rcptCommand(r.addr, dsn))
+	}
+
+	replies := make([]error, len(cmds))
+	if ok, _ := c.Extension("PIPELINING"); ok {
+		for _, cmd := range cmds {
+			if _, err := c.Text.W.WriteString(cmd + "\r\n"); err != nil {
+				return fmt.Errorf("MAIL FROM error: %w", err)
+			}
+		}
+		if err := c.Text.W.Flush(); err != nil {
+			return fmt.Errorf("MAIL FROM error: %w", err)
+		}
+		// Every reply must be consumed, even after a failure, to keep the
+		// command stream in sync for the next transaction on this session.
+		for i := range cmds {
+			_, _, replies[i] = c.Text.ReadResponse(25)
+			if isConnectionError(replies[i]) {
+				return fmt.Errorf("MAIL FROM error: %w", replies[i])
+			}
+		}
+	} else {
+		for i, cmd := range cmds {
+			if err := c.Text.PrintfLine("%s", cmd); err != nil {
+				return fmt.Errorf("MAIL FROM error: %w", err)
+			}
+			_, _, replies[i] = c.Text.ReadResponse(25)
+			if i <= 1 && replies[i] != nil {
+				break // MAIL or primary RCPT failed; nothing else is worth sending
+			}
+		}
+	}
+
+	if replies[0] != nil {
+		return fmt.Errorf("MAIL FROM error: %w", replies[0])
+	}
+	if replies[1] != nil {
+		return fmt.Errorf("RCPT TO error for %s: %w", env.rcpts[0].addr, replies[1])
+	}
+
+	var rcptErr error
+	for i, r := range env.rcpts[1:] {
+		if err := replies[i+2]; err != nil {
+			log.Printf("️ Failed to add %s: %s (%v)", r.kind, r.addr, err)
+			if rcptErr == nil {
+				rcptErr = fmt.Errorf("failed to add %s recipient %s: %w", r.kind, r.addr, err)
+			}
+		}
+	}
+	return rcptErr
+}
+
+// openBody starts the message content phase. Servers advertising CHUNKING get
+// BDAT, which needs no dot-stuffing and (with PIPELINING) does not wait for a
+// 354 go-ahead; everyone else gets DATA.
+func openBody(c *smtp.Client) (io.WriteCloser, error) {
+	if ok, _ := c.Extension("CHUNKING"); ok {
+		pipelined, _ := c.Extension("PIPELINING")
+		return &bdatWriter{text: c.Text, pipelined: pipelined}, nil
+	}
+	return c.Data()
+}
+
+// bdatWriter streams a message as BDAT chunks (RFC 3030). Bare LF line
+// endings are normalised to CRLF since BDAT carries the message verbatim.
+// With PIPELINING, intermediate chunk replies are collected at Close instead
+// of after each chunk.
+type bdatWriter struct {
+	text      *textproto.Conn
+	pipelined bool
+	buf       []byte
+	lastCR    bool
+	pending   int // chunk replies not yet read
+	err       error
+}
+
+func (b *bdatWriter) Write(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	for _, ch := range p {
+		if ch == '\n' && !b.lastCR {
+			b.buf = append(b.buf, '\r')
+		}
+		b.buf = append(b.buf, ch)
+		b.lastCR = ch == '\r'
+		if len(b.buf) >= bdatChunkSize {
+			if err := b.sendChunk(false); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return len(p), nil
+}
+
+// Close sends the final chunk marked LAST and returns the server's verdict
+// on the whole message.
+func (b *bdatWriter) Close() error {
+	if b.err != nil {
+		return b.err
+	}
+	if err := b.sendChunk(true); err != nil {
+		return err
+	}
+	return b.readPending()
+}
+
+func (b *bdatWriter) sendChunk(last bool) error {
+	w := b.text.W
+	if last {
+		fmt.Fprintf(w, "BDAT %d LAST\r\n", len(b.buf))
+	} else {
+		fmt.Fprintf(w, "BDAT %d\r\n", len(b.buf))
+	}
+	if _, err := w.Write(b.buf); err != nil {
+		b.err = fmt.Errorf("BDAT write error: %w", err)
+		return b.err
+	}
+	if err := w.Flush(); err != nil {
+		b.err = fmt.Errorf("BDAT write error: %w", err)
+		return b.err
+	}
+	b.buf = b.buf[:0]
+	b.pending++
+	if !b.pipelined && !last {
+		return b.readPending()
+	}
+	return nil
+}
+
+func (b *bdatWriter) readPending() error {
+	for ; b.pending > 0; b.pending-- {
+		if _, _, err := b.text.ReadResponse(250); err != nil {
+			b.pending--
+			// Drain the remaining replies so the session stays usable.
+			for ; b.pending > 0; b.pending-- {
+				_, _, _ = b.text.ReadResponse(250)
+			}
+			b.err = fmt.Errorf("BDAT error: %w", err)
+			return b.err
+		}
+	}
+	return nil
+}
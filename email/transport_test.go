@@ -0,0 +1,206 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func sendDirect(t *testing.T, s *fakeSMTP, task Task) error {
+	t.Helper()
+	c, err := s.dial(context.Background(), s.config())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Quit()
+	return SendWithClient(c, s.config(), task, nil)
+}
+
+func commandVerbs(cmds []string) []string {
+	verbs := make([]string, 0, len(cmds))
+	for _, c := range cmds {
+		verbs = append(verbs, strings.SplitN(c, " ", 2)[0])
+	}
+	return verbs
+}
+
+func TestSendWithClient_PipelinesEnvelope(t *testing.T) {
+	s := newFakeSMTP(t, "PIPELINING")
+	task := Task{
+		Recipient: parser.Recipient{Email: "to@example.com"},
+		CC:        []string{"cc@example.com"},
+		BCC:       []string{"bcc@example.com"},
+		Subject:   "hi",
+		PlainText: "body",
+	}
+	if err := sendDirect(t, s, task); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	// MAIL + 3×RCPT answered in one flush.
+	if got := s.largestBurst(); got != 4 {
+		t.Errorf("expected the envelope to arrive as one burst of 4 commands, got %d", got)
+	}
+	cmds := s.allCommands()[0]
+	want := []string{"EHLO", "MAIL", "RCPT", "RCPT", "RCPT", "DATA", "QUIT"}
+	if got := commandVerbs(cmds); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+	if cmds[1] != "MAIL FROM:<sender@example.com> BODY=8BITMIME" {
+		t.Errorf("MAIL command = %q", cmds[1])
+	}
+}
+
+func TestSendWithClient_PipelinedRejectionKeepsSessionInSync(t *testing.T) {
+	s := newFakeSMTP(t, "PIPELINING")
+	s.rejectRcpt = "bad@example.com"
+	p := newTestPool(s, PoolOptions{})
+	defer p.Close()
+
+	if err := sendVia(t, p, s, "bad@example.com"); err == nil || !strings.Contains(err.Error(), "RCPT TO error for bad@example.com") {
+		t.Fatalf("expected primary RCPT rejection, got %v", err)
+	}
+	if err := sendVia(t, p, s, "good@example.com"); err != nil {
+		t.Fatalf("send after pipelined rejection: %v", err)
+	}
+	if got := s.connCount(); got != 1 {
+		t.Errorf("expected the session to be reused, got %d connections", got)
+	}
+	if got := len(s.acceptedMessages()); got != 1 {
+		t.Errorf("expected 1 accepted message, got %d", got)
+	}
+}
+
+func TestSendWithClient_PipelinedCCRejection(t *testing.T) {
+	s := newFakeSMTP(t, "PIPELINING")
+	s.rejectRcpt = "cc@example.com"
+	task := Task{
+		Recipient: parser.Recipient{Email: "to@example.com"},
+		CC:        []string{"cc@example.com"},
+		Subject:   "hi",
+		PlainText: "body",
+	}
+	err := sendDirect(t, s, task)
+	if err == nil || !strings.Contains(err.Error(), "failed to add CC recipient cc@example.com") {
+		t.Fatalf("expected CC rejection, got %v", err)
+	}
+	for _, c := range s.allCommands()[0] {
+		if c == "DATA" || strings.HasPrefix(c, "BDAT") {
+			t.Errorf("body must not be sent after a recipient rejection: %v", s.allCommands()[0])
+		}
+	}
+}
+
+func TestSendWithClient_UsesBDATWhenChunkingAdvertised(t *testing.T) {
+	s := newFakeSMTP(t, "PIPELINING", "CHUNKING")
+	task := Task{
+		Recipient: parser.Recipient{Email: "to@example.com"},
+		Subject:   "hi",
+		PlainText: "line one\nline two\n.leading dot",
+	}
+	if err := sendDirect(t, s, task); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	cmds := s.allCommands()[0]
+	var sawBDAT bool
+	for _, c := range cmds {
+		if c == "DATA" {
+			t.Errorf("DATA used despite CHUNKING: %v", cmds)
+		}
+		if strings.HasPrefix(c, "BDAT ") && strings.HasSuffix(c, " LAST") {
+			sawBDAT = true
+		}
+	}
+	if !sawBDAT {
+		t.Fatalf("expected BDAT ... LAST, got %v", cmds)
+	}
+	msgs := s.acceptedMessages()
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	if !strings.Contains(msgs[0], "line one\r\nline two\r\n.leading dot") {
+		t.Errorf("body not normalised to CRLF or dot was stuffed: %q", msgs[0])
+	}
+}
+
+func TestBDATWriter_SplitsLargeMessages(t *testing.T) {
+	for _, exts := range [][]string{{"CHUNKING"}, {"CHUNKING", "PIPELINING"}} {
+		s := newFakeSMTP(t, exts...)
+		big := strings.Repeat("x", bdatChunkSize*2+100)
+		task := Task{Recipient: parser.Recipient{Email: "to@example.com"}, Subject: "hi", PlainText: big}
+		if err := sendDirect(t, s, task); err != nil {
+			t.Fatalf("%v: send: %v", exts, err)
+		}
+		var chunks int
+		for _, c := range s.allCommands()[0] {
+			if strings.HasPrefix(c, "BDAT ") {
+				chunks++
+			}
+		}
+		if chunks != 3 {
+			t.Errorf("%v: expected 3 BDAT chunks, got %d", exts, chunks)
+		}
+		if msgs := s.acceptedMessages(); len(msgs) != 1 || !strings.Contains(msgs[0], big) {
+			t.Errorf("%v: large body did not arrive intact", exts)
+		}
+	}
+}
+
+func TestSendWithClient_FallsBackWithoutExtensions(t *testing.T) {
+	s := newFakeSMTP(t)
+	task := Task{
+		Recipient: parser.Recipient{Email: "to@example.com"},
+		CC:        []string{"cc@example.com"},
+		Subject:   "hi",
+		PlainText: "body",
+	}
+	if err := sendDirect(t, s, task); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got := s.largestBurst(); got != 1 {
+		t.Errorf("expected lock-step commands without PIPELINING, largest burst %d", got)
+	}
+	want := []string{"EHLO", "MAIL", "RCPT", "RCPT", "DATA", "QUIT"}
+	if got := commandVerbs(s.allCommands()[0]); strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+}
+
+func TestSendEnvelope_RejectsLineBreaks(t *testing.T) {
+	s := newFakeSMTP(t, "PIPELINING")
+	task := Task{Recipient: parser.Recipient{Email: "to@example.com>\r\nRCPT TO:<evil@example.com"}, Subject: "hi", PlainText: "x"}
+	if err := sendDirect(t, s, task); err == nil {
+		t.Fatal("expected CRLF in address to be rejected")
+	}
+	for _, c := range s.allCommands()[0] {
+		if strings.Contains(c, "evil") {
+			t.Errorf("injected command reached the server: %v", s.allCommands()[0])
+		}
+	}
+}
+
+func TestSendWithClient_ListUnsubscribeHeaders(t *testing.T) {
+	s := newFakeSMTP(t)
+	task := Task{
+		Recipient:      parser.Recipient{Email: "to@example.com"},
+		Subject:        "hi",
+		PlainText:      "body",
+		UnsubscribeURL: "https://mail.example.com/unsubscribe/abc.def",
+	}
+	if err := sendDirect(t, s, task); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	msgs := s.acceptedMessages()
+	if len(msgs) != 1 {
+		t.Fatalf("expected one message, got %d", len(msgs))
+	}
+	for _, h := range []string{
+		"List-Unsubscribe: <https://mail.example.com/unsubscribe/abc.def>\r\n",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n",
+	} {
+		if !strings.Contains(msgs[0], h) {
+			t.Errorf("missing header %q in:\n%s", h, msgs[0])
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package email
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"net/mail"
+	"strings"
+)
+
+// msgIDEncoding encodes recipients inside Message-IDs. Base32 keeps the
+// result within the dot-atom character set and survives case folding.
+var msgIDEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// verpPrefix is the local-part prefix of every VERP return path.
+const verpPrefix = "bounces+"
+
+// verpTagMark starts the campaign tag inside a VERP token.
+const verpTagMark = "t"
+
+// CampaignTag returns the short, stable tag that identifies jobID inside VERP
+// tokens. Job IDs can be arbitrarily long, so only the first 8 hex digits of
+// their SHA-256 are embedded; bounce processing matches the tag against known
+// job IDs.
+func CampaignTag(jobID string) string {
+	if jobID == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(jobID))
+	return hex.EncodeToString(sum[:4])
+}
+
+// VERPAddress returns the per-recipient envelope sender
+// bounces+{token}@domain, where token is "t{tag}.{local}={rcptdomain}"
+// (classic VERP encoding of the recipient, prefixed with the campaign tag).
+// Without a jobID the token is ".{local}={rcptdomain}": the dot ending the
+// tag section is always there, so no recipient is mistaken for a tag. It
+// returns "" if domain is empty or rcpt is not a plain address.
+func VERPAddress(domain, jobID, rcpt string) string {
+	domain = strings.TrimSpace(domain)
+	rcpt = strings.TrimSpace(rcpt)
+	at := strings.LastIndexByte(rcpt, '@')
+	if domain == "" || at <= 0 || at == len(rcpt)-1 {
+		return ""
+	}
+	token := "." + rcpt[:at] + "=" + rcpt[at+1:]
+	if tag := CampaignTag(jobID); tag != "" {
+		token = verpTagMark + tag + token
+	}
+	return verpPrefix + token + "@" + domain
+}
+
+// ParseVERP decodes an address produced by VERPAddress, returning the campaign
+// tag (possibly empty) and the original recipient.
+func ParseVERP(addr string) (tag, rcpt string, ok bool) {
+	addr = strings.Trim(strings.TrimSpace(addr), "<>")
+	at := strings.LastIndexByte(addr, '@')
+	if at <= 0 {
+		return "", "", false
+	}
+	local := addr[:at]
+	if len(local) <= len(verpPrefix) || !strings.EqualFold(local[:len(verpPrefix)], verpPrefix) {
+		return "", "", false
+	}
+	token := local[len(verpPrefix):]
+
+	// The tag section has no dot, so the first one ends it.
+	dot := strings.IndexByte(token, '.')
+	if dot < 0 {
+		return "", "", false
+	}
+	switch section := token[:dot]; {
+	case section == "":
+	case len(section) == len(verpTagMark)+8 && strings.EqualFold(section[:len(verpTagMark)], verpTagMark) && isHex(section[len(verpTagMark):]):
+		tag = strings.ToLower(section[len(verpTagMark):])
+	default:
+		return "", "", false
+	}
+	token = token[dot+1:]
+
+	// The recipient's domain cannot contain '=', so the last one splits it
+	// from the recipient's local part.
+	eq := strings.LastIndexByte(token, '=')
+	if eq <= 0 || eq == len(token)-1 {
+		return "", "", false
+	}
+	return tag, token[:eq] + "@" + token[eq+1:], true
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// MessageID returns a Message-ID header value that bounce processing can map
+// back to the recipient and campaign even when the bounce carries neither a
+// DSN nor a VERP address, as long as the original headers are quoted:
+//
+//	<mg.{tag}.{base32(rcpt)}.{random}@{domain}>
+//
+// domain is taken from from (which may include a display name); "x" stands in
+// for the tag when jobID is empty.
+func MessageID(jobID, rcpt, from string) string {
+	tag := CampaignTag(jobID)
+	if tag == "" {
+		tag = "x"
+	}
+	var nonce [6]byte
+	_, _ = crand.Read(nonce[:])
+	enc := strings.ToLower(msgIDEncoding.EncodeToString([]byte(strings.TrimSpace(rcpt))))
+	return "<mg." + tag + "." + enc + "." + hex.EncodeToString(nonce[:]) + "@" + senderDomain(from) + ">"
+}
+
+// ParseMessageID decodes a Message-ID produced by MessageID.
+func ParseMessageID(id string) (tag, rcpt string, ok bool) {
+	id = strings.Trim(strings.TrimSpace(id), "<>")
+	at := strings.LastIndexByte(id, '@')
+	if at < 0 {
+		return "", "", false
+	}
+	parts := strings.Split(id[:at], ".")
+	if len(parts) != 4 || parts[0] != "mg" {
+		return "", "", false
+	}
+	raw, err := msgIDEncoding.DecodeString(strings.ToUpper(parts[2]))
+	if err != nil || !strings.Contains(string(raw), "@") {
+		return "", "", false
+	}
+	if parts[1] != "x" {
+		tag = parts[1]
+	}
+	return tag, string(raw), true
+}
+
+// senderDomain returns the domain of a From value such as
+// "News <news@example.com>", falling back to "localhost".
+func senderDomain(from string) string {
+	addr := strings.TrimSpace(from)
+	if a, err := mail.ParseAddress(addr); err == nil {
+		addr = a.Address
+	}
+	if at := strings.LastIndexByte(addr, '@'); at >= 0 && at < len(addr)-1 {
+		return strings.Trim(addr[at+1:], "> ")
+	}
+	return "localhost"
+}
@@ -0,0 +1,118 @@
+package email
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func TestVERPAddress_RoundTrip(t *testing.T) {
+	tests := []struct {
+		jobID, rcpt string
+	}{
+		{"mailgrid-1700000000", "alice@example.com"},
+		{"mailgrid-1700000000", "bob+news=x@sub.example.org"},
+		{"", "carol@example.net"},
+		// local parts that look like a tag
+		{"mailgrid-1700000000", "0123abcd-dave@example.com"},
+		{"", "0123abcd-dave@example.com"},
+		{"", "t0123abcd.erin@example.com"},
+	}
+	for _, tt := range tests {
+		addr := VERPAddress("bounce.example.com", tt.jobID, tt.rcpt)
+		if !strings.HasPrefix(addr, "bounces+") || !strings.HasSuffix(addr, "@bounce.example.com") {
+			t.Errorf("VERPAddress(%q) = %q", tt.rcpt, addr)
+		}
+		tag, rcpt, ok := ParseVERP(addr)
+		if !ok || rcpt != tt.rcpt || tag != CampaignTag(tt.jobID) {
+			t.Errorf("ParseVERP(%q) = %q, %q, %v; want %q, %q", addr, tag, rcpt, ok, CampaignTag(tt.jobID), tt.rcpt)
+		}
+	}
+}
+
+func TestVERPAddress_Invalid(t *testing.T) {
+	if got := VERPAddress("", "job", "a@example.com"); got != "" {
+		t.Errorf("empty domain should disable VERP, got %q", got)
+	}
+	if got := VERPAddress("b.example.com", "job", "not-an-address"); got != "" {
+		t.Errorf("invalid recipient should yield empty address, got %q", got)
+	}
+	for _, addr := range []string{"noreply@example.com", "bounces+@example.com", "bounces+abc@example.com",
+		"bounces+0123abcd-alice=example.com@example.com", "bounces+tzz.alice=example.com@example.com", "bounces+.=example.com@example.com"} {
+		if _, _, ok := ParseVERP(addr); ok {
+			t.Errorf("ParseVERP(%q) should fail", addr)
+		}
+	}
+}
+
+func TestSendWithClient_VERPAndDSN(t *testing.T) {
+	s := newFakeSMTP(t, "DSN")
+	cfg := s.config()
+	cfg.VERPDomain = "bounce.example.com"
+	c, err := s.dial(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Quit()
+
+	task := Task{
+		Recipient: parser.Recipient{Email: "alice@example.com"},
+		Subject:   "hi",
+		PlainText: "body",
+		JobID:     "mailgrid-42",
+	}
+	if err := SendWithClient(c, cfg, task, nil); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	cmds := s.allCommands()[0]
+	wantMail := "MAIL FROM:<" + VERPAddress("bounce.example.com", "mailgrid-42", "alice@example.com") + "> BODY=8BITMIME RET=HDRS ENVID=mailgrid-42"
+	if cmds[1] != wantMail {
+		t.Errorf("MAIL = %q\nwant  %q", cmds[1], wantMail)
+	}
+	wantRcpt := "RCPT TO:<alice@example.com> NOTIFY=FAILURE,DELAY ORCPT=rfc822;alice@example.com"
+	if cmds[2] != wantRcpt {
+		t.Errorf("RCPT = %q\nwant  %q", cmds[2], wantRcpt)
+	}
+	if msgs := s.acceptedMessages(); len(msgs) != 1 || !strings.Contains(msgs[0], "From: Mailgrid <sender@example.com>") {
+		t.Errorf("header From must stay the configured sender: %v", msgs)
+	}
+}
+
+func TestSendWithClient_NoDSNParamsWithoutExtension(t *testing.T) {
+	s := newFakeSMTP(t)
+	task := Task{Recipient: parser.Recipient{Email: "a@example.com"}, Subject: "hi", PlainText: "x", JobID: "job"}
+	if err := sendDirect(t, s, task); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	for _, c := range s.allCommands()[0] {
+		if strings.Contains(c, "NOTIFY=") || strings.Contains(c, "ENVID=") {
+			t.Errorf("DSN parameter sent to a server without DSN: %q", c)
+		}
+	}
+}
+
+func TestXText(t *testing.T) {
+	if got := xtext("a+b=c d"); got != "a+2Bb+3Dc+20d" {
+		t.Errorf("xtext = %q", got)
+	}
+}
+
+func TestMessageID_RoundTrip(t *testing.T) {
+	id := MessageID("mailgrid-42", "Alice@example.com", "News <news@example.com>")
+	if !strings.HasSuffix(id, "@example.com>") {
+		t.Errorf("Message-ID should use the sender's domain: %q", id)
+	}
+	if other := MessageID("mailgrid-42", "Alice@example.com", "news@example.com"); other == id {
+		t.Error("Message-IDs must be unique per message")
+	}
+	tag, rcpt, ok := ParseMessageID(id)
+	if !ok || tag != CampaignTag("mailgrid-42") || rcpt != "Alice@example.com" {
+		t.Errorf("ParseMessageID(%q) = %q, %q, %v", id, tag, rcpt, ok)
+	}
+	if _, _, ok := ParseMessageID("<random@example.com>"); ok {
+		t.Error("foreign Message-IDs must not parse")
+	}
+}
@@ -3,7 +3,6 @@ package email
 import (
 	"log"
 	"math/rand"
-	"net/smtp"
 	"strings"
 	"sync"
 	"time"
@@ -125,9 +124,12 @@ func isConnectionError(err error) bool {
 	return false
 }
 
-// startWorker handles email sending using a persistent SMTP connection and batch-mode dispatch.
-// Retries are handled inline (sleep + retry within the worker), avoiding the goroutine leak
-// and channel-close race conditions of the previous time.AfterFunc approach.
+// startWorker pulls tasks in batches and sends them over connections from the
+// shared pool. Failed sends are parked in the dispatcher's retry queue instead
+// of sleeping inside the worker, so a burst of transient failures never idles
+// the pool. A partially filled batch is flushed as soon as the queue runs dry;
+// holding it would stall retries the feeder cannot release until those tasks
+// settle.
 func startWorker(w worker) {
 	defer w.Wg.Done()
 
@@ -136,49 +138,88 @@ func startWorker(w worker) {
 		return
 	}
 
-	client, err := ConnectSMTPWithContext(w.Ctx, w.Config)
-	if err != nil {
-		log.Printf("[Worker %d] SMTP connection failed: %v", w.ID, err)
-		return
-	}
-	defer func() {
-		if err := client.Quit(); err != nil {
-			log.Printf("[Worker %d] Failed to quit SMTP session: %v", w.ID, err)
-		}
-	}()
-
 	batch := make([]Task, 0, w.BatchSize)
 
 	for {
+		if len(batch) > 0 {
+			select {
+			case task, ok := <-w.TaskQueue:
+				if !ok {
+					processBatch(w, batch)
+					return
+				}
+				batch = append(batch, task)
+				if len(batch) >= w.BatchSize {
+					processBatch(w, batch)
+					batch = batch[:0]
+				}
+				continue
+			default:
+				processBatch(w, batch)
+				batch = batch[:0]
+			}
+		}
+
 		select {
 		case <-w.Ctx.Done():
-			if len(batch) > 0 {
-				processBatch(w, &client, batch)
-			}
 			log.Printf("[Worker %d] Context cancelled, stopping", w.ID)
 			return
 
 		case task, ok := <-w.TaskQueue:
 			if !ok {
-				if len(batch) > 0 {
-					processBatch(w, &client, batch)
-				}
 				return
 			}
 
 			batch = append(batch, task)
 			if len(batch) >= w.BatchSize {
-				processBatch(w, &client, batch)
+				processBatch(w, batch)
 				batch = batch[:0]
 			}
 		}
 	}
 }
 
-// processBatch sends a batch of tasks with inline retry logic.
-// On failure the worker sleeps for the backoff duration and retries up to retryLimit
-// times — no goroutines or channels needed, which eliminates all prior race conditions.
-func processBatch(w worker, clientPtr **smtp.Client, batch []Task) {
+// sendPooled sends task over a pooled connection and returns the connection
+// with the outcome. A connection-level failure is retried once immediately on
+// a fresh connection, since the relay may simply have closed an idle session.
+func sendPooled(w worker, task Task) error {
+	err := sendOnce(w, task)
+	if isConnectionError(err) {
+		log.Printf("[Worker %d] Connection error, retrying on a fresh connection: %v", w.ID, err)
+		err = sendOnce(w, task)
+	}
+	return err
+}
+
+func sendOnce(w worker, task Task) error {
+	conn, err := w.Pool.Get(w.Ctx)
+	if err != nil {
+		return err
+	}
+	err = SendWithClient(conn.client, w.Config, task, w.AttachmentCache)
+	w.Pool.Put(conn, err)
+	return err
+}
+
+// settle reports a final outcome for task to the feeder and drops any
+// persisted retry entry.
+func settle(w worker, task Task) {
+	if w.Deferred != nil {
+		w.Deferred.Settle(task)
+	}
+	if w.Settled == nil {
+		return
+	}
+	select {
+	case w.Settled <- struct{}{}:
+	case <-w.Ctx.Done():
+	}
+}
+
+// processBatch sends a batch of tasks. A failed task with attempts left is
+// parked in the retry queue with its backoff deadline and the worker moves on
+// to the next task; exhausted tasks are recorded as permanent failures.
+func processBatch(w worker, batch []Task) {
 	currentLimit := GetRetryLimit()
 
 	for _, task := range batch {
@@ -187,75 +228,63 @@ func processBatch(w worker, clientPtr **smtp.Client, batch []Task) {
 			return
 		}
 
-		// Inner retry loop: attempt → fail → sleep → retry (inline)
-		for {
-			start := time.Now()
-			w.Monitor.UpdateRecipientStatus(task.Recipient.Email, monitor.StatusSending, 0, "")
+		start := time.Now()
+		w.Monitor.UpdateRecipientStatus(task.Recipient.Email, monitor.StatusSending, 0, "")
 
-			err := SendWithClient(*clientPtr, w.Config, task, w.AttachmentCache)
+		err := sendPooled(w, task)
+		duration := time.Since(start)
 
-			// Reconnect on connection errors and retry once immediately
-			if isConnectionError(err) {
-				log.Printf("[Worker %d] Connection error, reconnecting: %v", w.ID, err)
-				if quitErr := (*clientPtr).Quit(); quitErr != nil {
-					log.Printf("[Worker %d] Quit failed: %v", w.ID, quitErr)
-				}
-				newClient, reconnErr := ConnectSMTPWithContext(w.Ctx, w.Config)
-				if reconnErr != nil {
-					log.Printf("[Worker %d] Reconnection failed: %v", w.ID, reconnErr)
-				} else {
-					*clientPtr = newClient
-					start = time.Now()
-					err = SendWithClient(*clientPtr, w.Config, task, w.AttachmentCache)
-				}
+		if err == nil {
+			logger.LogSuccessDetail(task.Recipient.Email, task.Subject, task.Variant)
+			w.Monitor.UpdateRecipientStatus(task.Recipient.Email, monitor.StatusSent, duration, "")
+			w.Monitor.AddSMTPResponse("250")
+			w.Sent.Add(1)
+			if w.OnSent != nil {
+				w.OnSent(task)
 			}
 
-			duration := time.Since(start)
-
-			if err == nil {
-				logger.LogSuccess(task.Recipient.Email, task.Subject)
-				w.Monitor.UpdateRecipientStatus(task.Recipient.Email, monitor.StatusSent, duration, "")
-				w.Monitor.AddSMTPResponse("250")
-				w.Sent.Add(1)
-
-				// Mark this index complete; the tracker maintains a contiguous
-				// high-water mark so resume is correct under concurrency. The
-				// disk write is coalesced by the dispatcher's flusher.
-				if w.Tracker != nil {
+			// Mark this index complete; the tracker maintains a contiguous
+			// high-water mark so resume is correct under concurrency. The
+			// disk write is coalesced by the dispatcher's flusher.
+			if w.Tracker != nil {
+				if task.Skipped > 0 {
+					w.Tracker.MarkCompleteRange(task.Index-task.Skipped, task.Index+1)
+				} else {
 					w.Tracker.MarkComplete(task.Index)
 				}
-				break
 			}
+			settle(w, task)
+			continue
+		}
 
-			// Send failed
+		// Send failed
+		if task.Variant != "" {
+			log.Printf("[Worker %d] Failed to send variant %s to %s: %v", w.ID, task.Variant, task.Recipient.Email, err)
+		} else {
 			log.Printf("[Worker %d] Failed to send to %s: %v", w.ID, task.Recipient.Email, err)
-			if code := extractSMTPCode(err.Error()); code != "" {
-				w.Monitor.AddSMTPResponse(code)
-			} else {
-				w.Monitor.AddSMTPResponse("error")
-			}
-
-			if task.Retries >= currentLimit {
-				// Exhausted retries — permanent failure
-				logger.LogFailure(task.Recipient.Email, task.Subject)
-				w.Monitor.UpdateRecipientStatus(task.Recipient.Email, monitor.StatusFailed, duration, err.Error())
-				w.Failed.Add(1)
-				break
-			}
-
-			// Schedule inline retry: increment counter, sleep with backoff, loop
-			task.Retries++
-			delay := retryDelay(task.Retries)
-			log.Printf("[Worker %d] Retrying %s in %v (attempt %d/%d)", w.ID, task.Recipient.Email, delay, task.Retries, currentLimit)
-			w.Monitor.UpdateRecipientStatus(task.Recipient.Email, monitor.StatusRetry, duration, err.Error())
+		}
+		code := extractSMTPCode(err.Error())
+		if code != "" {
+			w.Monitor.AddSMTPResponse(code)
+		} else {
+			w.Monitor.AddSMTPResponse("error")
+		}
 
-			select {
-			case <-time.After(delay):
-				// Continue to next iteration of retry loop
-			case <-w.Ctx.Done():
-				log.Printf("[Worker %d] Context cancelled during retry wait for %s", w.ID, task.Recipient.Email)
-				return
-			}
+		if task.Retries >= currentLimit || w.Deferred == nil {
+			// Exhausted retries — permanent failure
+			logger.LogFailureDetail(task.Recipient.Email, task.Subject, code, w.JobID)
+			w.Monitor.UpdateRecipientStatus(task.Recipient.Email, monitor.StatusFailed, duration, err.Error())
+			w.Failed.Add(1)
+			settle(w, task)
+			continue
 		}
+
+		// Park the task with its backoff deadline; any free worker picks it
+		// up once the feeder finds it due.
+		task.Retries++
+		delay := retryDelay(task.Retries)
+		log.Printf("[Worker %d] Deferring %s for %v (attempt %d/%d)", w.ID, task.Recipient.Email, delay, task.Retries, currentLimit)
+		w.Monitor.UpdateRecipientStatus(task.Recipient.Email, monitor.StatusRetry, duration, err.Error())
+		w.Deferred.Defer(task, time.Now().Add(delay))
 	}
 }
@@ -1,7 +1,14 @@
 package email
 
 import (
+	"context"
+	"os"
+	"sync/atomic"
 	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/logger"
+	"github.com/bravo1goingdark/mailgrid/monitor"
+	"github.com/bravo1goingdark/mailgrid/parser"
 )
 
 func TestExtractSMTPCode(t *testing.T) {
@@ -130,3 +137,50 @@ func TestSetRetryLimit(t *testing.T) {
 		})
 	}
 }
+
+// logToTempDir runs the test in a temporary directory, where sends write
+// success.csv and failed.csv.
+func logToTempDir(t *testing.T) {
+	t.Helper()
+	dir, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		logger.FlushAndClose()
+		os.Chdir(dir)
+	})
+}
+
+func TestProcessBatch_OnSent(t *testing.T) {
+	logToTempDir(t)
+
+	s := newFakeSMTP(t)
+	s.rejectRcpt = "bounce@example.com"
+	p := newTestPool(s, PoolOptions{})
+	defer p.Close()
+
+	var sent, failed atomic.Int64
+	var got []string
+	w := worker{
+		ID:      1,
+		Config:  s.config(),
+		Monitor: monitor.NewNoOpMonitor(),
+		Pool:    p,
+		Ctx:     context.Background(),
+		Sent:    &sent,
+		Failed:  &failed,
+		OnSent:  func(t Task) { got = append(got, t.Recipient.Email+"/"+t.Variant) },
+	}
+	processBatch(w, []Task{
+		{Recipient: parser.Recipient{Email: "a@example.com"}, Subject: "hi", PlainText: "body", Variant: "A"},
+		{Recipient: parser.Recipient{Email: "bounce@example.com"}, Subject: "hi", PlainText: "body", Variant: "B"},
+	})
+
+	if len(got) != 1 || got[0] != "a@example.com/A" {
+		t.Errorf("OnSent called for %v, want only a@example.com/A", got)
+	}
+	if sent.Load() != 1 || failed.Load() != 1 {
+		t.Errorf("sent %d, failed %d; want 1 and 1", sent.Load(), failed.Load())
+	}
+}
@@ -0,0 +1,69 @@
+// Package token signs the small key/value payloads carried in links mailgrid
+// puts into emails (unsubscribe, tracking), so the endpoints that receive them
+// need no server-side state and links cannot be forged.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalid is returned for tokens that are malformed or whose signature
+// does not match.
+var ErrInvalid = errors.New("invalid token")
+
+// Purposes a token is signed for. The purpose is part of the signed payload,
+// so a token verifies only for its own: a tracked link cannot be used to
+// unsubscribe when both share a secret.
+const (
+	Unsubscribe = "unsub"
+	Open        = "open"
+	Click       = "click"
+)
+
+// purposeKey is the payload key holding the purpose.
+const purposeKey = "p"
+
+// Sign encodes v and purpose as "<base64url payload>.<base64url
+// HMAC-SHA256>". The result is safe in a URL path segment.
+func Sign(secret []byte, purpose string, v url.Values) string {
+	signed := url.Values{purposeKey: {purpose}}
+	for k, vs := range v {
+		signed[k] = vs
+	}
+	payload := []byte(signed.Encode())
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac(secret, payload))
+}
+
+// Verify checks tok against secret and purpose and returns its payload
+// without the purpose.
+func Verify(secret []byte, purpose, tok string) (url.Values, error) {
+	dot := strings.IndexByte(tok, '.')
+	if dot < 0 {
+		return nil, ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(tok[:dot])
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(tok[dot+1:])
+	if err != nil || !hmac.Equal(sig, mac(secret, payload)) {
+		return nil, ErrInvalid
+	}
+	v, err := url.ParseQuery(string(payload))
+	if err != nil || purpose == "" || v.Get(purposeKey) != purpose {
+		return nil, ErrInvalid
+	}
+	v.Del(purposeKey)
+	return v, nil
+}
+
+func mac(secret, payload []byte) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write(payload)
+	return m.Sum(nil)
+}
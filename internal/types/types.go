@@ -7,20 +7,49 @@ import (
 
 // CLIArgs is the payload used for scheduled jobs. It mirrors key CLI fields.
 type CLIArgs struct {
-	EnvPath     string   `json:"env,omitempty"`
-	To          string   `json:"to,omitempty"`
-	Subject     string   `json:"subject,omitempty"`
-	Text        string   `json:"text,omitempty"`
-	Template    string   `json:"template,omitempty"`
-	CSVPath     string   `json:"csv,omitempty"`
-	SheetURL    string   `json:"sheet_url,omitempty"`
-	Attachments []string `json:"attachments,omitempty"`
-	Cc          string   `json:"cc,omitempty"`
-	Bcc         string   `json:"bcc,omitempty"`
-	Concurrency int      `json:"concurrency,omitempty"`
-	RetryLimit  int      `json:"retries,omitempty"`
-	BatchSize   int      `json:"batch_size,omitempty"`
-	Filter      string   `json:"filter,omitempty"`
+	EnvPath      string   `json:"env,omitempty"`
+	To           string   `json:"to,omitempty"`
+	Subject      string   `json:"subject,omitempty"`
+	Subjects     []string `json:"subject_variants,omitempty"`
+	Text         string   `json:"text,omitempty"`
+	Template     string   `json:"template,omitempty"`
+	Templates    []string `json:"template_variants,omitempty"`
+	Weights      []int    `json:"variant_weights,omitempty"`
+	ABTest       string   `json:"ab_test,omitempty"`
+	ABMetric     string   `json:"ab_metric,omitempty"`
+	ABWait       string   `json:"ab_wait,omitempty"`
+	ABRollout    string   `json:"ab_rollout,omitempty"`
+	ABWinner     string   `json:"ab_winner,omitempty"`
+	CSVPath      string   `json:"csv,omitempty"`
+	Join         []string `json:"join_csv,omitempty"`
+	JoinMode     string   `json:"join,omitempty"`
+	JoinConflict string   `json:"join_conflict,omitempty"`
+	JoinReport   string   `json:"unmatched_report,omitempty"`
+	Sheet        string   `json:"sheet,omitempty"`
+	SheetURL     string   `json:"sheet_url,omitempty"`
+	Query        string   `json:"query,omitempty"`
+	SourceURL    string   `json:"source_url,omitempty"`
+	Headers      []string `json:"source_headers,omitempty"`
+	Delimiter    string   `json:"delimiter,omitempty"`
+	Quote        string   `json:"quote,omitempty"`
+	Encoding     string   `json:"encoding,omitempty"`
+	EmailColumn  string   `json:"email_column,omitempty"`
+	Rename       []string `json:"rename_columns,omitempty"`
+	Attachments  []string `json:"attachments,omitempty"`
+	Cc           string   `json:"cc,omitempty"`
+	Bcc          string   `json:"bcc,omitempty"`
+	Concurrency  int      `json:"concurrency,omitempty"`
+	RetryLimit   int      `json:"retries,omitempty"`
+	BatchSize    int      `json:"batch_size,omitempty"`
+	Filter       string   `json:"filter,omitempty"`
+	ColumnTypes  []string `json:"column_types,omitempty"`
+	List         string   `json:"list,omitempty"`
+	NoSuppress   bool     `json:"ignore_suppressions,omitempty"`
+	MXCheck      string   `json:"mx_check,omitempty"`
+	Sample       string   `json:"sample,omitempty"`
+	Limit        int      `json:"limit,omitempty"`
+	Holdout      string   `json:"holdout,omitempty"`
+	UTM          []string `json:"utm,omitempty"`
 
 	ScheduleAt    string `json:"schedule_at,omitempty"`
 	Interval      string `json:"interval,omitempty"`
@@ -53,3 +82,92 @@ type Job struct {
 	LastRunAt time.Time `json:"last_run_at,omitempty"`
 	NextRunAt time.Time `json:"next_run_at,omitempty"`
 }
+
+// Campaign is the history record of one bulk send, keyed by its job ID.
+// Delivery counters are written when the run finishes; bounce counters are
+// maintained by bounce ingestion.
+type Campaign struct {
+	ID          string         `json:"id"`
+	ParentID    string         `json:"parent_id,omitempty"` // set for retry and A/B rollout campaigns
+	Tag         string         `json:"tag,omitempty"`       // short VERP/Message-ID tag derived from ID
+	Total       int            `json:"total"`
+	Suppressed  int            `json:"suppressed,omitempty"` // recipients skipped by the suppression list
+	Sent        int            `json:"sent"`
+	Failed      int            `json:"failed"`
+	HardBounces int            `json:"hard_bounces"`
+	SoftBounces int            `json:"soft_bounces"`
+	Complaints  int            `json:"complaints,omitempty"` // feedback-loop spam reports
+	Opened      int            `json:"opened,omitempty"`     // recipients who opened (or clicked) at least once
+	Clicked     int            `json:"clicked,omitempty"`    // recipients who clicked at least once
+	Groups      map[string]int `json:"groups,omitempty"`     // recipients per --holdout/--sample/--ab-test/--limit group
+	Variants    map[string]int `json:"variants,omitempty"`   // emails sent per A/B variant
+	Winner      string         `json:"winner,omitempty"`     // A/B variant a rollout campaign sent
+	StartedAt   time.Time      `json:"started_at"`
+	FinishedAt  time.Time      `json:"finished_at,omitempty"`
+}
+
+// BounceEvent is one recipient's bounce for a campaign. Re-ingesting the
+// same bounce overwrites the earlier event rather than counting twice.
+type BounceEvent struct {
+	CampaignID string    `json:"campaign_id,omitempty"`
+	Email      string    `json:"email"`
+	Class      string    `json:"class"` // "hard" or "soft"
+	Status     string    `json:"status,omitempty"`
+	Diagnostic string    `json:"diagnostic,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// ComplaintEvent is one recipient's spam complaint for a campaign, from an
+// ISP feedback-loop (ARF) report. Like bounces, events are keyed by campaign
+// and recipient.
+type ComplaintEvent struct {
+	CampaignID   string    `json:"campaign_id,omitempty"`
+	Email        string    `json:"email"`
+	FeedbackType string    `json:"feedback_type,omitempty"` // abuse, fraud, ...
+	Reporter     string    `json:"reporter,omitempty"`      // User-Agent of the reporting ISP
+	ArrivalDate  time.Time `json:"arrival_date,omitempty"`
+	ReceivedAt   time.Time `json:"received_at"`
+}
+
+// EngagementEvent is one tracked open or click.
+type EngagementEvent struct {
+	CampaignID string    `json:"campaign_id"`
+	Email      string    `json:"email"`
+	Kind       string    `json:"kind"`          // "open" or "click"
+	URL        string    `json:"url,omitempty"` // click target
+	At         time.Time `json:"at"`
+}
+
+// GroupMember records which group of a campaign's --holdout, --sample or
+// --limit selection a recipient was assigned to, and which A/B variant it
+// was sent.
+type GroupMember struct {
+	CampaignID string `json:"campaign_id"`
+	Email      string `json:"email"`
+	Group      string `json:"group"`
+	Variant    string `json:"variant,omitempty"`
+}
+
+// Engagement aggregates the tracked opens and clicks of one recipient in one
+// campaign.
+type Engagement struct {
+	CampaignID   string         `json:"campaign_id"`
+	Email        string         `json:"email"`
+	Opens        int            `json:"opens"`
+	Clicks       int            `json:"clicks"`
+	FirstOpenAt  time.Time      `json:"first_open_at,omitempty"`
+	LastOpenAt   time.Time      `json:"last_open_at,omitempty"`
+	FirstClickAt time.Time      `json:"first_click_at,omitempty"`
+	LastClickAt  time.Time      `json:"last_click_at,omitempty"`
+	Links        map[string]int `json:"links,omitempty"` // clicks per target URL
+}
+
+// Suppression is an address that bulk sends skip. An empty Scope suppresses
+// the address for every list; otherwise only runs with a matching --list skip it.
+type Suppression struct {
+	Email     string    `json:"email"`
+	Scope     string    `json:"scope,omitempty"`
+	Reason    string    `json:"reason,omitempty"` // e.g. "hard-bounce", "manual"
+	Source    string    `json:"source,omitempty"` // campaign or file that produced it
+	CreatedAt time.Time `json:"created_at"`
+}
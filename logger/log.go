@@ -2,12 +2,18 @@ package logger
 
 import (
 	"bufio"
-	"fmt"
+	"encoding/csv"
+	"errors"
+	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
 )
 
+// FailedLogFile is the default path of the permanent-failure log.
+const FailedLogFile = "failed.csv"
+
 // csvLogger holds a persistent, buffered file handle for a CSV log file.
 // Opening a new file handle on every log call is slow under high concurrency
 // and risks interleaved writes. One handle per file, flushed periodically and at shutdown.
@@ -15,6 +21,7 @@ type csvLogger struct {
 	mu     sync.Mutex
 	file   *os.File
 	writer *bufio.Writer
+	csv    *csv.Writer
 }
 
 func newCSVLogger(filename string) (*csvLogger, error) {
@@ -22,16 +29,20 @@ func newCSVLogger(filename string) (*csvLogger, error) {
 	if err != nil {
 		return nil, err
 	}
+	bw := bufio.NewWriterSize(f, 64*1024) // 64 KB write buffer
 	return &csvLogger{
 		file:   f,
-		writer: bufio.NewWriterSize(f, 64*1024), // 64 KB write buffer
+		writer: bw,
+		csv:    csv.NewWriter(bw),
 	}, nil
 }
 
-func (l *csvLogger) write(email, subject, status string) {
+// write appends one row. Fields are CSV-quoted only when they contain a
+// comma, quote or newline, so plain rows stay "email,subject,status,...".
+func (l *csvLogger) write(fields ...string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	if _, err := fmt.Fprintf(l.writer, "%s,%s,%s\n", email, subject, status); err != nil {
+	if err := l.csv.Write(fields); err != nil {
 		log.Printf("Error writing CSV log: %v", err)
 	}
 }
@@ -39,6 +50,7 @@ func (l *csvLogger) write(email, subject, status string) {
 func (l *csvLogger) flush() {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.csv.Flush()
 	if err := l.writer.Flush(); err != nil {
 		log.Printf("Error flushing CSV log: %v", err)
 	}
@@ -78,7 +90,7 @@ func getFailedLogger() *csvLogger {
 	defer loggerMu.Unlock()
 	if failedLogger == nil {
 		var err error
-		failedLogger, err = newCSVLogger("failed.csv")
+		failedLogger, err = newCSVLogger(FailedLogFile)
 		if err != nil {
 			log.Printf("Could not open failed.csv: %v", err)
 		}
@@ -88,18 +100,82 @@ func getFailedLogger() *csvLogger {
 
 // LogSuccess logs a successful send to stdout and appends to success.csv.
 func LogSuccess(email string, subject string) {
-	log.Printf("Sent to %s", email)
+	LogSuccessDetail(email, subject, "")
+}
+
+// LogSuccessDetail is LogSuccess with the A/B variant the recipient was
+// sent. Every row has the variant column, left empty outside an A/B test,
+// so success.csv keeps a fixed width.
+func LogSuccessDetail(email, subject, variant string) {
+	if variant == "" {
+		log.Printf("Sent to %s", email)
+	} else {
+		log.Printf("Sent to %s (variant %s)", email, variant)
+	}
 	if l := getSuccessLogger(); l != nil {
-		l.write(email, subject, "OK")
+		l.write(email, subject, "OK", variant)
 	}
 }
 
 // LogFailure logs a permanent failure to stdout and appends to failed.csv.
 func LogFailure(email string, subject string) {
+	LogFailureDetail(email, subject, "", "")
+}
+
+// LogFailureDetail is LogFailure with the last SMTP response code (empty for
+// connection-level errors) and the campaign job ID, which `mailgrid retry`
+// uses to tell transient failures from hard bounces.
+func LogFailureDetail(email, subject, code, jobID string) {
 	log.Printf("Failed permanently: %s", email)
 	if l := getFailedLogger(); l != nil {
-		l.write(email, subject, "Failed")
+		l.write(email, subject, "Failed", code, jobID)
+	}
+}
+
+// FailureRecord is one row of failed.csv.
+type FailureRecord struct {
+	Email   string
+	Subject string
+	Code    string // SMTP response code; empty for connection errors and legacy rows
+	JobID   string // empty for legacy rows
+}
+
+// ReadFailures parses a failed.csv written by LogFailure/LogFailureDetail.
+// Legacy three-column rows are accepted with empty Code and JobID.
+func ReadFailures(path string) ([]FailureRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	var out []FailureRecord
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) == 0 || strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		fr := FailureRecord{Email: strings.TrimSpace(rec[0])}
+		if len(rec) > 1 {
+			fr.Subject = rec[1]
+		}
+		if len(rec) > 3 {
+			fr.Code = strings.TrimSpace(rec[3])
+		}
+		if len(rec) > 4 {
+			fr.JobID = strings.TrimSpace(rec[4])
+		}
+		out = append(out, fr)
 	}
+	return out, nil
 }
 
 // FlushAndClose flushes write buffers and closes all open log file handles.
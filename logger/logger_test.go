@@ -58,7 +58,7 @@ func TestCSVLogger(t *testing.T) {
 	}
 }
 
-// TestCSVLoggerWithCommasInSubject verifies that subjects with commas are written as-is.
+// TestCSVLoggerWithCommasInSubject verifies that subjects with commas survive the write.
 func TestCSVLoggerWithCommasInSubject(t *testing.T) {
 	tmpDir := t.TempDir()
 	testFile := filepath.Join(tmpDir, "test.csv")
@@ -77,7 +77,7 @@ func TestCSVLoggerWithCommasInSubject(t *testing.T) {
 		t.Fatalf("Failed to read test file: %v", err)
 	}
 
-	// The logger writes raw CSV without quoting; commas in subject fields are unescaped.
+	// Fields with commas are CSV-quoted so the row still parses back.
 	if !strings.Contains(string(content), "Subject, with, commas") {
 		t.Error("Subject with commas was not written correctly")
 	}
@@ -119,11 +119,16 @@ func TestLogFunctions(t *testing.T) {
 
 	// Test LogSuccess
 	LogSuccess("success@example.com", "Success Subject")
+	LogSuccessDetail("variant@example.com", "Subject B", "B")
 	FlushAndClose()
 
 	// Verify success.csv was created and flushed
-	if _, err := os.Stat("success.csv"); os.IsNotExist(err) {
-		t.Error("success.csv was not created")
+	data, err := os.ReadFile("success.csv")
+	if err != nil {
+		t.Fatalf("success.csv was not created: %v", err)
+	}
+	if want := "success@example.com,Success Subject,OK,\nvariant@example.com,Subject B,OK,B\n"; string(data) != want {
+		t.Errorf("success.csv = %q, want %q", data, want)
 	}
 
 	// Reset so LogFailure gets a fresh handle in the same dir
@@ -145,3 +150,30 @@ func TestLogFunctions(t *testing.T) {
 	Errorf("Test error: %s", "test")
 	Warnf("Test warning: %s", "test")
 }
+
+func TestReadFailures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "failed.csv")
+
+	l, err := newCSVLogger(path)
+	if err != nil {
+		t.Fatalf("newCSVLogger() error: %v", err)
+	}
+	l.write("legacy@example.com", "Old", "Failed")
+	l.write("new@example.com", "Hi, there", "Failed", "451", "mailgrid-1")
+	l.close()
+
+	got, err := ReadFailures(path)
+	if err != nil {
+		t.Fatalf("ReadFailures() error: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 records, got %d: %+v", len(got), got)
+	}
+	if got[0].Email != "legacy@example.com" || got[0].Code != "" || got[0].JobID != "" {
+		t.Errorf("legacy row parsed as %+v", got[0])
+	}
+	want := FailureRecord{Email: "new@example.com", Subject: "Hi, there", Code: "451", JobID: "mailgrid-1"}
+	if got[1] != want {
+		t.Errorf("detailed row = %+v, want %+v", got[1], want)
+	}
+}
@@ -6,9 +6,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/bravo1goingdark/mailgrid/internal/types"
 )
 
 // EmailStatus represents the status of an individual email
@@ -32,28 +36,30 @@ type RecipientStatus struct {
 	Attempts      int         `json:"attempts"`
 	LastAttempt   time.Time   `json:"last_attempt"`
 	Error         string      `json:"error,omitempty"`
-	Duration      int64       `json:"duration_ms"` // Duration in milliseconds
+	Duration      int64       `json:"duration_ms"`       // Duration in milliseconds
+	Variant       string      `json:"variant,omitempty"` // A/B test variant
 	domainCounted bool        `json:"-"`
 }
 
 // CampaignStats represents real-time campaign statistics
 type CampaignStats struct {
-	JobID             string                      `json:"job_id"`
-	StartTime         time.Time                   `json:"start_time"`
-	TotalRecipients   int                         `json:"total_recipients"`
-	PendingCount      int                         `json:"pending_count"`
-	SendingCount      int                         `json:"sending_count"`
-	SentCount         int                         `json:"sent_count"`
-	FailedCount       int                         `json:"failed_count"`
-	RetryCount        int                         `json:"retry_count"`
-	EmailsPerSecond   float64                     `json:"emails_per_second"`
-	EstimatedTimeLeft string                      `json:"estimated_time_left"`
-	AvgDurationMs     float64                     `json:"avg_duration_ms"`
-	Recipients        map[string]*RecipientStatus `json:"recipients"`
-	DomainBreakdown   map[string]int              `json:"domain_breakdown"`
-	SMTPResponseCodes map[string]int              `json:"smtp_response_codes"`
-	ConfigSummary     ConfigSummary               `json:"config_summary"`
-	LogEntries        []LogEntry                  `json:"log_entries"`
+	JobID             string                         `json:"job_id"`
+	StartTime         time.Time                      `json:"start_time"`
+	TotalRecipients   int                            `json:"total_recipients"`
+	PendingCount      int                            `json:"pending_count"`
+	SendingCount      int                            `json:"sending_count"`
+	SentCount         int                            `json:"sent_count"`
+	FailedCount       int                            `json:"failed_count"`
+	RetryCount        int                            `json:"retry_count"`
+	EmailsPerSecond   float64                        `json:"emails_per_second"`
+	EstimatedTimeLeft string                         `json:"estimated_time_left"`
+	AvgDurationMs     float64                        `json:"avg_duration_ms"`
+	Recipients        map[string]*RecipientStatus    `json:"recipients"`
+	DomainBreakdown   map[string]int                 `json:"domain_breakdown"`
+	VariantBreakdown  map[string]map[EmailStatus]int `json:"variant_breakdown,omitempty"` // recipients per A/B variant and status
+	SMTPResponseCodes map[string]int                 `json:"smtp_response_codes"`
+	ConfigSummary     ConfigSummary                  `json:"config_summary"`
+	LogEntries        []LogEntry                     `json:"log_entries"`
 }
 
 // ConfigSummary holds campaign configuration details
@@ -97,6 +103,25 @@ type Server struct {
 	// Broadcast debounce: callers set dirty; the broadcaster goroutine flushes at 100ms cadence.
 	dirty atomic.Bool
 	quit  chan struct{} // closed by Stop() to terminate background goroutines
+
+	// complaintCounts, when set, reports spam complaints per campaign from
+	// the campaign history for /metrics.
+	complaintCounts func() (map[string]int, error)
+
+	// engagement, when set, loads the tracked opens and clicks of a campaign
+	// for /api/engagement.
+	engagement func(jobID string) ([]types.Engagement, error)
+}
+
+// EngagementReport is the /api/engagement response.
+type EngagementReport struct {
+	JobID      string             `json:"job_id"`
+	Opened     int                `json:"opened"`  // recipients with at least one open
+	Clicked    int                `json:"clicked"` // recipients with at least one click
+	Opens      int                `json:"opens"`
+	Clicks     int                `json:"clicks"`
+	Links      map[string]int     `json:"links"` // clicks per target URL
+	Recipients []types.Engagement `json:"recipients"`
 }
 
 // NewServer creates a new monitoring server. clientTimeout controls how long
@@ -109,6 +134,7 @@ func NewServer(port int, clientTimeout time.Duration) *Server {
 	stats := &CampaignStats{
 		Recipients:        make(map[string]*RecipientStatus),
 		DomainBreakdown:   make(map[string]int),
+		VariantBreakdown:  make(map[string]map[EmailStatus]int),
 		SMTPResponseCodes: make(map[string]int),
 		LogEntries:        make([]LogEntry, 0, 1000),
 	}
@@ -144,6 +170,8 @@ func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
 		s.handleStatusAPI(w, r)
 	case "/api/stream":
 		s.handleStatusStream(w, r)
+	case "/api/engagement":
+		s.handleEngagementAPI(w, r)
 	case "/metrics":
 		s.handleMetrics(w, r)
 	case "/health":
@@ -155,6 +183,69 @@ func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// SetComplaintCounts registers fn as the source of the per-campaign
+// mailgrid_complaints_total series. It is called on every /metrics scrape,
+// so complaints ingested while the server runs show up without a restart;
+// campaigns open the database only briefly, so `complaints ingest` can write
+// meanwhile.
+func (s *Server) SetComplaintCounts(fn func() (map[string]int, error)) {
+	s.mu.Lock()
+	s.complaintCounts = fn
+	s.mu.Unlock()
+}
+
+// SetEngagementSource registers fn as the loader behind /api/engagement.
+func (s *Server) SetEngagementSource(fn func(jobID string) ([]types.Engagement, error)) {
+	s.mu.Lock()
+	s.engagement = fn
+	s.mu.Unlock()
+}
+
+// handleEngagementAPI returns the opens and clicks recorded for the campaign
+// named by ?job= (default: the running campaign) as JSON.
+func (s *Server) handleEngagementAPI(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	load := s.engagement
+	jobID := s.stats.JobID
+	s.mu.RUnlock()
+
+	if load == nil {
+		http.Error(w, "engagement tracking unavailable", http.StatusNotFound)
+		return
+	}
+	if q := r.URL.Query().Get("job"); q != "" {
+		jobID = q
+	}
+	recipients, err := load(jobID)
+	if err != nil {
+		log.Printf("monitor: failed to load engagement: %v", err)
+		http.Error(w, "failed to load engagement", http.StatusInternalServerError)
+		return
+	}
+
+	rep := EngagementReport{JobID: jobID, Links: map[string]int{}, Recipients: recipients}
+	if rep.Recipients == nil {
+		rep.Recipients = []types.Engagement{}
+	}
+	for _, e := range recipients {
+		if e.Opens > 0 {
+			rep.Opened++
+		}
+		if e.Clicks > 0 {
+			rep.Clicked++
+		}
+		rep.Opens += e.Opens
+		rep.Clicks += e.Clicks
+		for link, n := range e.Links {
+			rep.Links[link] += n
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(rep); err != nil {
+		http.Error(w, "failed to encode engagement", http.StatusInternalServerError)
+	}
+}
+
 // handleMetrics writes a minimal Prometheus-compatible text exposition of
 // current campaign counters. No external dependencies — plain fmt.Fprintf.
 func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
@@ -172,6 +263,7 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 			durationSecs = 0
 		}
 	}
+	complaintCounts := s.complaintCounts
 	s.mu.RUnlock()
 
 	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
@@ -190,8 +282,31 @@ func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "# HELP mailgrid_campaign_duration_seconds Elapsed seconds since campaign start\n")
 	fmt.Fprintf(w, "# TYPE mailgrid_campaign_duration_seconds gauge\n")
 	fmt.Fprintf(w, "mailgrid_campaign_duration_seconds %.3f\n", durationSecs)
+
+	if complaintCounts != nil {
+		counts, err := complaintCounts()
+		if err != nil {
+			log.Printf("monitor: failed to load complaint counts: %v", err)
+			return
+		}
+		campaigns := make([]string, 0, len(counts))
+		for id := range counts {
+			campaigns = append(campaigns, id)
+		}
+		sort.Strings(campaigns)
+		fmt.Fprintf(w, "# HELP mailgrid_complaints_total Spam complaints recorded from feedback-loop reports\n")
+		fmt.Fprintf(w, "# TYPE mailgrid_complaints_total counter\n")
+		for _, id := range campaigns {
+			fmt.Fprintf(w, "mailgrid_complaints_total{campaign=\"%s\"} %d\n", labelEscaper.Replace(id), counts[id])
+		}
+	}
 }
 
+// labelEscaper escapes a Prometheus label value. The text exposition format
+// defines only these three escapes; everything else, including non-ASCII
+// UTF-8, is written as is.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
 // handleHealth returns a basic health check
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -282,6 +397,11 @@ func (s *Server) InitializePending(emails []string) {
 		}
 		s.stats.PendingCount++
 	}
+	// Streamed runs start with an unknown total and grow it as recipients
+	// are queued.
+	if n := len(s.stats.Recipients); n > s.stats.TotalRecipients {
+		s.stats.TotalRecipients = n
+	}
 	s.calculateMetrics()
 	s.broadcastUpdate()
 }
@@ -303,6 +423,13 @@ func (s *Server) UpdateRecipientStatus(email string, status EmailStatus, duratio
 		s.decrementStatusCount(oldStatus)
 	}
 	s.incrementStatusCount(status)
+	if recipient.Variant != "" {
+		counts := s.stats.VariantBreakdown[recipient.Variant]
+		if oldStatus != "" {
+			counts[oldStatus]--
+		}
+		counts[status]++
+	}
 
 	recipient.Status = status
 	recipient.LastAttempt = time.Now()
@@ -328,6 +455,25 @@ func (s *Server) UpdateRecipientStatus(email string, status EmailStatus, duratio
 	s.broadcastUpdate()
 }
 
+// AssignVariant records the A/B variant a queued recipient was assigned, so
+// the dashboard can break progress down by variant.
+func (s *Server) AssignVariant(email, variant string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recipient, ok := s.stats.Recipients[email]
+	if !ok || recipient.Variant != "" {
+		return
+	}
+	recipient.Variant = variant
+	counts := s.stats.VariantBreakdown[variant]
+	if counts == nil {
+		counts = make(map[EmailStatus]int)
+		s.stats.VariantBreakdown[variant] = counts
+	}
+	counts[recipient.Status]++
+	s.broadcastUpdate()
+}
+
 // AddSMTPResponse records an SMTP response code
 func (s *Server) AddSMTPResponse(code string) {
 	s.mu.Lock()
@@ -472,6 +618,14 @@ func (s *Server) flushBroadcast() {
 	for k, v := range s.stats.SMTPResponseCodes {
 		statsCopy.SMTPResponseCodes[k] = v
 	}
+	statsCopy.VariantBreakdown = make(map[string]map[EmailStatus]int, len(s.stats.VariantBreakdown))
+	for variant, counts := range s.stats.VariantBreakdown {
+		cp := make(map[EmailStatus]int, len(counts))
+		for k, v := range counts {
+			cp[k] = v
+		}
+		statsCopy.VariantBreakdown[variant] = cp
+	}
 	if len(s.stats.LogEntries) > 0 {
 		statsCopy.LogEntries = make([]LogEntry, len(s.stats.LogEntries))
 		copy(statsCopy.LogEntries, s.stats.LogEntries)
@@ -588,6 +742,7 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
             <div id="campaign-info">
                 <strong>Campaign:</strong> <span id="job-id">-</span> |
                 <strong>Started:</strong> <span id="start-time">-</span>
+                <span id="variants"></span>
             </div>
         </div>
 
@@ -671,6 +826,10 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
             document.getElementById('estimated-time').textContent = stats.estimated_time_left || '-';
             document.getElementById('avg-duration').textContent = Math.round(stats.avg_duration_ms || 0) + 'ms';
 
+            const variants = Object.entries(stats.variant_breakdown || {}).sort().map(([name, counts]) =>
+                ` + "`${name}: ${counts.sent || 0} sent, ${counts.failed || 0} failed`" + `);
+            document.getElementById('variants').textContent = variants.length ? ' | Variants: ' + variants.join('; ') : '';
+
             const progress = stats.total_recipients > 0 ? (stats.sent_count / stats.total_recipients) * 100 : 0;
             document.getElementById('sent-progress').style.width = progress + '%';
 
@@ -681,7 +840,7 @@ func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
                 const row = document.createElement('div');
                 row.className = 'table-row';
                 row.innerHTML = ` + "`" + `
-                    <div>${recipient.email}</div>
+                    <div>${recipient.email}${recipient.variant ? ' [' + recipient.variant + ']' : ''}</div>
                     <div><span class="status status-${recipient.status}">${recipient.status}</span></div>
                     <div>${recipient.attempts || 0}</div>
                     <div>${recipient.duration_ms || 0}ms</div>
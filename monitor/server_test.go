@@ -1,8 +1,14 @@
 package monitor
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/bravo1goingdark/mailgrid/internal/types"
 )
 
 func TestNewServer(t *testing.T) {
@@ -175,6 +181,30 @@ func TestDomainBreakdownSingleRecipientMultipleTransitions(t *testing.T) {
 	}
 }
 
+func TestVariantBreakdown(t *testing.T) {
+	server := NewServer(9091, 0)
+	server.InitializeCampaign("test-job", ConfigSummary{}, 0)
+	server.InitializePending([]string{"a@example.com", "b@example.com", "c@example.com"})
+	server.AssignVariant("a@example.com", "A")
+	server.AssignVariant("b@example.com", "B")
+	server.AssignVariant("c@example.com", "B")
+
+	server.UpdateRecipientStatus("a@example.com", StatusSending, 0, "")
+	server.UpdateRecipientStatus("a@example.com", StatusSent, time.Millisecond, "")
+	server.UpdateRecipientStatus("b@example.com", StatusFailed, time.Millisecond, "550")
+
+	got := server.GetStats().VariantBreakdown
+	if a := got["A"]; a[StatusSent] != 1 || a[StatusPending] != 0 || a[StatusSending] != 0 {
+		t.Errorf("A = %v", a)
+	}
+	if b := got["B"]; b[StatusFailed] != 1 || b[StatusPending] != 1 {
+		t.Errorf("B = %v", b)
+	}
+	if v := server.stats.Recipients["c@example.com"].Variant; v != "B" {
+		t.Errorf("c variant = %q", v)
+	}
+}
+
 func TestAddSMTPResponse(t *testing.T) {
 	server := NewServer(9091, 0)
 
@@ -210,3 +240,69 @@ func TestEmailStatusConstants(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleMetrics_ComplaintCounts(t *testing.T) {
+	server := NewServer(9091, 0)
+	server.InitializeCampaign("job-2", ConfigSummary{}, 10)
+
+	rec := httptest.NewRecorder()
+	server.handleMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if strings.Contains(rec.Body.String(), "mailgrid_complaints_total") {
+		t.Error("complaint series must be absent without a history source")
+	}
+
+	server.SetComplaintCounts(func() (map[string]int, error) {
+		return map[string]int{"job-2": 0, "job-1": 3}, nil
+	})
+	rec = httptest.NewRecorder()
+	server.handleMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	want := "mailgrid_complaints_total{campaign=\"job-1\"} 3\nmailgrid_complaints_total{campaign=\"job-2\"} 0\n"
+	if !strings.Contains(body, "# TYPE mailgrid_complaints_total counter\n"+want) {
+		t.Errorf("unexpected metrics:\n%s", body)
+	}
+
+	// Label values escape only backslash, quote and newline.
+	server.SetComplaintCounts(func() (map[string]int, error) {
+		return map[string]int{"a\\b\"c\nd-é": 1}, nil
+	})
+	rec = httptest.NewRecorder()
+	server.handleMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if want := `mailgrid_complaints_total{campaign="a\\b\"c\nd-é"} 1` + "\n"; !strings.Contains(rec.Body.String(), want) {
+		t.Errorf("escaped label: want %q in:\n%s", want, rec.Body.String())
+	}
+}
+
+func TestHandleEngagementAPI(t *testing.T) {
+	server := NewServer(9092, 0)
+	server.InitializeCampaign("job-2", ConfigSummary{}, 10)
+
+	rec := httptest.NewRecorder()
+	server.serveHTTP(rec, httptest.NewRequest("GET", "/api/engagement", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 without an engagement source, got %d", rec.Code)
+	}
+
+	var asked string
+	server.SetEngagementSource(func(jobID string) ([]types.Engagement, error) {
+		asked = jobID
+		return []types.Engagement{
+			{Email: "a@example.com", Opens: 2, Clicks: 1, Links: map[string]int{"https://example.com/x": 1}},
+			{Email: "b@example.com", Opens: 1},
+		}, nil
+	})
+	rec = httptest.NewRecorder()
+	server.serveHTTP(rec, httptest.NewRequest("GET", "/api/engagement", nil))
+	var rep EngagementReport
+	if err := json.NewDecoder(rec.Body).Decode(&rep); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if asked != "job-2" || rep.Opened != 2 || rep.Clicked != 1 || rep.Opens != 3 || rep.Links["https://example.com/x"] != 1 || len(rep.Recipients) != 2 {
+		t.Errorf("job %q, report %+v", asked, rep)
+	}
+
+	server.serveHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/engagement?job=job-1", nil))
+	if asked != "job-1" {
+		t.Errorf("?job= ignored, loaded %q", asked)
+	}
+}
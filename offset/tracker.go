@@ -17,9 +17,10 @@ const DefaultOffsetFile = ".mailgrid.offset"
 // Concurrency model:
 //   - GetOffset / ShouldSkip / GetJobID / GetInfo: read-only; safe from any goroutine.
 //   - UpdateOffset / SetJobID: writer methods used by the CLI before/after dispatch.
-//   - MarkComplete: hot-path call from worker goroutines. Maintains a contiguous
-//     high-water mark so the persisted offset advances only past indices that
-//     have all been completed (correct under concurrent out-of-order completion).
+//   - MarkComplete / MarkCompleteRange: hot-path calls from worker goroutines.
+//     Maintain a contiguous high-water mark so the persisted offset advances
+//     only past indices that have all been completed (correct under
+//     concurrent out-of-order completion).
 //   - Save: serializes to disk; the file write happens outside the lock so
 //     readers and MarkComplete callers do not block on I/O.
 type Tracker struct {
@@ -29,10 +30,10 @@ type Tracker struct {
 	jobID    string
 	dirty    bool
 
-	// pending holds completed indices that arrived out of order (i.e. with
-	// idx > offset at the time of MarkComplete). The map is drained as the
-	// contiguous prefix advances.
-	pending map[int]struct{}
+	// pending holds completed ranges [start, end) that arrived out of order
+	// (i.e. with start > offset at the time they were marked), keyed by start.
+	// The map is drained as the contiguous prefix advances.
+	pending map[int]int
 }
 
 // OffsetInfo contains information about the current offset state
@@ -157,21 +158,33 @@ func (t *Tracker) UpdateOffset(newOffset int) {
 // Indices that arrive below the current offset (e.g. duplicates from a retry
 // after a flusher has already moved past) are ignored.
 func (t *Tracker) MarkComplete(idx int) {
+	t.MarkCompleteRange(idx, idx+1)
+}
+
+// MarkCompleteRange records every index in [from, to) as done at once. The
+// CLI uses it for a delivered row together with the rows before it that were
+// filtered out, deduplicated or suppressed, which produce no task of their own.
+// Ranges are expected not to overlap except for exact repeats.
+func (t *Tracker) MarkCompleteRange(from, to int) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	if idx < t.offset {
+	if to <= t.offset {
 		return
 	}
-	if idx == t.offset {
-		t.offset++
+	if from < t.offset {
+		from = t.offset
+	}
+	if from == t.offset {
+		t.offset = to
 		if t.pending != nil {
 			for {
-				if _, ok := t.pending[t.offset]; !ok {
+				end, ok := t.pending[t.offset]
+				if !ok {
 					break
 				}
 				delete(t.pending, t.offset)
-				t.offset++
+				t.offset = end
 			}
 			if len(t.pending) == 0 {
 				t.pending = nil
@@ -182,9 +195,11 @@ func (t *Tracker) MarkComplete(idx int) {
 	}
 
 	if t.pending == nil {
-		t.pending = make(map[int]struct{})
+		t.pending = make(map[int]int)
+	}
+	if end, ok := t.pending[from]; !ok || end < to {
+		t.pending[from] = to
 	}
-	t.pending[idx] = struct{}{}
 }
 
 // Save writes the current offset to disk atomically (write to temp + rename).
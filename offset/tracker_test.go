@@ -266,6 +266,27 @@ func TestTracker_MarkComplete_RespectsResumeBaseline(t *testing.T) {
 	}
 }
 
+func TestTracker_MarkCompleteRange(t *testing.T) {
+	tracker := NewTracker("")
+
+	// Rows 3..5 were filtered out and are completed along with row 6.
+	tracker.MarkCompleteRange(3, 7)
+	if got := tracker.GetOffset(); got != 0 {
+		t.Fatalf("offset advanced past gap: got %d", got)
+	}
+
+	tracker.MarkCompleteRange(0, 3)
+	if got := tracker.GetOffset(); got != 7 {
+		t.Fatalf("expected 7 once ranges are contiguous, got %d", got)
+	}
+
+	// A range straddling the offset only contributes its upper part.
+	tracker.MarkCompleteRange(5, 9)
+	if got := tracker.GetOffset(); got != 9 {
+		t.Fatalf("expected 9, got %d", got)
+	}
+}
+
 func TestTracker_MarkComplete_Concurrent(t *testing.T) {
 	tracker := NewTracker("")
 	const total = 1000
@@ -0,0 +1,212 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnType is the type filter expressions see a column's values as.
+// Dates may name a Go time layout after a colon, as in "date:02/01/2006".
+type ColumnType string
+
+const (
+	TypeString ColumnType = "string"
+	TypeInt    ColumnType = "int"
+	TypeFloat  ColumnType = "float"
+	TypeBool   ColumnType = "bool"
+	TypeDate   ColumnType = "date" // time.Time
+)
+
+// dateLayouts are tried in order for dates without a declared layout.
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// typeAliases maps the names accepted by ParseColumnTypes to types.
+var typeAliases = map[string]ColumnType{
+	"string": TypeString, "text": TypeString, "str": TypeString,
+	"int": TypeInt, "integer": TypeInt,
+	"float": TypeFloat, "number": TypeFloat, "decimal": TypeFloat,
+	"bool": TypeBool, "boolean": TypeBool,
+	"date": TypeDate, "time": TypeDate, "datetime": TypeDate, "timestamp": TypeDate,
+}
+
+// base returns the type without a date layout.
+func (t ColumnType) base() ColumnType {
+	if i := strings.IndexByte(string(t), ':'); i >= 0 {
+		return t[:i]
+	}
+	return t
+}
+
+// zero returns a value of the Go type filters see for t.
+func (t ColumnType) zero() any {
+	switch t.base() {
+	case TypeInt:
+		return 0
+	case TypeFloat:
+		return 0.0
+	case TypeBool:
+		return false
+	case TypeDate:
+		return time.Time{}
+	}
+	return ""
+}
+
+// Convert parses a cell as t. Blank cells and cells that do not parse
+// return an error.
+func (t ColumnType) Convert(value string) (any, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, fmt.Errorf("blank value")
+	}
+	switch t.base() {
+	case TypeInt:
+		return strconv.Atoi(value)
+	case TypeFloat:
+		return strconv.ParseFloat(value, 64)
+	case TypeBool:
+		switch strings.ToLower(value) {
+		case "true", "yes", "y", "1":
+			return true, nil
+		case "false", "no", "n", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean", value)
+	case TypeDate:
+		if i := strings.IndexByte(string(t), ':'); i >= 0 {
+			return time.Parse(string(t[i+1:]), value)
+		}
+		for _, layout := range dateLayouts {
+			if v, err := time.Parse(layout, value); err == nil {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("%q is not a date", value)
+	}
+	return strings.ToLower(value), nil
+}
+
+// ParseColumnTypes reads declarations such as "age=int,signup=date". Each
+// spec may hold several comma-separated name=type pairs. Column names are
+// lower-cased like headers; types are string, int, float, bool and date,
+// or date:<layout> for a Go time layout.
+func ParseColumnTypes(specs ...string) (map[string]ColumnType, error) {
+	types := make(map[string]ColumnType)
+	for _, spec := range specs {
+		for _, pair := range strings.Split(spec, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			name, typ, ok := strings.Cut(pair, "=")
+			name = strings.ToLower(strings.TrimSpace(name))
+			typ = strings.TrimSpace(typ)
+			if !ok || name == "" || typ == "" {
+				return nil, fmt.Errorf("invalid column type %q: expected name=type", pair)
+			}
+			layout := ""
+			if i := strings.IndexByte(typ, ':'); i >= 0 {
+				typ, layout = typ[:i], typ[i+1:]
+			}
+			t, known := typeAliases[strings.ToLower(typ)]
+			if !known {
+				return nil, fmt.Errorf("unknown type %q for column %q (use string, int, float, bool or date)", typ, name)
+			}
+			if layout != "" {
+				if t != TypeDate {
+					return nil, fmt.Errorf("only date columns take a layout, got %q for column %q", pair, name)
+				}
+				t += ColumnType(":" + layout)
+			}
+			types[name] = t
+		}
+	}
+	return types, nil
+}
+
+// plainInt and plainFloat match numbers without leading zeros or a plus
+// sign, so codes such as zip "02134" or phone "+4930..." are not mistaken
+// for numbers.
+var (
+	plainInt   = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+	plainFloat = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+)
+
+// InferColumnTypes guesses a type for each column from sample: int when
+// every non-blank value is a plain integer, float when every one is a
+// number, bool for true/false/yes/no and date for the layouts Convert
+// accepts without one. Other columns, and columns blank throughout the
+// sample, are strings.
+func InferColumnTypes(sample []Recipient) map[string]ColumnType {
+	values := make(map[string][]string)
+	for _, r := range sample {
+		for k, v := range r.Data {
+			if v = strings.TrimSpace(v); v != "" {
+				values[k] = append(values[k], v)
+			} else if _, ok := values[k]; !ok {
+				values[k] = nil
+			}
+		}
+	}
+
+	types := make(map[string]ColumnType, len(values))
+	for col, vs := range values {
+		types[col] = inferType(vs)
+	}
+	return types
+}
+
+func inferType(values []string) ColumnType {
+	if len(values) == 0 {
+		return TypeString
+	}
+	candidates := []ColumnType{TypeInt, TypeFloat, TypeBool, TypeDate}
+	for _, t := range candidates {
+		fits := true
+		for _, v := range values {
+			switch t {
+			case TypeInt:
+				fits = plainInt.MatchString(v)
+			case TypeFloat:
+				fits = plainFloat.MatchString(v)
+			case TypeBool:
+				switch strings.ToLower(v) {
+				case "true", "false", "yes", "no":
+				default:
+					fits = false
+				}
+			default:
+				_, err := t.Convert(v)
+				fits = err == nil
+			}
+			if !fits {
+				break
+			}
+		}
+		if fits {
+			return t
+		}
+	}
+	return TypeString
+}
+
+// describeTypes lists the non-string column types for error messages.
+func describeTypes(types map[string]ColumnType) string {
+	var parts []string
+	for col, t := range types {
+		if t != TypeString {
+			parts = append(parts, col+"="+string(t))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ", ")
+}
@@ -3,11 +3,13 @@ package parser
 import (
 	"encoding/csv"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/mail"
 	"os"
 	"strings"
+	"unicode"
 )
 
 // ValidateEmail checks if an email address is valid using net/mail.
@@ -22,23 +24,71 @@ func IsValidEmail(email string) bool {
 	return ValidateEmail(email) == nil
 }
 
-// ParseCSVFromReader reads a CSV from any io.Reader and returns a list of Recipients.
-// It expects one column to be named 'email' and uses other columns as dynamic data.
-// Invalid email addresses are skipped with a warning.
-func ParseCSVFromReader(reader io.Reader) ([]Recipient, error) {
-	// create a new CSV reader instance
-	csvReader := csv.NewReader(reader)
-	csvReader.TrimLeadingSpace = true // clean up any accidental spaces
+// rowReader yields the raw cells of a table one row at a time, returning
+// io.EOF after the last row. *csv.Reader satisfies it.
+type rowReader interface {
+	Read() ([]string, error)
+}
 
+// TableSource streams recipients from tabular input: CSV or a spreadsheet.
+// It expects one column to be named 'email' and uses the other columns as
+// dynamic data. Malformed rows and invalid addresses are skipped with a
+// warning; rows with a blank email are skipped silently. Row numbers count
+// every data row, skipped or not.
+type TableSource struct {
+	reader   rowReader
+	closer   io.Closer
+	headers  []string
+	emailIdx int
+	lenient  bool // pad short rows; ignore extra cells when empty
+	rows     int  // data rows read so far
+	skipped  int  // malformed rows and invalid addresses
+	line     int  // input line the last row started on
+	onSkip   func(SkippedRow)
+}
+
+// Skip reasons reported in SkippedRow.Reason.
+const (
+	SkipMalformed    = "malformed"
+	SkipInvalidEmail = "invalid_email"
+	SkipBlankEmail   = "blank_email"
+)
+
+// SkippedRow is a data row a TableSource did not return as a recipient.
+type SkippedRow struct {
+	Row    int // 0-based data row, counted as Recipient.Row
+	Line   int // 1-based input line the row starts on
+	Reason string
+	Email  string
+	Record []string // the cells as read; a malformed row may be partial
+	Err    error    // the parse error of a malformed row, if any
+}
+
+// positionReader is a rowReader that knows where its last row started.
+// *csv.Reader satisfies it.
+type positionReader interface {
+	FieldPos(field int) (line, column int)
+}
+
+// newTableSource reads the header row from reader and applies the column
+// mapping of opts. kind names the input format in errors. Spreadsheets are
+// read leniently because they omit trailing empty cells.
+func newTableSource(reader rowReader, kind string, lenient bool, opts ReadOptions) (*TableSource, error) {
 	// read the first row as header (column names)
-	headers, err := csvReader.Read()
+	headers, err := reader.Read()
 	if err != nil {
 		return nil, err
 	}
+	headers = append([]string(nil), headers...)
+	if lenient {
+		for len(headers) > 0 && strings.TrimSpace(headers[len(headers)-1]) == "" {
+			headers = headers[:len(headers)-1]
+		}
+	}
 
-	// normalize headers to lowercase and trim extra spaces
-	for i, h := range headers {
-		headers[i] = strings.ToLower(strings.TrimSpace(h))
+	// normalize headers to lowercase, trim extra spaces and rename columns
+	if err := opts.mapHeaders(headers); err != nil {
+		return nil, err
 	}
 
 	emailIdx := -1
@@ -49,75 +99,222 @@ func ParseCSVFromReader(reader io.Reader) ([]Recipient, error) {
 		}
 	}
 	if emailIdx == -1 {
-		return nil, errors.New("CSV must include 'email' column")
+		return nil, fmt.Errorf("%s must include 'email' column", kind)
+	}
+	return &TableSource{reader: reader, headers: headers, emailIdx: emailIdx, lenient: lenient}, nil
+}
+
+// NewCSVSource reads the header row from reader and returns a source for the
+// remaining rows. The input is comma-separated UTF-8; a leading byte order
+// mark is ignored.
+func NewCSVSource(reader io.Reader) (*TableSource, error) {
+	return NewCSVSourceWithOptions(reader, ReadOptions{})
+}
+
+// NewCSVSourceWithOptions is NewCSVSource for the delimiter, quote,
+// encoding and column mapping in opts.
+func NewCSVSourceWithOptions(reader io.Reader, opts ReadOptions) (*TableSource, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	decoded, err := decodeInput(reader, opts.Encoding)
+	if err != nil {
+		return nil, err
 	}
 
-	var recipients []Recipient
-	var skippedCount int
-	var totalRows int
+	// encoding/csv only quotes with '"', so another quote character is
+	// swapped with it on the way in and back in each parsed field.
+	var input io.Reader = decoded
+	comma := opts.delimiter()
+	quote := byte('"')
+	switch {
+	case opts.Quote == NoQuote:
+		quote = 0
+	case opts.Quote != 0:
+		quote = byte(opts.Quote)
+	}
+	if quote != '"' {
+		input = quoteSwapReader{r: decoded, q: quote}
+		if comma == '"' {
+			comma = rune(quote)
+		}
+	}
+
+	// create a new CSV reader instance
+	csvReader := csv.NewReader(input)
+	csvReader.Comma = comma
+	csvReader.TrimLeadingSpace = !unicode.IsSpace(comma) // clean up any accidental spaces
+	csvReader.ReuseRecord = true                         // each row's values are copied into Data
+	var rows rowReader = csvReader
+	if quote != '"' {
+		rows = quoteSwapRows{rows: csvReader, q: quote}
+	}
+	return newTableSource(rows, "CSV", false, opts)
+}
+
+// OpenCSV opens the CSV file at path as a streaming source. Closing the
+// source closes the file.
+func OpenCSV(path string, opts ReadOptions) (*TableSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	src, err := NewCSVSourceWithOptions(file, opts)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	src.closer = file
+	return src, nil
+}
 
-	// Read the remaining rows (one recipient per row)
+// Next returns the next valid recipient, or io.EOF at the end of the input.
+func (s *TableSource) Next() (Recipient, error) {
 	for {
-		record, err := csvReader.Read()
+		record, err := s.reader.Read()
 		if err == io.EOF {
-			break // end of file reached
+			return Recipient{}, io.EOF
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return Recipient{}, err // I/O error: the rest of the input is unreadable
+		}
+		row := s.rows
+		s.rows++
+		s.position(record, parseErr)
+		if err == nil && s.lenient {
+			record = s.fit(record)
 		}
-		totalRows++
-		if err != nil || len(record) != len(headers) {
-			skippedCount++
-			log.Printf("Warning: Skipping malformed CSV row (expected %d fields, got %d)", len(headers), len(record))
+		if err != nil || len(record) != len(s.headers) {
+			s.skipped++
+			log.Printf("Warning: Skipping malformed row %d (expected %d fields, got %d)", s.rows, len(s.headers), len(record))
+			s.skip(SkippedRow{Row: row, Reason: SkipMalformed, Record: record, Err: err})
 			continue // skip malformed or mismatched rows
 		}
 
 		// Grab and clean the email value
-		email := strings.TrimSpace(record[emailIdx])
+		email := strings.TrimSpace(record[s.emailIdx])
 		if email == "" {
+			s.skip(SkippedRow{Row: row, Reason: SkipBlankEmail, Record: record})
 			continue // skip blank emails
 		}
 
 		// Validate email address
 		if !IsValidEmail(email) {
-			skippedCount++
-			log.Printf("Warning: Skipping row %d with invalid email: %s", totalRows, email)
+			s.skipped++
+			log.Printf("Warning: Skipping row %d with invalid email: %s", s.rows, email)
+			s.skip(SkippedRow{Row: row, Reason: SkipInvalidEmail, Email: email, Record: record})
 			continue
 		}
 
 		// Collect all the other data fields (except email)
-		data := make(map[string]string)
+		data := make(map[string]string, len(record)-1)
 		for i, value := range record {
-			key := headers[i]
+			key := s.headers[i]
 			if key == "email" {
 				continue
 			}
 			data[key] = strings.TrimSpace(value)
 		}
+		return Recipient{Email: email, Data: data, Row: row}, nil
+	}
+}
 
-		// Add the parsed recipient to our result list
-		recipients = append(recipients, Recipient{
-			Email: email,
-			Data:  data,
-		})
-	}
-
-	// Deduplicate by email (case-insensitive). A CSV with duplicate addresses
-	// would otherwise send the same email multiple times.
-	seen := make(map[string]struct{}, len(recipients))
-	deduped := recipients[:0]
-	var dupCount int
-	for _, r := range recipients {
-		key := strings.ToLower(r.Email)
-		if _, exists := seen[key]; exists {
-			dupCount++
-			continue
-		}
-		seen[key] = struct{}{}
-		deduped = append(deduped, r)
+// position records the input line the row just read starts on. Readers
+// that cannot tell, like spreadsheets, are assumed to hold one row per line
+// below the header.
+func (s *TableSource) position(record []string, parseErr *csv.ParseError) {
+	switch pr, ok := s.reader.(positionReader); {
+	case parseErr != nil:
+		s.line = parseErr.StartLine
+	case ok && len(record) > 0:
+		s.line, _ = pr.FieldPos(0)
+	default:
+		s.line = s.rows + 1
+	}
+}
+
+func (s *TableSource) skip(r SkippedRow) {
+	if s.onSkip == nil {
+		return
+	}
+	r.Line = s.line
+	r.Record = append([]string(nil), r.Record...)
+	s.onSkip(r)
+}
+
+// OnSkip registers fn to be called for every row Next skips, including rows
+// with a blank email, which are not counted by Skipped.
+func (s *TableSource) OnSkip(fn func(SkippedRow)) { s.onSkip = fn }
+
+// Line returns the 1-based input line the last row read started on.
+func (s *TableSource) Line() int { return s.line }
+
+// Columns returns the column names after mapping, in input order. The
+// email column is named "email".
+func (s *TableSource) Columns() []string { return append([]string(nil), s.headers...) }
+
+// fit pads a short spreadsheet row to the header width and drops empty
+// cells past it. A non-empty cell past the last header leaves the row
+// mismatched.
+func (s *TableSource) fit(record []string) []string {
+	for len(record) > len(s.headers) && strings.TrimSpace(record[len(record)-1]) == "" {
+		record = record[:len(record)-1]
+	}
+	for len(record) < len(s.headers) {
+		record = append(record, "")
+	}
+	return record
+}
+
+// Rows returns how many data rows have been read.
+func (s *TableSource) Rows() int { return s.rows }
+
+// Skipped returns how many rows were skipped as malformed or invalid.
+func (s *TableSource) Skipped() int { return s.skipped }
+
+// Close releases the underlying file, if the source owns one.
+func (s *TableSource) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// ParseCSVFromReader reads a CSV from any io.Reader and returns a list of Recipients.
+// It expects one column to be named 'email' and uses other columns as dynamic data.
+// Invalid email addresses are skipped with a warning.
+func ParseCSVFromReader(reader io.Reader) ([]Recipient, error) {
+	src, err := NewCSVSource(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return collectTable(src, "CSV")
+}
+
+// ParseCSVWithOptions is ParseCSVFromReader for the dialect and column
+// mapping in opts.
+func ParseCSVWithOptions(reader io.Reader, opts ReadOptions) ([]Recipient, error) {
+	src, err := NewCSVSourceWithOptions(reader, opts)
+	if err != nil {
+		return nil, err
+	}
+	return collectTable(src, "CSV")
+}
+
+// collectTable reads every recipient of src, dropping duplicate addresses
+// (case-insensitive) so no one is sent the same email twice.
+func collectTable(src CountingSource, kind string) ([]Recipient, error) {
+	dedupe := DedupeSource(src, NewMemoryDeduper())
+	recipients, err := Collect(dedupe)
+	if err != nil {
+		return nil, err
 	}
-	recipients = deduped
 
-	if skippedCount > 0 || dupCount > 0 {
-		log.Printf("CSV parsing: %d recipients loaded, %d rows skipped, %d duplicates removed (total rows: %d)",
-			len(recipients), skippedCount, dupCount, totalRows)
+	if src.Skipped() > 0 || dedupe.Duplicates() > 0 {
+		log.Printf("%s parsing: %d recipients loaded, %d rows skipped, %d duplicates removed (total rows: %d)",
+			kind, len(recipients), src.Skipped(), dedupe.Duplicates(), src.Rows())
 	}
 
 	return recipients, nil
@@ -0,0 +1,201 @@
+package parser
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// Deduper remembers the addresses a stream has produced.
+type Deduper interface {
+	// Seen records key and reports whether it had been recorded before.
+	Seen(key string) (bool, error)
+	Close() error
+}
+
+// memoryDeduper keeps every key in a map. Exact, but memory grows with the
+// list; use it for lists known to be small.
+type memoryDeduper map[string]struct{}
+
+// NewMemoryDeduper returns an exact in-memory Deduper.
+func NewMemoryDeduper() Deduper { return memoryDeduper{} }
+
+func (m memoryDeduper) Seen(key string) (bool, error) {
+	if _, ok := m[key]; ok {
+		return true, nil
+	}
+	m[key] = struct{}{}
+	return false, nil
+}
+
+func (m memoryDeduper) Close() error { return nil }
+
+const (
+	// bloomBits sizes the in-memory filter (8 MiB). At 5M addresses about
+	// 0.5% of lookups fall through to disk.
+	bloomBits   = 1 << 26
+	bloomHashes = 4
+	// diskBatch is how many new keys are buffered before they are written.
+	diskBatch = 10000
+)
+
+var dedupeBucket = []byte("seen")
+
+// DiskDeduper is an exact Deduper in bounded memory. A bloom filter answers
+// "never seen" for almost every new address; only its positives are checked
+// against the full key set, which lives in a temporary BoltDB file.
+type DiskDeduper struct {
+	bloom   []uint64
+	db      *bbolt.DB
+	path    string
+	pending map[string]struct{} // keys not yet written to db
+}
+
+// NewDiskDeduper creates a DiskDeduper whose key file lives in dir (the
+// system temp directory when empty). Close removes the file.
+func NewDiskDeduper(dir string) (*DiskDeduper, error) {
+	f, err := os.CreateTemp(dir, "mailgrid-dedupe-*.db")
+	if err != nil {
+		return nil, fmt.Errorf("create dedupe file: %w", err)
+	}
+	path := f.Name()
+	f.Close()
+
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second, NoSync: true, NoFreelistSync: true})
+	if err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("open dedupe file: %w", err)
+	}
+	if err := db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(dedupeBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		os.Remove(path)
+		return nil, fmt.Errorf("init dedupe file: %w", err)
+	}
+	return &DiskDeduper{
+		bloom:   make([]uint64, bloomBits/64),
+		db:      db,
+		path:    path,
+		pending: make(map[string]struct{}, diskBatch),
+	}, nil
+}
+
+// Seen records key and reports whether it had been recorded before.
+func (d *DiskDeduper) Seen(key string) (bool, error) {
+	if d.bloomAdd(key) {
+		// Possibly seen: confirm against the exact set.
+		if _, ok := d.pending[key]; ok {
+			return true, nil
+		}
+		var found bool
+		if err := d.db.View(func(tx *bbolt.Tx) error {
+			found = tx.Bucket(dedupeBucket).Get([]byte(key)) != nil
+			return nil
+		}); err != nil {
+			return false, err
+		}
+		if found {
+			return true, nil
+		}
+	}
+	d.pending[key] = struct{}{}
+	if len(d.pending) >= diskBatch {
+		return false, d.flush()
+	}
+	return false, nil
+}
+
+// bloomAdd sets key's bits and reports whether they were all set already.
+func (d *DiskDeduper) bloomAdd(key string) bool {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	present := true
+	for i := uint64(0); i < bloomHashes; i++ {
+		bit := (h1 + i*h2) % bloomBits
+		word, mask := bit/64, uint64(1)<<(bit%64)
+		if d.bloom[word]&mask == 0 {
+			present = false
+			d.bloom[word] |= mask
+		}
+	}
+	return present
+}
+
+func (d *DiskDeduper) flush() error {
+	if len(d.pending) == 0 {
+		return nil
+	}
+	err := d.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(dedupeBucket)
+		for key := range d.pending {
+			if err := b.Put([]byte(key), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("write dedupe file: %w", err)
+	}
+	d.pending = make(map[string]struct{}, diskBatch)
+	return nil
+}
+
+// Close deletes the key file.
+func (d *DiskDeduper) Close() error {
+	err := d.db.Close()
+	if rmErr := os.Remove(d.path); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// DedupedSource drops recipients whose address (compared case-insensitively)
+// an earlier row already had.
+type DedupedSource struct {
+	src        RecipientSource
+	seen       Deduper
+	duplicates int
+}
+
+// DedupeSource wraps src so each address is yielded once. Closing the
+// returned source closes both src and seen.
+func DedupeSource(src RecipientSource, seen Deduper) *DedupedSource {
+	return &DedupedSource{src: src, seen: seen}
+}
+
+func (d *DedupedSource) Next() (Recipient, error) {
+	for {
+		r, err := d.src.Next()
+		if err != nil {
+			return r, err
+		}
+		dup, err := d.seen.Seen(strings.ToLower(r.Email))
+		if err != nil {
+			return Recipient{}, err
+		}
+		if !dup {
+			return r, nil
+		}
+		d.duplicates++
+	}
+}
+
+// Duplicates returns how many rows were dropped so far.
+func (d *DedupedSource) Duplicates() int { return d.duplicates }
+
+func (d *DedupedSource) Close() error {
+	err := d.src.Close()
+	if serr := d.seen.Close(); err == nil {
+		err = serr
+	}
+	return err
+}
@@ -0,0 +1,313 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// NoQuote as ReadOptions.Quote reads quote characters as ordinary text.
+const NoQuote rune = -1
+
+// ReadOptions describes how a recipient file is read. The zero value reads
+// comma-separated, double-quoted UTF-8 with a column named 'email', and the
+// first sheet of a spreadsheet.
+type ReadOptions struct {
+	// Sheet selects the worksheet of an .xlsx/.ods file by name or 1-based
+	// index.
+	Sheet string
+
+	// Delimiter separates CSV fields; 0 means ','.
+	Delimiter rune
+	// Quote encloses CSV fields containing delimiters or line breaks; 0
+	// means '"'. It must be an ASCII character or NoQuote.
+	Quote rune
+	// Encoding is the character set of CSV and JSON input: utf-8 (the
+	// default), latin1, windows-1252, iso-8859-15, utf-16, utf-16le or
+	// utf-16be. A byte order mark is stripped whatever the encoding, and
+	// a UTF-16 one switches to UTF-16 unless another encoding is set.
+	Encoding string
+
+	// EmailColumn names the column holding addresses, when it is not
+	// 'email'. It is matched case-insensitively.
+	EmailColumn string
+	// Rename maps column names, matched case-insensitively, to the field
+	// names templates and filters use.
+	Rename map[string]string
+}
+
+// Validate reports options that can never be satisfied.
+func (o ReadOptions) Validate() error {
+	if o.Delimiter == '\r' || o.Delimiter == '\n' || o.Delimiter == utf8.RuneError || o.Delimiter < 0 {
+		return fmt.Errorf("invalid delimiter %q", o.Delimiter)
+	}
+	if o.Quote != 0 && o.Quote != NoQuote && (o.Quote > utf8.RuneSelf-1 || o.Quote == '\r' || o.Quote == '\n') {
+		return fmt.Errorf("invalid quote character %q: must be ASCII", o.Quote)
+	}
+	if quote := o.Quote; quote != NoQuote && (quote == o.delimiter() || quote == 0 && o.delimiter() == '"') {
+		return fmt.Errorf("quote and delimiter must differ")
+	}
+	if _, err := charsetDecoder(o.Encoding); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (o ReadOptions) delimiter() rune {
+	if o.Delimiter == 0 {
+		return ','
+	}
+	return o.Delimiter
+}
+
+// columnMap returns the lowercased rename table, with EmailColumn mapped to
+// 'email'.
+func (o ReadOptions) columnMap() map[string]string {
+	if len(o.Rename) == 0 && o.EmailColumn == "" {
+		return nil
+	}
+	m := make(map[string]string, len(o.Rename)+1)
+	for from, to := range o.Rename {
+		m[normalizeColumn(from)] = normalizeColumn(to)
+	}
+	if o.EmailColumn != "" {
+		m[normalizeColumn(o.EmailColumn)] = "email"
+	}
+	return m
+}
+
+// mapHeaders normalizes headers in place and applies the column mapping.
+// Every mapped column must be present, so a typo fails the run instead of
+// silently leaving a field empty.
+func (o ReadOptions) mapHeaders(headers []string) error {
+	for i, h := range headers {
+		headers[i] = normalizeColumn(h)
+	}
+	m := o.columnMap()
+	if m == nil {
+		return nil
+	}
+	found := make(map[string]bool, len(m))
+	for i, h := range headers {
+		if to, ok := m[h]; ok {
+			headers[i] = to
+			found[h] = true
+		}
+	}
+	for from := range m {
+		if !found[from] {
+			return fmt.Errorf("column %q not found (columns: %s)", from, strings.Join(headers, ", "))
+		}
+	}
+	return nil
+}
+
+// renameKey applies the column mapping to one already-normalized key.
+func renameKey(m map[string]string, key string) string {
+	if to, ok := m[key]; ok {
+		return to
+	}
+	return key
+}
+
+func normalizeColumn(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// decodeInput wraps r so it yields UTF-8 without a byte order mark.
+func decodeInput(r io.Reader, encoding string) (*bufio.Reader, error) {
+	decode, err := charsetDecoder(encoding)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(r)
+	bom, _ := br.Peek(3)
+	switch {
+	case len(bom) >= 3 && string(bom[:3]) == "\xEF\xBB\xBF":
+		br.Discard(3)
+	case len(bom) >= 2 && bom[0] == 0xFF && bom[1] == 0xFE:
+		if decode == nil || isUTF16(encoding) {
+			br.Discard(2)
+			decode = utf16Decoder(false)
+		}
+	case len(bom) >= 2 && bom[0] == 0xFE && bom[1] == 0xFF:
+		if decode == nil || isUTF16(encoding) {
+			br.Discard(2)
+			decode = utf16Decoder(true)
+		}
+	}
+	if decode == nil {
+		return br, nil
+	}
+	return bufio.NewReader(&runeDecoder{src: br, next: decode}), nil
+}
+
+func isUTF16(encoding string) bool {
+	return strings.HasPrefix(normalizeCharset(encoding), "utf16")
+}
+
+func normalizeCharset(encoding string) string {
+	return strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(encoding))
+}
+
+// charsetDecoder returns the rune reader for encoding, or nil for UTF-8.
+func charsetDecoder(encoding string) (func(*bufio.Reader) (rune, error), error) {
+	switch normalizeCharset(encoding) {
+	case "", "utf8":
+		return nil, nil
+	case "latin1", "iso88591", "l1":
+		return singleByteDecoder(nil), nil
+	case "windows1252", "cp1252":
+		return singleByteDecoder(&cp1252), nil
+	case "iso885915", "latin9":
+		return singleByteDecoder(&iso885915), nil
+	case "utf16", "utf16le":
+		return utf16Decoder(false), nil
+	case "utf16be":
+		return utf16Decoder(true), nil
+	}
+	return nil, fmt.Errorf("unsupported encoding %q (use utf-8, latin1, windows-1252, iso-8859-15, utf-16, utf-16le or utf-16be)", encoding)
+}
+
+// singleByteDecoder reads an ISO-8859-1 superset; high, when set, replaces
+// the characters 0x80-0xBF.
+func singleByteDecoder(high *[64]rune) func(*bufio.Reader) (rune, error) {
+	return func(br *bufio.Reader) (rune, error) {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if high != nil && b >= 0x80 && b < 0xC0 {
+			return high[b-0x80], nil
+		}
+		return rune(b), nil
+	}
+}
+
+func utf16Decoder(bigEndian bool) func(*bufio.Reader) (rune, error) {
+	unit := func(br *bufio.Reader) (uint16, error) {
+		var b [2]byte
+		if _, err := io.ReadFull(br, b[:]); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				return utf8.RuneError, nil
+			}
+			return 0, err
+		}
+		if bigEndian {
+			return uint16(b[0])<<8 | uint16(b[1]), nil
+		}
+		return uint16(b[1])<<8 | uint16(b[0]), nil
+	}
+	return func(br *bufio.Reader) (rune, error) {
+		u, err := unit(br)
+		if err != nil {
+			return 0, err
+		}
+		if !utf16.IsSurrogate(rune(u)) {
+			return rune(u), nil
+		}
+		u2, err := unit(br)
+		if err != nil {
+			return utf8.RuneError, nil
+		}
+		return utf16.DecodeRune(rune(u), rune(u2)), nil
+	}
+}
+
+// runeDecoder re-encodes the runes next reads from src as UTF-8.
+type runeDecoder struct {
+	src     *bufio.Reader
+	next    func(*bufio.Reader) (rune, error)
+	pending []byte
+	err     error
+}
+
+func (d *runeDecoder) Read(p []byte) (int, error) {
+	for len(d.pending) < len(p) && d.err == nil {
+		r, err := d.next(d.src)
+		if err != nil {
+			d.err = err
+			break
+		}
+		d.pending = utf8.AppendRune(d.pending, r)
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	if n == 0 && d.err != nil {
+		return 0, d.err
+	}
+	return n, nil
+}
+
+// quoteSwapReader exchanges the bytes q and '"' so encoding/csv, which
+// only knows '"', parses fields quoted with q. quoteSwapRows swaps them back
+// in the parsed values.
+type quoteSwapReader struct {
+	r io.Reader
+	q byte
+}
+
+func (s quoteSwapReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	for i := 0; i < n; i++ {
+		p[i] = swapQuote(p[i], s.q)
+	}
+	return n, err
+}
+
+type quoteSwapRows struct {
+	rows rowReader
+	q    byte
+}
+
+func (s quoteSwapRows) Read() ([]string, error) {
+	record, err := s.rows.Read()
+	for i, f := range record {
+		if strings.IndexByte(f, s.q) >= 0 || strings.IndexByte(f, '"') >= 0 {
+			b := []byte(f)
+			for j := range b {
+				b[j] = swapQuote(b[j], s.q)
+			}
+			record[i] = string(b)
+		}
+	}
+	return record, err
+}
+
+func (s quoteSwapRows) FieldPos(field int) (int, int) {
+	if pr, ok := s.rows.(positionReader); ok {
+		return pr.FieldPos(field)
+	}
+	return 0, 0
+}
+
+func swapQuote(b, q byte) byte {
+	switch b {
+	case q:
+		return '"'
+	case '"':
+		return q
+	}
+	return b
+}
+
+// cp1252 holds Windows-1252 characters 0x80-0xBF; undefined ones keep their
+// Latin-1 code point.
+var cp1252 = [64]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021, 0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014, 0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+	0x00A0, 0x00A1, 0x00A2, 0x00A3, 0x00A4, 0x00A5, 0x00A6, 0x00A7, 0x00A8, 0x00A9, 0x00AA, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x00AF,
+	0x00B0, 0x00B1, 0x00B2, 0x00B3, 0x00B4, 0x00B5, 0x00B6, 0x00B7, 0x00B8, 0x00B9, 0x00BA, 0x00BB, 0x00BC, 0x00BD, 0x00BE, 0x00BF,
+}
+
+// iso885915 holds ISO-8859-15 characters 0x80-0xBF, which differ from
+// Latin-1 in eight places (the euro sign among them).
+var iso885915 = [64]rune{
+	0x0080, 0x0081, 0x0082, 0x0083, 0x0084, 0x0085, 0x0086, 0x0087, 0x0088, 0x0089, 0x008A, 0x008B, 0x008C, 0x008D, 0x008E, 0x008F,
+	0x0090, 0x0091, 0x0092, 0x0093, 0x0094, 0x0095, 0x0096, 0x0097, 0x0098, 0x0099, 0x009A, 0x009B, 0x009C, 0x009D, 0x009E, 0x009F,
+	0x00A0, 0x00A1, 0x00A2, 0x00A3, 0x20AC, 0x00A5, 0x0160, 0x00A7, 0x0161, 0x00A9, 0x00AA, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x00AF,
+	0x00B0, 0x00B1, 0x00B2, 0x00B3, 0x017D, 0x00B5, 0x00B6, 0x00B7, 0x017E, 0x00B9, 0x00BA, 0x00BB, 0x0152, 0x0153, 0x0178, 0x00BF,
+}
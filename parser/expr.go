@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"log"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	exprparser "github.com/expr-lang/expr/parser"
 	"github.com/expr-lang/expr/vm"
 )
 
@@ -19,17 +22,33 @@ type Expression interface {
 // compiledExpr wraps a compiled expr program for evaluation.
 type compiledExpr struct {
 	program *vm.Program
+	types   map[string]ColumnType // nil: every value is a string
+	raw     bool                  // pass the original text of each value too
+	fields  []string              // the columns the expression reads
 }
 
 // Evaluate runs the compiled expression against the provided data.
 func (c *compiledExpr) Evaluate(data map[string]string) bool {
-	// Convert all string values to lowercase for case-insensitive comparison
-	lowerData := make(map[string]string, len(data))
-	for k, v := range data {
-		lowerData[k] = strings.ToLower(v)
+	var result any
+	var err error
+	if c.types == nil {
+		// Convert all string values to lowercase for case-insensitive comparison
+		lowerData := make(map[string]string, len(data))
+		for k, v := range data {
+			lowerData[k] = strings.ToLower(v)
+			if c.raw {
+				lowerData[rawPrefix+k] = v
+			}
+		}
+		result, err = expr.Run(c.program, lowerData)
+	} else {
+		env, incomplete := c.typedEnv(data)
+		result, err = expr.Run(c.program, env)
+		if err != nil && incomplete {
+			// A blank or unparsable typed value matches no comparison.
+			return false
+		}
 	}
-
-	result, err := expr.Run(c.program, lowerData)
 	if err != nil {
 		log.Printf("Expression evaluation error: %v", err)
 		return false
@@ -48,112 +67,245 @@ func (c *compiledExpr) Evaluate(data map[string]string) bool {
 	return false
 }
 
-// Package-level compiled regexes — compiled once at init time, not per ParseExpression call.
-var (
-	reContainsFn   = regexp.MustCompile(`contains\s*\(\s*(\w+)\s*,\s*("[^"]*")\s*\)`)
-	reStartsWithFn = regexp.MustCompile(`startsWith\s*\(\s*(\w+)\s*,\s*("[^"]*")\s*\)`)
-	reEndsWithFn   = regexp.MustCompile(`endsWith\s*\(\s*(\w+)\s*,\s*("[^"]*")\s*\)`)
-	reOperator     = regexp.MustCompile(`(\w+)\s+(contains|startsWith|endsWith)\s+("[^"]*")`)
-	reEquality     = regexp.MustCompile(`(\w+)\s*(==|!=)\s*("[^"]*")`)
-)
-
-// lowerQuoted lowercases the content of a double-quoted string literal.
-func lowerQuoted(s string) string {
-	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
-		return `"` + strings.ToLower(s[1:len(s)-1]) + `"`
+// typedEnv converts data to the declared column types. Strings are
+// lower-cased as in untyped expressions. A blank or unparsable bool is
+// false; other typed values that are missing, blank or do not parse are
+// nil, and incomplete is set.
+func (c *compiledExpr) typedEnv(data map[string]string) (env map[string]any, incomplete bool) {
+	env = make(map[string]any, len(data)+len(c.types))
+	for k, t := range c.types {
+		if t != TypeString {
+			env[k] = t.zero()
+		}
+	}
+	for k, v := range data {
+		if c.raw {
+			env[rawPrefix+k] = v
+		}
+		t, ok := c.types[k]
+		if !ok || t == TypeString {
+			env[k] = strings.ToLower(v)
+			continue
+		}
+		val, err := t.Convert(v)
+		if err != nil && t != TypeBool {
+			incomplete = true
+			val = nil
+		} else if err != nil {
+			val = false
+		}
+		env[k] = val
 	}
-	return s
+	for k, t := range c.types {
+		if _, ok := data[k]; !ok && t != TypeString && t != TypeBool {
+			env[k] = nil
+			incomplete = true
+		}
+	}
+	return env, incomplete
 }
 
-// transformExpression converts our simplified syntax to expr-compatible syntax.
-// It transforms:
-//   - contains(field, "value") -> field contains "value" (with lowercase value)
-//   - startsWith(field, "value") -> field startsWith "value" (with lowercase value)
-//   - endsWith(field, "value") -> field endsWith "value" (with lowercase value)
-//   - field == "Value" -> field == "value" (lowercase comparison value)
-func transformExpression(input string) string {
-	input = reContainsFn.ReplaceAllStringFunc(input, func(match string) string {
-		parts := reContainsFn.FindStringSubmatch(match)
-		if len(parts) == 3 {
-			return parts[1] + ` contains ` + lowerQuoted(parts[2])
-		}
-		return match
-	})
+// nilSafeComparisons rewrites a < b, and the other ordering comparisons,
+// to a != nil && b != nil && a < b. A blank typed value then fails the
+// comparison it appears in instead of the whole expression.
+type nilSafeComparisons struct{}
 
-	input = reStartsWithFn.ReplaceAllStringFunc(input, func(match string) string {
-		parts := reStartsWithFn.FindStringSubmatch(match)
-		if len(parts) == 3 {
-			return parts[1] + ` startsWith ` + lowerQuoted(parts[2])
-		}
-		return match
+func (nilSafeComparisons) Visit(node *ast.Node) {
+	n, ok := (*node).(*ast.BinaryNode)
+	if !ok {
+		return
+	}
+	switch n.Operator {
+	case "<", "<=", ">", ">=":
+	default:
+		return
+	}
+	notNil := func(side ast.Node) ast.Node {
+		return &ast.BinaryNode{Operator: "!=", Left: side, Right: &ast.NilNode{}}
+	}
+	ast.Patch(node, &ast.BinaryNode{
+		Operator: "&&",
+		Left:     &ast.BinaryNode{Operator: "&&", Left: notNil(n.Left), Right: notNil(n.Right)},
+		Right:    n,
 	})
+}
 
-	input = reEndsWithFn.ReplaceAllStringFunc(input, func(match string) string {
-		parts := reEndsWithFn.FindStringSubmatch(match)
-		if len(parts) == 3 {
-			return parts[1] + ` endsWith ` + lowerQuoted(parts[2])
+// rewriteSource prepares an expression for expr's parser. Dotted field
+// names such as address.city, which flattened JSON input produces, become
+// $env["address.city"] so expr looks the whole key up instead of treating
+// it as member access. Calls to functions that are also operators, such as
+// contains(name, "x"), become $contains(name, "x"), which filterPatch turns
+// back into the operator. String literals are left alone.
+func rewriteSource(input string) string {
+	var b strings.Builder
+	for i := 0; i < len(input); {
+		c := input[i]
+		if c == '"' || c == '\'' || c == '`' {
+			end := i + 1
+			for end < len(input) && input[end] != c {
+				if input[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			if end < len(input) {
+				end++
+			}
+			b.WriteString(input[i:end])
+			i = end
+			continue
 		}
-		return match
-	})
-
-	input = reOperator.ReplaceAllStringFunc(input, func(match string) string {
-		parts := reOperator.FindStringSubmatch(match)
-		if len(parts) == 4 {
-			return parts[1] + ` ` + parts[2] + ` ` + lowerQuoted(parts[3])
+		if !isIdentStart(c) || (i > 0 && (isIdentPart(input[i-1]) || input[i-1] == '.' || input[i-1] == '$')) {
+			b.WriteByte(c)
+			i++
+			continue
 		}
-		return match
-	})
-
-	input = reEquality.ReplaceAllStringFunc(input, func(match string) string {
-		parts := reEquality.FindStringSubmatch(match)
-		if len(parts) == 4 {
-			return parts[1] + ` ` + parts[2] + ` ` + lowerQuoted(parts[3])
+		end := i
+		dotted := false
+		for end < len(input) {
+			if isIdentPart(input[end]) {
+				end++
+			} else if input[end] == '.' && end+1 < len(input) && isIdentPart(input[end+1]) {
+				dotted = true
+				end++
+			} else {
+				break
+			}
 		}
-		return match
-	})
+		word := input[i:end]
+		switch {
+		case dotted:
+			b.WriteString(`$env["` + word + `"]`)
+		case filterTable[word].operator && isCall(input[end:]) && startsOperand(b.String()):
+			b.WriteString("$" + word)
+		default:
+			b.WriteString(word)
+		}
+		i = end
+	}
+	return b.String()
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
 
-	return input
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// isCall reports whether rest, the text after a name, opens its argument
+// list.
+func isCall(rest string) bool {
+	return strings.HasPrefix(strings.TrimLeft(rest, " \t\r\n"), "(")
+}
+
+// startsOperand reports whether a name following before, the expression so
+// far, starts an operand, so that "contains (" is a call there and not the
+// operator.
+func startsOperand(before string) bool {
+	before = strings.TrimRight(before, " \t\r\n")
+	if before == "" || strings.ContainsRune("(,[!&|?:", rune(before[len(before)-1])) {
+		return true
+	}
+	end := len(before)
+	start := end
+	for start > 0 && isIdentPart(before[start-1]) {
+		start--
+	}
+	switch before[start:end] {
+	case "and", "or", "not":
+		return true
+	}
+	return false
 }
 
 // ParseExpression compiles a filter expression string into an evaluable Expression.
 //
+// Every field is a string, so comparisons are textual; ParseTypedExpression
+// compares numbers, booleans and dates.
+//
 // Supported syntax:
 //   - Comparison: ==, !=, <, <=, >, >=
 //   - String contains: contains(field, "value") or field contains "value"
 //   - String prefix: startsWith(field, "value") or field startsWith "value"
 //   - String suffix: endsWith(field, "value") or field endsWith "value"
+//   - Regular expressions: matches(field, "^a.*z$") or field matches "^a.*z$"
+//   - Lists: field in ["a", "b"], or inFile(field, "list.txt") with one value per line
+//   - Address domain: domain(email) == "acme.com"
+//   - Blank values: isEmpty(field)
+//   - Case-sensitive tests: equalsCase, containsCase, startsWithCase,
+//     endsWithCase, matchesCase and inCase
 //   - Logical operators: &&, ||, !
 //   - Parentheses for grouping: (a == b && c == d)
 //
-// All string comparisons are case-insensitive.
+// Other string comparisons are case-insensitive. The functions are listed in
+// filterTable.
 //
 // Example expressions:
 //   - name == "John"
-//   - company != "Acme" && salary > 50000
+//   - company != "Acme" && salary > "50000"
 //   - contains(location, "York") || startsWith(email, "admin")
 //   - location contains "York" || email startsWith "admin"
+//   - domain(email) in ["acme.com", "beta.io"] && !isEmpty(name)
 func ParseExpression(input string) (Expression, error) {
-	// Clean up the input
+	return compile(input, map[string]string{}, nil)
+}
+
+// compile compiles input against env, the type expr checks names against,
+// and types, which Evaluate converts values to.
+func compile(input string, env any, types map[string]ColumnType) (Expression, error) {
 	input = strings.TrimSpace(input)
 	if input == "" {
 		return nil, fmt.Errorf("empty expression")
 	}
+	input = rewriteSource(input)
 
-	// Transform function-style calls to operator-style and lowercase values
-	input = transformExpression(input)
-
-	// Configure expr with options
-	options := []expr.Option{
-		expr.Env(map[string]string{}),
+	patch := &filterPatch{}
+	options := append([]expr.Option{
+		expr.Env(env),
 		expr.AllowUndefinedVariables(),
+		expr.Patch(patch),
+	}, filterOptions()...)
+	if types != nil {
+		options = append(options, expr.Patch(nilSafeComparisons{}))
 	}
 
 	program, err := expr.Compile(input, options...)
+	if err == nil {
+		err = patch.err
+	}
 	if err != nil {
+		if desc := describeTypes(types); desc != "" {
+			return nil, fmt.Errorf("failed to compile expression (column types: %s): %w", desc, err)
+		}
 		return nil, fmt.Errorf("failed to compile expression: %w", err)
 	}
+	for _, path := range patch.files {
+		if err := loadValueFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return &compiledExpr{program: program, types: types, raw: patch.raw, fields: referencedFields(program.Node())}, nil
+}
 
-	return &compiledExpr{program: program}, nil
+// ParseTypedExpression is ParseExpression for columns of known types: int
+// and float columns compare as numbers, bool columns are booleans and date
+// columns are time.Time values, which daysSince(column) and comparisons
+// with date("2024-01-31") accept. Comparing a column with a value of the
+// wrong type, such as age > "30", is a compile error. Columns missing from
+// types are strings. A blank or unparsable value matches no comparison it
+// appears in; a blank bool is false.
+func ParseTypedExpression(input string, types map[string]ColumnType) (Expression, error) {
+	env := make(map[string]any, len(types)+1)
+	env["email"] = ""
+	for col, t := range types {
+		env[col] = t.zero()
+	}
+	if types == nil {
+		types = map[string]ColumnType{}
+	}
+	return compile(input, env, types)
 }
 
 // MustParseExpression is like ParseExpression but panics on error. Useful for testing.
@@ -165,40 +317,204 @@ func MustParseExpression(input string) Expression {
 	return expr
 }
 
-// ValidateFields ensures all fields used in the expression exist in the CSV data.
-// It extracts field names from the expression and checks them against the first recipient.
-// Fields like "email" (injected at filter time) are also accepted.
-// Note: This is informational only - the expr library handles undefined
-// variables gracefully by returning false.
+// ValidateFields reports the fields exp uses that no recipient has, so a
+// misspelt column, which would otherwise match nobody, is caught before
+// sending. The columns are those of every recipient given; "email" is
+// always available.
 func ValidateFields(exp Expression, recipients []Recipient) error {
 	if len(recipients) == 0 {
 		return fmt.Errorf("no recipients to validate fields")
 	}
-	return nil
+	var columns []string
+	seen := make(map[string]bool)
+	for _, r := range recipients {
+		for k := range r.Data {
+			if k = strings.ToLower(k); !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+	}
+	return CheckFields(exp, columns)
 }
 
-// ExtractFieldNames extracts potential field names from an expression string.
-// This is a best-effort extraction for informational purposes.
-func ExtractFieldNames(exprStr string) []string {
-	// Pattern to match identifiers (field names) that aren't string literals
-	// This is a simple heuristic - field names are typically lowercase identifiers
-	re := regexp.MustCompile(`\b[a-z_][a-z0-9_]*\b`)
-	matches := re.FindAllString(exprStr, -1)
+// CheckFields reports the fields exp uses that are not among columns,
+// suggesting the closest column for each.
+func CheckFields(exp Expression, columns []string) error {
+	c, ok := exp.(*compiledExpr)
+	if !ok {
+		return nil
+	}
+	known := map[string]bool{"email": true}
+	for _, col := range columns {
+		known[strings.ToLower(col)] = true
+	}
+	var unknown []string
+	for _, f := range c.fields {
+		if known[f] {
+			continue
+		}
+		if near := closestName(f, columns); near != "" {
+			f = fmt.Sprintf("%s (did you mean %s?)", f, near)
+		}
+		unknown = append(unknown, f)
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sorted := append([]string(nil), columns...)
+	sort.Strings(sorted)
+	return fmt.Errorf("unknown field(s) in filter: %s; the columns are: %s",
+		strings.Join(unknown, ", "), strings.Join(sorted, ", "))
+}
 
-	// Filter out keywords and functions
-	keywords := map[string]bool{
-		"contains": true, "startsWith": true, "endsWith": true,
-		"and": true, "or": true, "not": true, "true": true, "false": true,
+// closestName returns the candidate within a small edit distance of name,
+// or "" when none is close.
+func closestName(name string, candidates []string) string {
+	best, bestDist := "", len(name)/3+1
+	if bestDist < 2 {
+		bestDist = 2
 	}
+	for _, c := range candidates {
+		c = strings.ToLower(c)
+		if d := editDistance(name, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if d := prev[j] + 1; d < cur[j] {
+				cur[j] = d
+			}
+			if d := cur[j-1] + 1; d < cur[j] {
+				cur[j] = d
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// ExtractFieldNames lists the fields an expression string uses, in order of
+// first use. It returns nil when the expression does not parse.
+func ExtractFieldNames(exprStr string) []string {
+	tree, err := exprparser.Parse(rewriteSource(exprStr))
+	if err != nil {
+		return nil
+	}
+	return referencedFields(tree.Node)
+}
 
+// referencedFields lists the columns node reads: names that are not
+// functions or variables the expression declares, and the keys of $env
+// lookups, in order of first use.
+func referencedFields(node ast.Node) []string {
+	v := &fieldCollector{callees: make(map[*ast.IdentifierNode]bool), declared: make(map[string]bool)}
+	ast.Walk(&node, v)
 	var fields []string
 	seen := make(map[string]bool)
-	for _, match := range matches {
-		if !keywords[match] && !seen[match] {
-			fields = append(fields, match)
-			seen[match] = true
+	for _, n := range v.names {
+		if id, ok := n.(*ast.IdentifierNode); ok {
+			if v.callees[id] || strings.HasPrefix(id.Value, "$") {
+				continue
+			}
+			n = id.Value
+		}
+		name := strings.TrimPrefix(n.(string), rawPrefix)
+		if !seen[name] && !v.declared[name] {
+			seen[name] = true
+			fields = append(fields, name)
 		}
 	}
-
 	return fields
 }
+
+// fieldCollector gathers names for referencedFields. Walk visits children
+// first, so an identifier is only known to name a function once its call
+// has been visited; names holds identifiers and $env keys until then.
+type fieldCollector struct {
+	names    []any
+	callees  map[*ast.IdentifierNode]bool
+	declared map[string]bool
+}
+
+func (v *fieldCollector) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		v.names = append(v.names, n)
+	case *ast.CallNode:
+		if id, ok := n.Callee.(*ast.IdentifierNode); ok {
+			v.callees[id] = true
+		}
+	case *ast.VariableDeclaratorNode:
+		v.declared[n.Name] = true
+	case *ast.MemberNode:
+		if id, ok := n.Node.(*ast.IdentifierNode); ok && id.Value == "$env" {
+			if key, ok := n.Property.(*ast.StringNode); ok {
+				v.names = append(v.names, key.Value)
+			}
+		}
+	}
+}
+
+// SplitClauses splits an expression joined by && (or "and") at the top
+// level into its clauses, written as a user would write them. Any other
+// expression is one clause.
+func SplitClauses(input string) ([]string, error) {
+	tree, err := exprparser.Parse(rewriteSource(strings.TrimSpace(input)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression: %w", err)
+	}
+	var clauses []string
+	var split func(n ast.Node)
+	split = func(n ast.Node) {
+		if b, ok := n.(*ast.BinaryNode); ok && (b.Operator == "&&" || b.Operator == "and") {
+			split(b.Left)
+			split(b.Right)
+			return
+		}
+		clauses = append(clauses, unrewrite(n.String()))
+	}
+	split(tree.Node)
+	return clauses, nil
+}
+
+var (
+	reEnvField    = regexp.MustCompile(`\$env\["([^"\\]*)"\]`)
+	reOperatorFun = regexp.MustCompile(`\$(` + operatorNames() + `)\(`)
+)
+
+// operatorNames returns the alternation of the operators in filterTable,
+// which rewriteSource renames to $name when they are called.
+func operatorNames() string {
+	var names []string
+	for name, f := range filterTable {
+		if f.operator {
+			names = append(names, regexp.QuoteMeta(name))
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, "|")
+}
+
+// unrewrite undoes rewriteSource for display.
+func unrewrite(s string) string {
+	s = reEnvField.ReplaceAllString(s, "$1")
+	return reOperatorFun.ReplaceAllString(s, "$1(")
+}
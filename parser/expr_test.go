@@ -159,6 +159,12 @@ func TestEvaluate(t *testing.T) {
 			data:     map[string]string{"name": "John"},
 			expected: true,
 		},
+		{
+			name:     "dotted field from JSON input",
+			input:    `address.city == "Paris" && address.zip startsWith "75"`,
+			data:     map[string]string{"address.city": "PARIS", "address.zip": "75001"},
+			expected: true,
+		},
 		{
 			name:     "simple equality no match",
 			input:    `name == "John"`,
@@ -278,7 +284,7 @@ func TestEvaluate(t *testing.T) {
 	}
 }
 
-func TestTransformExpression(t *testing.T) {
+func TestRewriteSource(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
@@ -287,45 +293,45 @@ func TestTransformExpression(t *testing.T) {
 		{
 			name:     "contains function",
 			input:    `contains(field, "Value")`,
-			expected: `field contains "value"`,
+			expected: `$contains(field, "Value")`,
 		},
 		{
 			name:     "startsWith function",
-			input:    `startsWith(field, "Value")`,
-			expected: `field startsWith "value"`,
+			input:    `!startsWith (field, "Value")`,
+			expected: `!$startsWith (field, "Value")`,
 		},
 		{
-			name:     "endsWith function",
-			input:    `endsWith(field, "Value")`,
-			expected: `field endsWith "value"`,
+			name:     "matches function after and",
+			input:    `a and matches(field, "^x")`,
+			expected: `a and $matches(field, "^x")`,
 		},
 		{
-			name:     "equality comparison",
-			input:    `field == "Value"`,
-			expected: `field == "value"`,
+			name:     "operators are left alone",
+			input:    `field contains ("Value") && field endsWith "x"`,
+			expected: `field contains ("Value") && field endsWith "x"`,
 		},
 		{
-			name:     "not equal comparison",
-			input:    `field != "Value"`,
-			expected: `field != "value"`,
+			name:     "other functions are left alone",
+			input:    `domain(email) == "x" && isEmpty(name)`,
+			expected: `domain(email) == "x" && isEmpty(name)`,
 		},
 		{
-			name:     "contains operator",
-			input:    `field contains "Value"`,
-			expected: `field contains "value"`,
+			name:     "names in string literals are left alone",
+			input:    `note == "contains(x)"`,
+			expected: `note == "contains(x)"`,
 		},
 		{
-			name:     "multiple transformations",
-			input:    `name == "John" && contains(email, "GMAIL")`,
-			expected: `name == "john" && email contains "gmail"`,
+			name:     "dotted field",
+			input:    `address.city == "Paris" && contains(tags.0, "VIP") && email == "a.b@X.com"`,
+			expected: `$env["address.city"] == "Paris" && $contains($env["tags.0"], "VIP") && email == "a.b@X.com"`,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := transformExpression(tt.input)
+			result := rewriteSource(tt.input)
 			if result != tt.expected {
-				t.Errorf("transformExpression(%q) = %q, expected %q", tt.input, result, tt.expected)
+				t.Errorf("rewriteSource(%q) = %q, expected %q", tt.input, result, tt.expected)
 			}
 		})
 	}
@@ -18,13 +18,23 @@ func Filter(recipients []Recipient, exp Expression) []Recipient {
 	}
 
 	var out []Recipient
+	match := Matcher(exp)
+	for _, r := range recipients {
+		if match(r) {
+			out = append(out, r)
+		}
+	}
 
-	// Preallocate once with capacity hint; clear and reuse for each recipient
-	// to avoid allocating a new map per call.
-	data := make(map[string]string, len(recipients[0].Data)+1)
+	return out
+}
 
-	for _, r := range recipients {
-		// Clear previous iteration's data without re-allocating.
+// Matcher returns a predicate evaluating exp against one recipient, for use
+// with FilterSource. The predicate reuses a scratch map between calls and
+// must not be shared between goroutines.
+func Matcher(exp Expression) func(Recipient) bool {
+	data := make(map[string]string)
+	return func(r Recipient) bool {
+		// Clear previous call's data without re-allocating.
 		for k := range data {
 			delete(data, k)
 		}
@@ -33,11 +43,6 @@ func Filter(recipients []Recipient, exp Expression) []Recipient {
 		for k, v := range r.Data {
 			data[strings.ToLower(k)] = v
 		}
-
-		if exp.Evaluate(data) {
-			out = append(out, r)
-		}
+		return exp.Evaluate(data)
 	}
-
-	return out
 }
@@ -0,0 +1,300 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+)
+
+// filterFunction is a helper filter expressions may call.
+type filterFunction struct {
+	// fn implements the function. It is nil for operators, whose calls
+	// are rewritten to the operator: contains(a, "b") is a contains "b".
+	fn       func(params ...any) (any, error)
+	types    []any  // expr.Function signatures; none accepts any arguments
+	operator bool   // the name is also a binary operator
+	raw      bool   // a column passed first is seen with its original case
+	args     int    // number of arguments, checked at compile time
+	usage    string // shown when the arguments are wrong
+}
+
+// filterTable lists the functions filter expressions may call besides the
+// expr builtins such as date() and now(). Adding a function here is all it
+// takes to make it available.
+var filterTable = map[string]filterFunction{
+	"contains":   {operator: true, args: 2, usage: `contains(field, "text")`},
+	"startsWith": {operator: true, args: 2, usage: `startsWith(field, "prefix")`},
+	"endsWith":   {operator: true, args: 2, usage: `endsWith(field, "suffix")`},
+	"matches":    {operator: true, args: 2, usage: `matches(field, "regex")`},
+
+	"equalsCase":     {fn: stringTest(func(s, v string) bool { return s == v }), raw: true, args: 2, usage: `equalsCase(field, "Text")`},
+	"containsCase":   {fn: stringTest(strings.Contains), raw: true, args: 2, usage: `containsCase(field, "Text")`},
+	"startsWithCase": {fn: stringTest(strings.HasPrefix), raw: true, args: 2, usage: `startsWithCase(field, "Prefix")`},
+	"endsWithCase":   {fn: stringTest(strings.HasSuffix), raw: true, args: 2, usage: `endsWithCase(field, "Suffix")`},
+	"matchesCase":    {fn: matchRegexp, raw: true, args: 2, usage: `matchesCase(field, "regex")`},
+	"inCase":         {fn: inList, raw: true, args: 2, usage: `inCase(field, ["A", "B"])`},
+
+	"inFile":    {fn: inFile, args: 2, usage: `inFile(field, "list.txt")`},
+	"domain":    {fn: domainOf, args: 1, usage: `domain(email)`},
+	"isEmpty":   {fn: isEmpty, raw: true, args: 1, usage: `isEmpty(field)`},
+	"daysSince": {fn: daysSince, types: []any{new(func(time.Time) int)}, args: 1, usage: `daysSince(date)`},
+}
+
+// filterOptions registers filterTable with expr.
+func filterOptions() []expr.Option {
+	var options []expr.Option
+	for name, f := range filterTable {
+		if f.fn != nil {
+			options = append(options, expr.Function(name, f.fn, f.types...))
+		}
+	}
+	return options
+}
+
+// text returns a function argument as a string.
+func text(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+// stringTest adapts a test such as strings.Contains to a filter function.
+func stringTest(test func(s, v string) bool) func(params ...any) (any, error) {
+	return func(params ...any) (any, error) {
+		return test(text(params[0]), text(params[1])), nil
+	}
+}
+
+// regexps caches the patterns of matchesCase, which are usually literals
+// evaluated once per row.
+var regexps sync.Map
+
+func matchRegexp(params ...any) (any, error) {
+	pattern := text(params[1])
+	re, ok := regexps.Load(pattern)
+	if !ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		re, _ = regexps.LoadOrStore(pattern, compiled)
+	}
+	return re.(*regexp.Regexp).MatchString(text(params[0])), nil
+}
+
+func inList(params ...any) (any, error) {
+	list, ok := params[1].([]any)
+	if !ok {
+		return nil, fmt.Errorf("inCase expects a list, got %T", params[1])
+	}
+	s := text(params[0])
+	for _, v := range list {
+		if text(v) == s {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func domainOf(params ...any) (any, error) {
+	s := text(params[0])
+	if _, domain, ok := SplitAddress(s); ok {
+		return domain, nil
+	}
+	if at := strings.LastIndexByte(s, '@'); at >= 0 {
+		return strings.ToLower(strings.TrimSpace(s[at+1:])), nil
+	}
+	return "", nil
+}
+
+func isEmpty(params ...any) (any, error) {
+	return strings.TrimSpace(text(params[0])) == "", nil
+}
+
+func daysSince(params ...any) (any, error) {
+	if params[0] == nil {
+		return nil, nil // blank typed value
+	}
+	t, ok := params[0].(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("daysSince expects a date, got %T", params[0])
+	}
+	return int(time.Since(t).Hours() / 24), nil
+}
+
+// valueFiles holds the lists read by inFile, keyed by path. They are read
+// when an expression using them is compiled.
+var valueFiles sync.Map
+
+func inFile(params ...any) (any, error) {
+	path := text(params[1])
+	set, ok := valueFiles.Load(path)
+	if !ok {
+		return nil, fmt.Errorf("inFile: %s was not loaded", path)
+	}
+	_, found := set.(map[string]struct{})[strings.ToLower(strings.TrimSpace(text(params[0])))]
+	return found, nil
+}
+
+// loadValueFile reads the list at path for inFile: one value per line,
+// compared case-insensitively. Blank lines and lines starting with # are
+// ignored.
+func loadValueFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("inFile: %w", err)
+	}
+	defer file.Close()
+	set := make(map[string]struct{})
+	sc := bufio.NewScanner(file)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			set[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return fmt.Errorf("inFile: failed to read %s: %w", path, err)
+	}
+	valueFiles.Store(path, set)
+	return nil
+}
+
+// rawPrefix marks the keys under which Evaluate passes the original text
+// of each column to the functions whose filterFunction.raw is set.
+const rawPrefix = "\x00"
+
+// foldedOperators compare strings case-insensitively. Evaluate lower-cases
+// column values, and filterPatch lower-cases the string literals compared
+// with them.
+var foldedOperators = map[string]bool{
+	"==": true, "!=": true, "in": true,
+	"contains": true, "startsWith": true, "endsWith": true,
+}
+
+// filterPatch applies filterTable to a parsed expression before it is type
+// checked: calls to operators become operator nodes, literals compared with
+// lower-cased values are lower-cased, and columns passed to case-sensitive
+// functions are read with their original case. Problems are collected in
+// err, and the inFile lists named in files.
+type filterPatch struct {
+	raw   bool // some function reads original text
+	files []string
+	err   error
+}
+
+func (p *filterPatch) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.CallNode:
+		p.call(node, n)
+	case *ast.BinaryNode:
+		p.fold(n)
+	}
+}
+
+func (p *filterPatch) call(node *ast.Node, n *ast.CallNode) {
+	callee, ok := n.Callee.(*ast.IdentifierNode)
+	if !ok {
+		return
+	}
+	// rewriteSource renames calls to operators, which expr cannot parse,
+	// from contains(...) to $contains(...).
+	name := strings.TrimPrefix(callee.Value, "$")
+	f, ok := filterTable[name]
+	if !ok || f.operator != strings.HasPrefix(callee.Value, "$") {
+		return
+	}
+	if len(n.Arguments) != f.args {
+		p.fail(fmt.Errorf("%s takes %d argument(s): %s", name, f.args, f.usage))
+		return
+	}
+
+	switch {
+	case f.operator:
+		b := &ast.BinaryNode{Operator: name, Left: n.Arguments[0], Right: n.Arguments[1]}
+		p.fold(b)
+		ast.Patch(node, b)
+	case name == "inFile":
+		if s, ok := n.Arguments[1].(*ast.StringNode); ok {
+			p.files = append(p.files, s.Value)
+		} else {
+			p.fail(fmt.Errorf("inFile takes a file name in quotes: %s", f.usage))
+		}
+	case f.raw:
+		if name == "matchesCase" {
+			if s, ok := n.Arguments[1].(*ast.StringNode); ok {
+				if _, err := regexp.Compile(s.Value); err != nil {
+					p.fail(fmt.Errorf("matchesCase: %w", err))
+				}
+			}
+		}
+		if key, ok := fieldName(n.Arguments[0]); ok {
+			n.Arguments[0] = &ast.MemberNode{
+				Node:     &ast.IdentifierNode{Value: "$env"},
+				Property: &ast.StringNode{Value: rawPrefix + key},
+			}
+			p.raw = true
+		}
+	}
+}
+
+// fail records the first problem found.
+func (p *filterPatch) fail(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// fold lower-cases the string literals a folded operator compares, and
+// makes matches case-insensitive.
+func (p *filterPatch) fold(n *ast.BinaryNode) {
+	if n.Operator == "matches" {
+		if s, ok := n.Right.(*ast.StringNode); ok && !strings.HasPrefix(s.Value, "(?i)") {
+			s.Value = "(?i)" + s.Value
+		}
+		return
+	}
+	if !foldedOperators[n.Operator] {
+		return
+	}
+	for _, side := range []ast.Node{n.Left, n.Right} {
+		switch s := side.(type) {
+		case *ast.StringNode:
+			s.Value = strings.ToLower(s.Value)
+		case *ast.ArrayNode:
+			for _, elem := range s.Nodes {
+				if str, ok := elem.(*ast.StringNode); ok {
+					str.Value = strings.ToLower(str.Value)
+				}
+			}
+		}
+	}
+}
+
+// fieldName returns the column a node reads, for a plain name or a dotted
+// one quoted by rewriteSource.
+func fieldName(node ast.Node) (string, bool) {
+	switch n := node.(type) {
+	case *ast.IdentifierNode:
+		return n.Value, !strings.HasPrefix(n.Value, "$")
+	case *ast.MemberNode:
+		env, ok := n.Node.(*ast.IdentifierNode)
+		key, isString := n.Property.(*ast.StringNode)
+		if ok && isString && env.Value == "$env" {
+			return key.Value, true
+		}
+	}
+	return "", false
+}
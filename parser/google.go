@@ -0,0 +1,273 @@
+package parser
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultGoogleTokenURL = "https://oauth2.googleapis.com/token"
+	defaultSheetsAPIURL   = "https://sheets.googleapis.com/v4/"
+	sheetsReadOnlyScope   = "https://www.googleapis.com/auth/spreadsheets.readonly"
+)
+
+// GoogleSheetsOptions configures access to private Google Sheets through
+// the Sheets API with a service account. Share the sheet with the service
+// account's email address to grant it read access.
+type GoogleSheetsOptions struct {
+	// CredentialsFile is the service account's JSON key file.
+	CredentialsFile string
+	// TokenURL overrides the OAuth token endpoint. Empty uses the key's
+	// token_uri, or Google's default.
+	TokenURL string
+	// APIBaseURL overrides the Sheets API root, e.g. for a test server.
+	APIBaseURL string
+	// Client performs the requests; nil uses a client with a 30-second
+	// timeout.
+	Client *http.Client
+}
+
+// serviceAccountKey holds the fields of a service account key we use.
+type serviceAccountKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+}
+
+// OpenPrivateSheet reads the tab of the Google Sheet at sheetURL selected
+// by its gid, as TableSource reads a spreadsheet. The Sheets API returns a
+// tab's values in one response, so the list is held in memory.
+func OpenPrivateSheet(sheetURL string, g GoogleSheetsOptions, opts ReadOptions) (*TableSource, error) {
+	id, gid, err := ExtractSheetInfo(sheetURL)
+	if err != nil {
+		return nil, err
+	}
+	key, err := loadServiceAccountKey(g.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	client := g.Client
+	if client == nil {
+		client = &http.Client{Timeout: sheetTimeout}
+	}
+	token, err := key.accessToken(client, g.TokenURL)
+	if err != nil {
+		return nil, err
+	}
+
+	base := g.APIBaseURL
+	if base == "" {
+		base = defaultSheetsAPIURL
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	api := sheetsAPI{client: client, base: base + "spreadsheets/" + url.PathEscape(id), token: token}
+
+	title, err := api.sheetTitle(gid)
+	if err != nil {
+		return nil, err
+	}
+	values, err := api.values(title)
+	if err != nil {
+		return nil, err
+	}
+	src, err := newTableSource(&valueRows{values: values}, "sheet", true, opts)
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("sheet %q is empty", title)
+		}
+		return nil, err
+	}
+	return src, nil
+}
+
+func loadServiceAccountKey(path string) (*serviceAccountKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account key: %w", err)
+	}
+	var key serviceAccountKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("invalid service account key: %w", err)
+	}
+	if key.Type != "service_account" || key.ClientEmail == "" || key.PrivateKey == "" {
+		return nil, fmt.Errorf("invalid service account key: expected type \"service_account\" with client_email and private_key")
+	}
+	return &key, nil
+}
+
+// accessToken exchanges a signed JWT assertion for an OAuth access token
+// (RFC 7523).
+func (k *serviceAccountKey) accessToken(client *http.Client, tokenURL string) (string, error) {
+	if tokenURL == "" {
+		tokenURL = k.TokenURI
+	}
+	if tokenURL == "" {
+		tokenURL = defaultGoogleTokenURL
+	}
+	assertion, err := k.assertion(tokenURL, time.Now())
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.PostForm(tokenURL, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to request access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return "", fmt.Errorf("token request failed: %s %s %s", resp.Status, body.Error, body.Description)
+	}
+	return body.AccessToken, nil
+}
+
+// assertion builds the RS256-signed JWT identifying the service account.
+func (k *serviceAccountKey) assertion(audience string, now time.Time) (string, error) {
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil {
+		return "", fmt.Errorf("invalid service account key: private_key is not PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return "", fmt.Errorf("invalid service account key: %w", err)
+	}
+	rsaKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", fmt.Errorf("invalid service account key: private_key is not an RSA key")
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": k.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss":   k.ClientEmail,
+		"scope": sheetsReadOnlyScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	enc := base64.RawURLEncoding
+	signed := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	return signed + "." + enc.EncodeToString(sig), nil
+}
+
+// sheetsAPI calls the Sheets API for one spreadsheet.
+type sheetsAPI struct {
+	client *http.Client
+	base   string // .../spreadsheets/{id}
+	token  string
+}
+
+func (a sheetsAPI) get(path string, query url.Values, v any) error {
+	req, err := http.NewRequest(http.MethodGet, a.base+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error fetching sheet: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body)
+		return fmt.Errorf("sheet returned status: %s %s", resp.Status, body.Error.Message)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid Sheets API response: %w", err)
+	}
+	return nil
+}
+
+// sheetTitle finds the title of the tab with the given gid. gid 0, the
+// default when a URL names none, falls back to the first tab.
+func (a sheetsAPI) sheetTitle(gid string) (string, error) {
+	var meta struct {
+		Sheets []struct {
+			Properties struct {
+				SheetID int64  `json:"sheetId"`
+				Title   string `json:"title"`
+			} `json:"properties"`
+		} `json:"sheets"`
+	}
+	if err := a.get("", url.Values{"fields": {"sheets.properties(sheetId,title)"}}, &meta); err != nil {
+		return "", err
+	}
+	if len(meta.Sheets) == 0 {
+		return "", fmt.Errorf("spreadsheet has no sheets")
+	}
+	for _, s := range meta.Sheets {
+		if fmt.Sprint(s.Properties.SheetID) == gid {
+			return s.Properties.Title, nil
+		}
+	}
+	if gid == "0" {
+		return meta.Sheets[0].Properties.Title, nil
+	}
+	return "", fmt.Errorf("no sheet with gid %s", gid)
+}
+
+// values returns the formatted cell values of the tab title.
+func (a sheetsAPI) values(title string) ([][]string, error) {
+	var resp struct {
+		Values [][]string `json:"values"`
+	}
+	rng := "'" + strings.ReplaceAll(title, "'", "''") + "'"
+	query := url.Values{"majorDimension": {"ROWS"}, "valueRenderOption": {"FORMATTED_VALUE"}}
+	if err := a.get("/values/"+url.PathEscape(rng), query, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Values, nil
+}
+
+// valueRows yields rows already in memory.
+type valueRows struct {
+	values [][]string
+}
+
+func (v *valueRows) Read() ([]string, error) {
+	if len(v.values) == 0 {
+		return nil, io.EOF
+	}
+	row := v.values[0]
+	v.values = v.values[1:]
+	return row, nil
+}
@@ -0,0 +1,334 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+// JoinMode selects how JoinSources combines recipient lists.
+type JoinMode string
+
+const (
+	// JoinUnion sends to every address in any input. Rows sharing an
+	// address are merged into one recipient.
+	JoinUnion JoinMode = "union"
+	// JoinLeft sends to every address of the primary input, adding the
+	// columns of matching rows in the other inputs.
+	JoinLeft JoinMode = "left"
+	// JoinInner sends only to primary addresses found in every other input.
+	JoinInner JoinMode = "inner"
+)
+
+// ParseJoinMode accepts union, left and inner, with or without a "-join"
+// suffix.
+func ParseJoinMode(s string) (JoinMode, error) {
+	switch mode := JoinMode(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), "-join")); mode {
+	case JoinUnion, JoinLeft, JoinInner:
+		return mode, nil
+	case "":
+		return JoinLeft, nil
+	}
+	return "", fmt.Errorf("invalid join mode %q (use union, left or inner)", s)
+}
+
+// ConflictRule decides the value of a column present in several joined
+// rows for the same address.
+type ConflictRule string
+
+const (
+	// ConflictFirst keeps the value of the earliest input that has one.
+	ConflictFirst ConflictRule = "first"
+	// ConflictLast keeps the value of the latest input that has one.
+	ConflictLast ConflictRule = "last"
+	// ConflictError fails when two inputs hold different non-empty values.
+	ConflictError ConflictRule = "error"
+)
+
+// ParseConflictRule accepts first, last and error; empty means first.
+func ParseConflictRule(s string) (ConflictRule, error) {
+	switch rule := ConflictRule(strings.ToLower(strings.TrimSpace(s))); rule {
+	case ConflictFirst, ConflictLast, ConflictError:
+		return rule, nil
+	case "":
+		return ConflictFirst, nil
+	}
+	return "", fmt.Errorf("invalid conflict rule %q (use first, last or error)", s)
+}
+
+// JoinInput is one list taking part in a join. Name identifies it in
+// conflict errors and the unmatched report, typically its path.
+type JoinInput struct {
+	Name   string
+	Source CountingSource
+}
+
+// UnmatchedRow is a row of Source whose address is missing from the
+// input MissingFrom.
+type UnmatchedRow struct {
+	Source      string
+	Row         int
+	Email       string
+	MissingFrom string
+}
+
+// JoinOptions configures JoinSources.
+type JoinOptions struct {
+	Mode     JoinMode
+	Conflict ConflictRule
+	// Unmatched, when set, is called for every unmatched row: primary rows
+	// as they are read, rows of the other inputs once the primary input is
+	// exhausted.
+	Unmatched func(UnmatchedRow)
+}
+
+// JoinStats summarises a join once it has been read.
+type JoinStats struct {
+	Matched            int // primary rows with a match in every other input
+	PrimaryUnmatched   int // primary rows missing from at least one other input
+	SecondaryUnmatched int // rows of other inputs whose address is not in the primary input
+}
+
+// joinEntry is a row of a secondary input, indexed by address.
+type joinEntry struct {
+	rec     Recipient
+	matched bool // the address occurs in the primary input
+}
+
+// joinIndex holds a secondary input in memory.
+type joinIndex struct {
+	name    string
+	rows    int
+	skipped int
+	order   []*joinEntry
+	byEmail map[string]*joinEntry
+}
+
+// JoinSource streams the primary input of a join, merging in the columns
+// of the other inputs. It reports the row and skip counts of all inputs
+// read.
+type JoinSource struct {
+	primary JoinInput
+	others  []*joinIndex
+	opts    JoinOptions
+	stats   JoinStats
+
+	tail    []Recipient // union: rows of other inputs, after the primary
+	tailSet bool
+}
+
+// JoinSources joins others onto primary by email address, compared
+// case-insensitively. The other inputs are read into memory and closed
+// here, so they should be the smaller lists; the primary input is
+// streamed. Within one other input the first row for an address is used
+// and later ones are skipped as duplicates. On error the caller still owns
+// the primary input.
+//
+// Joined recipients keep the row number of their primary row. Under
+// JoinUnion, rows found only in other inputs follow with row numbers
+// continuing past the primary input, in input order.
+func JoinSources(primary JoinInput, others []JoinInput, opts JoinOptions) (*JoinSource, error) {
+	if opts.Mode == "" {
+		opts.Mode = JoinLeft
+	}
+	if opts.Conflict == "" {
+		opts.Conflict = ConflictFirst
+	}
+	j := &JoinSource{primary: primary, opts: opts}
+	var err error
+	for _, in := range others {
+		var idx *joinIndex
+		if err == nil {
+			idx, err = indexJoinInput(in)
+			j.others = append(j.others, idx)
+		}
+		if closeErr := in.Source.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to close %s: %w", in.Name, closeErr)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func indexJoinInput(in JoinInput) (*joinIndex, error) {
+	idx := &joinIndex{name: in.Name, byEmail: make(map[string]*joinEntry)}
+	duplicates := 0
+	for {
+		r, err := in.Source.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", in.Name, err)
+		}
+		key := strings.ToLower(r.Email)
+		if _, dup := idx.byEmail[key]; dup {
+			duplicates++
+			continue
+		}
+		e := &joinEntry{rec: r}
+		idx.byEmail[key] = e
+		idx.order = append(idx.order, e)
+	}
+	idx.rows, idx.skipped = in.Source.Rows(), in.Source.Skipped()
+	if duplicates > 0 {
+		log.Printf("Warning: %s has %d duplicate addresses; the first row of each is joined", in.Name, duplicates)
+	}
+	return idx, nil
+}
+
+// Next returns the next joined recipient, or io.EOF when all are read.
+func (j *JoinSource) Next() (Recipient, error) {
+	for !j.tailSet {
+		r, err := j.primary.Source.Next()
+		if errors.Is(err, io.EOF) {
+			if err := j.finishPrimary(); err != nil {
+				return Recipient{}, err
+			}
+			break
+		}
+		if err != nil {
+			return Recipient{}, err
+		}
+
+		key := strings.ToLower(r.Email)
+		missing := false
+		r.Data = copyData(r.Data)
+		for _, idx := range j.others {
+			e, ok := idx.byEmail[key]
+			if !ok {
+				missing = true
+				j.report(UnmatchedRow{Source: j.primary.Name, Row: r.Row, Email: r.Email, MissingFrom: idx.name})
+				continue
+			}
+			e.matched = true
+			if err := j.merge(&r, e.rec, idx.name); err != nil {
+				return Recipient{}, err
+			}
+		}
+		if missing {
+			j.stats.PrimaryUnmatched++
+			if j.opts.Mode == JoinInner {
+				continue
+			}
+		} else {
+			j.stats.Matched++
+		}
+		return r, nil
+	}
+
+	if len(j.tail) == 0 {
+		return Recipient{}, io.EOF
+	}
+	r := j.tail[0]
+	j.tail = j.tail[1:]
+	return r, nil
+}
+
+// finishPrimary reports the rows of other inputs no primary row matched
+// and, for a union, queues them, merging rows that share an address.
+func (j *JoinSource) finishPrimary() error {
+	j.tailSet = true
+	row := j.primary.Source.Rows()
+	for i, idx := range j.others {
+		for _, e := range idx.order {
+			if e.matched {
+				continue
+			}
+			j.stats.SecondaryUnmatched++
+			j.report(UnmatchedRow{Source: idx.name, Row: e.rec.Row, Email: e.rec.Email, MissingFrom: j.primary.Name})
+			if j.opts.Mode != JoinUnion {
+				continue
+			}
+			r := e.rec
+			r.Data = copyData(r.Data)
+			r.Row = row + e.rec.Row
+			for _, later := range j.others[i+1:] {
+				if other, ok := later.byEmail[strings.ToLower(r.Email)]; ok && !other.matched {
+					other.matched = true
+					j.stats.SecondaryUnmatched++
+					j.report(UnmatchedRow{Source: later.name, Row: other.rec.Row, Email: other.rec.Email, MissingFrom: j.primary.Name})
+					if err := j.merge(&r, other.rec, later.name); err != nil {
+						return err
+					}
+				}
+			}
+			j.tail = append(j.tail, r)
+		}
+		row += idx.rows
+	}
+	return nil
+}
+
+// merge adds the columns of src, read from the input named from, to dst
+// following the conflict rule.
+func (j *JoinSource) merge(dst *Recipient, src Recipient, from string) error {
+	for k, v := range src.Data {
+		cur, ok := dst.Data[k]
+		switch {
+		case !ok || cur == "":
+			dst.Data[k] = v
+		case v == "" || v == cur:
+		case j.opts.Conflict == ConflictLast:
+			dst.Data[k] = v
+		case j.opts.Conflict == ConflictError:
+			return fmt.Errorf("conflicting values for column %q of %s: %q, and %q in %s", k, dst.Email, cur, v, from)
+		}
+	}
+	for k, v := range src.Fields {
+		if _, ok := dst.Fields[k]; ok && j.opts.Conflict != ConflictLast {
+			continue
+		}
+		if dst.Fields == nil {
+			dst.Fields = make(map[string]any)
+		}
+		dst.Fields[k] = v
+	}
+	return nil
+}
+
+func (j *JoinSource) report(u UnmatchedRow) {
+	if j.opts.Unmatched != nil {
+		j.opts.Unmatched(u)
+	}
+}
+
+// Stats returns the match counts so far; they are final after io.EOF.
+func (j *JoinSource) Stats() JoinStats { return j.stats }
+
+// Rows returns how many rows have been read: the primary rows, plus under
+// JoinUnion the rows of the other inputs, whose row numbers follow them.
+func (j *JoinSource) Rows() int {
+	n := j.primary.Source.Rows()
+	if j.opts.Mode == JoinUnion {
+		for _, idx := range j.others {
+			n += idx.rows
+		}
+	}
+	return n
+}
+
+// Skipped returns how many rows of all inputs were skipped as malformed or
+// invalid.
+func (j *JoinSource) Skipped() int {
+	n := j.primary.Source.Skipped()
+	for _, idx := range j.others {
+		n += idx.skipped
+	}
+	return n
+}
+
+// Close closes the primary input.
+func (j *JoinSource) Close() error { return j.primary.Source.Close() }
+
+func copyData(data map[string]string) map[string]string {
+	out := make(map[string]string, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,276 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// JSONSource streams recipients from JSON: either one array of objects or
+// a sequence of objects such as NDJSON. Each object is a recipient and must
+// have an 'email' key. Keys are lowercased like CSV headers; nested objects
+// and arrays are flattened into Data under dotted keys ("address.city",
+// "tags.0") and kept structured in Fields for templates.
+type JSONSource struct {
+	dec     *json.Decoder
+	closer  io.Closer
+	columns map[string]string // from ReadOptions, applied to top-level keys
+	array   bool              // input is a single top-level array
+	done    bool
+	rows    int // records read so far
+	skipped int // non-object records and invalid addresses
+}
+
+// NewJSONSource returns a source over the JSON in reader.
+func NewJSONSource(reader io.Reader) (*JSONSource, error) {
+	return NewJSONSourceWithOptions(reader, ReadOptions{})
+}
+
+// NewJSONSourceWithOptions is NewJSONSource for the encoding and column
+// mapping in opts. Renames apply to top-level keys; since records need not
+// share keys, a renamed key missing from the input is not an error.
+func NewJSONSourceWithOptions(reader io.Reader, opts ReadOptions) (*JSONSource, error) {
+	br, err := decodeInput(reader, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	first, err := firstByte(br)
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("JSON input is empty")
+		}
+		return nil, err
+	}
+	dec := json.NewDecoder(br)
+	dec.UseNumber() // keep numbers exactly as written
+	s := &JSONSource{dec: dec, columns: opts.columnMap()}
+	if first == '[' {
+		if _, err := dec.Token(); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		s.array = true
+	}
+	return s, nil
+}
+
+// OpenJSON opens the JSON or NDJSON file at path as a streaming source.
+// Closing the source closes the file.
+func OpenJSON(path string, opts ReadOptions) (*JSONSource, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	src, err := NewJSONSourceWithOptions(file, opts)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	src.closer = file
+	return src, nil
+}
+
+// ParseJSON reads JSON or NDJSON recipients from reader into the same
+// Recipient values ParseCSVFromReader returns, plus structured Fields.
+func ParseJSON(reader io.Reader) ([]Recipient, error) {
+	src, err := NewJSONSource(reader)
+	if err != nil {
+		return nil, err
+	}
+	return collectTable(src, "JSON")
+}
+
+// Next returns the next valid recipient, or io.EOF at the end of the input.
+// Syntax errors end the stream, since the decoder cannot resynchronise.
+func (s *JSONSource) Next() (Recipient, error) {
+	for {
+		if s.done || (s.array && !s.dec.More()) {
+			s.done = true
+			return Recipient{}, io.EOF
+		}
+		var value any
+		if err := s.dec.Decode(&value); err != nil {
+			if err == io.EOF && !s.array {
+				s.done = true
+				return Recipient{}, io.EOF
+			}
+			return Recipient{}, fmt.Errorf("invalid JSON in record %d: %w", s.rows+1, unexpectedEOF(err))
+		}
+		row := s.rows
+		s.rows++
+
+		obj, ok := value.(map[string]any)
+		if !ok {
+			s.skipped++
+			log.Printf("Warning: Skipping record %d: expected a JSON object", s.rows)
+			continue
+		}
+		r, ok := jsonRecipient(obj, s.columns)
+		if !ok {
+			s.skipped++
+			log.Printf("Warning: Skipping record %d with invalid email: %s", s.rows, r.Email)
+			continue
+		}
+		if r.Email == "" {
+			continue // skip blank emails
+		}
+		r.Row = row
+		return r, nil
+	}
+}
+
+// Rows returns how many records have been read.
+func (s *JSONSource) Rows() int { return s.rows }
+
+// Skipped returns how many records were skipped as malformed or invalid.
+func (s *JSONSource) Skipped() int { return s.skipped }
+
+// Close releases the underlying file, if the source owns one.
+func (s *JSONSource) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// jsonRecipient converts one object, renaming top-level keys by columns.
+// ok is false when the email is present but not a valid address.
+func jsonRecipient(obj map[string]any, columns map[string]string) (Recipient, bool) {
+	r := Recipient{Data: make(map[string]string, len(obj))}
+	for k, v := range obj {
+		key := renameKey(columns, normalizeColumn(k))
+		if key == "email" {
+			s, isString := v.(string)
+			if v != nil && !isString {
+				r.Email = fmt.Sprint(v)
+				return r, false
+			}
+			r.Email = strings.TrimSpace(s)
+			continue
+		}
+		flattenJSON(key, v, r.Data)
+		switch v.(type) {
+		case map[string]any, []any:
+			if r.Fields == nil {
+				r.Fields = make(map[string]any)
+			}
+			r.Fields[key] = normalizeJSON(v)
+		}
+	}
+	if r.Email != "" && !IsValidEmail(r.Email) {
+		return r, false
+	}
+	return r, true
+}
+
+// flattenJSON writes v into data under key, descending into objects and
+// arrays with dotted keys.
+func flattenJSON(key string, v any, data map[string]string) {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			flattenJSON(key+"."+strings.ToLower(strings.TrimSpace(k)), child, data)
+		}
+	case []any:
+		for i, child := range t {
+			flattenJSON(key+"."+strconv.Itoa(i), child, data)
+		}
+	default:
+		data[key] = jsonScalar(v)
+	}
+}
+
+// normalizeJSON lowercases object keys and turns scalars into the strings
+// templates see elsewhere, keeping the shape of objects and arrays.
+func normalizeJSON(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(t))
+		for k, child := range t {
+			out[strings.ToLower(strings.TrimSpace(k))] = normalizeJSON(child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(t))
+		for i, child := range t {
+			out[i] = normalizeJSON(child)
+		}
+		return out
+	default:
+		return jsonScalar(v)
+	}
+}
+
+// jsonScalar renders a JSON scalar as text; null becomes empty.
+func jsonScalar(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(t)
+	case json.Number:
+		return t.String()
+	case bool:
+		return strconv.FormatBool(t)
+	default:
+		return fmt.Sprint(t)
+	}
+}
+
+// firstByte returns the first byte of br that is neither whitespace nor a
+// UTF-8 byte order mark, leaving it unread. The BOM is consumed.
+func firstByte(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			br.ReadByte()
+			continue
+		case 0xEF:
+			if bom, err := br.Peek(3); err == nil && string(bom) == "\xEF\xBB\xBF" {
+				br.Discard(3)
+				continue
+			}
+		}
+		return b[0], nil
+	}
+}
+
+// errStdinSpreadsheet is returned for spreadsheets piped on stdin or
+// downloaded, which cannot be read without seeking.
+var errStdinSpreadsheet = errors.New("spreadsheets cannot be streamed from stdin or a URL; pass a file path instead")
+
+// OpenReader streams recipients from r, detecting the format from its
+// content: JSON when it starts with '[' or '{', otherwise CSV. It is used
+// for standard input, where there is no file extension to go by.
+func OpenReader(r io.Reader, opts ReadOptions) (CountingSource, error) {
+	br, err := decodeInput(r, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	opts.Encoding = "" // already decoded
+	first, err := firstByte(br)
+	if err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("input is empty")
+		}
+		return nil, err
+	}
+	switch first {
+	case '[', '{':
+		return NewJSONSourceWithOptions(br, opts)
+	case 'P':
+		if magic, err := br.Peek(4); err == nil && string(magic) == "PK\x03\x04" {
+			return nil, errStdinSpreadsheet
+		}
+	}
+	return NewCSVSourceWithOptions(br, opts)
+}
@@ -0,0 +1,126 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MXResolver is the part of *net.Resolver a DomainChecker uses, so tests
+// can answer lookups without DNS.
+type MXResolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DomainStatus is what DNS says about a domain's ability to receive mail.
+type DomainStatus string
+
+const (
+	// DomainOK has an MX record, or no MX but an address record, which
+	// serves as an implicit MX (RFC 5321 section 5.1).
+	DomainOK DomainStatus = "ok"
+	// DomainNullMX publishes the null MX "." (RFC 7505): it accepts no mail.
+	DomainNullMX DomainStatus = "null_mx"
+	// DomainNoMailHost does not exist, or has neither MX nor address
+	// records.
+	DomainNoMailHost DomainStatus = "no_mx"
+	// DomainUnknown could not be looked up, for example on a timeout.
+	DomainUnknown DomainStatus = "unknown"
+)
+
+// Deliverable reports whether mail to the domain can be attempted. Domains
+// whose lookup failed are given the benefit of the doubt.
+func (s DomainStatus) Deliverable() bool { return s == DomainOK || s == DomainUnknown }
+
+// DomainChecker looks up the mail exchangers of recipient domains, caching
+// the answer for each domain. It is safe for concurrent use.
+type DomainChecker struct {
+	resolver MXResolver
+	timeout  time.Duration
+
+	mu    sync.Mutex
+	cache map[string]DomainStatus
+}
+
+// NewDomainChecker returns a checker asking resolver, or the system
+// resolver when nil. Each lookup is limited to timeout when positive.
+func NewDomainChecker(resolver MXResolver, timeout time.Duration) *DomainChecker {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &DomainChecker{resolver: resolver, timeout: timeout, cache: make(map[string]DomainStatus)}
+}
+
+// CheckAddress checks the domain of addr and returns it lower-cased. An
+// address that does not parse has no domain and DomainNoMailHost.
+func (c *DomainChecker) CheckAddress(ctx context.Context, addr string) (string, DomainStatus) {
+	_, domain, ok := SplitAddress(addr)
+	if !ok {
+		return "", DomainNoMailHost
+	}
+	return domain, c.Check(ctx, domain)
+}
+
+// Check returns the status of domain, looking it up on first use.
+func (c *DomainChecker) Check(ctx context.Context, domain string) DomainStatus {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	c.mu.Lock()
+	status, ok := c.cache[domain]
+	c.mu.Unlock()
+	if ok {
+		return status
+	}
+
+	status = c.lookup(ctx, domain)
+	c.mu.Lock()
+	c.cache[domain] = status
+	c.mu.Unlock()
+	return status
+}
+
+func (c *DomainChecker) lookup(ctx context.Context, domain string) DomainStatus {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	// LookupMX may return the well-formed records along with an error
+	// about malformed ones.
+	mxs, err := c.resolver.LookupMX(ctx, domain+".")
+	if err != nil && len(mxs) == 0 && !isNotFound(err) {
+		return DomainUnknown
+	}
+	null := false
+	for _, mx := range mxs {
+		if mx.Host == "." || mx.Host == "" {
+			null = true
+			continue
+		}
+		return DomainOK
+	}
+	if null {
+		return DomainNullMX
+	}
+
+	// No MX: an address record is the implicit mail exchanger.
+	addrs, err := c.resolver.LookupIPAddr(ctx, domain+".")
+	switch {
+	case err != nil && !isNotFound(err):
+		return DomainUnknown
+	case len(addrs) > 0:
+		return DomainOK
+	}
+	return DomainNoMailHost
+}
+
+// isNotFound reports whether err is an authoritative "no such name" or "no
+// such record" answer rather than a failed lookup.
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,321 @@
+package parser
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// OpenODS opens a table of the OpenDocument spreadsheet at path as a
+// streaming source. opts.Sheet selects it by name or 1-based index; empty
+// selects the first table. Closing the source closes the file.
+func OpenODS(filePath string, opts ReadOptions) (*TableSource, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spreadsheet: %w", err)
+	}
+	src, err := newODSSource(&zr.Reader, opts)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	src.closer = multiCloser{src.closer, zr}
+	return src, nil
+}
+
+// NewODSSource returns a source over a table of the OpenDocument
+// spreadsheet in r. See OpenODS for sheet selection.
+func NewODSSource(r io.ReaderAt, size int64, opts ReadOptions) (*TableSource, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spreadsheet: %w", err)
+	}
+	return newODSSource(zr, opts)
+}
+
+func newODSSource(zr *zip.Reader, opts ReadOptions) (*TableSource, error) {
+	sheet := opts.Sheet
+	var content *zip.File
+	for _, f := range zr.File {
+		if f.Name == "content.xml" {
+			content = f
+			break
+		}
+	}
+	if content == nil {
+		return nil, fmt.Errorf("not an OpenDocument spreadsheet: content.xml is missing")
+	}
+
+	// A numeric selector only means a position when no table carries that
+	// name, which needs the full list of names up front.
+	want := -1
+	if _, err := strconv.Atoi(strings.TrimSpace(sheet)); err == nil {
+		names, err := odsTableNames(content)
+		if err != nil {
+			return nil, err
+		}
+		if want, err = selectSheet(names, sheet); err != nil {
+			return nil, err
+		}
+	}
+
+	rc, err := content.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spreadsheet: %w", err)
+	}
+	rows := &odsRows{dec: xml.NewDecoder(rc)}
+	name, err := rows.seekTable(sheet, want)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	src, err := newTableSource(rows, "spreadsheet", true, opts)
+	if err != nil {
+		rc.Close()
+		if err == io.EOF {
+			return nil, fmt.Errorf("sheet %q is empty", name)
+		}
+		return nil, err
+	}
+	src.closer = rc
+	return src, nil
+}
+
+// odsTableNames lists the tables of content in document order.
+func odsTableNames(content *zip.File) ([]string, error) {
+	rc, err := content.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spreadsheet: %w", err)
+	}
+	defer rc.Close()
+	dec := xml.NewDecoder(rc)
+	var names []string
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spreadsheet: %w", err)
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "table" {
+			names = append(names, odsAttr(se, "name"))
+			if err := dec.Skip(); err != nil {
+				return nil, fmt.Errorf("failed to read spreadsheet: %w", err)
+			}
+		}
+	}
+}
+
+// odsRows reads the rows of one table as a token stream.
+type odsRows struct {
+	dec    *xml.Decoder
+	record []string
+	repeat int // further copies of record still to return
+	done   bool
+}
+
+// seekTable advances to the table to read: the one at position want when
+// it is not negative, otherwise the one named sheet, or the first table when
+// sheet is empty. It returns the table's name.
+func (o *odsRows) seekTable(sheet string, want int) (string, error) {
+	var names []string
+	for {
+		tok, err := o.dec.Token()
+		if err == io.EOF {
+			if len(names) == 0 {
+				return "", fmt.Errorf("spreadsheet has no sheets")
+			}
+			return "", fmt.Errorf("sheet %q not found (available: %s)", sheet, strings.Join(names, ", "))
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read spreadsheet: %w", err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "table" {
+			continue
+		}
+		name := odsAttr(se, "name")
+		pos := len(names)
+		names = append(names, name)
+		switch {
+		case want >= 0 && pos == want,
+			want < 0 && sheet == "",
+			want < 0 && sheetNameMatches(name, sheet):
+			return name, nil
+		}
+		if err := o.dec.Skip(); err != nil {
+			return "", fmt.Errorf("failed to read spreadsheet: %w", err)
+		}
+	}
+}
+
+// Read returns the cells of the next non-empty row of the table. Rows with
+// no content are dropped, which also collapses the long runs of repeated
+// empty rows spreadsheets store after the data.
+func (o *odsRows) Read() ([]string, error) {
+	if o.repeat > 0 {
+		o.repeat--
+		return o.record, nil
+	}
+	for !o.done {
+		tok, err := o.dec.Token()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "table-row" {
+				continue // row groups and header rows hold rows too
+			}
+			if err := o.readRow(); err != nil {
+				return nil, err
+			}
+			if len(o.record) == 0 {
+				continue
+			}
+			o.repeat = odsRepeat(t, "number-rows-repeated") - 1
+			return o.record, nil
+		case xml.EndElement:
+			if t.Name.Local == "table" {
+				o.done = true
+			}
+		}
+	}
+	return nil, io.EOF
+}
+
+// readRow decodes one table row into record, without trailing empty cells.
+func (o *odsRows) readRow() error {
+	o.record = o.record[:0]
+	empty := 0 // empty cells not yet written, kept back in case none follow
+	for {
+		tok, err := o.dec.Token()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "table-cell" && t.Name.Local != "covered-table-cell" {
+				if err := o.dec.Skip(); err != nil {
+					return unexpectedEOF(err)
+				}
+				continue
+			}
+			value, err := o.readCell(t)
+			if err != nil {
+				return err
+			}
+			n := odsRepeat(t, "number-columns-repeated")
+			if value == "" {
+				empty += n
+				continue
+			}
+			for ; empty > 0; empty-- {
+				o.record = append(o.record, "")
+			}
+			for i := 0; i < n; i++ {
+				o.record = append(o.record, value)
+			}
+		case xml.EndElement:
+			return nil
+		}
+	}
+}
+
+// readCell returns the value of a cell: the typed office value for numbers,
+// dates and booleans, otherwise its text.
+func (o *odsRows) readCell(start xml.StartElement) (string, error) {
+	text, err := o.cellText()
+	if err != nil {
+		return "", err
+	}
+	switch odsAttr(start, "value-type") {
+	case "float", "percentage", "currency":
+		if f, err := strconv.ParseFloat(odsAttr(start, "value"), 64); err == nil {
+			return formatNumber(f), nil
+		}
+	case "date":
+		if v := odsAttr(start, "date-value"); v != "" {
+			v = strings.Replace(v, "T", " ", 1)
+			return strings.TrimSuffix(v, " 00:00:00"), nil
+		}
+	case "boolean":
+		if odsAttr(start, "boolean-value") == "true" {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	}
+	return text, nil
+}
+
+// cellText collects the paragraphs of the current cell, honouring the
+// space, tab and line-break elements OpenDocument uses for whitespace.
+func (o *odsRows) cellText() (string, error) {
+	var b strings.Builder
+	paragraphs := 0
+	inParagraph := 0
+	depth := 1
+	for depth > 0 {
+		tok, err := o.dec.Token()
+		if err != nil {
+			return "", unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch t.Name.Local {
+			case "p":
+				if paragraphs > 0 {
+					b.WriteByte('\n')
+				}
+				paragraphs++
+				inParagraph++
+			case "s":
+				n := odsRepeat(t, "c")
+				b.WriteString(strings.Repeat(" ", n))
+			case "tab":
+				b.WriteByte('\t')
+			case "line-break":
+				b.WriteByte('\n')
+			case "annotation":
+				depth--
+				if err := o.dec.Skip(); err != nil {
+					return "", unexpectedEOF(err)
+				}
+			}
+		case xml.EndElement:
+			depth--
+			if t.Name.Local == "p" {
+				inParagraph--
+			}
+		case xml.CharData:
+			if inParagraph > 0 {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// odsAttr returns the attribute with local name local.
+func odsAttr(se xml.StartElement, local string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// odsRepeat returns a repeat-count attribute, at least 1.
+func odsRepeat(se xml.StartElement, local string) int {
+	n, err := strconv.Atoi(odsAttr(se, local))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// SplitAddress returns the lower-cased local part and domain of addr, which
+// may carry a display name ("Ann <ann@example.com>"). ok is false when addr
+// does not parse.
+func SplitAddress(addr string) (local, domain string, ok bool) {
+	a, err := mail.ParseAddress(addr)
+	if err != nil {
+		return "", "", false
+	}
+	at := strings.LastIndexByte(a.Address, '@')
+	if at < 0 {
+		return "", "", false
+	}
+	return strings.ToLower(a.Address[:at]), strings.ToLower(a.Address[at+1:]), true
+}
+
+// CanonicalEmail returns the mailbox addr delivers to, for spotting aliases
+// of one inbox: the bare address lower-cased and, for Gmail, without dots or
+// a +tag in the local part and with googlemail.com folded into gmail.com.
+// Addresses that do not parse are only lower-cased.
+func CanonicalEmail(addr string) string {
+	local, domain, ok := SplitAddress(addr)
+	if !ok {
+		return strings.ToLower(strings.TrimSpace(addr))
+	}
+	if domain == "gmail.com" || domain == "googlemail.com" {
+		if plus := strings.IndexByte(local, '+'); plus >= 0 {
+			local = local[:plus]
+		}
+		local = strings.ReplaceAll(local, ".", "")
+		domain = "gmail.com"
+	}
+	return local + "@" + domain
+}
+
+// roleLocalParts are mailbox names that reach a team or a system rather
+// than a person. Mail to them is often ignored, shared among many readers
+// or reported as spam.
+var roleLocalParts = map[string]struct{}{
+	"abuse": {}, "accounts": {}, "admin": {}, "administrator": {}, "billing": {},
+	"careers": {}, "contact": {}, "customerservice": {}, "enquiries": {},
+	"feedback": {}, "help": {}, "helpdesk": {}, "hello": {}, "hostmaster": {},
+	"info": {}, "inquiries": {}, "jobs": {}, "mail": {}, "mailer-daemon": {},
+	"marketing": {}, "media": {}, "news": {}, "newsletter": {}, "no-reply": {},
+	"noc": {}, "noreply": {}, "office": {}, "postmaster": {}, "press": {},
+	"privacy": {}, "root": {}, "sales": {}, "security": {}, "service": {},
+	"support": {}, "team": {}, "webmaster": {},
+}
+
+// IsRoleAddress reports whether addr is a role mailbox such as info@ or
+// postmaster@. A +tag is ignored.
+func IsRoleAddress(addr string) bool {
+	local, _, ok := SplitAddress(addr)
+	if !ok {
+		return false
+	}
+	if plus := strings.IndexByte(local, '+'); plus > 0 {
+		local = local[:plus]
+	}
+	_, role := roleLocalParts[local]
+	return role
+}
+
+// DomainSet is a set of mail domains. A domain also matches its
+// subdomains.
+type DomainSet map[string]struct{}
+
+// NewDomainSet returns a set of the given domains, lower-cased.
+func NewDomainSet(domains ...string) DomainSet {
+	s := make(DomainSet, len(domains))
+	s.Add(domains...)
+	return s
+}
+
+// Add adds domains to the set. Blank entries and a leading "@" or "." are
+// ignored.
+func (s DomainSet) Add(domains ...string) {
+	for _, d := range domains {
+		d = strings.TrimLeft(strings.ToLower(strings.TrimSpace(d)), "@.")
+		if d != "" {
+			s[d] = struct{}{}
+		}
+	}
+}
+
+// Matches reports whether the domain of addr, or a parent of it, is in the
+// set.
+func (s DomainSet) Matches(addr string) bool {
+	_, domain, ok := SplitAddress(addr)
+	if !ok {
+		return false
+	}
+	for domain != "" {
+		if _, found := s[domain]; found {
+			return true
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return false
+}
+
+// DisposableDomains returns a new set of well-known disposable and
+// temporary mailbox providers. Callers may add their own entries to it.
+func DisposableDomains() DomainSet {
+	return NewDomainSet(
+		"10minutemail.com", "20minutemail.com", "33mail.com", "burnermail.io",
+		"discard.email", "dispostable.com", "emailondeck.com", "fakeinbox.com",
+		"getairmail.com", "getnada.com", "guerrillamail.com", "guerrillamail.net",
+		"guerrillamail.org", "guerrillamailblock.com", "harakirimail.com",
+		"inboxkitten.com", "mailcatch.com", "maildrop.cc", "mailinator.com",
+		"mailnesia.com", "mintemail.com", "mohmal.com", "mytemp.email",
+		"sharklasers.com", "spam4.me", "spamgourmet.com", "temp-mail.org",
+		"tempail.com", "tempmail.com", "tempmail.net", "tempmailo.com",
+		"temporary-mail.net", "throwawaymail.com", "trashmail.com",
+		"trashmail.de", "yopmail.com", "yopmail.fr",
+	)
+}
@@ -0,0 +1,313 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// URLOptions controls how OpenURL downloads a recipient list.
+type URLOptions struct {
+	// AllowedHosts lists the hosts lists may be fetched from, including
+	// redirects. "*.example.com" matches subdomains of example.com. An
+	// empty list allows no host.
+	AllowedHosts []string
+	// Headers are sent with every request.
+	Headers map[string]string
+	// BearerToken, when set, is sent as "Authorization: Bearer <token>".
+	BearerToken string
+	// CacheDir holds downloads keyed by URL and request headers, so an
+	// unchanged list is not downloaded again and a copy fetched with one
+	// credential is never served to another: the stored ETag is sent as If-None-Match and a 304
+	// response replays the stored copy. Empty disables caching.
+	CacheDir string
+	// Client performs the requests; nil uses a client that gives up on
+	// servers not answering within 30 seconds.
+	Client *http.Client
+}
+
+// hostAllowed reports whether host matches an entry of AllowedHosts.
+func (o URLOptions) hostAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range o.AllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == host {
+			return true
+		}
+		if strings.HasPrefix(allowed, "*.") && strings.HasSuffix(host, allowed[1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkURL rejects URLs that are not https or whose host is not allowed.
+func (o URLOptions) checkURL(u *url.URL) error {
+	if u.Scheme != "https" {
+		return fmt.Errorf("source URL must use https, got %q", u.Scheme)
+	}
+	if !o.hostAllowed(u.Hostname()) {
+		return fmt.Errorf("host %q is not in the allowed source hosts", u.Hostname())
+	}
+	return nil
+}
+
+// OpenURL downloads the CSV or JSON recipient list at rawURL and streams
+// it as it arrives. The format comes from the Content-Type, then the file
+// extension of the URL path, then the content itself as for stdin.
+func OpenURL(rawURL string, urlOpts URLOptions, opts ReadOptions) (CountingSource, error) {
+	body, contentType, err := FetchURL(rawURL, urlOpts)
+	if err != nil {
+		return nil, err
+	}
+	var src CountingSource
+	switch remoteFormat(rawURL, contentType) {
+	case "json":
+		src, err = NewJSONSourceWithOptions(body, opts)
+	case "csv":
+		src, err = NewCSVSourceWithOptions(body, opts)
+	default:
+		src, err = OpenReader(body, opts)
+	}
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	return closingSource{CountingSource: src, closer: body}, nil
+}
+
+// remoteFormat picks "json" or "csv" for a download, or "" to sniff.
+func remoteFormat(rawURL, contentType string) string {
+	if media, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch {
+		case media == "text/csv":
+			return "csv"
+		case strings.HasSuffix(media, "json"), media == "application/x-ndjson", media == "application/jsonl":
+			return "json"
+		}
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		switch strings.ToLower(path.Ext(u.Path)) {
+		case ".csv", ".tsv", ".txt":
+			return "csv"
+		case ".json", ".ndjson", ".jsonl":
+			return "json"
+		}
+	}
+	return ""
+}
+
+// closingSource closes a download along with the source reading it.
+type closingSource struct {
+	CountingSource
+	closer io.Closer
+}
+
+func (s closingSource) Close() error {
+	return multiCloser{s.CountingSource, s.closer}.Close()
+}
+
+// FetchURL downloads rawURL, enforcing the host allowlist on the URL and on
+// every redirect, and returns the body with its Content-Type.
+func FetchURL(rawURL string, o URLOptions) (io.ReadCloser, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid source URL: %w", err)
+	}
+	if err := o.checkURL(u); err != nil {
+		return nil, "", err
+	}
+
+	var client http.Client
+	if o.Client != nil {
+		client = *o.Client
+	} else {
+		// The download itself may take longer than a response should.
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ResponseHeaderTimeout = sheetTimeout
+		client = http.Client{Transport: transport}
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := o.checkURL(req.URL); err != nil {
+			return fmt.Errorf("redirect blocked: %w", err)
+		}
+		if len(via) >= 3 {
+			return fmt.Errorf("too many redirects")
+		}
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	for k, v := range o.Headers {
+		req.Header.Set(k, v)
+	}
+	if o.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+o.BearerToken)
+	}
+	cache := newURLCache(o.CacheDir, u.String(), req.Header)
+	if etag, ok := cache.etag(); ok {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("error fetching %s: %w", u.Redacted(), err)
+	}
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cache != nil:
+		resp.Body.Close()
+		body, contentType, err := cache.open()
+		if err != nil {
+			return nil, "", fmt.Errorf("cached copy of %s is unreadable: %w", u.Redacted(), err)
+		}
+		log.Printf("Source %s not modified; using cached copy", u.Redacted())
+		return body, contentType, nil
+	case resp.StatusCode != http.StatusOK:
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("source URL returned status: %s", resp.Status)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if etag := resp.Header.Get("ETag"); etag != "" && cache != nil {
+		return cache.store(resp.Body, etag, contentType), contentType, nil
+	}
+	return resp.Body, contentType, nil
+}
+
+// urlCache stores one download and its ETag under CacheDir. A nil cache
+// stores nothing.
+type urlCache struct {
+	base string // path prefix of the cache files
+}
+
+// newURLCache returns the cache of rawURL requested with header, which
+// holds the credentials: each set of headers has its own copy.
+func newURLCache(dir, rawURL string, header http.Header) *urlCache {
+	if dir == "" {
+		return nil
+	}
+	h := sha256.New()
+	h.Write([]byte(rawURL))
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range header[name] {
+			fmt.Fprintf(h, "\n%s: %s", name, v)
+		}
+	}
+	sum := h.Sum(nil)
+	return &urlCache{base: filepath.Join(dir, hex.EncodeToString(sum[:16]))}
+}
+
+// etag returns the stored ETag when a complete copy is stored.
+func (c *urlCache) etag() (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	meta, err := os.ReadFile(c.base + ".etag")
+	if err != nil {
+		return "", false
+	}
+	if _, err := os.Stat(c.base + ".body"); err != nil {
+		return "", false
+	}
+	etag, _, _ := strings.Cut(string(meta), "\n")
+	return etag, etag != ""
+}
+
+func (c *urlCache) open() (io.ReadCloser, string, error) {
+	meta, err := os.ReadFile(c.base + ".etag")
+	if err != nil {
+		return nil, "", err
+	}
+	_, contentType, _ := strings.Cut(string(meta), "\n")
+	f, err := os.Open(c.base + ".body")
+	if err != nil {
+		return nil, "", err
+	}
+	return f, contentType, nil
+}
+
+// store copies body to the cache as it is read. The copy replaces the
+// stored one only once body has been read to the end.
+func (c *urlCache) store(body io.ReadCloser, etag, contentType string) io.ReadCloser {
+	if err := os.MkdirAll(filepath.Dir(c.base), 0o700); err != nil {
+		log.Printf("Warning: Source cache disabled: %v", err)
+		return body
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.base), filepath.Base(c.base)+".*.tmp")
+	if err != nil {
+		log.Printf("Warning: Source cache disabled: %v", err)
+		return body
+	}
+	return &cachingBody{ReadCloser: body, tmp: tmp, cache: c, meta: etag + "\n" + contentType}
+}
+
+// cachingBody tees a download into a temporary cache file.
+type cachingBody struct {
+	io.ReadCloser
+	tmp   *os.File
+	cache *urlCache
+	meta  string
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if b.tmp != nil && n > 0 {
+		if _, werr := b.tmp.Write(p[:n]); werr != nil {
+			log.Printf("Warning: Failed to cache source: %v", werr)
+			b.discard()
+		}
+	}
+	if err == io.EOF && b.tmp != nil {
+		b.commit()
+	}
+	return n, err
+}
+
+func (b *cachingBody) Close() error {
+	b.discard()
+	return b.ReadCloser.Close()
+}
+
+func (b *cachingBody) commit() {
+	tmp := b.tmp
+	b.tmp = nil
+	os.Remove(b.cache.base + ".etag") // never pair an old ETag with a new copy
+	err := tmp.Close()
+	if err == nil {
+		err = os.Rename(tmp.Name(), b.cache.base+".body")
+	}
+	if err == nil {
+		err = os.WriteFile(b.cache.base+".etag", []byte(b.meta), 0o600)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		log.Printf("Warning: Failed to cache source: %v", err)
+	}
+}
+
+func (b *cachingBody) discard() {
+	if b.tmp == nil {
+		return
+	}
+	b.tmp.Close()
+	os.Remove(b.tmp.Name())
+	b.tmp = nil
+}
@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"errors"
+	"io"
+)
+
+// RecipientSource yields recipients one at a time so a list never has to be
+// held in memory as a whole. Next returns io.EOF after the last recipient.
+// Rows come out in source order with increasing Recipient.Row.
+type RecipientSource interface {
+	Next() (Recipient, error)
+	Close() error
+}
+
+// CountingSource is a RecipientSource read from an input format, reporting
+// how many records it has read and how many it skipped as malformed.
+type CountingSource interface {
+	RecipientSource
+	Rows() int
+	Skipped() int
+}
+
+// sliceSource serves an in-memory list.
+type sliceSource struct {
+	recipients []Recipient
+	pos        int
+}
+
+// SliceSource returns a RecipientSource over recipients, numbering rows by
+// their position in the slice.
+func SliceSource(recipients []Recipient) RecipientSource {
+	return &sliceSource{recipients: recipients}
+}
+
+func (s *sliceSource) Next() (Recipient, error) {
+	if s.pos >= len(s.recipients) {
+		return Recipient{}, io.EOF
+	}
+	r := s.recipients[s.pos]
+	r.Row = s.pos
+	s.pos++
+	return r, nil
+}
+
+func (s *sliceSource) Close() error { return nil }
+
+// Collect drains src into a slice. Closing src is left to the caller.
+func Collect(src RecipientSource) ([]Recipient, error) {
+	var out []Recipient
+	for {
+		r, err := src.Next()
+		if errors.Is(err, io.EOF) {
+			return out, nil
+		}
+		if err != nil {
+			return out, err
+		}
+		out = append(out, r)
+	}
+}
+
+// FilteredSource passes on the recipients keep accepts and counts the rest.
+type FilteredSource struct {
+	src     RecipientSource
+	keep    func(Recipient) bool
+	dropped int
+}
+
+// FilterSource wraps src so only recipients for which keep returns true are
+// yielded.
+func FilterSource(src RecipientSource, keep func(Recipient) bool) *FilteredSource {
+	return &FilteredSource{src: src, keep: keep}
+}
+
+func (f *FilteredSource) Next() (Recipient, error) {
+	for {
+		r, err := f.src.Next()
+		if err != nil {
+			return r, err
+		}
+		if f.keep(r) {
+			return r, nil
+		}
+		f.dropped++
+	}
+}
+
+func (f *FilteredSource) Close() error { return f.src.Close() }
+
+// Dropped returns how many recipients were rejected so far.
+func (f *FilteredSource) Dropped() int { return f.dropped }
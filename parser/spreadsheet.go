@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// OpenFile opens a recipient list, choosing the reader by file extension:
+// .xlsx/.xlsm workbooks, .ods spreadsheets, .json/.ndjson/.jsonl JSON, and
+// CSV for anything else. A path of "-" reads standard input, detecting JSON
+// or CSV from the content. opts.Sheet selects the worksheet of a spreadsheet
+// by name or 1-based index and must be empty for other formats; the column
+// mapping applies to every format.
+func OpenFile(path string, opts ReadOptions) (CountingSource, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xlsx", ".xlsm":
+		return OpenXLSX(path, opts)
+	case ".ods":
+		return OpenODS(path, opts)
+	}
+	if opts.Sheet != "" {
+		return nil, fmt.Errorf("sheet selection applies to .xlsx and .ods files only")
+	}
+	if path == "-" {
+		return OpenReader(os.Stdin, opts)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json", ".ndjson", ".jsonl":
+		return OpenJSON(path, opts)
+	}
+	return OpenCSV(path, opts)
+}
+
+// ParseFile reads the recipient list at path, in any format OpenFile
+// accepts, into the same Recipient values ParseCSVFromReader returns.
+func ParseFile(path string, opts ReadOptions) ([]Recipient, error) {
+	src, err := OpenFile(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	kind := strings.ToUpper(strings.TrimPrefix(filepath.Ext(path), "."))
+	if kind == "" {
+		kind = "Input"
+	}
+	return collectTable(src, kind)
+}
+
+// selectSheet resolves a sheet selector against the sheet names in order.
+// An exact name wins, then a case-insensitive one, then a 1-based index.
+// Empty selects the first sheet.
+func selectSheet(names []string, sheet string) (int, error) {
+	if len(names) == 0 {
+		return 0, fmt.Errorf("spreadsheet has no sheets")
+	}
+	if sheet == "" {
+		return 0, nil
+	}
+	for i, n := range names {
+		if n == sheet {
+			return i, nil
+		}
+	}
+	for i, n := range names {
+		if sheetNameMatches(n, sheet) {
+			return i, nil
+		}
+	}
+	if i, err := strconv.Atoi(strings.TrimSpace(sheet)); err == nil {
+		if i < 1 || i > len(names) {
+			return 0, fmt.Errorf("sheet index %d out of range (spreadsheet has %d sheets)", i, len(names))
+		}
+		return i - 1, nil
+	}
+	return 0, fmt.Errorf("sheet %q not found (available: %s)", sheet, strings.Join(names, ", "))
+}
+
+// sheetNameMatches compares sheet names case-insensitively.
+func sheetNameMatches(name, sheet string) bool {
+	return strings.EqualFold(strings.TrimSpace(name), strings.TrimSpace(sheet))
+}
+
+// decodeZipXML unmarshals the XML part f of a zip archive into v.
+func decodeZipXML(f *zip.File, v interface{}) error {
+	if f == nil {
+		return fmt.Errorf("missing part")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// unexpectedEOF reports input that ends inside an element as truncated.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// multiCloser closes each closer in order and returns the first error.
+type multiCloser []io.Closer
+
+func (m multiCloser) Close() error {
+	var first error
+	for _, c := range m {
+		if c == nil {
+			continue
+		}
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
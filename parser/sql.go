@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+
+	_ "github.com/go-sql-driver/mysql" // registers "mysql"
+	_ "github.com/lib/pq"              // registers "postgres"
+	_ "modernc.org/sqlite"             // registers "sqlite"
+)
+
+// SQLDriver maps a driver name from the config file to the database/sql
+// driver registered for it: sqlite, postgres or mysql, plus common aliases.
+func SQLDriver(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "sqlite", "sqlite3":
+		return "sqlite", nil
+	case "postgres", "postgresql", "pg":
+		return "postgres", nil
+	case "mysql", "mariadb":
+		return "mysql", nil
+	case "":
+		return "", fmt.Errorf("database driver is required (sqlite, postgres or mysql)")
+	}
+	return "", fmt.Errorf("unsupported database driver %q (use sqlite, postgres or mysql)", name)
+}
+
+// OpenSQL runs the SELECT query against the database at dsn and streams the
+// result rows as recipients, exactly as if they were rows of a CSV file with
+// the result columns as headers: one column must be named 'email' (after
+// the column mapping of opts) and NULL reads as empty. Rows are fetched as
+// they are consumed. Closing the source closes the connection.
+func OpenSQL(driver, dsn, query string, opts ReadOptions) (*TableSource, error) {
+	name, err := SQLDriver(driver)
+	if err != nil {
+		return nil, err
+	}
+	if !isSelect(query) {
+		return nil, fmt.Errorf("query must be a SELECT statement")
+	}
+
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	rows, err := db.QueryContext(context.Background(), query)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to run query: %w", err)
+	}
+	reader, err := newSQLRows(rows)
+	if err != nil {
+		rows.Close()
+		db.Close()
+		return nil, err
+	}
+	src, err := newTableSource(reader, "query result", false, opts)
+	if err != nil {
+		rows.Close()
+		db.Close()
+		return nil, err
+	}
+	src.closer = multiCloser{rows, db}
+	return src, nil
+}
+
+// isSelect reports whether query reads rather than writes, judged by its
+// first keyword. Leading comments are not skipped.
+func isSelect(query string) bool {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return false
+	}
+	switch strings.ToLower(strings.TrimLeft(fields[0], "(")) {
+	case "select", "with":
+		return true
+	}
+	return false
+}
+
+// sqlRows adapts query results to rowReader: the column names first, then
+// each row as text.
+type sqlRows struct {
+	rows    *sql.Rows
+	header  []string
+	values  []sql.NullString
+	dest    []any
+	record  []string
+	started bool
+}
+
+func newSQLRows(rows *sql.Rows) (*sqlRows, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read query columns: %w", err)
+	}
+	s := &sqlRows{
+		rows:   rows,
+		header: columns,
+		values: make([]sql.NullString, len(columns)),
+		dest:   make([]any, len(columns)),
+		record: make([]string, len(columns)),
+	}
+	for i := range s.values {
+		s.dest[i] = &s.values[i]
+	}
+	return s, nil
+}
+
+func (s *sqlRows) Read() ([]string, error) {
+	if !s.started {
+		s.started = true
+		return s.header, nil
+	}
+	if !s.rows.Next() {
+		if err := s.rows.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read query results: %w", err)
+		}
+		return nil, io.EOF
+	}
+	if err := s.rows.Scan(s.dest...); err != nil {
+		return nil, fmt.Errorf("failed to read query row: %w", err)
+	}
+	for i, v := range s.values {
+		s.record[i] = v.String // empty when NULL
+	}
+	return s.record, nil
+}
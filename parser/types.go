@@ -3,4 +3,25 @@ package parser
 type Recipient struct {
 	Email string
 	Data  map[string]string
+	// Fields holds structured values keyed by top-level name, such as nested
+	// objects and arrays from JSON input, for templates to walk and range
+	// over. Data carries the same values flattened to dotted keys.
+	Fields map[string]any
+	// Row is the 0-based position of the record among the data rows of its
+	// source, counting rows that were skipped. Resume offsets refer to it.
+	Row int
+}
+
+// TemplateData returns the values a template sees: email, every Data field
+// and, in place of their flattened forms, the structured Fields.
+func (r Recipient) TemplateData() map[string]any {
+	data := make(map[string]any, len(r.Data)+len(r.Fields)+1)
+	data["email"] = r.Email
+	for k, v := range r.Data {
+		data[k] = v
+	}
+	for k, v := range r.Fields {
+		data[k] = v
+	}
+	return data
 }
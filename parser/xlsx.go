@@ -0,0 +1,466 @@
+package parser
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenXLSX opens a worksheet of the Excel workbook at path as a streaming
+// source. opts.Sheet selects it by name or 1-based index; empty selects the
+// first worksheet. Closing the source closes the file.
+func OpenXLSX(filePath string, opts ReadOptions) (*TableSource, error) {
+	zr, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workbook: %w", err)
+	}
+	src, err := newXLSXSource(&zr.Reader, opts)
+	if err != nil {
+		zr.Close()
+		return nil, err
+	}
+	src.closer = multiCloser{src.closer, zr}
+	return src, nil
+}
+
+// NewXLSXSource returns a source over a worksheet of the Excel workbook in
+// r. See OpenXLSX for sheet selection.
+func NewXLSXSource(r io.ReaderAt, size int64, opts ReadOptions) (*TableSource, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open workbook: %w", err)
+	}
+	return newXLSXSource(zr, opts)
+}
+
+func newXLSXSource(zr *zip.Reader, opts ReadOptions) (*TableSource, error) {
+	sheet := opts.Sheet
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[strings.TrimPrefix(f.Name, "/")] = f
+	}
+
+	wb, err := readXLSXWorkbook(files)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(wb.Sheets))
+	for i, s := range wb.Sheets {
+		names[i] = s.Name
+	}
+	idx, err := selectSheet(names, sheet)
+	if err != nil {
+		return nil, err
+	}
+	target, ok := wb.targets[wb.Sheets[idx].RelID]
+	if !ok {
+		return nil, fmt.Errorf("workbook has no part for sheet %q", names[idx])
+	}
+	sheetFile, ok := files[target]
+	if !ok {
+		return nil, fmt.Errorf("workbook is missing %s", target)
+	}
+
+	shared, err := readXLSXSharedStrings(files["xl/sharedStrings.xml"])
+	if err != nil {
+		return nil, err
+	}
+	styles, err := readXLSXStyles(files["xl/styles.xml"])
+	if err != nil {
+		return nil, err
+	}
+
+	rc, err := sheetFile.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sheet %q: %w", names[idx], err)
+	}
+	rows := &xlsxRows{dec: xml.NewDecoder(rc), shared: shared, styles: styles, date1904: wb.Properties.Date1904}
+	src, err := newTableSource(rows, "spreadsheet", true, opts)
+	if err != nil {
+		rc.Close()
+		if err == io.EOF {
+			return nil, fmt.Errorf("sheet %q is empty", names[idx])
+		}
+		return nil, err
+	}
+	src.closer = rc
+	return src, nil
+}
+
+// xlsxWorkbook is the part of xl/workbook.xml needed to find worksheets.
+type xlsxWorkbook struct {
+	Properties struct {
+		Date1904 bool `xml:"date1904,attr"`
+	} `xml:"workbookPr"`
+	Sheets []struct {
+		Name  string `xml:"name,attr"`
+		RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+
+	targets map[string]string // relationship ID → zip path
+}
+
+func readXLSXWorkbook(files map[string]*zip.File) (*xlsxWorkbook, error) {
+	var wb xlsxWorkbook
+	if err := decodeZipXML(files["xl/workbook.xml"], &wb); err != nil {
+		return nil, fmt.Errorf("not an Excel workbook: %w", err)
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := decodeZipXML(files["xl/_rels/workbook.xml.rels"], &rels); err != nil {
+		return nil, fmt.Errorf("not an Excel workbook: %w", err)
+	}
+	wb.targets = make(map[string]string, len(rels.Relationships))
+	for _, r := range rels.Relationships {
+		target := r.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		wb.targets[r.ID] = target
+	}
+	return &wb, nil
+}
+
+// readXLSXSharedStrings loads the shared string table. Rich-text runs are
+// concatenated; phonetic hints are dropped.
+func readXLSXSharedStrings(f *zip.File) ([]string, error) {
+	if f == nil {
+		return nil, nil
+	}
+	var sst struct {
+		Items []struct {
+			T    string `xml:"t"`
+			Runs []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := decodeZipXML(f, &sst); err != nil {
+		return nil, fmt.Errorf("failed to read shared strings: %w", err)
+	}
+	out := make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		if len(si.Runs) == 0 {
+			out[i] = si.T
+			continue
+		}
+		var b strings.Builder
+		for _, r := range si.Runs {
+			b.WriteString(r.T)
+		}
+		out[i] = b.String()
+	}
+	return out, nil
+}
+
+// xlsxStyle is how a cell style formats numbers.
+type xlsxStyle struct {
+	date  bool // numbers are date serials
+	zeros int  // integers are zero-padded to this many digits, as by "00000"
+}
+
+// readXLSXStyles reports, per cell style index, how numbers in that style
+// are formatted.
+func readXLSXStyles(f *zip.File) ([]xlsxStyle, error) {
+	if f == nil {
+		return nil, nil
+	}
+	var styles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	if err := decodeZipXML(f, &styles); err != nil {
+		return nil, fmt.Errorf("failed to read styles: %w", err)
+	}
+	custom := make(map[int]string, len(styles.NumFmts))
+	for _, nf := range styles.NumFmts {
+		custom[nf.ID] = nf.Code
+	}
+	out := make([]xlsxStyle, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		code, ok := custom[xf.NumFmtID]
+		switch {
+		case !ok:
+			out[i].date = isBuiltinDateFormat(xf.NumFmtID)
+		case code != "" && strings.Trim(code, "0") == "":
+			// zip codes, account numbers and other fixed-width IDs
+			out[i].zeros = len(code)
+		default:
+			out[i].date = isDateFormat(code)
+		}
+	}
+	return out, nil
+}
+
+// isBuiltinDateFormat reports whether a built-in number format ID is a date
+// or time format.
+func isBuiltinDateFormat(id int) bool {
+	return (id >= 14 && id <= 22) || (id >= 27 && id <= 36) || (id >= 45 && id <= 47) || (id >= 50 && id <= 58)
+}
+
+// isDateFormat reports whether a custom number format code formats dates or
+// times: it has a date/time token outside quoted text, escapes and
+// bracketed modifiers such as colours.
+func isDateFormat(code string) bool {
+	inQuote := false
+	for i := 0; i < len(code); i++ {
+		c := code[i]
+		switch {
+		case inQuote:
+			inQuote = c != '"'
+		case c == '"':
+			inQuote = true
+		case c == '[':
+			end := strings.IndexByte(code[i:], ']')
+			if end < 0 {
+				return false
+			}
+			// elapsed time such as [h]:mm; anything else is a colour,
+			// condition or locale
+			if inner := strings.ToLower(code[i+1 : i+end]); inner != "" && strings.Trim(inner, "hms") == "" {
+				return true
+			}
+			i += end
+		case c == '\\' || c == '_' || c == '*':
+			i++ // escaped or padding character
+		default:
+			switch c {
+			case 'd', 'D', 'm', 'M', 'y', 'Y', 'h', 'H', 's', 'S':
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// xlsxRows reads the rows of one worksheet as a token stream, so the sheet
+// is never held in memory as a whole.
+type xlsxRows struct {
+	dec      *xml.Decoder
+	shared   []string
+	styles   []xlsxStyle
+	date1904 bool
+	record   []string
+}
+
+// Read returns the cells of the next row. Empty rows are not stored in the
+// file and so are never returned.
+func (x *xlsxRows) Read() ([]string, error) {
+	for {
+		tok, err := x.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "row" {
+			return x.readRow()
+		}
+	}
+}
+
+func (x *xlsxRows) readRow() ([]string, error) {
+	x.record = x.record[:0]
+	for {
+		tok, err := x.dec.Token()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Local != "c" {
+				if err := x.dec.Skip(); err != nil {
+					return nil, unexpectedEOF(err)
+				}
+				continue
+			}
+			col, value, err := x.readCell(t)
+			if err != nil {
+				return nil, err
+			}
+			if col < 0 {
+				col = len(x.record)
+			}
+			for len(x.record) < col {
+				x.record = append(x.record, "")
+			}
+			if col < len(x.record) {
+				x.record[col] = value
+			} else {
+				x.record = append(x.record, value)
+			}
+		case xml.EndElement:
+			return x.record, nil
+		}
+	}
+}
+
+// readCell decodes one <c> element into its column index (-1 when the cell
+// has no reference) and display value.
+func (x *xlsxRows) readCell(start xml.StartElement) (int, string, error) {
+	col := -1
+	var typ string
+	style := 0
+	for _, a := range start.Attr {
+		switch a.Name.Local {
+		case "r":
+			col = columnIndex(a.Value)
+		case "t":
+			typ = a.Value
+		case "s":
+			style, _ = strconv.Atoi(a.Value)
+		}
+	}
+
+	var v strings.Builder
+	for {
+		tok, err := x.dec.Token()
+		if err != nil {
+			return 0, "", unexpectedEOF(err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "v", "t":
+				var text string
+				if err := x.dec.DecodeElement(&text, &t); err != nil {
+					return 0, "", err
+				}
+				v.WriteString(text)
+			case "is", "r":
+				// inline string container; its <t> children are read above
+			default:
+				if err := x.dec.Skip(); err != nil {
+					return 0, "", unexpectedEOF(err)
+				}
+			}
+		case xml.EndElement:
+			if t.Name.Local == "c" {
+				return col, x.cellValue(typ, style, v.String()), nil
+			}
+		}
+	}
+}
+
+func (x *xlsxRows) cellValue(typ string, style int, raw string) string {
+	switch typ {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || i < 0 || i >= len(x.shared) {
+			return ""
+		}
+		return x.shared[i]
+	case "b":
+		if strings.TrimSpace(raw) == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "inlineStr", "str", "e", "d":
+		return raw
+	}
+	if raw == "" {
+		return ""
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return raw
+	}
+	if style < 0 || style >= len(x.styles) {
+		return formatNumber(f)
+	}
+	switch st := x.styles[style]; {
+	case st.date:
+		return formatSerialDate(f, x.date1904)
+	case st.zeros > 0:
+		return formatZeroPadded(f, st.zeros)
+	}
+	return formatNumber(f)
+}
+
+// columnIndex converts the letters of a cell reference such as "AB12" to a
+// 0-based column index.
+func columnIndex(ref string) int {
+	col := 0
+	n := 0
+	for _, c := range ref {
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A'+1)
+		n++
+	}
+	if n == 0 {
+		return -1
+	}
+	return col - 1
+}
+
+// formatNumber renders a spreadsheet number the way it is usually shown:
+// integers without a fraction, others to 15 significant digits, dropping
+// binary floating-point noise.
+func formatNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// formatZeroPadded renders f rounded to an integer with at least digits
+// digits, the way a "00000" number format shows it.
+func formatZeroPadded(f float64, digits int) string {
+	f = math.Round(f)
+	if math.Abs(f) >= 1e15 {
+		return formatNumber(f)
+	}
+	n := strconv.FormatInt(int64(math.Abs(f)), 10)
+	if len(n) < digits {
+		n = strings.Repeat("0", digits-len(n)) + n
+	}
+	if f < 0 {
+		return "-" + n
+	}
+	return n
+}
+
+// formatSerialDate converts an Excel date serial to "2006-01-02", adding
+// the time of day when it has one ("15:04:05" alone for pure times).
+func formatSerialDate(serial float64, date1904 bool) string {
+	base := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	if date1904 {
+		base = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+	} else if serial < 61 {
+		// Excel treats 1900 as a leap year; serials before the
+		// non-existent 29 February are one day off.
+		base = base.AddDate(0, 0, 1)
+	}
+	days := math.Floor(serial)
+	secs := math.Round((serial - days) * 86400)
+	t := base.AddDate(0, 0, int(days)).Add(time.Duration(secs) * time.Second)
+	switch {
+	case days == 0 && secs > 0:
+		return t.Format("15:04:05")
+	case secs > 0:
+		return t.Format("2006-01-02 15:04:05")
+	default:
+		return t.Format("2006-01-02")
+	}
+}
@@ -114,6 +114,18 @@ func (sm *SchedulerManager) ScheduleJob(args types.CLIArgs, runAt time.Time, cro
 	return nil
 }
 
+// ReattachHandlers makes handler run the stored jobs that have none in this
+// process, including jobs other processes store later.
+func (sm *SchedulerManager) ReattachHandlers(handler JobHandler) error {
+	if err := sm.ensureStarted(); err != nil {
+		return fmt.Errorf("failed to start scheduler: %w", err)
+	}
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	sm.scheduler.ReattachHandlers(handler)
+	return nil
+}
+
 // ListJobs returns all scheduled jobs
 func (sm *SchedulerManager) ListJobs() ([]types.Job, error) {
 	sm.mu.Lock()
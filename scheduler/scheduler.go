@@ -29,6 +29,7 @@ type Scheduler struct {
 	log        Logger
 	mu         sync.RWMutex
 	handlers   map[string]JobHandler
+	fallback   JobHandler // runs stored jobs without a handler; set by ReattachHandlers
 	jobsCache  map[string]types.Job
 	quit       chan struct{}
 	wg         sync.WaitGroup
@@ -149,10 +150,12 @@ func (s *Scheduler) ListJobs() ([]types.Job, error) {
 	return s.db.LoadJobs()
 }
 
-// ReattachHandlers sets a default handler for all existing jobs that do not have one in-memory.
+// ReattachHandlers sets a default handler for all existing jobs that do not have one in-memory,
+// and for jobs stored later by other processes, such as the rollout of an A/B test.
 func (s *Scheduler) ReattachHandlers(handler JobHandler) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.fallback = handler
 	jobs, err := s.db.LoadJobs()
 	if err != nil {
 		s.log.Errorf("reattach: load jobs: %v", err)
@@ -240,6 +243,9 @@ func (s *Scheduler) execute(job types.Job) {
 
 	s.mu.RLock()
 	handler := s.handlers[job.ID]
+	if handler == nil {
+		handler = s.fallback
+	}
 	s.mu.RUnlock()
 	if handler == nil {
 		s.log.Warnf("no handler for job %s — marking failed", job.ID)
@@ -0,0 +1,115 @@
+package cli_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func TestParseVariants(t *testing.T) {
+	one, err := cli.ParseVariants(cli.CLIArgs{Subject: "Hi", TemplatePath: "a.html"})
+	if err != nil || len(one) != 1 || one[0].Name != "" || one[0].Subject != "Hi" {
+		t.Fatalf("single version = %+v, %v", one, err)
+	}
+
+	// A --template given once is shared by every --subject variant.
+	vs, err := cli.ParseVariants(cli.CLIArgs{
+		Subject: "One", SubjectVariants: []string{"Two", "Three"},
+		TemplatePath: "a.html", VariantWeights: []int{1, 2, 3},
+	})
+	if err != nil {
+		t.Fatalf("ParseVariants: %v", err)
+	}
+	for i, want := range []cli.Variant{
+		{Name: "A", Subject: "One", TemplatePath: "a.html", Weight: 1},
+		{Name: "B", Subject: "Two", TemplatePath: "a.html", Weight: 2},
+		{Name: "C", Subject: "Three", TemplatePath: "a.html", Weight: 3},
+	} {
+		if vs[i] != want {
+			t.Errorf("variant %d = %+v, want %+v", i, vs[i], want)
+		}
+	}
+
+	for name, args := range map[string]cli.CLIArgs{
+		"mismatched counts": {Subject: "A", SubjectVariants: []string{"B"}, TemplatePath: "a", TemplateVariants: []string{"b", "c"}},
+		"weights count":     {Subject: "A", SubjectVariants: []string{"B"}, VariantWeights: []int{1}},
+		"zero weight":       {Subject: "A", SubjectVariants: []string{"B"}, VariantWeights: []int{1, 0}},
+		"weights alone":     {Subject: "A", VariantWeights: []int{1}},
+	} {
+		if _, err := cli.ParseVariants(args); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestAssignVariant(t *testing.T) {
+	variants := []cli.Variant{{Name: "A", Weight: 1}, {Name: "B", Weight: 3}}
+	if a, b := cli.AssignVariant(variants, "Alice@Example.com"), cli.AssignVariant(variants, "alice@example.com"); a != b {
+		t.Errorf("variant depends on case: %d != %d", a, b)
+	}
+
+	// Shares follow the weights: B gets about three quarters.
+	counts := make([]int, len(variants))
+	for i := 0; i < 10000; i++ {
+		counts[cli.AssignVariant(variants, fmt.Sprintf("user%d@example.com", i))]++
+	}
+	if counts[1] < 7200 || counts[1] > 7800 {
+		t.Errorf("variant B got %d of 10000, want about 7500", counts[1])
+	}
+}
+
+func TestStreamVariantTasks(t *testing.T) {
+	variants := []cli.Variant{{Name: "A", Subject: "Hi {{ .name }}", Weight: 1}, {Name: "B", Subject: "Hello {{ .name }}", Weight: 1}}
+	var recipients []parser.Recipient
+	for i := 0; i < 20; i++ {
+		recipients = append(recipients, parser.Recipient{Email: fmt.Sprintf("user%d@example.com", i), Data: map[string]string{"name": "Sam"}})
+	}
+
+	taskCh, errCh := cli.StreamVariantTasks(context.Background(), parser.SliceSource(recipients), variants, "", nil, nil, nil, 0, 0)
+	seen := map[string]bool{}
+	for task := range taskCh {
+		want := map[string]string{"A": "Hi Sam", "B": "Hello Sam"}[task.Variant]
+		if task.Subject != want {
+			t.Errorf("%s: variant %q subject %q, want %q", task.Recipient.Email, task.Variant, task.Subject, want)
+		}
+		if v := variants[cli.AssignVariant(variants, task.Recipient.Email)].Name; task.Variant != v {
+			t.Errorf("%s: tagged %q, assigned %q", task.Recipient.Email, task.Variant, v)
+		}
+		seen[task.Variant] = true
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("StreamVariantTasks error: %v", err)
+	}
+	if !seen["A"] || !seen["B"] {
+		t.Errorf("variants sent = %v, want both", seen)
+	}
+}
+
+func TestPickWinner(t *testing.T) {
+	results := []cli.VariantResult{
+		{Variant: "A", Recipients: 100, Opened: 30, Clicked: 2},
+		{Variant: "B", Recipients: 50, Opened: 10, Clicked: 5},
+		{Variant: "C", Recipients: 50, Opened: 15, Clicked: 1},
+	}
+	for metric, want := range map[string]string{cli.MetricOpens: "A", cli.MetricClicks: "B"} {
+		if got, err := cli.PickWinner(results, metric); err != nil || got != want {
+			t.Errorf("PickWinner(%s) = %q, %v; want %q", metric, got, err, want)
+		}
+	}
+
+	// A tie goes to the variant named first.
+	tied := []cli.VariantResult{{Variant: "A", Recipients: 10, Opened: 1}, {Variant: "B", Recipients: 10, Opened: 1}}
+	if got, _ := cli.PickWinner(tied, cli.MetricOpens); got != "A" {
+		t.Errorf("tie went to %q, want A", got)
+	}
+
+	if _, err := cli.PickWinner([]cli.VariantResult{{Variant: "A", Recipients: 10}}, cli.MetricOpens); err == nil {
+		t.Error("picked a winner without tracked opens")
+	}
+	if _, err := cli.PickWinner(results, cli.MetricManual); err == nil {
+		t.Error("picked a winner for the manual metric")
+	}
+}
@@ -0,0 +1,123 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+	"github.com/bravo1goingdark/mailgrid/database"
+	"github.com/bravo1goingdark/mailgrid/email"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// dsnFor builds an RFC 3464 bounce delivered to returnPath.
+func dsnFor(returnPath, finalRcpt, status string) string {
+	return `From: MAILER-DAEMON@mx.example.net
+To: ` + returnPath + `
+Subject: Undelivered Mail Returned to Sender
+Content-Type: multipart/report; report-type=delivery-status; boundary="B"
+
+--B
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.net
+
+Final-Recipient: rfc822; ` + finalRcpt + `
+Action: failed
+Status: ` + status + `
+
+--B--
+`
+}
+
+func TestIngestBounces(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.NewDB(filepath.Join(dir, "mailgrid.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	jobID := "mailgrid-1700000000"
+	if err := db.SaveCampaign(&types.Campaign{ID: jobID, Tag: email.CampaignTag(jobID), StartedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveCampaign: %v", err)
+	}
+
+	// alice: hard bounce via VERP, reported under a forwarded final address.
+	// bob: soft bounce (mailbox full) via VERP.
+	mbox := "From MAILER-DAEMON Mon Jan  1 00:00:00 2024\n" +
+		dsnFor(email.VERPAddress("bounce.example.com", jobID, "alice@example.com"), "alice@forwarded.example", "5.1.1") +
+		"\nFrom MAILER-DAEMON Mon Jan  1 00:00:01 2024\n" +
+		dsnFor(email.VERPAddress("bounce.example.com", jobID, "bob@example.com"), "bob@example.com", "4.2.2") +
+		"\nFrom someone Mon Jan  1 00:00:02 2024\nFrom: a@example.com\nSubject: hi\n\nnot a bounce\n"
+	path := filepath.Join(dir, "bounces.mbox")
+	if err := os.WriteFile(path, []byte(mbox), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := cli.IngestBounces(db, path)
+	if err != nil {
+		t.Fatalf("IngestBounces: %v", err)
+	}
+	if sum.Messages != 3 || sum.Bounces != 2 || sum.Hard != 1 || sum.Soft != 1 || sum.NotBounces != 1 || sum.Unmatched != 0 || sum.Suppressed != 1 {
+		t.Errorf("unexpected summary: %+v", sum)
+	}
+
+	campaign, err := db.GetCampaign(jobID)
+	if err != nil {
+		t.Fatalf("GetCampaign: %v", err)
+	}
+	if campaign.HardBounces != 1 || campaign.SoftBounces != 1 {
+		t.Errorf("campaign counters = %d hard / %d soft", campaign.HardBounces, campaign.SoftBounces)
+	}
+
+	suppressed, err := db.LoadSuppressions()
+	if err != nil {
+		t.Fatalf("LoadSuppressions: %v", err)
+	}
+	if len(suppressed) != 1 || suppressed[0].Email != "alice@example.com" || suppressed[0].Source != jobID {
+		t.Errorf("suppressions = %+v", suppressed)
+	}
+
+	// Ingesting the same mailbox again must not double count.
+	sum, err = cli.IngestBounces(db, path)
+	if err != nil {
+		t.Fatalf("second IngestBounces: %v", err)
+	}
+	if sum.Suppressed != 0 {
+		t.Errorf("re-ingest suppressed %d new addresses", sum.Suppressed)
+	}
+	campaign, _ = db.GetCampaign(jobID)
+	if campaign.HardBounces != 1 || campaign.SoftBounces != 1 {
+		t.Errorf("re-ingest changed counters: %d hard / %d soft", campaign.HardBounces, campaign.SoftBounces)
+	}
+}
+
+func TestSuppressRecipients(t *testing.T) {
+	recipients := []parser.Recipient{{Email: "Alice@Example.com"}, {Email: "bob@example.com"}, {Email: "carol@example.com"}}
+	suppressed := []types.Suppression{
+		{Email: "alice@example.com", Reason: "hard-bounce"},
+		{Email: "alice@example.com", Scope: "newsletter", Reason: "manual"},
+		{Email: "bob@example.com", Scope: "newsletter", Reason: "unsubscribe"},
+	}
+
+	kept, summary := cli.SuppressRecipients(recipients, suppressed, "")
+	if summary.Total != 1 || len(kept) != 2 || summary.Scoped != 0 {
+		t.Errorf("no list: kept=%+v summary=%+v", kept, summary)
+	}
+
+	kept, summary = cli.SuppressRecipients(recipients, suppressed, "newsletter")
+	if len(kept) != 1 || !strings.EqualFold(kept[0].Email, "carol@example.com") {
+		t.Errorf("newsletter: kept=%+v", kept)
+	}
+	if summary.Total != 2 || summary.Scoped != 1 || summary.ByReason["hard-bounce"] != 1 || summary.ByReason["unsubscribe"] != 1 {
+		t.Errorf("newsletter: summary=%+v", summary)
+	}
+	if got := summary.String(); got != "2 (hard-bounce: 1, unsubscribe: 1)" {
+		t.Errorf("String() = %q", got)
+	}
+}
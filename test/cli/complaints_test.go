@@ -0,0 +1,112 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+	"github.com/bravo1goingdark/mailgrid/database"
+	"github.com/bravo1goingdark/mailgrid/email"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+)
+
+// arfFor builds an RFC 5965 feedback report about a message with the given
+// Message-ID and X-Campaign-ID, as a provider that redacts the recipient.
+func arfFor(feedbackType, rcptTo, messageID, campaignID string) string {
+	return `From: fbl@isp.example
+To: abuse@example.com
+Subject: FW: Hello
+Content-Type: multipart/report; report-type=feedback-report; boundary="F"
+
+--F
+Content-Type: text/plain
+
+This is an abuse report.
+
+--F
+Content-Type: message/feedback-report
+
+Feedback-Type: ` + feedbackType + `
+User-Agent: ISP-FBL/1.0
+Version: 1
+Original-Rcpt-To: ` + rcptTo + `
+
+--F
+Content-Type: text/rfc822-headers
+
+From: news@example.com
+To: [redacted]
+Message-ID: ` + messageID + `
+X-Campaign-ID: ` + campaignID + `
+Subject: Hello
+
+--F--
+`
+}
+
+func TestIngestComplaints(t *testing.T) {
+	dir := t.TempDir()
+	db, err := database.NewDB(filepath.Join(dir, "mailgrid.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	jobID := "mailgrid-1700000000"
+	if err := db.SaveCampaign(&types.Campaign{ID: jobID, Tag: email.CampaignTag(jobID), StartedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveCampaign: %v", err)
+	}
+
+	// carol: recipient only recoverable from mailgrid's Message-ID.
+	// dave: not-spam report, ignored.
+	// erin: foreign Message-ID, known only from Original-Rcpt-To and X-Campaign-ID.
+	mbox := "From fbl Mon Jan  1 00:00:00 2024\n" +
+		arfFor("abuse", "redacted", email.MessageID(jobID, "Carol@example.com", "news@example.com"), jobID) +
+		"\nFrom fbl Mon Jan  1 00:00:01 2024\n" +
+		arfFor("not-spam", "dave@example.com", email.MessageID(jobID, "dave@example.com", "news@example.com"), jobID) +
+		"\nFrom fbl Mon Jan  1 00:00:02 2024\n" +
+		arfFor("abuse", "erin@example.com", "<other@example.com>", "mailgrid-42")
+	path := filepath.Join(dir, "fbl.mbox")
+	if err := os.WriteFile(path, []byte(mbox), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := cli.IngestComplaints(db, path)
+	if err != nil {
+		t.Fatalf("IngestComplaints: %v", err)
+	}
+	if sum.Messages != 3 || sum.Complaints != 2 || sum.NotSpam != 1 || sum.Unmatched != 0 || sum.Suppressed != 2 {
+		t.Errorf("unexpected summary: %+v", sum)
+	}
+
+	campaign, err := db.GetCampaign(jobID)
+	if err != nil || campaign.Complaints != 1 {
+		t.Errorf("campaign complaints = %+v, %v", campaign, err)
+	}
+	events, _ := db.LoadComplaints(jobID)
+	if len(events) != 1 || events[0].Email != "carol@example.com" || events[0].Reporter != "ISP-FBL/1.0" {
+		t.Errorf("complaint events = %+v", events)
+	}
+	if other, err := db.GetCampaign("mailgrid-42"); err != nil || other.Complaints != 1 {
+		t.Errorf("X-Campaign-ID campaign = %+v, %v", other, err)
+	}
+
+	suppressed, _ := db.LoadSuppressions()
+	reasons := map[string]string{}
+	for _, s := range suppressed {
+		reasons[s.Email] = s.Reason
+	}
+	if len(suppressed) != 2 || reasons["carol@example.com"] != "complaint" || reasons["erin@example.com"] != "complaint" {
+		t.Errorf("suppressions = %+v", suppressed)
+	}
+
+	// Re-ingesting must not double count.
+	if sum, err = cli.IngestComplaints(db, path); err != nil || sum.Suppressed != 0 {
+		t.Errorf("re-ingest: %+v, %v", sum, err)
+	}
+	if campaign, _ = db.GetCampaign(jobID); campaign.Complaints != 1 {
+		t.Errorf("re-ingest changed the counter to %d", campaign.Complaints)
+	}
+}
@@ -0,0 +1,92 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func TestExplainFilter(t *testing.T) {
+	input := "email,tier,age\n" +
+		"ann@acme.com,gold,41\n" +
+		"ben@acme.com,silver,52\n" +
+		"cy@beta.io,gold,19\n" +
+		"dee@beta.io,bronze,\n" +
+		"eve@acme.com,gold,35\n"
+	src, err := parser.NewCSVSource(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]parser.ColumnType{"age": parser.TypeInt}
+
+	report, err := cli.ExplainFilter(src, `tier == "Gold" && age >= 30 && domain(email) == "acme.com"`, types, 1)
+	if err != nil {
+		t.Fatalf("ExplainFilter: %v", err)
+	}
+	if report.Rows != 5 || report.Matched != 2 {
+		t.Errorf("rows=%d matched=%d, want 5 and 2", report.Rows, report.Matched)
+	}
+	want := []cli.ClauseStats{
+		{Clause: `tier == "Gold"`, Failed: 2, Eliminated: 2},
+		{Clause: `age >= 30`, Failed: 2, Eliminated: 1},
+		{Clause: `domain(email) == "acme.com"`, Failed: 2, Eliminated: 0},
+	}
+	for i, c := range report.Clauses {
+		if i >= len(want) || c != want[i] {
+			t.Errorf("clause %d = %+v, want %+v", i, c, want)
+		}
+	}
+	if len(report.Matches) != 1 || report.Matches[0].Email != "ann@acme.com" {
+		t.Errorf("matches = %+v, want only ann", report.Matches)
+	}
+	if len(report.Unmatched) != 1 || report.Unmatched[0] != (cli.ExplainedRecipient{Row: 2, Email: "ben@acme.com", Failed: `tier == "Gold"`}) {
+		t.Errorf("unmatched = %+v, want only ben, failing the tier clause", report.Unmatched)
+	}
+
+	var out bytes.Buffer
+	report.WriteText(&out)
+	if !strings.Contains(out.String(), "2 of 5 recipients matched (40.0%)") {
+		t.Errorf("report does not state the match count:\n%s", out.String())
+	}
+}
+
+func TestRun_FilterColumnTypes(t *testing.T) {
+	dir := t.TempDir()
+	env := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(env, []byte(`{"smtp":{"host":"127.0.0.1","port":1,"username":"u","password":"p","from":"f@example.com"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	csv := filepath.Join(dir, "list.csv")
+	if err := os.WriteFile(csv, []byte("email,zip,active,salary,company,age\nann@example.com,12345,true,60000,Beta,41\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	run := func(filter string, types ...string) error {
+		return cli.Run(cli.CLIArgs{EnvPath: env, CSVPath: csv, Subject: "Hi", Text: "Hi", Concurrency: 1, BatchSize: 1,
+			Filter: filter, ColumnTypes: types, FilterExplain: true})
+	}
+
+	// Undeclared columns are strings, whatever their values look like.
+	for _, filter := range []string{`zip == "12345"`, `active == "true"`, `salary > "50000"`, `company != "Acme" && salary > "50000"`} {
+		if err := run(filter); err != nil {
+			t.Errorf("%s: %v", filter, err)
+		}
+	}
+
+	if err := run(`age >= 18`); err == nil || !strings.Contains(err.Error(), "--column-types auto") {
+		t.Errorf("number comparison on a string column: err = %v, want a hint to declare it", err)
+	}
+	for _, types := range [][]string{{"age=int"}, {"zip=string,auto"}} {
+		if err := run(`age >= 18 && zip == "12345"`, types...); err != nil {
+			t.Errorf("--column-types %v: %v", types, err)
+		}
+	}
+	// Inference is opt-in: it types the zip code as a number.
+	if err := run(`zip == "12345"`, "auto"); err == nil || !strings.Contains(err.Error(), "types inferred from the data") {
+		t.Errorf("--column-types auto: err = %v, want an inferred type mismatch", err)
+	}
+}
@@ -45,3 +45,19 @@ func TestParseFlags_Defaults(t *testing.T) {
 		t.Fatalf("expected no attachments by default")
 	}
 }
+
+func TestParseFlags_RepeatedCSVJoins(t *testing.T) {
+	old := os.Args
+	defer func() { os.Args = old }()
+	os.Args = []string{"mailgrid", "--csv", "master.csv", "-f", "coupons.csv", "--csv", "extra.csv", "--join", "inner", "--join-conflict", "last"}
+	pflag.CommandLine = pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
+
+	args := cli.ParseFlags()
+
+	if args.CSVPath != "master.csv" || len(args.JoinPaths) != 2 || args.JoinPaths[0] != "coupons.csv" || args.JoinPaths[1] != "extra.csv" {
+		t.Fatalf("csv paths mismatch: %q %+v", args.CSVPath, args.JoinPaths)
+	}
+	if args.JoinMode != "inner" || args.JoinConflict != "last" {
+		t.Fatalf("join flags mismatch: %+v", args)
+	}
+}
@@ -0,0 +1,46 @@
+package cli_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+)
+
+func TestParseFraction(t *testing.T) {
+	for in, want := range map[string]float64{"5%": 0.05, " 12.5 % ": 0.125, "0.05": 0.05, "1": 1, "0%": 0} {
+		got, err := cli.ParseFraction(in)
+		if err != nil || got != want {
+			t.Errorf("ParseFraction(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "five", "150%", "-0.1", "1.5", "NaN"} {
+		if _, err := cli.ParseFraction(in); err == nil {
+			t.Errorf("ParseFraction(%q) accepted", in)
+		}
+	}
+}
+
+func TestRecipientBucket(t *testing.T) {
+	if a, b := cli.RecipientBucket("holdout", "Alice@Example.com "), cli.RecipientBucket("holdout", "alice@example.com"); a != b {
+		t.Errorf("bucket depends on case or spacing: %v != %v", a, b)
+	}
+	if cli.RecipientBucket("holdout", "alice@example.com") == cli.RecipientBucket("sample", "alice@example.com") {
+		t.Error("salts give the same bucket")
+	}
+
+	// Buckets spread evenly, so a 10% share holds about 10% of a list.
+	in := 0
+	for i := 0; i < 10000; i++ {
+		b := cli.RecipientBucket("holdout", fmt.Sprintf("user%d@example.com", i))
+		if b < 0 || b >= 1 {
+			t.Fatalf("bucket %v out of range", b)
+		}
+		if b < 0.1 {
+			in++
+		}
+	}
+	if in < 900 || in > 1100 {
+		t.Errorf("%d of 10000 recipients in a 10%% share", in)
+	}
+}
@@ -0,0 +1,59 @@
+package cli_test
+
+import (
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+	"github.com/bravo1goingdark/mailgrid/logger"
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func TestSelectRetryRecipients(t *testing.T) {
+	recipients := []parser.Recipient{
+		{Email: "alice@example.com", Data: map[string]string{"name": "Alice"}},
+		{Email: "bob@example.com", Data: map[string]string{"name": "Bob"}},
+		{Email: "carol@example.com", Data: map[string]string{"name": "Carol"}},
+		{Email: "dave@example.com", Data: map[string]string{"name": "Dave"}},
+	}
+	failures := []logger.FailureRecord{
+		{Email: "Alice@example.com", Code: "451", JobID: "mailgrid-1"},
+		{Email: "bob@example.com", Code: "550", JobID: "mailgrid-1"},
+		{Email: "carol@example.com", Code: "", JobID: "mailgrid-1"},
+		{Email: "ghost@example.com", Code: "421", JobID: "mailgrid-1"},
+		{Email: "dave@example.com", Code: "421", JobID: "mailgrid-0"},
+	}
+
+	sel := cli.SelectRetryRecipients(recipients, failures, "mailgrid-1")
+
+	if len(sel.Recipients) != 2 {
+		t.Fatalf("expected 2 recipients, got %d: %+v", len(sel.Recipients), sel.Recipients)
+	}
+	if sel.Recipients[0].Email != "alice@example.com" || sel.Recipients[0].Data["name"] != "Alice" {
+		t.Errorf("expected alice with original CSV data, got %+v", sel.Recipients[0])
+	}
+	if sel.Recipients[1].Email != "carol@example.com" {
+		t.Errorf("expected carol (connection error is transient), got %+v", sel.Recipients[1])
+	}
+	if sel.Bounced != 1 || sel.Transient != 3 || sel.Missing != 1 {
+		t.Errorf("unexpected counts: bounced=%d transient=%d missing=%d", sel.Bounced, sel.Transient, sel.Missing)
+	}
+	if sel.ParentJobID != "mailgrid-1" {
+		t.Errorf("ParentJobID = %q, want mailgrid-1", sel.ParentJobID)
+	}
+}
+
+func TestSelectRetryRecipients_LatestRowWins(t *testing.T) {
+	recipients := []parser.Recipient{{Email: "alice@example.com"}}
+	failures := []logger.FailureRecord{
+		{Email: "alice@example.com", Code: "451", JobID: "mailgrid-1"},
+		{Email: "alice@example.com", Code: "550", JobID: "mailgrid-retry-2"},
+	}
+
+	sel := cli.SelectRetryRecipients(recipients, failures, "")
+	if len(sel.Recipients) != 0 || sel.Bounced != 1 {
+		t.Fatalf("expected the later hard bounce to win, got %+v", sel)
+	}
+	if sel.ParentJobID != "" {
+		t.Errorf("ParentJobID should be empty when failures span campaigns, got %q", sel.ParentJobID)
+	}
+}
@@ -0,0 +1,99 @@
+package cli_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+	"github.com/bravo1goingdark/mailgrid/database"
+)
+
+func TestImportExportSuppressions(t *testing.T) {
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "mailgrid.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer db.Close()
+
+	input := "email,reason,scope\n" +
+		"Alice@Example.com,,\n" +
+		"bob@example.com,complaint,newsletter\n" +
+		"not-an-address,,\n" +
+		"alice@example.com,,\n"
+	added, skipped, err := cli.ImportSuppressions(db, strings.NewReader(input), "", "imported", "old.csv")
+	if err != nil {
+		t.Fatalf("ImportSuppressions: %v", err)
+	}
+	if added != 2 || skipped != 1 {
+		t.Errorf("added=%d skipped=%d, want 2 and 1", added, skipped)
+	}
+
+	// A headerless file uses the first column and the default scope.
+	added, _, err = cli.ImportSuppressions(db, strings.NewReader("carol@example.com\n"), "promo", "manual", "cli")
+	if err != nil || added != 1 {
+		t.Fatalf("headerless import: added=%d err=%v", added, err)
+	}
+
+	var out bytes.Buffer
+	if err := cli.ExportSuppressions(db, &out, ""); err != nil {
+		t.Fatalf("ExportSuppressions: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || lines[0] != "email,scope,reason,source,created_at" {
+		t.Fatalf("unexpected export:\n%s", out.String())
+	}
+	if !strings.HasPrefix(lines[1], "alice@example.com,,imported,old.csv,") ||
+		!strings.HasPrefix(lines[2], "bob@example.com,newsletter,complaint,old.csv,") ||
+		!strings.HasPrefix(lines[3], "carol@example.com,promo,manual,cli,") {
+		t.Errorf("unexpected export rows:\n%s", out.String())
+	}
+
+	// Re-importing an export into a fresh database restores every entry.
+	fresh, err := database.NewDB(filepath.Join(t.TempDir(), "fresh.db"))
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	defer fresh.Close()
+	if added, _, err := cli.ImportSuppressions(fresh, &out, "", "manual", "restore"); err != nil || added != 3 {
+		t.Fatalf("round trip: added=%d err=%v", added, err)
+	}
+	entries, _ := fresh.LoadSuppressions()
+	for _, e := range entries {
+		if e.Email == "bob@example.com" && (e.Scope != "newsletter" || e.Reason != "complaint") {
+			t.Errorf("round trip lost fields: %+v", e)
+		}
+	}
+
+	var scoped bytes.Buffer
+	if err := cli.ExportSuppressions(db, &scoped, "promo"); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(strings.TrimSpace(scoped.String()), "\n"); n != 1 {
+		t.Errorf("scoped export should hold one row, got:\n%s", scoped.String())
+	}
+}
+
+func TestRun_ToSuppressed(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "mailgrid.db")
+	db, err := database.NewDB(dbPath)
+	if err != nil {
+		t.Fatalf("NewDB: %v", err)
+	}
+	if _, _, err := cli.ImportSuppressions(db, strings.NewReader("bob@example.com\n"), "newsletter", "manual", "cli"); err != nil {
+		t.Fatalf("ImportSuppressions: %v", err)
+	}
+	db.Close()
+
+	env := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(env, []byte(`{"smtp":{"host":"127.0.0.1","port":1,"username":"u","password":"p","from":"f@example.com"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = cli.Run(cli.CLIArgs{EnvPath: env, To: "Bob@example.com", Subject: "Hi", Text: "Hi", DBPath: dbPath, Concurrency: 1, List: "newsletter"})
+	if err == nil || !strings.Contains(err.Error(), "suppression list") {
+		t.Errorf("send to a suppressed --to: err = %v, want refusal", err)
+	}
+}
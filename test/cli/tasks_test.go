@@ -1,6 +1,7 @@
 package cli_test
 
 import (
+	"context"
 	"io"
 	"os"
 	"strings"
@@ -235,3 +236,31 @@ func captureStdout(t *testing.T, fn func()) string {
 
 	return string(output)
 }
+
+func TestStreamEmailTasks_RowsAndSkipped(t *testing.T) {
+	recipients := []parser.Recipient{
+		{Email: "a@b.com", Data: map[string]string{"keep": "no"}},
+		{Email: "b@b.com", Data: map[string]string{"keep": "yes"}},
+		{Email: "c@b.com", Data: map[string]string{"keep": "no"}},
+		{Email: "d@b.com", Data: map[string]string{"keep": "no"}},
+		{Email: "e@b.com", Data: map[string]string{"keep": "yes"}},
+		{Email: "f@b.com", Data: map[string]string{"keep": "yes"}},
+	}
+	src := parser.FilterSource(parser.SliceSource(recipients), func(r parser.Recipient) bool {
+		return r.Data["keep"] == "yes"
+	})
+
+	// Resume from row 2: row 4 covers the filtered rows 2 and 3.
+	taskCh, errCh := cli.StreamEmailTasks(context.Background(), src, "", "", "Hi", nil, nil, nil, 2, 0)
+	var got [][2]int
+	for task := range taskCh {
+		got = append(got, [2]int{task.Index, task.Skipped})
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("StreamEmailTasks error: %v", err)
+	}
+	want := [][2]int{{4, 2}, {5, 0}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("tasks (index, skipped) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+	"github.com/bravo1goingdark/mailgrid/parser"
+	"github.com/bravo1goingdark/mailgrid/tracking"
+	"github.com/bravo1goingdark/mailgrid/unsubscribe"
+)
+
+func TestTrackingLinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "news.html")
+	body := `<html><body><a href="https://shop.example.com/sale">Sale</a> <a href="{{ unsubscribeURL }}">Unsubscribe</a></body></html>`
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recipients := []parser.Recipient{{Email: "alice@example.com"}}
+	unsub, err := unsubscribe.NewSigner("https://mail.example.com/u", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	track, err := tracking.NewSigner("https://t.example.com", "t0ken")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := cli.PrepareEmailTasks(recipients, path, "", "Hi", nil, nil, nil,
+		cli.UnsubscribeLinks(unsub, "", "mailgrid-1"), cli.TrackingLinks(track, "mailgrid-1"))
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("PrepareEmailTasks = %d tasks, %v", len(tasks), err)
+	}
+	got := tasks[0].Body
+	if strings.Contains(got, "shop.example.com") || !strings.Contains(got, `href="https://t.example.com/c/`) {
+		t.Errorf("link not routed through the click endpoint: %s", got)
+	}
+	if !strings.Contains(got, `href="`+tasks[0].UnsubscribeURL+`"`) {
+		t.Errorf("unsubscribe link must stay direct: %s", got)
+	}
+	if !strings.Contains(got, `<img src="https://t.example.com/o/`) {
+		t.Errorf("open pixel missing: %s", got)
+	}
+
+	// Without a tracking config the body is left as rendered.
+	tasks, _ = cli.PrepareEmailTasks(recipients, path, "", "Hi", nil, nil, nil,
+		cli.UnsubscribeLinks(unsub, "", "mailgrid-1"), cli.TrackingLinks(nil, "mailgrid-1"))
+	if len(tasks) != 1 || !strings.Contains(tasks[0].Body, "https://shop.example.com/sale") {
+		t.Errorf("untracked body changed: %+v", tasks)
+	}
+}
@@ -0,0 +1,50 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+	"github.com/bravo1goingdark/mailgrid/parser"
+	"github.com/bravo1goingdark/mailgrid/unsubscribe"
+)
+
+func TestUnsubscribeLinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "news.html")
+	if err := os.WriteFile(path, []byte(`<p>Hi {{ .name }}</p><a href="{{ unsubscribeURL }}">Unsubscribe</a>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recipients := []parser.Recipient{
+		{Email: "alice@example.com", Data: map[string]string{"name": "Alice"}},
+		{Email: "bob@example.com", Data: map[string]string{"name": "Bob"}},
+	}
+	signer, err := unsubscribe.NewSigner("https://mail.example.com/u", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tasks, err := cli.PrepareEmailTasks(recipients, path, "", "Hi", nil, nil, nil, cli.UnsubscribeLinks(signer, "newsletter", "mailgrid-1"))
+	if err != nil || len(tasks) != 2 {
+		t.Fatalf("PrepareEmailTasks = %d tasks, %v", len(tasks), err)
+	}
+	for _, task := range tasks {
+		want := signer.URL(unsubscribe.Subscription{Email: task.Recipient.Email, List: "newsletter", Campaign: "mailgrid-1"})
+		if task.UnsubscribeURL != want {
+			t.Errorf("%s: UnsubscribeURL = %q, want %q", task.Recipient.Email, task.UnsubscribeURL, want)
+		}
+		if !strings.Contains(task.Body, `href="`+want+`"`) {
+			t.Errorf("%s: body lacks its link: %s", task.Recipient.Email, task.Body)
+		}
+	}
+	if tasks[0].UnsubscribeURL == tasks[1].UnsubscribeURL {
+		t.Error("each recipient needs its own link")
+	}
+
+	// Without a configured signer the helper cannot be honoured.
+	tasks, _ = cli.PrepareEmailTasks(recipients, path, "", "Hi", nil, nil, nil, cli.UnsubscribeLinks(nil, "", ""))
+	if len(tasks) != 0 {
+		t.Errorf("expected recipients to be skipped without an unsubscribe config, got %d tasks", len(tasks))
+	}
+}
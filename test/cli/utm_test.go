@@ -0,0 +1,64 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+	"github.com/bravo1goingdark/mailgrid/parser"
+	"github.com/bravo1goingdark/mailgrid/unsubscribe"
+)
+
+func TestUTMParams(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "news.html")
+	body := `<a href="https://shop.example.com/sale">Sale</a>` +
+		`<a href="https://shop.example.com/p?id=7&utm_source=partner#top">Product</a>` +
+		`<a href="mailto:help@example.com">Help</a>` +
+		`<a href="{{ unsubscribeURL }}">Unsubscribe</a>`
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recipients := []parser.Recipient{{Email: "alice@example.com", Data: map[string]string{"tier": "gold plus"}}}
+	unsub, err := unsubscribe.NewSigner("https://mail.example.com/u", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utm, err := cli.ParseUTM([]string{"source=newsletter", "utm_medium=email", "campaign=spring-{{ .tier }}"})
+	if err != nil {
+		t.Fatalf("ParseUTM: %v", err)
+	}
+
+	tasks, err := cli.PrepareEmailTasks(recipients, path, "", "Hi", nil, nil, nil,
+		cli.UnsubscribeLinks(unsub, "", "mailgrid-1"), cli.UTMParams(utm))
+	if err != nil || len(tasks) != 1 {
+		t.Fatalf("PrepareEmailTasks = %d tasks, %v", len(tasks), err)
+	}
+	got := tasks[0].Body
+	for _, want := range []string{
+		`href="https://shop.example.com/sale?utm_source=newsletter&amp;utm_medium=email&amp;utm_campaign=spring-gold+plus"`,
+		// Existing parameters win, order and fragment are kept.
+		`href="https://shop.example.com/p?id=7&amp;utm_source=partner&amp;utm_medium=email&amp;utm_campaign=spring-gold+plus#top"`,
+		`href="mailto:help@example.com"`,
+		`href="` + tasks[0].UnsubscribeURL + `"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("body lacks %s:\n%s", want, got)
+		}
+	}
+
+	for _, bad := range [][]string{{"source"}, {"=x"}, {"source=a", "utm_source=b"}, {"campaign={{ .x"}} {
+		if _, err := cli.ParseUTM(bad); err == nil {
+			t.Errorf("ParseUTM(%q) should fail", bad)
+		}
+	}
+
+	// A value referencing a missing field skips the recipient like --subject.
+	utm, _ = cli.ParseUTM([]string{"campaign={{ .region }}"})
+	tasks, _ = cli.PrepareEmailTasks(recipients, path, "", "Hi", nil, nil, nil,
+		cli.UnsubscribeLinks(unsub, "", ""), cli.UTMParams(utm))
+	if len(tasks) != 0 {
+		t.Errorf("expected the recipient to be skipped, got %d tasks", len(tasks))
+	}
+}
@@ -0,0 +1,83 @@
+package cli_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/cli"
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func TestValidateList(t *testing.T) {
+	input := "email,name,plan\n" +
+		"ann@example.com,Ann,pro\n" +
+		"A.nn+x@gmail.com,,free\n" +
+		"ann@gmail.com,Ann again,\n" +
+		"info@acme.com,Info,pro\n" +
+		"bad@,Bad,pro\n" +
+		",Blank,pro\n" +
+		"zed@mailinator.com,Zed,pro\n" +
+		"dee@example.org,\"Dee\nDee\",\n" +
+		"ok@example.org,Ok,pro,extra\n"
+	src, err := parser.NewCSVSource(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var clean, rejected bytes.Buffer
+	report, err := cli.ValidateList(src, "list.csv", cli.ValidateOptions{
+		Disposable: parser.DisposableDomains(),
+		Clean:      &clean,
+		Rejected:   &rejected,
+	})
+	if err != nil {
+		t.Fatalf("ValidateList: %v", err)
+	}
+
+	if report.Rows != 9 || report.Sendable != 4 || report.Rejected != 5 {
+		t.Errorf("rows=%d sendable=%d rejected=%d, want 9, 4 and 5", report.Rows, report.Sendable, report.Rejected)
+	}
+	for kind, want := range map[string]int{
+		cli.IssueMalformed: 1, cli.IssueBlankEmail: 1, cli.IssueInvalid: 1,
+		cli.IssueDisposable: 1, cli.IssueDuplicate: 1, cli.IssueRole: 1,
+	} {
+		if report.Counts[kind] != want {
+			t.Errorf("Counts[%s] = %d, want %d", kind, report.Counts[kind], want)
+		}
+	}
+	if report.EmptyFields["name"] != 1 || report.EmptyFields["plan"] != 1 {
+		t.Errorf("EmptyFields = %v", report.EmptyFields)
+	}
+
+	lines := map[string]int{}
+	for _, is := range report.Issues {
+		lines[is.Kind] = is.Line
+	}
+	if lines[cli.IssueDuplicate] != 4 || lines[cli.IssueMalformed] != 11 {
+		t.Errorf("issue lines = %v", lines)
+	}
+
+	wantClean := "email,name,plan\nann@example.com,Ann,pro\nA.nn+x@gmail.com,,free\ninfo@acme.com,Info,pro\ndee@example.org,\"Dee\nDee\",\n"
+	if clean.String() != wantClean {
+		t.Errorf("clean CSV:\n%s", clean.String())
+	}
+	if !strings.HasPrefix(rejected.String(), "line,reason,email,name,plan\n4,duplicate,ann@gmail.com,Ann again,\n") ||
+		!strings.Contains(rejected.String(), "11,malformed,ok@example.org,Ok,pro,extra\n") {
+		t.Errorf("rejected CSV:\n%s", rejected.String())
+	}
+}
+
+func TestValidateList_RejectRoleAndMaxIssues(t *testing.T) {
+	src, err := parser.NewCSVSource(strings.NewReader("email\ninfo@a.com\nsales@a.com\nann@a.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := cli.ValidateList(src, "roles.csv", cli.ValidateOptions{RejectRole: true, MaxIssues: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Sendable != 1 || report.Counts[cli.IssueRole] != 2 || len(report.Issues) != 1 || !report.Truncated {
+		t.Errorf("unexpected report %+v", report)
+	}
+}
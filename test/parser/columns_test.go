@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func TestParseColumnTypes(t *testing.T) {
+	types, err := parser.ParseColumnTypes("Age=int, signup=date:02/01/2006", "vip=boolean,score=number")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]parser.ColumnType{"age": parser.TypeInt, "signup": "date:02/01/2006", "vip": parser.TypeBool, "score": parser.TypeFloat}
+	for col, typ := range want {
+		if types[col] != typ {
+			t.Errorf("types[%s] = %q, want %q", col, types[col], typ)
+		}
+	}
+
+	for _, bad := range []string{"age", "age=integerish", "age=int:2006"} {
+		if _, err := parser.ParseColumnTypes(bad); err == nil {
+			t.Errorf("ParseColumnTypes(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestInferColumnTypes(t *testing.T) {
+	sample := []parser.Recipient{
+		{Data: map[string]string{"age": "31", "score": "4.5", "vip": "Yes", "signup": "2024-01-05", "zip": "02134", "phone": "+4930123", "note": ""}},
+		{Data: map[string]string{"age": "", "score": "3", "vip": "no", "signup": "2024-02-01T10:00:00Z", "zip": "10001", "phone": "+4930999", "note": ""}},
+	}
+	want := map[string]parser.ColumnType{
+		"age": parser.TypeInt, "score": parser.TypeFloat, "vip": parser.TypeBool, "signup": parser.TypeDate,
+		"zip": parser.TypeString, "phone": parser.TypeString, "note": parser.TypeString,
+	}
+	got := parser.InferColumnTypes(sample)
+	for col, typ := range want {
+		if got[col] != typ {
+			t.Errorf("inferred %s as %q, want %q", col, got[col], typ)
+		}
+	}
+}
+
+func TestParseTypedExpression(t *testing.T) {
+	types := map[string]parser.ColumnType{"salary": parser.TypeInt, "signup": parser.TypeDate, "vip": parser.TypeBool, "joined": "date:02/01/2006"}
+	recent := time.Now().AddDate(0, 0, -10).Format("2006-01-02")
+	row := map[string]string{"name": "Ann", "salary": "60000", "signup": recent, "vip": "yes", "joined": "31/12/2023"}
+	blank := map[string]string{"name": "Ben", "salary": "", "signup": "soon", "vip": "", "joined": ""}
+
+	tests := []struct {
+		expr       string
+		row, blank bool
+	}{
+		{`salary > 50000`, true, false},
+		{`salary >= 59999.5 && name == "ANN"`, true, false},
+		{`!(salary > 50000)`, false, true},
+		{`salary != nil`, true, false},
+		{`daysSince(signup) < 30`, true, false},
+		{`vip`, true, false},
+		{`joined < date("2024-01-01")`, true, false},
+		{`salary > 50000 || name == "ben"`, true, true},
+	}
+	for _, tt := range tests {
+		exp, err := parser.ParseTypedExpression(tt.expr, types)
+		if err != nil {
+			t.Fatalf("ParseTypedExpression(%q): %v", tt.expr, err)
+		}
+		if got := exp.Evaluate(row); got != tt.row {
+			t.Errorf("%s on a full row = %v, want %v", tt.expr, got, tt.row)
+		}
+		if got := exp.Evaluate(blank); got != tt.blank {
+			t.Errorf("%s on a blank row = %v, want %v", tt.expr, got, tt.blank)
+		}
+	}
+
+	for _, bad := range []string{`salary > "50000"`, `daysSince(salary) > 3`, `vip == "yes"`} {
+		_, err := parser.ParseTypedExpression(bad, types)
+		if err == nil || !strings.Contains(err.Error(), "salary=int") {
+			t.Errorf("ParseTypedExpression(%q) error = %v, want a type error naming the column types", bad, err)
+		}
+	}
+}
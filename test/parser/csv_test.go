@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"fmt"
 	"os"
 	"strings"
 	"testing"
@@ -119,3 +120,79 @@ func TestParseCSV(t *testing.T) {
 		t.Errorf("unexpected recipients: %+v", recipients)
 	}
 }
+
+func TestTableSource_NumbersRowsAcrossSkips(t *testing.T) {
+	csvData := strings.Join([]string{
+		"email,name",
+		"a@example.com,Alice",
+		"not-an-email,Bad",
+		",Blank",
+		"b@example.com,Bob",
+	}, "\n") + "\n"
+
+	src, err := parser.NewCSVSource(strings.NewReader(csvData))
+	if err != nil {
+		t.Fatalf("NewCSVSource error: %v", err)
+	}
+	recipients, err := parser.Collect(src)
+	if err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	if len(recipients) != 2 || recipients[0].Row != 0 || recipients[1].Row != 3 {
+		t.Fatalf("unexpected recipients: %+v", recipients)
+	}
+	if src.Rows() != 4 || src.Skipped() != 1 {
+		t.Errorf("Rows() = %d, Skipped() = %d; want 4, 1", src.Rows(), src.Skipped())
+	}
+}
+
+func TestDedupeSource_DiskDeduper(t *testing.T) {
+	const n = 20000
+	recipients := make([]parser.Recipient, 0, 2*n)
+	for i := 0; i < n; i++ {
+		recipients = append(recipients, parser.Recipient{Email: fmt.Sprintf("user%d@example.com", i)})
+	}
+	// Every address again, differently cased.
+	for i := 0; i < n; i++ {
+		recipients = append(recipients, parser.Recipient{Email: fmt.Sprintf("USER%d@example.com", i)})
+	}
+
+	deduper, err := parser.NewDiskDeduper(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewDiskDeduper error: %v", err)
+	}
+	src := parser.DedupeSource(parser.SliceSource(recipients), deduper)
+	got, err := parser.Collect(src)
+	if err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	if err := src.Close(); err != nil {
+		t.Errorf("Close error: %v", err)
+	}
+	if len(got) != n || src.Duplicates() != n {
+		t.Errorf("kept %d, duplicates %d; want %d each", len(got), src.Duplicates(), n)
+	}
+	if got[n-1].Row != n-1 {
+		t.Errorf("last kept row = %d, want %d", got[n-1].Row, n-1)
+	}
+}
+
+func TestFilterSource(t *testing.T) {
+	recipients := []parser.Recipient{
+		{Email: "a@example.com", Data: map[string]string{"tier": "pro"}},
+		{Email: "b@example.com", Data: map[string]string{"tier": "free"}},
+		{Email: "c@example.com", Data: map[string]string{"tier": "pro"}},
+	}
+	expr, err := parser.ParseExpression(`tier == "pro"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := parser.FilterSource(parser.SliceSource(recipients), parser.Matcher(expr))
+	got, err := parser.Collect(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[1].Email != "c@example.com" || got[1].Row != 2 || src.Dropped() != 1 {
+		t.Errorf("unexpected result %+v (dropped %d)", got, src.Dropped())
+	}
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func TestParseCSVFromReader_StripsBOM(t *testing.T) {
+	recipients, err := parser.ParseCSVFromReader(strings.NewReader("\xEF\xBB\xBFemail,name\na@example.com,Ann\n"))
+	if err != nil {
+		t.Fatalf("ParseCSVFromReader error: %v", err)
+	}
+	if len(recipients) != 1 || recipients[0].Email != "a@example.com" || recipients[0].Data["name"] != "Ann" {
+		t.Errorf("unexpected recipients %+v", recipients)
+	}
+}
+
+func TestParseCSVWithOptions_EuropeanExport(t *testing.T) {
+	// Latin-1, semicolon-separated, with a BOM and a non-standard email header.
+	input := "\xEF\xBB\xBFE-Mail Address;Name;Stadt\n" +
+		"francois@example.com;Fran\xE7ois;K\xF6ln\n" +
+		"bad-address;Nobody;Nowhere\n"
+	opts := parser.ReadOptions{
+		Delimiter:   ';',
+		Encoding:    "ISO-8859-1",
+		EmailColumn: "e-mail address",
+		Rename:      map[string]string{"Stadt": "City"},
+	}
+	recipients, err := parser.ParseCSVWithOptions(strings.NewReader(input), opts)
+	if err != nil {
+		t.Fatalf("ParseCSVWithOptions error: %v", err)
+	}
+	if len(recipients) != 1 {
+		t.Fatalf("expected 1 recipient, got %+v", recipients)
+	}
+	r := recipients[0]
+	if r.Email != "francois@example.com" || r.Data["name"] != "François" || r.Data["city"] != "Köln" {
+		t.Errorf("unexpected recipient %+v", r)
+	}
+	if _, ok := r.Data["e-mail address"]; ok {
+		t.Error("the email column should not be part of Data")
+	}
+}
+
+func TestParseCSVWithOptions_Encodings(t *testing.T) {
+	utf16le := []byte{0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune("email\tnote\nb@example.com\t€ 𝄞\n")) {
+		utf16le = append(utf16le, byte(u), byte(u>>8))
+	}
+	tests := []struct {
+		name, input string
+		opts        parser.ReadOptions
+		want        string
+	}{
+		{"windows-1252", "email,note\nb@example.com,\x80 \x93ok\x94\n", parser.ReadOptions{Encoding: "cp1252"}, "€ “ok”"},
+		{"iso-8859-15", "email,note\nb@example.com,\xA4 \xBD\n", parser.ReadOptions{Encoding: "latin9"}, "€ œ"},
+		{"utf-16 bom", string(utf16le), parser.ReadOptions{Delimiter: '\t'}, "€ 𝄞"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recipients, err := parser.ParseCSVWithOptions(strings.NewReader(tt.input), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recipients) != 1 || recipients[0].Data["note"] != tt.want {
+				t.Errorf("got %+v, want note %q", recipients, tt.want)
+			}
+		})
+	}
+
+	if _, err := parser.ParseCSVWithOptions(strings.NewReader("email\n"), parser.ReadOptions{Encoding: "ebcdic"}); err == nil {
+		t.Error("expected an error for an unsupported encoding")
+	}
+}
+
+func TestParseCSVWithOptions_Quote(t *testing.T) {
+	input := "email,note\nc@example.com,'say \"hi\", then ''bye'''\n"
+	recipients, err := parser.ParseCSVWithOptions(strings.NewReader(input), parser.ReadOptions{Quote: '\''})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 1 || recipients[0].Data["note"] != `say "hi", then 'bye'` {
+		t.Errorf("unexpected recipients %+v", recipients)
+	}
+
+	input = "email|note\nc@example.com|\"6\" ruler\n"
+	recipients, err = parser.ParseCSVWithOptions(strings.NewReader(input), parser.ReadOptions{Delimiter: '|', Quote: parser.NoQuote})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 1 || recipients[0].Data["note"] != `"6" ruler` {
+		t.Errorf("unexpected recipients %+v", recipients)
+	}
+
+	if _, err := parser.ParseCSVWithOptions(strings.NewReader("email\n"), parser.ReadOptions{Delimiter: '"'}); err == nil {
+		t.Error("expected an error when the delimiter is the quote character")
+	}
+}
+
+func TestParseCSVWithOptions_MissingColumn(t *testing.T) {
+	_, err := parser.ParseCSVWithOptions(strings.NewReader("Mail,Name\na@example.com,Ann\n"),
+		parser.ReadOptions{EmailColumn: "E-Mail"})
+	if err == nil || !strings.Contains(err.Error(), `"e-mail"`) || !strings.Contains(err.Error(), "mail, name") {
+		t.Errorf("expected the missing column and available columns in error, got %v", err)
+	}
+}
+
+func TestJSONSource_RenamesKeys(t *testing.T) {
+	input := `{"Work Email":"d@example.com","First":"Dee"}`
+	src, err := parser.NewJSONSourceWithOptions(strings.NewReader(input),
+		parser.ReadOptions{EmailColumn: "work email", Rename: map[string]string{"first": "name"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients, err := parser.Collect(src)
+	if err != nil || len(recipients) != 1 || recipients[0].Email != "d@example.com" || recipients[0].Data["name"] != "Dee" {
+		t.Errorf("got %+v, %v", recipients, err)
+	}
+}
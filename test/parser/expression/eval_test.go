@@ -1,6 +1,9 @@
 package parser_test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/bravo1goingdark/mailgrid/parser"
@@ -227,3 +230,72 @@ func TestMustParse(t *testing.T) {
 		t.Error("MustParseExpression should not panic for valid expression")
 	}
 }
+
+func TestExpression_Functions(t *testing.T) {
+	vip := filepath.Join(t.TempDir(), "vip.txt")
+	if err := os.WriteFile(vip, []byte("# VIPs\nAnn@Acme.com\n\nbob@beta.io\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	data := map[string]string{
+		"email":        "ann@acme.com",
+		"name":         "Dr. Ann Lee",
+		"tier":         "Gold",
+		"note":         "",
+		"address.city": "Paris",
+	}
+
+	tests := []struct {
+		expr     string
+		expected bool
+	}{
+		{`matches(name, "^dr\\. ")`, true},
+		{`name matches "lee$"`, true},
+		{`matchesCase(name, "^dr")`, false},
+		{`matchesCase(name, "^Dr")`, true},
+		{`tier in ["SILVER", "gold"]`, true},
+		{`tier not in ["silver", "bronze"]`, true},
+		{`inCase(tier, ["gold"])`, false},
+		{`inCase(tier, ["Gold"])`, true},
+		{`inFile(email, "` + vip + `")`, true},
+		{`inFile(name, "` + vip + `")`, false},
+		{`domain(email) == "ACME.com"`, true},
+		{`domain(email) in ["beta.io"]`, false},
+		{`isEmpty(note) && !isEmpty(name) && isEmpty(missing)`, true},
+		{`equalsCase(tier, "gold")`, false},
+		{`equalsCase(tier, "Gold") && containsCase(name, "Ann")`, true},
+		{`startsWithCase(name, "dr") || endsWithCase(name, "LEE")`, false},
+		{`equalsCase(address.city, "Paris") && contains(address.city, "PAR")`, true},
+	}
+	for _, tt := range tests {
+		exp, err := parser.ParseExpression(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseExpression(%q): %v", tt.expr, err)
+		}
+		if got := exp.Evaluate(data); got != tt.expected {
+			t.Errorf("%s = %v, want %v", tt.expr, got, tt.expected)
+		}
+	}
+
+	typed, err := parser.ParseTypedExpression(`isEmpty(age) || domain(email) == "acme.com" && containsCase(name, "Ann")`,
+		map[string]parser.ColumnType{"age": parser.TypeInt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !typed.Evaluate(data) {
+		t.Error("typed expression using functions did not match")
+	}
+
+	for bad, want := range map[string]string{
+		`contains(name)`:                    "contains takes 2 argument(s)",
+		`domain(email, "x") == "y"`:         "domain takes 1 argument(s)",
+		`matchesCase(name, "(")`:            "matchesCase",
+		`inFile(email, "missing-list.txt")`: "missing-list.txt",
+		`inFile(email, name)`:               "file name in quotes",
+		`name matches "("`:                  "missing closing )",
+	} {
+		_, err := parser.ParseExpression(bad)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ParseExpression(%q) error = %v, want it to mention %q", bad, err, want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// writeServiceAccountKey writes a service account key file for a fresh RSA
+// key and returns its path with the public half.
+func writeServiceAccountKey(t *testing.T) (string, *rsa.PublicKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"client_email": "mailer@project.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+	})
+	path := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, &key.PublicKey
+}
+
+// verifyAssertion checks the signature and issuer of a JWT bearer assertion.
+func verifyAssertion(pub *rsa.PublicKey, assertion string) bool {
+	parts := strings.Split(assertion, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig) != nil {
+		return false
+	}
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var c struct{ Iss, Scope string }
+	return json.Unmarshal(claims, &c) == nil && c.Iss == "mailer@project.iam.gserviceaccount.com" && strings.Contains(c.Scope, "spreadsheets.readonly")
+}
+
+func TestOpenPrivateSheet(t *testing.T) {
+	keyPath, pub := writeServiceAccountKey(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || !verifyAssertion(pub, r.FormValue("assertion")) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"bad assertion"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token":"ya29.test","token_type":"Bearer","expires_in":3600}`))
+	})
+	mux.HandleFunc("/v4/spreadsheets/SHEET123", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"sheets":[{"properties":{"sheetId":0,"title":"Notes"}},{"properties":{"sheetId":77,"title":"Bob's list"}}]}`))
+	})
+	mux.HandleFunc("/v4/spreadsheets/SHEET123/values/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v4/spreadsheets/SHEET123/values/'Bob''s list'" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"range":"'Bob''s list'!A1:C3","majorDimension":"ROWS","values":[["Email","Name","Plan"],["dan@example.com","Dan"],["eve@example.com","Eve","pro"]]}`))
+	})
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" && r.Header.Get("Authorization") != "Bearer ya29.test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"code":401,"message":"missing token"}}`))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer api.Close()
+
+	g := parser.GoogleSheetsOptions{CredentialsFile: keyPath, TokenURL: api.URL + "/token", APIBaseURL: api.URL + "/v4"}
+	src, err := parser.OpenPrivateSheet("https://docs.google.com/spreadsheets/d/SHEET123/edit#gid=77", g, parser.ReadOptions{})
+	if err != nil {
+		t.Fatalf("OpenPrivateSheet error: %v", err)
+	}
+	recipients, err := parser.Collect(src)
+	if err != nil || len(recipients) != 2 {
+		t.Fatalf("got %+v, %v", recipients, err)
+	}
+	if recipients[0].Data["name"] != "Dan" || recipients[0].Data["plan"] != "" || recipients[1].Data["plan"] != "pro" {
+		t.Errorf("unexpected recipients %+v", recipients)
+	}
+
+	if _, err := parser.OpenPrivateSheet("https://docs.google.com/spreadsheets/d/SHEET123/edit#gid=5", g, parser.ReadOptions{}); err == nil || !strings.Contains(err.Error(), "gid 5") {
+		t.Errorf("expected an unknown gid error, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func joinInput(t *testing.T, name, csv string) parser.JoinInput {
+	t.Helper()
+	src, err := parser.NewCSVSource(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parser.JoinInput{Name: name, Source: src}
+}
+
+const (
+	masterCSV  = "email,name,tier\nann@example.com,Ann,gold\nben@example.com,Ben,\ncat@example.com,Cat,silver\n"
+	couponsCSV = "email,code,tier\nBEN@example.com,SAVE10,bronze\nann@example.com,SAVE20,platinum\nzed@example.com,SAVE30,\n"
+)
+
+func TestJoinSources_Modes(t *testing.T) {
+	tests := []struct {
+		mode   parser.JoinMode
+		emails []string
+		rows   []int
+	}{
+		{parser.JoinLeft, []string{"ann@example.com", "ben@example.com", "cat@example.com"}, []int{0, 1, 2}},
+		{parser.JoinInner, []string{"ann@example.com", "ben@example.com"}, []int{0, 1}},
+		{parser.JoinUnion, []string{"ann@example.com", "ben@example.com", "cat@example.com", "zed@example.com"}, []int{0, 1, 2, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			var unmatched []parser.UnmatchedRow
+			src, err := parser.JoinSources(joinInput(t, "master.csv", masterCSV),
+				[]parser.JoinInput{joinInput(t, "coupons.csv", couponsCSV)},
+				parser.JoinOptions{Mode: tt.mode, Unmatched: func(u parser.UnmatchedRow) { unmatched = append(unmatched, u) }})
+			if err != nil {
+				t.Fatal(err)
+			}
+			recipients, err := parser.Collect(src)
+			if err != nil {
+				t.Fatalf("Collect error: %v", err)
+			}
+			if len(recipients) != len(tt.emails) {
+				t.Fatalf("expected %v, got %+v", tt.emails, recipients)
+			}
+			for i, r := range recipients {
+				if r.Email != tt.emails[i] || r.Row != tt.rows[i] {
+					t.Errorf("recipient %d = %s row %d, want %s row %d", i, r.Email, r.Row, tt.emails[i], tt.rows[i])
+				}
+			}
+
+			// First value wins; blanks are filled from the joined file.
+			ann, ben := recipients[0], recipients[1]
+			if ann.Data["code"] != "SAVE20" || ann.Data["tier"] != "gold" || ben.Data["tier"] != "bronze" {
+				t.Errorf("merged data = %+v / %+v", ann.Data, ben.Data)
+			}
+
+			if len(unmatched) != 2 ||
+				unmatched[0] != (parser.UnmatchedRow{Source: "master.csv", Row: 2, Email: "cat@example.com", MissingFrom: "coupons.csv"}) ||
+				unmatched[1] != (parser.UnmatchedRow{Source: "coupons.csv", Row: 2, Email: "zed@example.com", MissingFrom: "master.csv"}) {
+				t.Errorf("unmatched = %+v", unmatched)
+			}
+			stats := src.Stats()
+			if stats.Matched != 2 || stats.PrimaryUnmatched != 1 || stats.SecondaryUnmatched != 1 {
+				t.Errorf("stats = %+v", stats)
+			}
+		})
+	}
+}
+
+func TestJoinSources_ConflictRules(t *testing.T) {
+	src, err := parser.JoinSources(joinInput(t, "master.csv", masterCSV),
+		[]parser.JoinInput{joinInput(t, "coupons.csv", couponsCSV)},
+		parser.JoinOptions{Mode: parser.JoinLeft, Conflict: parser.ConflictLast})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := src.Next()
+	if err != nil || r.Data["tier"] != "platinum" {
+		t.Errorf("ConflictLast: got %+v, %v", r, err)
+	}
+
+	src, err = parser.JoinSources(joinInput(t, "master.csv", masterCSV),
+		[]parser.JoinInput{joinInput(t, "coupons.csv", couponsCSV)},
+		parser.JoinOptions{Mode: parser.JoinLeft, Conflict: parser.ConflictError})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.Collect(src); err == nil || !strings.Contains(err.Error(), `column "tier"`) || !strings.Contains(err.Error(), "coupons.csv") {
+		t.Errorf("ConflictError: expected a conflict naming the column and file, got %v", err)
+	}
+}
+
+func TestParseJoinMode(t *testing.T) {
+	for in, want := range map[string]parser.JoinMode{"": parser.JoinLeft, "left-join": parser.JoinLeft, "Inner": parser.JoinInner, "union": parser.JoinUnion} {
+		if got, err := parser.ParseJoinMode(in); err != nil || got != want {
+			t.Errorf("ParseJoinMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := parser.ParseJoinMode("outer"); err == nil {
+		t.Error("expected an error for an unknown join mode")
+	}
+}
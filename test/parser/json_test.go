@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func TestParseJSON_ArrayWithNestedFields(t *testing.T) {
+	input := `[
+		{"Email": "alice@example.com", "name": "Alice", "age": 30, "vip": true, "note": null,
+		 "address": {"City": "Paris", "zip": "75001"}, "orders": [{"id": 1}, {"id": 2}]},
+		{"email": "not-an-email"},
+		{"email": "ALICE@example.com", "name": "Duplicate"},
+		"stray string",
+		{"email": "bob@example.com", "tags": ["a", "b"]}
+	]`
+	recipients, err := parser.ParseJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseJSON error: %v", err)
+	}
+	if len(recipients) != 2 {
+		t.Fatalf("expected 2 recipients, got %+v", recipients)
+	}
+
+	a := recipients[0]
+	want := map[string]string{
+		"name": "Alice", "age": "30", "vip": "true", "note": "",
+		"address.city": "Paris", "address.zip": "75001", "orders.0.id": "1", "orders.1.id": "2",
+	}
+	for k, v := range want {
+		if a.Data[k] != v {
+			t.Errorf("Data[%q] = %q, want %q", k, a.Data[k], v)
+		}
+	}
+	if _, ok := a.Data["email"]; ok {
+		t.Error("email should not be part of Data")
+	}
+	if recipients[1].Email != "bob@example.com" || recipients[1].Row != 4 {
+		t.Errorf("second recipient = %+v", recipients[1])
+	}
+
+	tmpl := template.Must(template.New("t").Option("missingkey=error").Parse(
+		`{{.name}} in {{.address.city}}:{{range .orders}} #{{.id}}{{end}}`))
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, a.TemplateData()); err != nil {
+		t.Fatalf("template error: %v", err)
+	}
+	if got := out.String(); got != "Alice in Paris: #1 #2" {
+		t.Errorf("rendered %q", got)
+	}
+}
+
+func TestJSONSource_NDJSON(t *testing.T) {
+	input := "\xEF\xBB\xBF{\"email\":\"a@example.com\",\"n\":1}\n\n{\"email\":\"b@example.com\",\"n\":1.50}\n"
+	src, err := parser.NewJSONSource(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recipients, err := parser.Collect(src)
+	if err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	if len(recipients) != 2 || recipients[1].Data["n"] != "1.50" || recipients[1].Row != 1 {
+		t.Errorf("unexpected recipients %+v", recipients)
+	}
+
+	src, _ = parser.NewJSONSource(strings.NewReader(`{"email":"a@example.com"}` + "\n" + `{"email": oops}`))
+	if _, err := parser.Collect(src); err == nil || !strings.Contains(err.Error(), "record 2") {
+		t.Errorf("expected a syntax error naming record 2, got %v", err)
+	}
+}
+
+func TestOpenReader_DetectsFormat(t *testing.T) {
+	tests := []struct {
+		name, input string
+	}{
+		{"csv", "email,name\nc@example.com,Carol\n"},
+		{"json array", ` [{"email":"c@example.com","name":"Carol"}]`},
+		{"ndjson", `{"email":"c@example.com","name":"Carol"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := parser.OpenReader(strings.NewReader(tt.input), parser.ReadOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			recipients, err := parser.Collect(src)
+			if err != nil || len(recipients) != 1 || recipients[0].Data["name"] != "Carol" {
+				t.Errorf("got %+v, %v", recipients, err)
+			}
+		})
+	}
+
+	if _, err := parser.OpenReader(strings.NewReader("PK\x03\x04rest"), parser.ReadOptions{}); err == nil {
+		t.Error("expected an error for a spreadsheet on stdin")
+	}
+}
@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// stubResolver answers lookups from fixed tables and counts them.
+type stubResolver struct {
+	mx      map[string][]*net.MX
+	addrs   map[string][]net.IPAddr
+	fail    map[string]bool
+	lookups int
+}
+
+func (s *stubResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	s.lookups++
+	if s.fail[name] {
+		return nil, &net.DNSError{Err: "i/o timeout", Name: name, IsTimeout: true}
+	}
+	if mx, ok := s.mx[name]; ok {
+		return mx, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (s *stubResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	if a, ok := s.addrs[host]; ok {
+		return a, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestDomainChecker(t *testing.T) {
+	stub := &stubResolver{
+		mx: map[string][]*net.MX{
+			"example.com.": {{Host: "mx1.example.com.", Pref: 10}},
+			"nomail.test.": {{Host: ".", Pref: 0}},
+		},
+		addrs: map[string][]net.IPAddr{"a-only.test.": {{IP: net.ParseIP("192.0.2.1")}}},
+		fail:  map[string]bool{"slow.test.": true},
+	}
+	c := parser.NewDomainChecker(stub, 0)
+	ctx := context.Background()
+
+	tests := []struct {
+		addr   string
+		domain string
+		want   parser.DomainStatus
+	}{
+		{"Ann <ann@Example.com>", "example.com", parser.DomainOK},
+		{"bob@a-only.test", "a-only.test", parser.DomainOK},
+		{"carol@nomail.test", "nomail.test", parser.DomainNullMX},
+		{"dan@gone.test", "gone.test", parser.DomainNoMailHost},
+		{"eve@slow.test", "slow.test", parser.DomainUnknown},
+	}
+	for _, tt := range tests {
+		domain, got := c.CheckAddress(ctx, tt.addr)
+		if domain != tt.domain || got != tt.want {
+			t.Errorf("CheckAddress(%q) = %q, %q; want %q, %q", tt.addr, domain, got, tt.domain, tt.want)
+		}
+	}
+	if parser.DomainNullMX.Deliverable() || parser.DomainNoMailHost.Deliverable() || !parser.DomainUnknown.Deliverable() {
+		t.Error("unexpected Deliverable results")
+	}
+
+	// Answers, failures included, are cached per domain.
+	before := stub.lookups
+	c.CheckAddress(ctx, "other@example.com")
+	c.Check(ctx, "SLOW.test.")
+	if stub.lookups != before {
+		t.Errorf("expected cached answers, got %d new lookups", stub.lookups-before)
+	}
+}
+
+func TestDomainChecker_TimeoutApplies(t *testing.T) {
+	c := parser.NewDomainChecker(deadlineResolver{}, 1)
+	if got := c.Check(context.Background(), "example.com"); got != parser.DomainUnknown {
+		t.Errorf("Check = %q, want %q", got, parser.DomainUnknown)
+	}
+}
+
+// deadlineResolver fails once its context is done.
+type deadlineResolver struct{}
+
+func (deadlineResolver) LookupMX(ctx context.Context, _ string) ([]*net.MX, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (deadlineResolver) LookupIPAddr(ctx context.Context, _ string) ([]net.IPAddr, error) {
+	return nil, errors.New("unreachable")
+}
@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func TestCanonicalEmail(t *testing.T) {
+	tests := map[string]string{
+		"Ann.Lee+news@Gmail.com":     "annlee@gmail.com",
+		"a.n.n@googlemail.com":       "ann@gmail.com",
+		"Ann <ann.lee+x@gmail.com>":  "annlee@gmail.com",
+		"first.last+tag@example.com": "first.last+tag@example.com",
+		"Bob@Example.COM":            "bob@example.com",
+		"not an address":             "not an address",
+	}
+	for in, want := range tests {
+		if got := parser.CanonicalEmail(in); got != want {
+			t.Errorf("CanonicalEmail(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRoleAndDisposableAddresses(t *testing.T) {
+	if !parser.IsRoleAddress("Info@acme.com") || !parser.IsRoleAddress("postmaster+x@acme.com") || parser.IsRoleAddress("ann@acme.com") {
+		t.Error("IsRoleAddress misclassified an address")
+	}
+
+	set := parser.DisposableDomains()
+	set.Add("@burner.test")
+	for addr, want := range map[string]bool{
+		"x@mailinator.com":    true,
+		"x@eu.mailinator.com": true,
+		"x@burner.test":       true,
+		"x@notmailinator.com": false,
+		"x@example.com":       false,
+	} {
+		if got := set.Matches(addr); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
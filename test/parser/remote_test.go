@@ -0,0 +1,137 @@
+package parser
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+func TestOpenURL_HeadersAndETagCache(t *testing.T) {
+	var fetches, notModified int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" || r.Header.Get("X-Team") != "growth" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fetches++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Write([]byte("email,name\na@example.com,Ann\nb@example.com,Ben\n"))
+	}))
+	defer srv.Close()
+
+	host, _ := url.Parse(srv.URL)
+	urlOpts := parser.URLOptions{
+		AllowedHosts: []string{host.Hostname()},
+		Headers:      map[string]string{"X-Team": "growth"},
+		BearerToken:  "s3cret",
+		CacheDir:     t.TempDir(),
+		Client:       srv.Client(),
+	}
+	for i := 0; i < 2; i++ {
+		src, err := parser.OpenURL(srv.URL+"/export", urlOpts, parser.ReadOptions{})
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i+1, err)
+		}
+		recipients, err := parser.Collect(src)
+		src.Close()
+		if err != nil || len(recipients) != 2 || recipients[1].Data["name"] != "Ben" {
+			t.Fatalf("fetch %d: got %+v, %v", i+1, recipients, err)
+		}
+	}
+	if fetches != 2 || notModified != 1 {
+		t.Errorf("fetches = %d, not modified = %d; want 2 and 1", fetches, notModified)
+	}
+}
+
+func TestOpenURL_CacheKeyedByCredentials(t *testing.T) {
+	// Both teams' exports share an ETag; only the credentials tell them apart.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte("email\n" + strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ") + "@example.com\n"))
+	}))
+	defer srv.Close()
+
+	host, _ := url.Parse(srv.URL)
+	cacheDir := t.TempDir()
+	for _, tc := range []struct{ token, header, want string }{
+		{"sales", "", "sales@example.com"},
+		{"support", "", "support@example.com"},
+		{"sales", "growth", "sales@example.com"},
+		{"sales", "", "sales@example.com"},
+	} {
+		urlOpts := parser.URLOptions{AllowedHosts: []string{host.Hostname()}, BearerToken: tc.token, CacheDir: cacheDir, Client: srv.Client()}
+		if tc.header != "" {
+			urlOpts.Headers = map[string]string{"X-Team": tc.header}
+		}
+		src, err := parser.OpenURL(srv.URL+"/export", urlOpts, parser.ReadOptions{})
+		if err != nil {
+			t.Fatalf("fetch as %s: %v", tc.token, err)
+		}
+		recipients, err := parser.Collect(src)
+		src.Close()
+		if err != nil || len(recipients) != 1 || recipients[0].Email != tc.want {
+			t.Errorf("fetch as %s/%s: got %+v, %v; want %s", tc.token, tc.header, recipients, err, tc.want)
+		}
+	}
+}
+
+func TestOpenURL_JSONByContentType(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte(`{"email":"c@example.com","plan":"pro"}` + "\n"))
+	}))
+	defer srv.Close()
+
+	host, _ := url.Parse(srv.URL)
+	src, err := parser.OpenURL(srv.URL+"/list", parser.URLOptions{AllowedHosts: []string{"*.example.org", host.Hostname()}, Client: srv.Client()}, parser.ReadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	r, err := src.Next()
+	if err != nil || r.Email != "c@example.com" || r.Data["plan"] != "pro" {
+		t.Errorf("Next() = %+v, %v", r, err)
+	}
+}
+
+func TestOpenURL_Allowlist(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://evil.example.net/list.csv", http.StatusFound)
+	}))
+	defer srv.Close()
+	host, _ := url.Parse(srv.URL)
+	allowed := parser.URLOptions{AllowedHosts: []string{host.Hostname()}, Client: srv.Client()}
+
+	tests := []struct {
+		name, url string
+		opts      parser.URLOptions
+		want      string
+	}{
+		{"host not allowed", srv.URL, parser.URLOptions{AllowedHosts: []string{"lists.example.com"}}, "not in the allowed"},
+		{"plain http", "http://" + host.Host + "/list.csv", allowed, "https"},
+		{"redirect off the allowlist", srv.URL + "/list.csv", allowed, "redirect blocked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.OpenURL(tt.url, tt.opts, parser.ReadOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
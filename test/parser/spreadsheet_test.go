@@ -0,0 +1,178 @@
+package parser
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// writeZip writes parts into a zip file under the test's temp dir.
+func writeZip(t *testing.T, name string, parts map[string]string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, body := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets>
+<sheet name="Notes" sheetId="1" r:id="rId1"/>
+<sheet name="Customers" sheetId="2" r:id="rId2"/>
+</sheets>
+</workbook>`
+
+const xlsxRels = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`
+
+const xlsxShared = `<?xml version="1.0" encoding="UTF-8"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<si><t>Email</t></si>
+<si><t>Name</t></si>
+<si><t>Zip</t></si>
+<si><t>alice@example.com</t></si>
+<si><r><t>Al</t></r><r><t>ice</t></r></si>
+<si><t>00501</t></si>
+<si><t>Joined</t></si>
+</sst>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy\-mm\-dd"/><numFmt numFmtId="165" formatCode="00000"/></numFmts>
+<cellXfs count="4"><xf numFmtId="0"/><xf numFmtId="164"/><xf numFmtId="2"/><xf numFmtId="165"/></cellXfs>
+</styleSheet>`
+
+const xlsxSheet2 = `<?xml version="1.0" encoding="UTF-8"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>6</v></c><c r="E1"><v>Score</v></c></row>
+<row r="2"><c r="A2" t="s"><v>3</v></c><c r="B2" t="s"><v>4</v></c><c r="C2" t="s"><v>5</v></c><c r="D2" s="1"><v>45352</v></c><c r="E2" s="2"><v>0.30000000000000004</v></c></row>
+<row r="4"><c r="A4" t="inlineStr"><is><t>bob@example.com</t></is></c><c r="C4" s="3"><v>2134</v></c><c r="E4"><v>12345</v></c></row>
+<row r="5"><c r="A5" t="inlineStr"><is><t>ALICE@example.com</t></is></c></row>
+</sheetData>
+</worksheet>`
+
+func testXLSX(t *testing.T) string {
+	return writeZip(t, "list.xlsx", map[string]string{
+		"xl/workbook.xml":            xlsxWorkbook,
+		"xl/_rels/workbook.xml.rels": xlsxRels,
+		"xl/sharedStrings.xml":       xlsxShared,
+		"xl/styles.xml":              xlsxStyles,
+		"xl/worksheets/sheet1.xml":   `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData/></worksheet>`,
+		"xl/worksheets/sheet2.xml":   xlsxSheet2,
+	})
+}
+
+func TestParseFile_XLSX(t *testing.T) {
+	path := testXLSX(t)
+	for _, sheet := range []string{"Customers", "customers", "2"} {
+		recipients, err := parser.ParseFile(path, parser.ReadOptions{Sheet: sheet})
+		if err != nil {
+			t.Fatalf("ParseFile(%q): %v", sheet, err)
+		}
+		if len(recipients) != 2 {
+			t.Fatalf("sheet %q: expected 2 recipients (duplicate removed), got %+v", sheet, recipients)
+		}
+		a, b := recipients[0], recipients[1]
+		if a.Email != "alice@example.com" || a.Data["name"] != "Alice" || a.Data["zip"] != "00501" ||
+			a.Data["joined"] != "2024-03-01" || a.Data["score"] != "0.3" {
+			t.Errorf("first recipient = %+v", a)
+		}
+		if b.Email != "bob@example.com" || b.Data["name"] != "" || b.Data["zip"] != "02134" || b.Data["score"] != "12345" || b.Row != 1 {
+			t.Errorf("second recipient = %+v", b)
+		}
+	}
+
+	if _, err := parser.ParseFile(path, parser.ReadOptions{}); err == nil || !strings.Contains(err.Error(), "empty") {
+		t.Errorf("first sheet is empty; got %v", err)
+	}
+	if _, err := parser.ParseFile(path, parser.ReadOptions{Sheet: "Orders"}); err == nil || !strings.Contains(err.Error(), "Notes, Customers") {
+		t.Errorf("expected available sheets in error, got %v", err)
+	}
+}
+
+const odsContent = `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+<office:body><office:spreadsheet>
+<table:table table:name="Summary"><table:table-row><table:table-cell office:value-type="string"><text:p>n/a</text:p></table:table-cell></table:table-row></table:table>
+<table:table table:name="List">
+<table:table-header-rows>
+<table:table-row><table:table-cell office:value-type="string"><text:p>email</text:p></table:table-cell><table:table-cell office:value-type="string"><text:p>Name</text:p></table:table-cell><table:table-cell office:value-type="string"><text:p>Zip</text:p></table:table-cell><table:table-cell office:value-type="string"><text:p>Paid</text:p></table:table-cell><table:table-cell table:number-columns-repeated="1020"/></table:table-row>
+</table:table-header-rows>
+<table:table-row><table:table-cell office:value-type="string"><text:p>carol@example.com</text:p></table:table-cell><table:table-cell office:value-type="string"><text:p>Carol<text:s text:c="2"/>Ann</text:p></table:table-cell><table:table-cell office:value-type="string"><text:p>02134</text:p></table:table-cell><table:table-cell office:value-type="boolean" office:boolean-value="true"><text:p>TRUE</text:p></table:table-cell></table:table-row>
+<table:table-row table:number-rows-repeated="3"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+<table:table-row><table:table-cell office:value-type="string"><text:p>dave@example.com</text:p></table:table-cell><table:table-cell table:number-columns-repeated="2"/><table:table-cell office:value-type="float" office:value="42"><text:p>42.00</text:p></table:table-cell></table:table-row>
+<table:table-row table:number-rows-repeated="1048570"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+</table:table>
+</office:spreadsheet></office:body>
+</office:document-content>`
+
+func TestParseFile_ODS(t *testing.T) {
+	path := writeZip(t, "list.ods", map[string]string{"content.xml": odsContent})
+
+	for _, sheet := range []string{"List", "2"} {
+		src, err := parser.OpenFile(path, parser.ReadOptions{Sheet: sheet})
+		if err != nil {
+			t.Fatalf("OpenFile(%q): %v", sheet, err)
+		}
+		recipients, err := parser.Collect(src)
+		src.Close()
+		if err != nil {
+			t.Fatalf("Collect: %v", err)
+		}
+		if len(recipients) != 2 {
+			t.Fatalf("sheet %q: expected 2 recipients, got %+v", sheet, recipients)
+		}
+		c, d := recipients[0], recipients[1]
+		if c.Email != "carol@example.com" || c.Data["name"] != "Carol  Ann" || c.Data["zip"] != "02134" || c.Data["paid"] != "TRUE" {
+			t.Errorf("first recipient = %+v", c)
+		}
+		if d.Email != "dave@example.com" || d.Data["paid"] != "42" || d.Row != 1 {
+			t.Errorf("second recipient = %+v", d)
+		}
+	}
+
+	if _, err := parser.OpenFile(path, parser.ReadOptions{Sheet: "Missing"}); err == nil || !strings.Contains(err.Error(), "Summary, List") {
+		t.Errorf("expected available sheets in error, got %v", err)
+	}
+}
+
+func TestOpenFile_CSVRejectsSheet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.csv")
+	if err := os.WriteFile(path, []byte("email\na@example.com\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.OpenFile(path, parser.ReadOptions{Sheet: "Sheet1"}); err == nil {
+		t.Error("expected an error selecting a sheet of a CSV file")
+	}
+	recipients, err := parser.ParseFile(path, parser.ReadOptions{})
+	if err != nil || len(recipients) != 1 {
+		t.Errorf("ParseFile = %+v, %v", recipients, err)
+	}
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/parser"
+)
+
+// testDatabase creates an SQLite file with a customers table.
+func testDatabase(t *testing.T) string {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "customers.db")
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE customers (id INTEGER PRIMARY KEY, contact TEXT, name TEXT, plan TEXT, spend REAL, active BOOLEAN);
+		INSERT INTO customers (contact, name, plan, spend, active) VALUES
+			('alice@example.com', 'Alice', 'pro', 120.5, 1),
+			('not-an-email', 'Mallory', 'free', 0, 1),
+			('bob@example.com', NULL, 'free', 0, 0),
+			('ALICE@example.com', 'Alice again', 'pro', 1, 1),
+			('', 'Blank', 'free', 0, 1);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dsn
+}
+
+func TestOpenSQL_StreamsRows(t *testing.T) {
+	dsn := testDatabase(t)
+	src, err := parser.OpenSQL("sqlite3", dsn,
+		"SELECT contact AS email, name, plan, spend FROM customers ORDER BY id", parser.ReadOptions{})
+	if err != nil {
+		t.Fatalf("OpenSQL error: %v", err)
+	}
+	recipients, err := parser.Collect(parser.DedupeSource(src, parser.NewMemoryDeduper()))
+	if err != nil {
+		t.Fatalf("Collect error: %v", err)
+	}
+	if len(recipients) != 2 {
+		t.Fatalf("expected 2 recipients, got %+v", recipients)
+	}
+	a, b := recipients[0], recipients[1]
+	if a.Email != "alice@example.com" || a.Data["name"] != "Alice" || a.Data["spend"] != "120.5" || a.Row != 0 {
+		t.Errorf("first recipient = %+v", a)
+	}
+	if b.Email != "bob@example.com" || b.Data["name"] != "" || b.Row != 2 {
+		t.Errorf("second recipient = %+v", b)
+	}
+	if src.Rows() != 5 || src.Skipped() != 1 {
+		t.Errorf("Rows() = %d, Skipped() = %d; want 5 and 1", src.Rows(), src.Skipped())
+	}
+}
+
+func TestOpenSQL_ColumnMapping(t *testing.T) {
+	dsn := testDatabase(t)
+	opts := parser.ReadOptions{EmailColumn: "Contact", Rename: map[string]string{"name": "first_name"}}
+	src, err := parser.OpenSQL("sqlite", dsn, "select contact, name from customers where plan = 'pro' order by id", opts)
+	if err != nil {
+		t.Fatalf("OpenSQL error: %v", err)
+	}
+	defer src.Close()
+	r, err := src.Next()
+	if err != nil || r.Email != "alice@example.com" || r.Data["first_name"] != "Alice" {
+		t.Errorf("Next() = %+v, %v", r, err)
+	}
+}
+
+func TestOpenSQL_Errors(t *testing.T) {
+	dsn := testDatabase(t)
+	tests := []struct {
+		name, driver, query, want string
+	}{
+		{"no email column", "sqlite", "SELECT contact, name FROM customers", "'email' column"},
+		{"not a select", "sqlite", "DELETE FROM customers", "SELECT"},
+		{"bad query", "sqlite", "SELECT email FROM nowhere", "failed to run query"},
+		{"unknown driver", "oracle", "SELECT 1 AS email", "unsupported database driver"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parser.OpenSQL(tt.driver, dsn, tt.query, parser.ReadOptions{})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -36,7 +36,17 @@ func TestExtractFieldNames(t *testing.T) {
 		{
 			name:     "no fields (string only)",
 			exprStr:  `"hello"`,
-			expected: []string{"hello"},
+			expected: nil,
+		},
+		{
+			name:     "functions and dotted fields",
+			exprStr:  `domain(email) in ["a.com"] && !isEmpty(address.city) && matches(name, "^x")`,
+			expected: []string{"email", "address.city", "name"},
+		},
+		{
+			name:     "column named like a function",
+			exprStr:  `domain == "x" || domain(email) == "y"`,
+			expected: []string{"domain", "email"},
 		},
 	}
 
@@ -75,10 +85,15 @@ func TestValidateFields(t *testing.T) {
 			wantErr:   false,
 		},
 		{
-			name:      "field not in csv but allowed",
-			exprInput: `department == "Engineering"`,
+			name:      "email is always available",
+			exprInput: `email endsWith "@example.com"`,
 			wantErr:   false,
 		},
+		{
+			name:      "field not in csv",
+			exprInput: `department == "Engineering"`,
+			wantErr:   true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -120,7 +135,24 @@ func TestValidateFields_MultipleFields(t *testing.T) {
 	expr, err := parser.ParseExpression(`name == "John" && age > 18 && city contains "York"`)
 	assert.NoError(t, err)
 
-	// Should not error even with 'age' field not present (expr handles undefined)
+	// age is not a column, so the filter would match nobody.
 	err = parser.ValidateFields(expr, recipients)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown field(s) in filter: age;")
+
+	expr, err = parser.ParseExpression(`compnay == "Acme" || citty == "NYC"`)
+	assert.NoError(t, err)
+	err = parser.ValidateFields(expr, recipients)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "compnay (did you mean company?), citty (did you mean city?)")
+}
+
+func TestSplitClauses(t *testing.T) {
+	clauses, err := parser.SplitClauses(`tier == "Gold" and (a || b) && contains(address.city, "par") && !isEmpty(name) && matches(name, "^a")`)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`tier == "Gold"`, `a || b`, `contains(address.city, "par")`, `!isEmpty(name)`, `matches(name, "^a")`}, clauses)
+
+	clauses, err = parser.SplitClauses(`a || b && c`)
 	assert.NoError(t, err)
+	assert.Len(t, clauses, 1)
 }
@@ -163,3 +163,30 @@ func TestJobExecution(t *testing.T) {
 		time.Sleep(20 * time.Millisecond)
 	}
 }
+
+func TestReattachHandlers_LaterJobs(t *testing.T) {
+	db := setupTestDB(t)
+	defer teardownTestDB(db)
+
+	sched := scheduler.NewScheduler(db, logger.New("test-scheduler"))
+	defer sched.Stop()
+
+	doneChan := make(chan string, 1)
+	sched.ReattachHandlers(func(j types.Job) error {
+		doneChan <- j.ID
+		return nil
+	})
+
+	// A job stored directly, as another process would, runs with the
+	// reattached handler.
+	job, err := scheduler.NewJob(types.CLIArgs{Subject: "Stored Job"}, time.Now(), "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, db.SaveJob(&job))
+
+	select {
+	case id := <-doneChan:
+		assert.Equal(t, job.ID, id)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stored job did not run")
+	}
+}
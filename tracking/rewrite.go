@@ -0,0 +1,23 @@
+package tracking
+
+import (
+	"html"
+
+	"github.com/bravo1goingdark/mailgrid/utils"
+)
+
+// Rewrite returns body with every http(s) <a href> replaced by a signed click
+// URL and a 1x1 open pixel inserted before </body> (or appended when the
+// document has none). Links for which skip returns true, and mailto:, tel:
+// and fragment links, are left alone. Everything other than the rewritten
+// <a> tags is copied byte for byte.
+func (s *Signer) Rewrite(body, jobID, rcpt string, skip func(href string) bool) string {
+	body = utils.RewriteLinks(body, func(href string) (string, bool) {
+		if !utils.IsHTTPURL(href) || (skip != nil && skip(href)) {
+			return "", false
+		}
+		return s.ClickURL(jobID, rcpt, href), true
+	})
+	pixel := `<img src="` + html.EscapeString(s.OpenURL(jobID, rcpt)) + `" width="1" height="1" alt="" style="display:block;border:0;width:1px;height:1px">`
+	return utils.InsertBeforeBodyEnd(body, pixel)
+}
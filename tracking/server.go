@@ -0,0 +1,158 @@
+package tracking
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/internal/token"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+)
+
+// pixelGIF is a transparent 1x1 GIF.
+var pixelGIF = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// Recorder receives tracked events. Record is called on the request path and
+// must not block.
+type Recorder interface {
+	Record(ev types.EngagementEvent)
+}
+
+// RecorderFunc adapts a function to Recorder.
+type RecorderFunc func(ev types.EngagementEvent)
+
+// Record calls f(ev).
+func (f RecorderFunc) Record(ev types.EngagementEvent) { f(ev) }
+
+// Handler serves the URLs issued by a Signer. The last two path segments are
+// "o/<token>" or "c/<token>", so it can be mounted under any prefix.
+//
+//   - GET o/<token> returns a transparent GIF and records an open. The GIF is
+//     served even for bad tokens so broken images never show in a message.
+//   - GET c/<token> records a click and redirects (302) to the signed target.
+//
+// HEAD requests, which link scanners use, are answered but not recorded.
+type Handler struct {
+	signer *Signer
+	rec    Recorder
+	now    func() time.Time
+}
+
+// NewHandler returns a Handler verifying tokens with signer and passing
+// events to rec.
+func NewHandler(signer *Signer, rec Recorder) *Handler {
+	return &Handler{signer: signer, rec: rec, now: time.Now}
+}
+
+// purposes maps the path segment of an endpoint to the purpose its tokens
+// are signed for.
+var purposes = map[string]string{"o": token.Open, "c": token.Click}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+	kind, tok := parts[len(parts)-2], parts[len(parts)-1]
+	jobID, rcpt, target, err := h.signer.verify(tok, purposes[kind])
+	record := err == nil && r.Method == http.MethodGet
+
+	switch kind {
+	case "o":
+		if record {
+			h.rec.Record(types.EngagementEvent{CampaignID: jobID, Email: rcpt, Kind: Open, At: h.now()})
+		}
+		w.Header().Set("Content-Type", "image/gif")
+		_, _ = w.Write(pixelGIF)
+	case "c":
+		lower := strings.ToLower(target)
+		if err != nil || !(strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")) {
+			http.Error(w, "invalid link", http.StatusBadRequest)
+			return
+		}
+		if record {
+			h.rec.Record(types.EngagementEvent{CampaignID: jobID, Email: rcpt, Kind: Click, URL: target, At: h.now()})
+		}
+		http.Redirect(w, r, target, http.StatusFound)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// Buffer is a Recorder that holds events in memory until Flush hands them to
+// a writer. Writes can then be batched, and retried while the database is
+// locked by a running campaign.
+type Buffer struct {
+	mu      sync.Mutex
+	events  []types.EngagementEvent
+	max     int
+	dropped int
+}
+
+// NewBuffer returns a Buffer holding at most max events; older events are
+// dropped (and counted) beyond that.
+func NewBuffer(max int) *Buffer {
+	return &Buffer{max: max}
+}
+
+// Record queues ev.
+func (b *Buffer) Record(ev types.EngagementEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.max > 0 && len(b.events) >= b.max {
+		b.events = b.events[1:]
+		b.dropped++
+	}
+	b.events = append(b.events, ev)
+}
+
+// Len returns the number of queued events.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.events)
+}
+
+// Flush passes the queued events to write. If write fails they are put back
+// in front of anything recorded meanwhile.
+func (b *Buffer) Flush(write func([]types.EngagementEvent) error) error {
+	b.mu.Lock()
+	events := b.events
+	b.events = nil
+	dropped := b.dropped
+	b.dropped = 0
+	b.mu.Unlock()
+
+	if dropped > 0 {
+		log.Printf("tracking: buffer full, dropped %d event(s)", dropped)
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if err := write(events); err != nil {
+		b.mu.Lock()
+		b.events = append(events, b.events...)
+		if b.max > 0 && len(b.events) > b.max {
+			b.dropped += len(b.events) - b.max
+			b.events = b.events[len(b.events)-b.max:]
+		}
+		b.mu.Unlock()
+		return err
+	}
+	return nil
+}
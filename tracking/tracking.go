@@ -0,0 +1,70 @@
+// Package tracking adds open and click tracking to rendered HTML bodies and
+// serves the pixel and redirect endpoints the rewritten links point to.
+package tracking
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/bravo1goingdark/mailgrid/internal/token"
+)
+
+// Event kinds recorded by the Handler.
+const (
+	Open  = "open"
+	Click = "click"
+)
+
+// Signer builds and verifies tracking URLs for a base URL such as
+// "https://t.example.com". Opens are served at <base>/o/<token> and clicks
+// at <base>/c/<token>; tokens are signed with internal/token, so the
+// redirect endpoint cannot be abused as an open redirect.
+type Signer struct {
+	baseURL string
+	secret  []byte
+}
+
+// NewSigner returns a Signer issuing URLs under baseURL.
+func NewSigner(baseURL, secret string) (*Signer, error) {
+	if secret == "" {
+		return nil, errors.New("tracking secret is required")
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("tracking base_url must be an absolute http(s) URL")
+	}
+	return &Signer{baseURL: strings.TrimRight(baseURL, "/"), secret: []byte(secret)}, nil
+}
+
+// OpenURL returns the pixel URL for recipient rcpt of campaign jobID.
+func (s *Signer) OpenURL(jobID, rcpt string) string {
+	return s.baseURL + "/o/" + token.Sign(s.secret, token.Open, payload(jobID, rcpt))
+}
+
+// ClickURL returns a redirect URL to target for recipient rcpt of campaign
+// jobID.
+func (s *Signer) ClickURL(jobID, rcpt, target string) string {
+	v := payload(jobID, rcpt)
+	v.Set("u", target)
+	return s.baseURL + "/c/" + token.Sign(s.secret, token.Click, v)
+}
+
+// verify decodes a token issued for purpose, token.Open or token.Click, into
+// the campaign, recipient and (for clicks) target it was issued for.
+func (s *Signer) verify(tok, purpose string) (jobID, rcpt, target string, err error) {
+	v, err := token.Verify(s.secret, purpose, tok)
+	if err != nil || v.Get("e") == "" {
+		return "", "", "", token.ErrInvalid
+	}
+	return v.Get("j"), v.Get("e"), v.Get("u"), nil
+}
+
+func payload(jobID, rcpt string) url.Values {
+	v := url.Values{}
+	v.Set("e", strings.ToLower(strings.TrimSpace(rcpt)))
+	if jobID != "" {
+		v.Set("j", jobID)
+	}
+	return v
+}
@@ -0,0 +1,160 @@
+package tracking
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/internal/token"
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+)
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	s, err := NewSigner("https://t.example.com/", "s3cret")
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	return s
+}
+
+func TestNewSigner_Validation(t *testing.T) {
+	if _, err := NewSigner("https://t.example.com", ""); err == nil {
+		t.Error("expected an error without a secret")
+	}
+	if _, err := NewSigner("t.example.com/track", "s"); err == nil {
+		t.Error("expected an error for a relative base URL")
+	}
+}
+
+var hrefRe = regexp.MustCompile(`href="([^"]*)"`)
+
+func TestRewrite(t *testing.T) {
+	s := newTestSigner(t)
+	body := `<html><body><p>Hi <b>Ann</b> &amp; co</p>` +
+		`<a href="https://example.com/a?x=1&amp;y=2" class="btn">Shop</a>` +
+		`<a href="mailto:help@example.com">Mail</a>` +
+		`<a href="#top">Top</a>` +
+		`<a href="https://example.com/unsub">Unsubscribe</a>` +
+		`</body></html>`
+
+	out := s.Rewrite(body, "job-1", "ann@example.com", func(href string) bool {
+		return href == "https://example.com/unsub"
+	})
+
+	if !strings.HasPrefix(out, `<html><body><p>Hi <b>Ann</b> &amp; co</p>`) {
+		t.Errorf("untouched markup changed: %s", out)
+	}
+	hrefs := hrefRe.FindAllStringSubmatch(out, -1)
+	if len(hrefs) != 4 {
+		t.Fatalf("hrefs = %v", hrefs)
+	}
+	if !strings.HasPrefix(hrefs[0][1], "https://t.example.com/c/") {
+		t.Errorf("link not rewritten: %s", hrefs[0][1])
+	}
+	for i, want := range []string{"mailto:help@example.com", "#top", "https://example.com/unsub"} {
+		if hrefs[i+1][1] != want {
+			t.Errorf("href %d = %q, want %q", i+1, hrefs[i+1][1], want)
+		}
+	}
+	if !strings.Contains(out, `class="btn"`) {
+		t.Errorf("other attributes lost: %s", out)
+	}
+	if !regexp.MustCompile(`<img src="https://t\.example\.com/o/[^"]+"[^>]*></body></html>$`).MatchString(out) {
+		t.Errorf("pixel not inserted before </body>: %s", out)
+	}
+
+	// The click token decodes to the original (unescaped) target.
+	tok := hrefs[0][1][strings.LastIndex(hrefs[0][1], "/")+1:]
+	job, rcpt, target, err := s.verify(strings.ReplaceAll(tok, "&amp;", "&"), token.Click)
+	if err != nil || job != "job-1" || rcpt != "ann@example.com" || target != "https://example.com/a?x=1&y=2" {
+		t.Errorf("verify = %q %q %q %v", job, rcpt, target, err)
+	}
+
+	if frag := s.Rewrite(`<p>no body tag</p>`, "job-1", "ann@example.com", nil); !strings.HasPrefix(frag, `<p>no body tag</p><img `) {
+		t.Errorf("pixel not appended to fragment: %s", frag)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := newTestSigner(t)
+	var got []types.EngagementEvent
+	h := NewHandler(s, RecorderFunc(func(ev types.EngagementEvent) { got = append(got, ev) }))
+
+	do := func(method, u string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, u, nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, s.OpenURL("job-1", "ann@example.com"))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/gif" || rec.Body.Len() == 0 {
+		t.Errorf("open: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if rec.Header().Get("Cache-Control") == "" {
+		t.Error("pixel must not be cacheable")
+	}
+
+	rec = do(http.MethodGet, s.ClickURL("job-1", "ann@example.com", "https://example.com/a"))
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://example.com/a" {
+		t.Errorf("click: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	// Link scanners' HEAD requests are answered but not recorded.
+	if rec := do(http.MethodHead, s.ClickURL("job-1", "ann@example.com", "https://example.com/a")); rec.Code != http.StatusFound {
+		t.Errorf("HEAD click: %d", rec.Code)
+	}
+
+	if len(got) != 2 || got[0].Kind != Open || got[1].Kind != Click || got[1].URL != "https://example.com/a" ||
+		got[0].CampaignID != "job-1" || got[0].Email != "ann@example.com" {
+		t.Errorf("recorded = %+v", got)
+	}
+
+	// Forged tokens: the pixel still renders, the redirect is refused.
+	if rec := do(http.MethodGet, "/o/forged.token"); rec.Code != http.StatusOK {
+		t.Errorf("forged open: %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/c/forged.token"); rec.Code != http.StatusBadRequest {
+		t.Errorf("forged click: %d", rec.Code)
+	}
+	// Tokens are only accepted by the endpoint they were issued for.
+	if rec := do(http.MethodGet, strings.Replace(s.OpenURL("job-1", "ann@example.com"), "/o/", "/c/", 1)); rec.Code != http.StatusBadRequest {
+		t.Errorf("open token on the redirect: %d", rec.Code)
+	}
+	unsub := token.Sign([]byte("s3cret"), token.Unsubscribe, url.Values{"e": {"ann@example.com"}, "u": {"https://example.com/"}})
+	do(http.MethodGet, "/o/"+unsub)
+	if rec := do(http.MethodGet, "/c/"+unsub); rec.Code != http.StatusBadRequest {
+		t.Errorf("unsubscribe token on the redirect: %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, s.OpenURL("job-1", "ann@example.com")); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: %d", rec.Code)
+	}
+	if len(got) != 2 {
+		t.Errorf("unexpected events recorded: %+v", got[2:])
+	}
+}
+
+func TestBuffer_KeepsEventsOnFailedFlush(t *testing.T) {
+	b := NewBuffer(3)
+	for _, e := range []string{"a", "b", "c", "d"} {
+		b.Record(types.EngagementEvent{Email: e, Kind: Open})
+	}
+	if b.Len() != 3 {
+		t.Fatalf("Len = %d, want 3 (oldest dropped)", b.Len())
+	}
+
+	if err := b.Flush(func([]types.EngagementEvent) error { return http.ErrServerClosed }); err == nil {
+		t.Fatal("expected the write error")
+	}
+	var flushed []types.EngagementEvent
+	if err := b.Flush(func(evs []types.EngagementEvent) error { flushed = evs; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(flushed) != 3 || flushed[0].Email != "b" || b.Len() != 0 {
+		t.Errorf("flushed = %+v, remaining %d", flushed, b.Len())
+	}
+}
@@ -0,0 +1,164 @@
+package unsubscribe
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+)
+
+// Reason is recorded on suppressions created through an unsubscribe link.
+const Reason = "unsubscribe"
+
+// maxFormBytes bounds POST bodies; a one-click request is a single field.
+const maxFormBytes = 16 << 10
+
+// Store records unsubscribes. *database.BoltDBClient satisfies it.
+type Store interface {
+	SaveSuppression(s *types.Suppression) (bool, error)
+}
+
+// ErrQueued is returned by a Store that could not record an unsubscribe yet
+// but has durably queued it to be recorded later. The request is answered
+// with 202 Accepted.
+var ErrQueued = errors.New("unsubscribe queued")
+
+// Handler serves the links issued by a Signer. The token is the last path
+// segment, so the handler can be mounted under any prefix.
+//
+//   - GET shows a confirmation page. Nothing is recorded, so link scanners
+//     that prefetch URLs cannot unsubscribe anyone.
+//   - POST with List-Unsubscribe=One-Click (RFC 8058, sent by mailbox
+//     providers from the List-Unsubscribe-Post header) unsubscribes at once.
+//   - POST from the confirmation form unsubscribes from the link's list or,
+//     with scope=all, from everything.
+type Handler struct {
+	signer *Signer
+	store  Store
+	now    func() time.Time
+}
+
+// NewHandler returns a Handler verifying tokens with signer and writing
+// suppressions to store.
+func NewHandler(signer *Signer, store Store) *Handler {
+	return &Handler{signer: signer, store: store, now: time.Now}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	// The token is a credential for this address; keep it out of Referer.
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	sub, err := h.signer.Verify(path.Base(r.URL.Path))
+	if err != nil {
+		h.render(w, http.StatusBadRequest, page{Title: "Invalid link", Message: "This unsubscribe link is invalid. Please use the link from the most recent email you received."})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		h.render(w, http.StatusOK, page{Title: "Unsubscribe", Email: sub.Email, List: sub.List, Confirm: true})
+	case http.MethodPost:
+		r.Body = http.MaxBytesReader(w, r.Body, maxFormBytes)
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "malformed request", http.StatusBadRequest)
+			return
+		}
+		oneClick := r.PostForm.Get("List-Unsubscribe") == "One-Click"
+		scope := sub.List
+		if !oneClick && r.PostForm.Get("scope") == "all" {
+			scope = ""
+		}
+		status := http.StatusOK
+		if err := h.unsubscribe(sub, scope); errors.Is(err, ErrQueued) {
+			status = http.StatusAccepted
+		} else if err != nil {
+			log.Printf("unsubscribe: failed to record %s: %v", sub.Email, err)
+			http.Error(w, "could not record unsubscribe, please try again", http.StatusInternalServerError)
+			return
+		}
+		if oneClick {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte("unsubscribed\n"))
+			return
+		}
+		h.render(w, status, page{Title: "Unsubscribed", Email: sub.Email, List: scope, Done: true})
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) unsubscribe(sub Subscription, scope string) error {
+	source := sub.Campaign
+	if source == "" {
+		source = "link"
+	}
+	_, err := h.store.SaveSuppression(&types.Suppression{
+		Email:     sub.Email,
+		Scope:     scope,
+		Reason:    Reason,
+		Source:    source,
+		CreatedAt: h.now(),
+	})
+	return err
+}
+
+type page struct {
+	Title   string
+	Message string
+	Email   string
+	List    string
+	Confirm bool
+	Done    bool
+}
+
+func (h *Handler) render(w http.ResponseWriter, status int, p page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := pageTemplate.Execute(w, p); err != nil {
+		log.Printf("unsubscribe: render page: %v", err)
+	}
+}
+
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", sans-serif; background: #f5f6f8; color: #222; }
+main { max-width: 28rem; margin: 4rem auto; background: #fff; padding: 2rem; border-radius: 8px; box-shadow: 0 1px 4px rgba(0,0,0,.08); }
+button { display: block; width: 100%; margin-top: .75rem; padding: .7rem; font-size: 1rem; border: 0; border-radius: 6px; background: #2563eb; color: #fff; cursor: pointer; }
+button.secondary { background: #e5e7eb; color: #222; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+{{- if .Confirm}}
+<p>Stop sending emails to <strong>{{.Email}}</strong>?</p>
+<form method="post">
+{{- if .List}}
+<button type="submit" name="scope" value="list">Unsubscribe from {{.List}}</button>
+<button type="submit" name="scope" value="all" class="secondary">Unsubscribe from all emails</button>
+{{- else}}
+<button type="submit" name="scope" value="all">Unsubscribe</button>
+{{- end}}
+</form>
+{{- else if .Done}}
+<p><strong>{{.Email}}</strong> will no longer receive {{if .List}}{{.List}} emails{{else}}emails from us{{end}}.</p>
+{{- else}}
+<p>{{.Message}}</p>
+{{- end}}
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,70 @@
+// Package unsubscribe signs per-recipient unsubscribe links and serves the
+// endpoint they point to.
+package unsubscribe
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/bravo1goingdark/mailgrid/internal/token"
+)
+
+// ErrInvalidToken is returned for tokens that are malformed or whose
+// signature does not match.
+var ErrInvalidToken = token.ErrInvalid
+
+// Subscription identifies who a link unsubscribes and from what.
+type Subscription struct {
+	Email    string // recipient address, lower-cased
+	List     string // --list the mail was sent for; empty for none
+	Campaign string // job ID of the sending run; may be empty
+}
+
+// Signer builds and verifies unsubscribe links. Tokens are signed with
+// internal/token, so links cannot be forged for another address, and only
+// tokens issued for unsubscribing are accepted.
+type Signer struct {
+	baseURL string
+	secret  []byte
+}
+
+// NewSigner returns a Signer issuing links under baseURL, e.g.
+// "https://mail.example.com/unsubscribe".
+func NewSigner(baseURL, secret string) (*Signer, error) {
+	if secret == "" {
+		return nil, errors.New("unsubscribe secret is required")
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("unsubscribe base_url must be an absolute http(s) URL")
+	}
+	return &Signer{baseURL: strings.TrimRight(baseURL, "/"), secret: []byte(secret)}, nil
+}
+
+// URL returns the signed unsubscribe link for s.
+func (g *Signer) URL(s Subscription) string {
+	return g.baseURL + "/" + g.Token(s)
+}
+
+// Token returns the signed token for s.
+func (g *Signer) Token(s Subscription) string {
+	v := url.Values{}
+	v.Set("e", strings.ToLower(strings.TrimSpace(s.Email)))
+	if s.List != "" {
+		v.Set("l", s.List)
+	}
+	if s.Campaign != "" {
+		v.Set("c", s.Campaign)
+	}
+	return token.Sign(g.secret, token.Unsubscribe, v)
+}
+
+// Verify checks tok and returns the subscription it was issued for.
+func (g *Signer) Verify(tok string) (Subscription, error) {
+	v, err := token.Verify(g.secret, token.Unsubscribe, tok)
+	if err != nil || v.Get("e") == "" {
+		return Subscription{}, ErrInvalidToken
+	}
+	return Subscription{Email: v.Get("e"), List: v.Get("l"), Campaign: v.Get("c")}, nil
+}
@@ -0,0 +1,156 @@
+package unsubscribe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/bravo1goingdark/mailgrid/internal/types"
+	"github.com/bravo1goingdark/mailgrid/tracking"
+)
+
+type memStore struct {
+	saved []types.Suppression
+}
+
+func (m *memStore) SaveSuppression(s *types.Suppression) (bool, error) {
+	m.saved = append(m.saved, *s)
+	return true, nil
+}
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	s, err := NewSigner("https://mail.example.com/unsubscribe/", "s3cret")
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	return s
+}
+
+func TestSigner_RoundTrip(t *testing.T) {
+	s := newTestSigner(t)
+	sub := Subscription{Email: "Alice@Example.com", List: "news & offers", Campaign: "mailgrid-42"}
+	link := s.URL(sub)
+	if !strings.HasPrefix(link, "https://mail.example.com/unsubscribe/") || strings.ContainsAny(link[8:], "&?=+ ") {
+		t.Errorf("link should be a plain path under the base URL: %q", link)
+	}
+	got, err := s.Verify(link[strings.LastIndexByte(link, '/')+1:])
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got.Email != "alice@example.com" || got.List != sub.List || got.Campaign != sub.Campaign {
+		t.Errorf("Verify = %+v", got)
+	}
+}
+
+func TestSigner_RejectsForgedTokens(t *testing.T) {
+	s := newTestSigner(t)
+	token := s.Token(Subscription{Email: "alice@example.com"})
+	other, _ := NewSigner("https://mail.example.com/unsubscribe", "other")
+	forged := other.Token(Subscription{Email: "bob@example.com"})
+	swapped := strings.SplitN(forged, ".", 2)[0] + "." + strings.SplitN(token, ".", 2)[1]
+
+	for _, bad := range []string{"", "nodot", token + "x", forged, swapped} {
+		if _, err := s.Verify(bad); err != ErrInvalidToken {
+			t.Errorf("Verify(%q) = %v, want ErrInvalidToken", bad, err)
+		}
+	}
+}
+
+func TestSigner_RejectsTrackingTokens(t *testing.T) {
+	s := newTestSigner(t)
+	// Tracked links signed with the same secret must not unsubscribe.
+	tr, err := tracking.NewSigner("https://t.example.com", "s3cret")
+	if err != nil {
+		t.Fatalf("tracking.NewSigner: %v", err)
+	}
+	for _, link := range []string{tr.OpenURL("mailgrid-42", "alice@example.com"), tr.ClickURL("mailgrid-42", "alice@example.com", "https://example.com/")} {
+		if _, err := s.Verify(link[strings.LastIndexByte(link, '/')+1:]); err != ErrInvalidToken {
+			t.Errorf("Verify(%q) = %v, want ErrInvalidToken", link, err)
+		}
+	}
+}
+
+func TestNewSigner_Validation(t *testing.T) {
+	if _, err := NewSigner("https://example.com/u", ""); err == nil {
+		t.Error("expected an error without a secret")
+	}
+	if _, err := NewSigner("/relative", "x"); err == nil {
+		t.Error("expected an error for a relative base URL")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := newTestSigner(t)
+	store := &memStore{}
+	h := NewHandler(s, store)
+	path := "/unsubscribe/" + s.Token(Subscription{Email: "alice@example.com", List: "newsletter", Campaign: "mailgrid-42"})
+
+	// GET only shows the confirmation page.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "Unsubscribe from newsletter") {
+		t.Errorf("GET = %d:\n%s", rec.Code, rec.Body.String())
+	}
+	if len(store.saved) != 0 {
+		t.Fatalf("GET must not unsubscribe, saved %+v", store.saved)
+	}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// RFC 8058 one-click unsubscribes from the link's list.
+	rec = post(url.Values{"List-Unsubscribe": {"One-Click"}})
+	if rec.Code != http.StatusOK || len(store.saved) != 1 {
+		t.Fatalf("one-click = %d, saved %+v", rec.Code, store.saved)
+	}
+	if got := store.saved[0]; got.Email != "alice@example.com" || got.Scope != "newsletter" || got.Reason != Reason || got.Source != "mailgrid-42" {
+		t.Errorf("one-click saved %+v", got)
+	}
+
+	// The form's "all" button suppresses globally.
+	rec = post(url.Values{"scope": {"all"}})
+	if rec.Code != http.StatusOK || len(store.saved) != 2 || store.saved[1].Scope != "" {
+		t.Errorf("scope=all = %d, saved %+v", rec.Code, store.saved)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/unsubscribe/garbage", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid token = %d, want 400", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, path, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE = %d, want 405", rec.Code)
+	}
+}
+
+// queueStore queues every unsubscribe, like a store whose database is locked.
+type queueStore struct{}
+
+func (queueStore) SaveSuppression(*types.Suppression) (bool, error) { return false, ErrQueued }
+
+func TestHandler_Queued(t *testing.T) {
+	s := newTestSigner(t)
+	h := NewHandler(s, queueStore{})
+	path := "/unsubscribe/" + s.Token(Subscription{Email: "alice@example.com", List: "newsletter"})
+
+	for _, form := range []url.Values{{"List-Unsubscribe": {"One-Click"}}, {"scope": {"all"}}} {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusAccepted {
+			t.Errorf("%v = %d, want 202", form, rec.Code)
+		}
+	}
+}
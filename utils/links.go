@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// IsHTTPURL reports whether href is an absolute http or https URL.
+func IsHTTPURL(href string) bool {
+	lower := strings.ToLower(strings.TrimSpace(href))
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// RewriteLinks passes the href of every <a> tag in body to fn and, when fn
+// reports true, replaces it with the returned URL. Only the rewritten tags
+// are re-serialised; everything else is copied byte for byte. Input the HTML
+// tokenizer cannot read is returned unchanged.
+func RewriteLinks(body string, fn func(href string) (string, bool)) string {
+	var out bytes.Buffer
+	out.Grow(len(body) + len(body)/8)
+
+	z := html.NewTokenizer(strings.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return body
+			}
+			return out.String()
+		}
+		if tt == html.StartTagToken || tt == html.SelfClosingTagToken {
+			raw := append([]byte(nil), z.Raw()...)
+			tok := z.Token()
+			if tok.Data == "a" && rewriteHref(&tok, fn) {
+				out.WriteString(tok.String())
+				continue
+			}
+			out.Write(raw)
+			continue
+		}
+		out.Write(z.Raw())
+	}
+}
+
+func rewriteHref(tok *html.Token, fn func(string) (string, bool)) bool {
+	for i, a := range tok.Attr {
+		if a.Namespace != "" || a.Key != "href" {
+			continue
+		}
+		href, ok := fn(strings.TrimSpace(a.Val))
+		if !ok {
+			return false
+		}
+		tok.Attr[i].Val = href
+		return true
+	}
+	return false
+}
+
+// InsertBeforeBodyEnd returns body with snippet inserted before the first
+// </body> tag, or appended when there is none.
+func InsertBeforeBodyEnd(body, snippet string) string {
+	z := html.NewTokenizer(strings.NewReader(body))
+	offset := 0
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return body + snippet
+		}
+		raw := z.Raw()
+		if tt == html.EndTagToken {
+			if name, _ := z.TagName(); string(name) == "body" {
+				return body[:offset] + snippet + body[offset:]
+			}
+		}
+		offset += len(raw)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"text/template/parse"
 	"time"
 
 	"github.com/bravo1goingdark/mailgrid/parser"
@@ -98,6 +99,17 @@ func canonicalPath(path string) string {
 	return path
 }
 
+// UnsubscribePlaceholder is what {{ unsubscribeURL }} renders to. Parsed
+// templates are cached and shared by every recipient, so the per-recipient
+// link is substituted into the rendered body afterwards (see
+// cli.UnsubscribeLinks).
+const UnsubscribePlaceholder = "mailgrid:unsubscribe-url"
+
+// templateFuncs are the helpers available to body templates.
+var templateFuncs = template.FuncMap{
+	"unsubscribeURL": func() template.URL { return UnsubscribePlaceholder },
+}
+
 // LoadTemplate parses and caches an HTML template file by its path.
 // The path is canonicalized so "./template.html" and "template.html" share a cache entry.
 func LoadTemplate(path string) (*template.Template, error) {
@@ -111,7 +123,7 @@ func LoadTemplate(path string) (*template.Template, error) {
 		return nil, fmt.Errorf("template file not found: %s", path)
 	}
 
-	tmpl, err := template.ParseFiles(abs)
+	tmpl, err := template.New(filepath.Base(abs)).Funcs(templateFuncs).ParseFiles(abs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -121,23 +133,71 @@ func LoadTemplate(path string) (*template.Template, error) {
 }
 
 // RenderTemplate renders an HTML template with the given recipient's data.
-// Recipient fields are accessible in templates as {{ .email }}, {{ .name }}, etc.
+// Recipient fields are accessible in templates as {{ .email }}, {{ .name }}, etc.;
+// nested JSON values as {{ .address.city }} or {{ range .orders }}.
 func RenderTemplate(recipient parser.Recipient, templatePath string) (string, error) {
 	tmpl, err := LoadTemplate(templatePath)
 	if err != nil {
 		return "", err
 	}
 
-	data := make(map[string]any)
-	data["email"] = recipient.Email
-	for key, value := range recipient.Data {
-		data[key] = value
-	}
-
 	var out bytes.Buffer
-	if err := tmpl.Execute(&out, data); err != nil {
+	if err := tmpl.Execute(&out, recipient.TemplateData()); err != nil {
 		return "", fmt.Errorf("failed to execute template: %w", err)
 	}
 
 	return out.String(), nil
 }
+
+// TemplateCalls reports whether the template at path calls the helper fn
+// anywhere, including inside if/range/with blocks and defined templates.
+func TemplateCalls(path, fn string) (bool, error) {
+	tmpl, err := LoadTemplate(path)
+	if err != nil {
+		return false, err
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil && nodeCalls(t.Tree.Root, fn) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func nodeCalls(node parse.Node, fn string) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, c := range n.Nodes {
+			if nodeCalls(c, fn) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return nodeCalls(n.Pipe, fn)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				if nodeCalls(arg, fn) {
+					return true
+				}
+			}
+		}
+	case *parse.IdentifierNode:
+		return n.Ident == fn
+	case *parse.IfNode:
+		return nodeCalls(n.Pipe, fn) || nodeCalls(n.List, fn) || nodeCalls(n.ElseList, fn)
+	case *parse.RangeNode:
+		return nodeCalls(n.Pipe, fn) || nodeCalls(n.List, fn) || nodeCalls(n.ElseList, fn)
+	case *parse.WithNode:
+		return nodeCalls(n.Pipe, fn) || nodeCalls(n.List, fn) || nodeCalls(n.ElseList, fn)
+	case *parse.TemplateNode:
+		return nodeCalls(n.Pipe, fn)
+	}
+	return false
+}
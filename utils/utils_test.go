@@ -131,3 +131,35 @@ func TestReadTextInput(t *testing.T) {
 		}
 	})
 }
+
+func TestRewriteLinks(t *testing.T) {
+	body := `<!-- <a href="https://a.example/"> --><p title="x">A &amp; B</p><a class=c href="https://a.example/?q=1&amp;r=2">A</a><A HREF="mailto:x@example.com">M</A>`
+	var seen []string
+	out := RewriteLinks(body, func(href string) (string, bool) {
+		seen = append(seen, href)
+		if !IsHTTPURL(href) {
+			return "", false
+		}
+		return href + "&s=3", true
+	})
+	want := `<!-- <a href="https://a.example/"> --><p title="x">A &amp; B</p><a class="c" href="https://a.example/?q=1&amp;r=2&amp;s=3">A</a><A HREF="mailto:x@example.com">M</A>`
+	if out != want {
+		t.Errorf("RewriteLinks:\n got %s\nwant %s", out, want)
+	}
+	if len(seen) != 2 || seen[0] != "https://a.example/?q=1&r=2" {
+		t.Errorf("hrefs passed to fn = %q (comments must be skipped, entities decoded)", seen)
+	}
+}
+
+func TestInsertBeforeBodyEnd(t *testing.T) {
+	tests := []struct{ body, want string }{
+		{`<html><body><p>x</p></body></html>`, `<html><body><p>x</p>[X]</body></html>`},
+		{`<p>fragment</p>`, `<p>fragment</p>[X]`},
+		{`<!-- </body> --><BODY>y</BODY>`, `<!-- </body> --><BODY>y[X]</BODY>`},
+	}
+	for _, tt := range tests {
+		if got := InsertBeforeBodyEnd(tt.body, "[X]"); got != tt.want {
+			t.Errorf("InsertBeforeBodyEnd(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
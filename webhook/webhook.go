@@ -17,19 +17,24 @@ import (
 
 // CampaignResult represents job completion data sent to webhook
 type CampaignResult struct {
-	JobID                string    `json:"job_id"`
-	Status               string    `json:"status"`
-	TotalRecipients      int       `json:"total_recipients"`
-	SuccessfulDeliveries int       `json:"successful_deliveries"`
-	FailedDeliveries     int       `json:"failed_deliveries"`
-	StartTime            time.Time `json:"start_time"`
-	EndTime              time.Time `json:"end_time"`
-	DurationSeconds      int       `json:"duration_seconds"`
-	CSVFile              string    `json:"csv_file,omitempty"`
-	SheetURL             string    `json:"sheet_url,omitempty"`
-	TemplateFile         string    `json:"template_file,omitempty"`
-	ConcurrentWorkers    int       `json:"concurrent_workers"`
-	ErrorMessage         string    `json:"error_message,omitempty"`
+	JobID                string         `json:"job_id"`
+	ParentJobID          string         `json:"parent_job_id,omitempty"` // original campaign for `mailgrid retry` runs
+	Status               string         `json:"status"`
+	TotalRecipients      int            `json:"total_recipients"`
+	SuccessfulDeliveries int            `json:"successful_deliveries"`
+	FailedDeliveries     int            `json:"failed_deliveries"`
+	Suppressed           int            `json:"suppressed,omitempty"` // recipients skipped by the suppression list
+	Groups               map[string]int `json:"groups,omitempty"`     // recipients per --holdout/--sample/--ab-test/--limit group
+	Variants             map[string]int `json:"variants,omitempty"`   // emails sent per A/B variant
+	Winner               string         `json:"winner,omitempty"`     // A/B variant sent by a rollout
+	StartTime            time.Time      `json:"start_time"`
+	EndTime              time.Time      `json:"end_time"`
+	DurationSeconds      int            `json:"duration_seconds"`
+	CSVFile              string         `json:"csv_file,omitempty"`
+	SheetURL             string         `json:"sheet_url,omitempty"`
+	TemplateFile         string         `json:"template_file,omitempty"`
+	ConcurrentWorkers    int            `json:"concurrent_workers"`
+	ErrorMessage         string         `json:"error_message,omitempty"`
 }
 
 // Client handles webhook HTTP requests with goroutine tracking
@@ -69,6 +74,27 @@ func (c *Client) signPayload(payload []byte) string {
 // SendNotification sends a POST request to webhook URL with campaign results.
 // This is non-blocking and spawns a goroutine for the HTTP request.
 func (c *Client) SendNotification(webhookURL string, result CampaignResult) error {
+	return c.sendAsync(webhookURL, result, true)
+}
+
+// EngagementEvent is posted for every open or click recorded by
+// `mailgrid serve-tracking`.
+type EngagementEvent struct {
+	Event     string    `json:"event"` // "open" or "click"
+	JobID     string    `json:"job_id"`
+	Email     string    `json:"email"`
+	URL       string    `json:"url,omitempty"` // link target of a click
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SendEngagement posts ev to webhookURL without blocking. Only failed
+// deliveries are logged, as a busy campaign produces many events.
+func (c *Client) SendEngagement(webhookURL string, ev EngagementEvent) error {
+	return c.sendAsync(webhookURL, ev, false)
+}
+
+// sendAsync marshals v and POSTs it in a tracked goroutine.
+func (c *Client) sendAsync(webhookURL string, v interface{}, logSuccess bool) error {
 	if webhookURL == "" {
 		return nil // No webhook configured
 	}
@@ -81,8 +107,8 @@ func (c *Client) SendNotification(webhookURL string, result CampaignResult) erro
 	}
 	c.mu.RUnlock()
 
-	// Marshal result to JSON
-	payload, err := json.Marshal(result)
+	// Marshal payload to JSON
+	payload, err := json.Marshal(v)
 	if err != nil {
 		return fmt.Errorf("failed to marshal webhook payload: %w", err)
 	}
@@ -121,7 +147,9 @@ func (c *Client) SendNotification(webhookURL string, result CampaignResult) erro
 		defer resp.Body.Close()
 
 		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-			log.Printf(" Webhook delivered successfully to %s (status: %d)", webhookURL, resp.StatusCode)
+			if logSuccess {
+				log.Printf(" Webhook delivered successfully to %s (status: %d)", webhookURL, resp.StatusCode)
+			}
 		} else {
 			log.Printf(" Webhook delivery failed: %s returned status %d", webhookURL, resp.StatusCode)
 		}